@@ -0,0 +1,167 @@
+// Package config defines the typed application configuration and loads it from layered sources.
+// Values start from built-in defaults and are overridden, in order, by a YAML or JSON file,
+// by environment variables and finally by command-line flags.
+package config
+
+import (
+	"time"
+)
+
+// Config is the root of the application configuration.
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Log        LogConfig        `yaml:"log"`
+	Pagination PaginationConfig `yaml:"pagination"`
+	CORS       CORSConfig       `yaml:"cors"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	Admin      AdminConfig      `yaml:"admin"`
+}
+
+// ServerConfig controls the HTTP listener, its connection timeouts, the shutdown deadline and the
+// proxies trusted to report client addresses.
+type ServerConfig struct {
+	Port              string        `yaml:"port" env:"PORT" flag:"port" usage:"HTTP listen port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" flag:"read-timeout" usage:"maximum duration for reading an entire request"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" flag:"read-header-timeout" usage:"maximum duration for reading request headers"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" flag:"write-timeout" usage:"maximum duration before timing out response writes"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" flag:"idle-timeout" usage:"maximum keep-alive idle time"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" flag:"max-header-bytes" usage:"maximum size of request headers in bytes"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"deadline for draining in-flight requests on shutdown"`
+	HealthTimeout     time.Duration `yaml:"health_timeout" env:"HEALTH_CHECK_TIMEOUT" flag:"health-timeout" usage:"timeout applied to each readiness sub-check"`
+	RequestTimeout    time.Duration `yaml:"request_timeout" env:"HTTP_REQUEST_TIMEOUT" flag:"request-timeout" usage:"deadline for handling a request, including its database queries"`
+	TrustedProxies    []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma-separated proxy IPs or CIDRs whose X-Forwarded-For is trusted (none by default)"`
+}
+
+// DatabaseConfig selects the storage backend and holds its connection parameters and pool sizing.
+// The network settings apply to PostgreSQL; Path applies to SQLite.
+type DatabaseConfig struct {
+	Driver          string        `yaml:"driver" env:"DB_DRIVER" flag:"db-driver" usage:"storage backend: postgres or sqlite"`
+	Path            string        `yaml:"path" env:"DB_PATH" flag:"db-path" usage:"SQLite database file"`
+	Host            string        `yaml:"host" env:"DB_HOST" flag:"db-host" usage:"database host"`
+	Port            string        `yaml:"port" env:"DB_PORT" flag:"db-port" usage:"database port"`
+	User            string        `yaml:"user" env:"DB_USER" flag:"db-user" usage:"database user"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD" flag:"db-password" usage:"database password" secret:"true"`
+	Name            string        `yaml:"name" env:"DB_NAME" flag:"db-name" usage:"database name"`
+	SSLMode         string        `yaml:"ssl_mode" env:"DB_SSLMODE" flag:"db-sslmode" usage:"PostgreSQL sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"maximum open connections in the pool"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"maximum idle connections in the pool"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"maximum lifetime of a pooled connection (0 = unlimited)"`
+	QueryTimeout    time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" flag:"db-query-timeout" usage:"deadline for each repository call"`
+	AutoMigrate     bool          `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE" flag:"db-auto-migrate" usage:"apply pending schema migrations on startup"`
+}
+
+// LogConfig controls structured logging output.
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"log level: debug, info, warn or error"`
+}
+
+// PaginationConfig bounds the page sizes accepted by list endpoints.
+type PaginationConfig struct {
+	DefaultPerPage int `yaml:"default_per_page" env:"PAGINATION_DEFAULT_PER_PAGE" flag:"default-per-page" usage:"page size used when per_page is omitted"`
+	MaxPerPage     int `yaml:"max_per_page" env:"PAGINATION_MAX_PER_PAGE" flag:"max-per-page" usage:"largest accepted per_page value"`
+}
+
+// CORSConfig holds the cross-origin policies of the management and delivery route groups.
+type CORSConfig struct {
+	Management CORSPolicy `yaml:"management" env:"CORS_MANAGEMENT_" flag:"cors-management-"`
+	Delivery   CORSPolicy `yaml:"delivery" env:"CORS_DELIVERY_" flag:"cors-delivery-"`
+}
+
+// CORSPolicy describes the cross-origin rules of a single route group.
+// Allowed origins may be exact ("https://admin.example.com"), subdomain wildcards ("https://*.example.com") or "*".
+type CORSPolicy struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"ALLOWED_ORIGINS" flag:"allowed-origins" usage:"comma-separated allowed origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"ALLOWED_METHODS" flag:"allowed-methods" usage:"comma-separated allowed methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"ALLOWED_HEADERS" flag:"allowed-headers" usage:"comma-separated allowed request headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"EXPOSED_HEADERS" flag:"exposed-headers" usage:"comma-separated response headers readable by scripts"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"ALLOW_CREDENTIALS" flag:"allow-credentials" usage:"allow cookies and credentials"`
+	MaxAge           time.Duration `yaml:"max_age" env:"MAX_AGE" flag:"max-age" usage:"how long browsers may cache preflight results"`
+}
+
+// RateLimitConfig holds the token-bucket limits of each route group, the daily quota and the API keys
+// that identify clients to both.
+type RateLimitConfig struct {
+	Delivery   RateLimitGroup `yaml:"delivery" env:"RATE_LIMIT_DELIVERY_" flag:"rate-limit-delivery-"`
+	Management RateLimitGroup `yaml:"management" env:"RATE_LIMIT_MANAGEMENT_" flag:"rate-limit-management-"`
+	DailyQuota int            `yaml:"daily_quota" env:"RATE_LIMIT_DAILY_QUOTA" flag:"rate-limit-daily-quota" usage:"requests per client per UTC day (0 = unlimited)"`
+	APIKeys    []string       `yaml:"api_keys" env:"RATE_LIMIT_API_KEYS" flag:"rate-limit-api-keys" usage:"comma-separated API keys accepted in X-API-Key (other clients are identified by IP)" secret:"true"`
+}
+
+// RateLimitGroup describes the token bucket applied to a single route group.
+type RateLimitGroup struct {
+	RPS   float64 `yaml:"rps" env:"RPS" flag:"rps" usage:"tokens replenished per second (0 disables the limit)"`
+	Burst int     `yaml:"burst" env:"BURST" flag:"burst" usage:"bucket capacity"`
+}
+
+// AdminConfig controls access to the administrative endpoints.
+type AdminConfig struct {
+	Token string `yaml:"token" env:"ADMIN_TOKEN" flag:"admin-token" usage:"token required in X-Admin-Token (empty disables /admin)" secret:"true"`
+}
+
+// exposedHeaders lists the response headers browser scripts may read by default.
+var exposedHeaders = []string{
+	"ETag", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "X-Request-ID",
+	"X-Experiment-Id", "X-Experiment-Variant",
+}
+
+// Default returns the configuration used when no other source provides a value.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:              "8080",
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   20 * time.Second,
+			HealthTimeout:     2 * time.Second,
+			RequestTimeout:    10 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:       "postgres",
+			Path:         "appdrop.db",
+			Host:         "localhost",
+			Port:         "5432",
+			User:         "postgres",
+			Name:         "appdrop",
+			SSLMode:      "disable",
+			MaxOpenConns: 25,
+			MaxIdleConns: 5,
+			QueryTimeout: 5 * time.Second,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Pagination: PaginationConfig{
+			DefaultPerPage: 10,
+			MaxPerPage:     100,
+		},
+		CORS: CORSConfig{
+			Management: CORSPolicy{
+				AllowedOrigins:   []string{"http://localhost:3000", "http://127.0.0.1:3000"},
+				AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization", "X-Request-ID", "X-API-Key"},
+				ExposedHeaders:   exposedHeaders,
+				AllowCredentials: true,
+				MaxAge:           10 * time.Minute,
+			},
+			Delivery: CORSPolicy{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "HEAD"},
+				AllowedHeaders: []string{
+					"Accept", "Accept-Language", "Content-Type", "X-Request-ID", "X-API-Key",
+					"X-Client-Platform", "X-Client-App-Version", "X-Client-Os-Version", "X-Client-Country", "X-Client-Language", "X-Client-New-User", "X-Client-User-Id", "X-Client-Device-Id",
+					"X-Client-Theme", "X-Client-Form-Factor",
+				},
+				ExposedHeaders: exposedHeaders,
+				MaxAge:         10 * time.Minute,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Delivery:   RateLimitGroup{RPS: 20, Burst: 40},
+			Management: RateLimitGroup{RPS: 5, Burst: 10},
+		},
+	}
+}
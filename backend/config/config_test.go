@@ -0,0 +1,151 @@
+// Package config contains unit tests for verifying layered loading, validation and redaction.
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envMap adapts a map to the lookup function accepted by Load.
+func envMap(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+// TestLoadPrecedence verifies that the file overrides defaults, the environment overrides the file,
+// and flags override the environment.
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appdrop.yaml")
+	file := "server:\n  port: \"7000\"\n  write_timeout: 30s\ndatabase:\n  host: file-host\n  name: file-db\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	env := envMap(map[string]string{
+		FileEnvVar:                        path,
+		"DB_HOST":                         "env-host",
+		"PORT":                            "7100",
+		"CORS_MANAGEMENT_ALLOWED_METHODS": "GET, POST",
+	})
+	cfg, err := Load("test", []string{"-port", "7200", "-rate-limit-delivery-rps", "3.5"}, env, io.Discard)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if cfg.Server.Port != "7200" {
+		t.Errorf("Expected flag port 7200, got %s", cfg.Server.Port)
+	}
+	if cfg.Database.Host != "env-host" {
+		t.Errorf("Expected env host, got %s", cfg.Database.Host)
+	}
+	if cfg.Database.Name != "file-db" {
+		t.Errorf("Expected file database name, got %s", cfg.Database.Name)
+	}
+	if cfg.Server.WriteTimeout != 30*time.Second {
+		t.Errorf("Expected file write timeout 30s, got %s", cfg.Server.WriteTimeout)
+	}
+	if cfg.RateLimit.Delivery.RPS != 3.5 {
+		t.Errorf("Expected flag delivery rps 3.5, got %v", cfg.RateLimit.Delivery.RPS)
+	}
+	if strings.Join(cfg.CORS.Management.AllowedMethods, ",") != "GET,POST" {
+		t.Errorf("Expected env CORS methods, got %v", cfg.CORS.Management.AllowedMethods)
+	}
+	if cfg.Database.MaxOpenConns != 25 {
+		t.Errorf("Expected default max open conns 25, got %d", cfg.Database.MaxOpenConns)
+	}
+}
+
+// TestLoadReportsAllProblems verifies that parse and validation problems are reported together.
+func TestLoadReportsAllProblems(t *testing.T) {
+	env := envMap(map[string]string{"DB_MAX_OPEN_CONNS": "many", "LOG_LEVEL": "verbose"})
+	_, err := Load("test", []string{"-port", "0", "-default-per-page", "500"}, env, io.Discard)
+
+	problems, ok := err.(*ProblemsError)
+	if !ok {
+		t.Fatalf("Expected *ProblemsError, got %v", err)
+	}
+	if len(problems.Problems) != 4 {
+		t.Errorf("Expected 4 problems, got %d: %v", len(problems.Problems), problems.Problems)
+	}
+}
+
+// TestRedacted verifies that secrets are masked without modifying the original configuration.
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "hunter2"
+	cfg.Admin.Token = "admin-secret"
+	cfg.RateLimit.APIKeys = []string{"key-one", "key-two"}
+
+	redacted := cfg.Redacted()
+	if redacted.Database.Password != "******" || redacted.Admin.Token != "******" {
+		t.Errorf("Expected secrets to be masked, got %q and %q", redacted.Database.Password, redacted.Admin.Token)
+	}
+	if len(redacted.RateLimit.APIKeys) != 1 || redacted.RateLimit.APIKeys[0] != "******" {
+		t.Errorf("Expected API keys to be masked, got %v", redacted.RateLimit.APIKeys)
+	}
+	if cfg.Database.Password != "hunter2" || cfg.RateLimit.APIKeys[0] != "key-one" {
+		t.Error("Expected original configuration to be unchanged")
+	}
+}
+
+// TestValidateCORSPolicies verifies that malformed origins and credentialed wildcards are rejected.
+func TestValidateCORSPolicies(t *testing.T) {
+	cfg := Default()
+	cfg.CORS.Management.AllowedOrigins = []string{"https://*.example.com", "example.com", "*"}
+
+	problems := cfg.Validate()
+	if len(problems) != 2 {
+		t.Errorf("Expected 2 problems, got %d: %v", len(problems), problems)
+	}
+}
+
+// TestValidateTrustedProxies verifies that trusted proxies have to be IP addresses or CIDR ranges.
+func TestValidateTrustedProxies(t *testing.T) {
+	cfg := Default()
+	if len(cfg.Server.TrustedProxies) != 0 {
+		t.Errorf("Expected no trusted proxies by default, got %v", cfg.Server.TrustedProxies)
+	}
+
+	cfg.Server.TrustedProxies = []string{"10.0.0.1", "10.0.0.0/8", "::1", "proxy.internal"}
+	if problems := cfg.Validate(); len(problems) != 1 {
+		t.Errorf("Expected 1 problem, got %v", problems)
+	}
+}
+
+// TestValidateSQLiteDriver verifies that the PostgreSQL settings are not required for SQLite
+// while its database path is, and that unknown drivers are rejected.
+func TestValidateSQLiteDriver(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.Host = ""
+	cfg.Database.SSLMode = "bogus"
+	if problems := cfg.Validate(); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+
+	cfg.Database.Path = ""
+	if problems := cfg.Validate(); len(problems) != 1 {
+		t.Errorf("Expected 1 problem, got %v", problems)
+	}
+
+	cfg.Database.Driver = "mysql"
+	if problems := cfg.Validate(); len(problems) != 1 {
+		t.Errorf("Expected 1 problem, got %v", problems)
+	}
+}
+
+// TestValidateQueryTimeout verifies that a query deadline longer than the request deadline is rejected.
+func TestValidateQueryTimeout(t *testing.T) {
+	cfg := Default()
+	cfg.Database.QueryTimeout = cfg.Server.RequestTimeout + time.Second
+
+	if problems := cfg.Validate(); len(problems) != 1 {
+		t.Errorf("Expected 1 problem, got %v", problems)
+	}
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnvVar names the environment variable that points at a configuration file
+// when the -config flag is not given.
+const FileEnvVar = "APPDROP_CONFIG"
+
+// durationType is used to recognise time.Duration fields, which share int64 as their kind.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting describes a single configurable leaf field and the sources it can be read from.
+type setting struct {
+	path   string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// settings walks the configuration struct and returns every leaf field with its fully
+// qualified YAML path, environment variable and flag name. Nested struct fields contribute
+// their env and flag tags as prefixes to the names of their children.
+func settings(v reflect.Value, path, envPrefix, flagPrefix string) []setting {
+	var out []setting
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.SplitN(field.Tag.Get("yaml"), ",", 2)[0]
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			out = append(out, settings(v.Field(i), fieldPath,
+				envPrefix+field.Tag.Get("env"), flagPrefix+field.Tag.Get("flag"))...)
+			continue
+		}
+
+		s := setting{
+			path:   fieldPath,
+			usage:  field.Tag.Get("usage"),
+			secret: field.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		}
+		if env := field.Tag.Get("env"); env != "" {
+			s.env = envPrefix + env
+		}
+		if fl := field.Tag.Get("flag"); fl != "" {
+			s.flag = flagPrefix + fl
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// assign parses raw and stores it in the field, supporting the scalar kinds used by Config.
+func assign(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// ProblemsError reports every problem found while loading and validating configuration at once.
+type ProblemsError struct {
+	Problems []string
+}
+
+// Error lists each configuration problem on its own line.
+func (e *ProblemsError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load builds the configuration from defaults, an optional file, the environment and the given
+// command-line arguments, in increasing order of precedence. The file is taken from the -config
+// flag or the APPDROP_CONFIG variable. All parse and validation problems are reported together
+// through a *ProblemsError; flag.ErrHelp is returned unchanged when -h is requested.
+func Load(name string, args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, error) {
+	cfg := Default()
+	fields := settings(reflect.ValueOf(&cfg).Elem(), "", "", "")
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	configPath := fs.String("config", "", "path to a YAML or JSON configuration file (env "+FileEnvVar+")")
+	rawFlags := make(map[string]*string)
+	for _, f := range fields {
+		if f.flag != "" {
+			usage := f.usage
+			if f.env != "" {
+				usage += " (env " + f.env + ")"
+			}
+			rawFlags[f.flag] = fs.String(f.flag, "", usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv(FileEnvVar)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if raw, ok := lookupEnv(f.env); ok && raw != "" {
+			if err := assign(f.value, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("env %s: %v", f.env, err))
+			}
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag == fl.Name {
+				if err := assign(f.value, *rawFlags[fl.Name]); err != nil {
+					problems = append(problems, fmt.Sprintf("flag -%s: %v", fl.Name, err))
+				}
+			}
+		}
+	})
+
+	problems = append(problems, cfg.Validate()...)
+	if len(problems) > 0 {
+		return nil, &ProblemsError{Problems: problems}
+	}
+	return &cfg, nil
+}
+
+// loadFile decodes a YAML or JSON file over the configuration, rejecting unknown keys.
+// JSON is accepted because it is a subset of YAML.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with every secret value masked, suitable for printing.
+func (c Config) Redacted() Config {
+	for _, f := range settings(reflect.ValueOf(&c).Elem(), "", "", "") {
+		if !f.secret {
+			continue
+		}
+		switch {
+		case f.value.Kind() == reflect.Slice && f.value.Len() > 0:
+			f.value.Set(reflect.ValueOf([]string{"******"}))
+		case f.value.Kind() == reflect.String && f.value.String() != "":
+			f.value.SetString("******")
+		}
+	}
+	return c
+}
+
+// WriteYAML prints the redacted configuration as YAML.
+func (c Config) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
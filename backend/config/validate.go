@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validSSLModes lists the sslmode values understood by the PostgreSQL driver.
+var validSSLModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// validLogLevels lists the accepted log level names.
+var validLogLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// Validate checks the configuration for invalid or inconsistent values and returns every problem found.
+func (c Config) Validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !isPort(c.Server.Port) {
+		add("server.port: must be a port number between 1 and 65535, got %q", c.Server.Port)
+	}
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"server.health_timeout", c.Server.HealthTimeout},
+		{"server.request_timeout", c.Server.RequestTimeout},
+		{"database.query_timeout", c.Database.QueryTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			add("%s: must be greater than zero", timeout.name)
+		}
+	}
+	if c.Database.QueryTimeout > c.Server.RequestTimeout {
+		add("database.query_timeout: must not exceed server.request_timeout (%s)", c.Server.RequestTimeout)
+	}
+	if c.Server.MaxHeaderBytes < 1024 {
+		add("server.max_header_bytes: must be at least 1024, got %d", c.Server.MaxHeaderBytes)
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if !isIPOrCIDR(proxy) {
+			add("server.trusted_proxies: %q is not an IP address or CIDR range", proxy)
+		}
+	}
+
+	switch c.Database.Driver {
+	case "postgres":
+		if c.Database.Host == "" {
+			add("database.host: is required")
+		}
+		if !isPort(c.Database.Port) {
+			add("database.port: must be a port number between 1 and 65535, got %q", c.Database.Port)
+		}
+		if c.Database.User == "" {
+			add("database.user: is required")
+		}
+		if c.Database.Name == "" {
+			add("database.name: is required")
+		}
+		if !validSSLModes[c.Database.SSLMode] {
+			add("database.ssl_mode: unsupported value %q", c.Database.SSLMode)
+		}
+	case "sqlite":
+		if c.Database.Path == "" {
+			add("database.path: is required for the sqlite driver")
+		}
+	default:
+		add("database.driver: must be one of postgres, sqlite, got %q", c.Database.Driver)
+	}
+	if c.Database.MaxOpenConns < 1 {
+		add("database.max_open_conns: must be at least 1, got %d", c.Database.MaxOpenConns)
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns: must be between 0 and max_open_conns (%d), got %d", c.Database.MaxOpenConns, c.Database.MaxIdleConns)
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		add("database.conn_max_lifetime: must not be negative")
+	}
+
+	if !validLogLevels[c.Log.Level] {
+		add("log.level: must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+
+	if c.Pagination.DefaultPerPage < 1 {
+		add("pagination.default_per_page: must be at least 1, got %d", c.Pagination.DefaultPerPage)
+	}
+	if c.Pagination.MaxPerPage < c.Pagination.DefaultPerPage {
+		add("pagination.max_per_page: must be at least default_per_page (%d), got %d", c.Pagination.DefaultPerPage, c.Pagination.MaxPerPage)
+	}
+
+	policies := []struct {
+		name   string
+		policy CORSPolicy
+	}{
+		{"cors.management", c.CORS.Management},
+		{"cors.delivery", c.CORS.Delivery},
+	}
+	for _, p := range policies {
+		if len(p.policy.AllowedMethods) == 0 {
+			add("%s.allowed_methods: must list at least one method", p.name)
+		}
+		for _, origin := range p.policy.AllowedOrigins {
+			if origin == "*" {
+				if p.policy.AllowCredentials {
+					add("%s.allowed_origins: \"*\" cannot be combined with allow_credentials", p.name)
+				}
+				continue
+			}
+			if !isOriginPattern(origin) {
+				add("%s.allowed_origins: %q is not an origin such as https://app.example.com or https://*.example.com", p.name, origin)
+			}
+		}
+		if p.policy.MaxAge < 0 {
+			add("%s.max_age: must not be negative", p.name)
+		}
+	}
+
+	groups := []struct {
+		name  string
+		group RateLimitGroup
+	}{
+		{"rate_limit.delivery", c.RateLimit.Delivery},
+		{"rate_limit.management", c.RateLimit.Management},
+	}
+	for _, g := range groups {
+		if g.group.RPS < 0 {
+			add("%s.rps: must not be negative", g.name)
+		}
+		if g.group.Burst < 0 || (g.group.RPS > 0 && g.group.Burst < 1) {
+			add("%s.burst: must be at least 1 when rps is set, got %d", g.name, g.group.Burst)
+		}
+	}
+	if c.RateLimit.DailyQuota < 0 {
+		add("rate_limit.daily_quota: must not be negative")
+	}
+
+	return problems
+}
+
+// isOriginPattern reports whether s is a scheme and host, optionally with a port and a leading "*." subdomain wildcard.
+func isOriginPattern(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil || u.RawQuery != "" || (u.Path != "" && u.Path != "/") {
+		return false
+	}
+	host := strings.TrimPrefix(u.Hostname(), "*.")
+	return host != "" && !strings.Contains(host, "*")
+}
+
+// isPort reports whether s is a decimal TCP port number.
+func isPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// isIPOrCIDR reports whether s is an IP address or a CIDR range.
+func isIPOrCIDR(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
@@ -1,60 +1,71 @@
 // Package database manages the lifecycle of the application's connection to the persistent data store.
-// It handles configuration parsing, connection establishment, and pooling parameters.
+// It handles connection establishment and pooling parameters for PostgreSQL and SQLite.
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"time"
+
+	"appdrop/migrations"
 
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// DriverPostgres selects the PostgreSQL server backend.
+	DriverPostgres = "postgres"
+	// DriverSQLite selects the embedded, single-file SQLite backend.
+	DriverSQLite = "sqlite"
 )
 
 // DB represents the global database connection pool instance.
 var DB *sql.DB
 
-// Config encapsulates the necessary parameters for establishing a connection to a PostgreSQL database.
+// driver records which backend the global connection pool was opened with.
+var driver = DriverPostgres
+
+// Config encapsulates the necessary parameters for establishing a connection to the database.
+// Driver selects the backend; the network settings apply to PostgreSQL and Path to SQLite.
 type Config struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Driver          string
+	Path            string
+	Host            string
+	Port            string
+	User            string
+	Password        string
+	DBName          string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
-// NewConfigFromEnv initializes a Config instance by reading parameters from system environment variables.
-// It applies sensible defaults if specific variables are not defined.
-func NewConfigFromEnv() Config {
-	return Config{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASSWORD", ""),
-		DBName:   getEnv("DB_NAME", "appdrop"),
-		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+// Connect establishes a persistent connection to the database using the provided configuration.
+// It performs a ping to verify connectivity and configures connection pool parameters.
+func Connect(config Config) error {
+	if config.Driver == "" {
+		config.Driver = DriverPostgres
 	}
-}
 
-// getEnv retrieves the value of an environment variable or returns a specified default value.
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	var connStr string
+	switch config.Driver {
+	case DriverPostgres:
+		connStr = fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode,
+		)
+	case DriverSQLite:
+		connStr = SQLiteDSN(config.Path)
+	default:
+		return fmt.Errorf("unsupported database driver %q", config.Driver)
 	}
-	return defaultValue
-}
-
-// Connect establishes a persistent connection to the PostgreSQL database using the provided configuration.
-// It performs a ping to verify connectivity and configures connection pool parameters.
-func Connect(config Config) error {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode,
-	)
 
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open(config.Driver, connStr)
 	if err != nil {
 		return fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -63,13 +74,33 @@ func Connect(config Config) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	DB.SetMaxOpenConns(25)
-	DB.SetMaxIdleConns(5)
+	DB.SetMaxOpenConns(config.MaxOpenConns)
+	DB.SetMaxIdleConns(config.MaxIdleConns)
+	DB.SetConnMaxLifetime(config.ConnMaxLifetime)
 
-	log.Println("Successfully connected to database")
+	driver = config.Driver
+	if driver == DriverSQLite {
+		slog.Info("Successfully opened database", "driver", driver, "path", config.Path)
+	} else {
+		slog.Info("Successfully connected to database", "driver", driver, "host", config.Host, "database", config.DBName)
+	}
 	return nil
 }
 
+// SQLiteDSN builds the connection string for a SQLite database file. Foreign keys are enabled so
+// that widgets cascade with their page, WAL journaling lets readers proceed during writes, and a
+// busy timeout makes concurrent writers wait for the lock instead of failing immediately.
+// Transactions begin IMMEDIATE, taking the write lock up front, so two transactions that read
+// before writing cannot deadlock on upgrading their locks.
+func SQLiteDSN(path string) string {
+	return "file:" + path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate"
+}
+
+// Driver returns the name of the backend the global connection pool was opened with.
+func Driver() string {
+	return driver
+}
+
 // Close terminates the active database connection pool if it exists.
 func Close() error {
 	if DB != nil {
@@ -82,3 +113,24 @@ func Close() error {
 func GetDB() *sql.DB {
 	return DB
 }
+
+// SchemaVersion returns the latest migration version embedded in this binary for the active
+// driver, which is the version the database schema is expected to be at.
+func SchemaVersion() int64 {
+	fsys, err := migrations.For(driver)
+	if err != nil {
+		return 0
+	}
+	all, err := LoadMigrations(fsys)
+	if err != nil || len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
+
+// CurrentSchemaVersion reports the highest migration version recorded in the schema_migrations table.
+func CurrentSchemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var version int64
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
@@ -0,0 +1,423 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"appdrop/migrations"
+)
+
+// migrationLockKey identifies the Postgres advisory lock held while migrations run, so that
+// replicas starting in parallel apply them one at a time. It spells "appdrop" in ASCII.
+const migrationLockKey int64 = 0x61707064726f70
+
+// downMarker separates the up section of a migration file from its down section.
+const downMarker = "-- +migrate Down"
+
+// migrationFilePattern matches migration file names such as "003_add_theme.sql".
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.sql$`)
+
+// Migration is a single versioned schema change read from a migration file.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus reports whether a migration has been applied and whether its file still matches
+// the checksum recorded when it was applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool
+	Missing   bool
+}
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// LoadMigrations reads and orders every migration file in fsys, rejecting malformed names and duplicate versions.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: file name must look like 001_description.sql", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %s", entry.Name(), version, other)
+		}
+		seen[version] = entry.Name()
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		up, down, _ := strings.Cut(string(data), downMarker)
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     match[2],
+			Up:       up,
+			Down:     down,
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations, recording each applied version in schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// NewMigrator initializes and returns a new Migrator instance for the migrations embedded for driver.
+func NewMigrator(db *sql.DB, driver string, logger *slog.Logger) (*Migrator, error) {
+	fsys, err := migrations.For(driver)
+	if err != nil {
+		return nil, err
+	}
+	all, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, driver: driver, migrations: all, logger: logger}, nil
+}
+
+// Latest returns the highest migration version known to the migrator, or zero when there are none.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration in version order, each in its own transaction.
+// It refuses to run when an applied migration file has been modified since it was applied.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			record, ok := applied[migration.Version]
+			if !ok {
+				continue
+			}
+			if record.checksum == "" {
+				if _, err := conn.ExecContext(ctx,
+					`UPDATE schema_migrations SET name = $2, checksum = $3 WHERE version = $1`,
+					migration.Version, migration.Name, migration.Checksum); err != nil {
+					return fmt.Errorf("failed to record checksum of migration %d: %w", migration.Version, err)
+				}
+				continue
+			}
+			if record.checksum != migration.Checksum {
+				return fmt.Errorf("migration %d_%s has been modified since it was applied", migration.Version, migration.Name)
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the given number of most recently applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration along with applied versions whose files no longer exist.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := loadApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = record.checksum != "" && record.checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		appliedAt := record.appliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version:   record.version,
+			Name:      record.name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// withLock runs fn on a dedicated connection while holding the migration advisory lock.
+// SQLite databases are single-node files, so no lock beyond SQLite's own write lock is taken.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if m.driver == DriverPostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+				m.logger.Warn("Failed to release migration lock", "error", err)
+			}
+		}()
+	}
+
+	if err := m.ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply runs the up section of a migration and records it in the same transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	start := time.Now()
+	err := inTx(ctx, conn, func(tx *sql.Tx) error {
+		if err := execScript(ctx, tx, migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+			 ON CONFLICT (version) DO UPDATE SET name = EXCLUDED.name, checksum = EXCLUDED.checksum`,
+			migration.Version, migration.Name, migration.Checksum)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("Applied migration", "version", migration.Version, "name", migration.Name,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// revert runs the down section of a migration and removes its record in the same transaction.
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	err := inTx(ctx, conn, func(tx *sql.Tx) error {
+		if err := execScript(ctx, tx, migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("Reverted migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+// ensureMigrationsTable creates schema_migrations. On PostgreSQL it also upgrades the version-only
+// table created by migration 002 with the name and checksum columns used by the runner.
+func (m *Migrator) ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum TEXT NOT NULL DEFAULT ''`,
+	}
+	if m.driver == DriverSQLite {
+		statements = []string{
+			`CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name TEXT NOT NULL DEFAULT '',
+				checksum TEXT NOT NULL DEFAULT '',
+				applied_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+			)`,
+		}
+	}
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to prepare schema_migrations: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadApplied reads the applied migrations keyed by version.
+func loadApplied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var record appliedMigration
+		if err := rows.Scan(&record.version, &record.name, &record.checksum, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[record.version] = record
+	}
+	return applied, rows.Err()
+}
+
+// inTx runs fn inside a transaction on conn, committing on success and rolling back otherwise.
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execScript executes a migration section, skipping sections that contain only comments.
+func execScript(ctx context.Context, tx *sql.Tx, script string) error {
+	if !hasStatements(script) {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, script)
+	return err
+}
+
+// hasStatements reports whether script contains anything besides blank lines and SQL comments.
+func hasStatements(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateMigration writes an empty migration file for every supported driver into the driver
+// directories below dir, numbered after the highest version found in any of them, and returns the paths.
+func CreateMigration(dir, name string) ([]string, error) {
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return nil, fmt.Errorf("migration name %q must contain letters or digits", name)
+	}
+
+	version := int64(1)
+	for _, driverDir := range migrations.Dirs {
+		existing, err := LoadMigrations(os.DirFS(filepath.Join(dir, driverDir)))
+		if err != nil {
+			return nil, err
+		}
+		if n := len(existing); n > 0 && existing[n-1].Version >= version {
+			version = existing[n-1].Version + 1
+		}
+	}
+
+	title := strings.ReplaceAll(slug, "_", " ")
+	var paths []string
+	for _, driverDir := range migrations.Dirs {
+		content := fmt.Sprintf("-- Mini App Config API %s (%s)\n-- Version: %d\n\n-- +migrate Up\n\n%s\n", title, driverDir, version, downMarker)
+		path := filepath.Join(dir, driverDir, fmt.Sprintf("%03d_%s.sql", version, slug))
+		if err := writeNewFile(path, content); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeNewFile creates path with content, failing if the file already exists.
+func writeNewFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,148 @@
+// Package database contains unit tests for verifying migration loading, execution and file generation.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"appdrop/migrations"
+)
+
+// TestLoadMigrations verifies ordering, up/down splitting and checksum calculation.
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_index.sql":   {Data: []byte("-- +migrate Up\nCREATE INDEX i ON t(c);\n-- +migrate Down\nDROP INDEX i;\n")},
+		"001_create_t.sql":    {Data: []byte("CREATE TABLE t (c INT);\n")},
+		"README.md":           {Data: []byte("ignored")},
+		"sub/003_ignored.sql": {Data: []byte("SELECT 1;")},
+	}
+
+	all, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations returned error: %v", err)
+	}
+	if len(all) != 2 || all[0].Version != 1 || all[1].Version != 2 {
+		t.Fatalf("Expected versions 1 and 2 in order, got %+v", all)
+	}
+	if all[0].Name != "create_t" || hasStatements(all[0].Down) {
+		t.Errorf("Expected up-only migration create_t, got %q with down %q", all[0].Name, all[0].Down)
+	}
+	if !strings.Contains(all[1].Down, "DROP INDEX") || strings.Contains(all[1].Up, "DROP INDEX") {
+		t.Errorf("Expected down section to be split from up section, got up %q down %q", all[1].Up, all[1].Down)
+	}
+	if len(all[0].Checksum) != 64 || all[0].Checksum == all[1].Checksum {
+		t.Errorf("Expected distinct sha256 checksums, got %q and %q", all[0].Checksum, all[1].Checksum)
+	}
+}
+
+// TestLoadMigrationsRejectsDuplicates verifies that two files sharing a version are reported.
+func TestLoadMigrationsRejectsDuplicates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_a.sql": {Data: []byte("SELECT 1;")},
+		"01_b.sql":  {Data: []byte("SELECT 2;")},
+	}
+	if _, err := LoadMigrations(fsys); err == nil {
+		t.Error("Expected duplicate version error")
+	}
+}
+
+// TestEmbeddedMigrations verifies that the shipped migrations of every driver load with non-empty up sections.
+func TestEmbeddedMigrations(t *testing.T) {
+	for _, driver := range migrations.Dirs {
+		fsys, err := migrations.For(driver)
+		if err != nil {
+			t.Fatalf("No migrations for %s: %v", driver, err)
+		}
+		all, err := LoadMigrations(fsys)
+		if err != nil {
+			t.Fatalf("Embedded %s migrations failed to load: %v", driver, err)
+		}
+		if len(all) == 0 {
+			t.Errorf("Expected %s migrations", driver)
+		}
+		for _, m := range all {
+			if !hasStatements(m.Up) {
+				t.Errorf("Migration %s/%d_%s has an empty up section", driver, m.Version, m.Name)
+			}
+		}
+	}
+}
+
+// TestMigratorSQLite verifies applying, inspecting and rolling back the SQLite migrations end to end.
+func TestMigratorSQLite(t *testing.T) {
+	db, err := sql.Open(DriverSQLite, SQLiteDSN(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator, err := NewMigrator(db, DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewMigrator returned error: %v", err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up returned error: %v", err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Second Up returned error: %v", err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status returned error: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.Modified || s.Missing || s.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied cleanly, got %+v", s.Version, s)
+		}
+	}
+
+	if err := migrator.Down(ctx, len(statuses)); err != nil {
+		t.Fatalf("Down returned error: %v", err)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('pages', 'widgets')`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("Expected Down to drop the tables, %d remain", tables)
+	}
+}
+
+// TestCreateMigration verifies that new files take the next version across drivers and a normalized name.
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	for _, driver := range migrations.Dirs {
+		if err := os.Mkdir(filepath.Join(dir, driver), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "postgres", "002_init.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := CreateMigration(dir, "Add Theme Tokens")
+	if err != nil {
+		t.Fatalf("CreateMigration returned error: %v", err)
+	}
+	if len(paths) != len(migrations.Dirs) {
+		t.Fatalf("Expected one file per driver, got %v", paths)
+	}
+	for _, path := range paths {
+		if filepath.Base(path) != "003_add_theme_tokens.sql" {
+			t.Errorf("Expected 003_add_theme_tokens.sql, got %s", path)
+		}
+		data, _ := os.ReadFile(path)
+		if !strings.Contains(string(data), downMarker) {
+			t.Errorf("Expected template with a down section, got %q", data)
+		}
+	}
+}
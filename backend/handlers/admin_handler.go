@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"appdrop/middleware"
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler orchestrates HTTP request processing for operational and administrative resources.
+type AdminHandler struct {
+	quotas    *middleware.QuotaTracker
+	auditRepo repository.AuditStore
+}
+
+// NewAdminHandler initializes and returns a new instance of AdminHandler with its required dependencies.
+func NewAdminHandler(quotas *middleware.QuotaTracker, auditRepo repository.AuditStore) *AdminHandler {
+	return &AdminHandler{quotas: quotas, auditRepo: auditRepo}
+}
+
+// ListQuotas processes requests to inspect today's request consumption for every known client key.
+func (h *AdminHandler) ListQuotas(c *gin.Context) {
+	usages := h.quotas.List()
+
+	c.JSON(http.StatusOK, gin.H{
+		"quotas": usages,
+		"total":  len(usages),
+	})
+}
+
+// GetQuota processes requests to inspect today's request consumption for a single client key.
+// The key is given in the same form used for accounting, such as "key:<api key fingerprint>" or "ip:<address>".
+func (h *AdminHandler) GetQuota(c *gin.Context) {
+	usage, ok := h.quotas.Get(c.Param("key"))
+	if !ok {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("No requests recorded for this key today"))
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// auditListLimit bounds the number of audit entries returned by ListAudit.
+const auditListLimit = 100
+
+// ListAudit processes requests to review the most recent audit entries, newest first.
+func (h *AdminHandler) ListAudit(c *gin.Context) {
+	limit := auditListLimit
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed < auditListLimit {
+			limit = parsed
+		}
+	}
+
+	entries, err := h.auditRepo.List(c.Request.Context(), limit)
+	if err != nil {
+		serverError(c, err, "Failed to fetch audit entries")
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   len(entries),
+	})
+}
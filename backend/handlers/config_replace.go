@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"appdrop/models"
+	"appdrop/repository"
+)
+
+// maxReplaceWidgets caps the number of widgets one find-and-replace may change, so that previews
+// stay readable and an applied change stays one reviewable transaction.
+const maxReplaceWidgets = 1000
+
+// configReplacer rewrites the string values of widget configs for a find-and-replace request.
+// A nil scope reaches every string value; otherwise only the value at the scope path is rewritten.
+type configReplacer struct {
+	scope       *repository.ConfigPredicate
+	literal     string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// newConfigReplacer validates a find-and-replace request and returns its replacer, or a requestError.
+func newConfigReplacer(req models.WidgetReplaceRequest) (*configReplacer, error) {
+	r := &configReplacer{literal: req.Match, replacement: req.Replacement}
+
+	if req.Scope != models.ReplaceScopeAllStrings {
+		scope, err := repository.ParseConfigPredicate(req.Scope)
+		if err != nil || scope.Op != repository.ConfigOpExists {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("scope", "config_path", `Scope must be a dot-separated config path or "*"`))
+		}
+		r.scope = &scope
+	}
+
+	if req.Regex {
+		re, err := regexp.Compile(req.Match)
+		if err != nil {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("match", "regex", "Invalid regular expression: "+err.Error()))
+		}
+		if re.MatchString("") {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("match", "regex", "Regular expression must not match the empty string"))
+		}
+		r.re = re
+	}
+	return r, nil
+}
+
+// replace applies the match and replacement to one string.
+func (r *configReplacer) replace(s string) string {
+	if r.re != nil {
+		return r.re.ReplaceAllString(s, r.replacement)
+	}
+	return strings.ReplaceAll(s, r.literal, r.replacement)
+}
+
+// rewrite applies the replacer to a widget config, returning the new config and the changes made.
+// Numbers keep their original text, and configs without changes are returned unmodified.
+func (r *configReplacer) rewrite(config json.RawMessage) (json.RawMessage, []models.ConfigChange, error) {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, err
+	}
+
+	var changes []models.ConfigChange
+	if r.scope == nil {
+		doc = r.walk(doc, "", &changes)
+	} else if object, ok := doc.(map[string]interface{}); ok {
+		r.rewritePath(object, r.scope.Path, &changes)
+	}
+	if len(changes) == 0 {
+		return config, nil, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, nil, err
+	}
+	return json.RawMessage(bytes.TrimSpace(buf.Bytes())), changes, nil
+}
+
+// rewritePath rewrites the string found at path inside object, if any.
+func (r *configReplacer) rewritePath(object map[string]interface{}, path []string, changes *[]models.ConfigChange) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := object[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		object = next
+	}
+	key := path[len(path)-1]
+	if before, ok := object[key].(string); ok {
+		object[key] = r.change(strings.Join(path, "."), before, changes)
+	}
+}
+
+// walk rewrites every string inside value, visiting object keys in sorted order so that the
+// changes are listed deterministically.
+func (r *configReplacer) walk(value interface{}, path string, changes *[]models.ConfigChange) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.change(path, v, changes)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v[key] = r.walk(v[key], joinPath(path, key), changes)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = r.walk(v[i], joinPath(path, strconv.Itoa(i)), changes)
+		}
+	}
+	return value
+}
+
+// change replaces within before and records the change when the value differs.
+func (r *configReplacer) change(path, before string, changes *[]models.ConfigChange) string {
+	after := r.replace(before)
+	if after != before {
+		*changes = append(*changes, models.ConfigChange{Path: path, Before: before, After: after})
+	}
+	return after
+}
+
+// joinPath appends a key to a dot-separated config path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
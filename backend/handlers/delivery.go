@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"appdrop/i18n"
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/targeting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// clientHeaders maps each targeting attribute to the request header that carries it.
+var clientHeaders = map[string]string{
+	"platform":    "X-Client-Platform",
+	"app_version": "X-Client-App-Version",
+	"os_version":  "X-Client-Os-Version",
+	"country":     "X-Client-Country",
+	"language":    "X-Client-Language",
+	"new_user":    "X-Client-New-User",
+	"user_id":     "X-Client-User-Id",
+	"theme":       "X-Client-Theme",
+	"form_factor": "X-Client-Form-Factor",
+}
+
+// deviceHeader carries the device ID by which experiments assign a variant to clients that send no
+// user ID. Like the attributes, it can also be given as the device_id query parameter.
+const deviceHeader = "X-Client-Device-Id"
+
+// audience describes when and for which client a delivery request evaluates schedules, audience
+// rules and segments. Filtered is false when the request passes all=true, as the dashboard does, to
+// receive content regardless of them, and untranslated. Segments holds the client's membership of
+// the segments referenced by the content being delivered, once resolved. Unit identifies the user
+// or device to which experiments assign variants, and is empty when the client sent neither.
+// Locales lists the locales the client prefers, most preferred first.
+type audience struct {
+	at       time.Time
+	client   targeting.Context
+	filtered bool
+	segments map[uuid.UUID]bool
+	unit     string
+	locales  []string
+}
+
+// unfilteredKey is the Gin context key set by Unfiltered on the routes that honor all=true.
+const unfilteredKey = "appdrop.unfiltered"
+
+// Unfiltered initializes a middleware handler that marks the routes of a group as management routes,
+// on which all=true returns content regardless of schedules, rules, segments and experiments.
+// Delivery routes refuse all=true. Marking a group does not authenticate it: like every management
+// route, the marked routes have to be restricted where the service is deployed.
+func Unfiltered() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(unfilteredKey, true)
+		c.Next()
+	}
+}
+
+// deliveryAudience reads the audience of a delivery request: the current time, the client context
+// sent in headers or query parameters, and the locales it prefers. all=true is answered with a 403
+// on routes not marked by Unfiltered.
+func deliveryAudience(c *gin.Context) (audience, error) {
+	if v := c.Query("all"); v != "" {
+		all, err := strconv.ParseBool(v)
+		if err != nil {
+			return audience{}, fail(http.StatusBadRequest, models.NewFieldValidationError("all", "boolean", "all must be true or false"))
+		}
+		if all {
+			if !c.GetBool(unfilteredKey) {
+				return audience{}, fail(http.StatusForbidden, models.NewForbiddenError("all=true is only available on the management routes under /manage"))
+			}
+			return audience{}, nil
+		}
+	}
+	locales, err := preferredLocales(c)
+	if err != nil {
+		return audience{}, err
+	}
+	client := clientContext(c)
+	return audience{at: time.Now(), client: client, filtered: true, unit: experimentUnit(c, client), locales: locales}, nil
+}
+
+// preferredLocales reads the locales the client prefers: the one named by the locale query
+// parameter, if any, followed by those of its Accept-Language header in order of quality.
+func preferredLocales(c *gin.Context) ([]string, error) {
+	var locales []string
+	if v := strings.TrimSpace(c.Query("locale")); v != "" {
+		tag, ok := i18n.Normalize(v)
+		if !ok {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("locale", "bcp47", "locale must be a language tag such as es or es-MX"))
+		}
+		locales = append(locales, tag)
+	}
+	return append(locales, i18n.ParseAcceptLanguage(c.GetHeader("Accept-Language"))...), nil
+}
+
+// clientContext collects the targeting attributes of the client from the X-Client-* headers,
+// overridden by query parameters named after the attributes.
+func clientContext(c *gin.Context) targeting.Context {
+	ctx := targeting.Context{}
+	for attr, header := range clientHeaders {
+		if v := strings.TrimSpace(c.GetHeader(header)); v != "" {
+			ctx[attr] = v
+		}
+		if v := strings.TrimSpace(c.Query(attr)); v != "" {
+			ctx[attr] = v
+		}
+	}
+	return ctx
+}
+
+// experimentUnit returns the ID by which experiments assign the client a variant: its user ID when
+// it sent one, so that a user keeps the variant across devices, and its device ID otherwise.
+func experimentUnit(c *gin.Context, client targeting.Context) string {
+	if userID := client["user_id"]; userID != "" {
+		return userID
+	}
+	if v := strings.TrimSpace(c.Query("device_id")); v != "" {
+		return v
+	}
+	return strings.TrimSpace(c.GetHeader(deviceHeader))
+}
+
+// resolveSegments looks up whether the client belongs to each of the given segments, in one query.
+// Nil IDs, for content without a segment, are skipped; nothing is looked up when the audience is
+// not filtered.
+func (a *audience) resolveSegments(ctx context.Context, segments repository.SegmentStore, ids ...*uuid.UUID) error {
+	if !a.filtered {
+		return nil
+	}
+	var wanted []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, id := range ids {
+		if id != nil && !seen[*id] {
+			seen[*id] = true
+			wanted = append(wanted, *id)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	memberships, err := segments.Memberships(ctx, wanted, a.client["user_id"])
+	if err != nil {
+		return err
+	}
+	a.segments = make(map[uuid.UUID]bool, len(memberships))
+	for _, m := range memberships {
+		a.segments[m.SegmentID] = inSegment(m, a.client)
+	}
+	return nil
+}
+
+// widgetSegments returns the segment references of widgets, for resolveSegments.
+func widgetSegments(widgets []models.Widget) []*uuid.UUID {
+	ids := make([]*uuid.UUID, len(widgets))
+	for i := range widgets {
+		ids[i] = widgets[i].SegmentID
+	}
+	return ids
+}
+
+// inSegment reports whether a client belongs to a segment: it has to satisfy the segment's rule,
+// if any, and be on its user list, if any. A segment with neither has no members.
+func inSegment(m models.SegmentMembership, client targeting.Context) bool {
+	if m.Rule == "" && !m.HasUsers {
+		return false
+	}
+	if m.Rule != "" && !targeting.Matches(m.Rule, client) {
+		return false
+	}
+	return !m.HasUsers || m.Listed
+}
+
+// hiddenReason explains why content with the given schedule, rule and segment is not delivered to
+// the audience, or returns "" when it is. Segments have to be resolved first.
+func (a audience) hiddenReason(schedule *models.Schedule, rule string, segmentID *uuid.UUID) string {
+	switch {
+	case !schedule.LiveAt(a.at):
+		return models.HiddenBySchedule
+	case !targeting.Matches(rule, a.client):
+		return models.HiddenByRule
+	case segmentID != nil && !a.segments[*segmentID]:
+		return models.HiddenBySegment
+	}
+	return ""
+}
+
+// sees reports whether content with the given schedule, rule and segment is delivered to the audience.
+func (a audience) sees(schedule *models.Schedule, rule string, segmentID *uuid.UUID) bool {
+	return !a.filtered || a.hiddenReason(schedule, rule, segmentID) == ""
+}
+
+// visibleWidgets returns the widgets delivered to the audience, keeping their order.
+func (a audience) visibleWidgets(widgets []models.Widget) []models.Widget {
+	if !a.filtered {
+		return widgets
+	}
+	visible := make([]models.Widget, 0, len(widgets))
+	for _, w := range widgets {
+		if a.sees(w.Schedule, w.Rule, w.SegmentID) {
+			visible = append(visible, w)
+		}
+	}
+	return visible
+}
+
+// serveExperiment returns the widgets of the variant that the page's running experiment assigns to
+// the audience, in place of the page's base widgets, and reports the assignment. The base widgets
+// are returned unchanged when the audience is not filtered, when the client sent neither a user nor
+// a device ID, or when no experiment runs on the page. Variants are applied before schedules, rules
+// and segments are evaluated, so those of the base widgets still hold under overrides.
+func (a audience) serveExperiment(ctx context.Context, experiments repository.ExperimentStore, pageID uuid.UUID, widgets []models.Widget) ([]models.Widget, *models.ExperimentAssignment, error) {
+	if !a.filtered || a.unit == "" {
+		return widgets, nil, nil
+	}
+	experiment, err := experiments.Running(ctx, pageID)
+	if err != nil || experiment == nil {
+		return widgets, nil, err
+	}
+	variant := experiment.Assign(a.unit)
+	if variant == nil {
+		return widgets, nil, nil
+	}
+	served, err := variant.Apply(pageID, widgets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return served, &models.ExperimentAssignment{ID: experiment.ID, Name: experiment.Name, Variant: variant.Key}, nil
+}
+
+// servedLocale picks the locale in which a page and its widgets are delivered to the audience: the
+// first locale of the client's fallback chain, each preferred locale followed by its parents, that
+// the page names or the widgets have configs for, and the page's default locale otherwise.
+func (a audience) servedLocale(page *models.Page, widgets []models.Widget) string {
+	fallback := page.DefaultLocale
+	if fallback == "" {
+		fallback = models.DefaultLocale
+	}
+	for _, locale := range i18n.Chain(a.locales, fallback) {
+		if locale == fallback {
+			return locale
+		}
+		if _, ok := page.Names[locale]; ok {
+			return locale
+		}
+		for _, w := range widgets {
+			if _, ok := w.Locales[locale]; ok {
+				return locale
+			}
+		}
+	}
+	return fallback
+}
+
+// localize serves a page and its widgets in the locale picked for the audience, reported in the
+// page's locale and dir fields: the page takes its most specific name for that locale, and each
+// widget config is merged with its overrides for the locale and its parents, less specific first,
+// so that es-MX overrides build on es. The raw names and overrides are left out. Nothing changes
+// when the audience is not filtered, so that the dashboard edits the stored content.
+func (a audience) localize(page *models.Page, widgets []models.Widget) error {
+	if !a.filtered {
+		return nil
+	}
+	locale := a.servedLocale(page, widgets)
+	lineage := i18n.Lineage(locale)
+	for i, j := 0, len(lineage)-1; i < j; i, j = i+1, j-1 {
+		lineage[i], lineage[j] = lineage[j], lineage[i]
+	}
+
+	page.Name = page.Localize(lineage)
+	page.Names = nil
+	page.Locale = locale
+	page.Direction = i18n.Direction(locale)
+	for i := range widgets {
+		config, err := widgets[i].Localize(lineage)
+		if err != nil {
+			return err
+		}
+		widgets[i].Config = config
+		widgets[i].Locales = nil
+	}
+	return nil
+}
+
+// resolveConfigs flattens the widget configs delivered to the audience, merging in the conditional
+// variants whose theme, platform and form factor conditions the client satisfies. Nothing changes
+// when the audience is not filtered, so that the dashboard edits every variant.
+func (a audience) resolveConfigs(widgets []models.Widget) error {
+	if !a.filtered {
+		return nil
+	}
+	for i := range widgets {
+		config, err := models.ResolveConfig(widgets[i].Config, a.client)
+		if err != nil {
+			return err
+		}
+		widgets[i].Config = config
+	}
+	return nil
+}
+
+// resolveTokens replaces the design token references of the widget configs delivered to the
+// audience with the values of the app theme, taking colors from the dark palette for clients that
+// ask for the dark theme. The theme is only loaded when a config references tokens, and nothing
+// changes when the audience is not filtered.
+func (a audience) resolveTokens(ctx context.Context, themes repository.ThemeStore, widgets []models.Widget) error {
+	if !a.filtered {
+		return nil
+	}
+	configs := make([]json.RawMessage, len(widgets))
+	for i := range widgets {
+		configs[i] = widgets[i].Config
+	}
+	if len(models.TokenReferences(configs...)) == 0 {
+		return nil
+	}
+
+	theme, err := themes.Get(ctx)
+	if err != nil || theme == nil {
+		return err
+	}
+	dark := strings.EqualFold(strings.TrimSpace(a.client["theme"]), "dark")
+	for i := range widgets {
+		config, err := theme.ResolveTokens(widgets[i].Config, dark)
+		if err != nil {
+			return err
+		}
+		widgets[i].Config = config
+	}
+	return nil
+}
+
+// reportLocale names the locale served by a delivery response in its Content-Language header, and
+// tells caches that the response depends on the client's Accept-Language.
+func reportLocale(c *gin.Context, page *models.Page) {
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	if page.Locale != "" {
+		c.Header("Content-Language", page.Locale)
+	}
+}
+
+// reportExperiment names the experiment variant served by a delivery response in its headers, for
+// clients that attribute conversions without reading the body.
+func reportExperiment(c *gin.Context, assignment *models.ExperimentAssignment) {
+	if assignment == nil {
+		return
+	}
+	c.Header("X-Experiment-Id", assignment.ID.String())
+	c.Header("X-Experiment-Variant", assignment.Variant)
+}
+
+// storedSchedule returns the schedule to store for a request, where an empty schedule means none.
+func storedSchedule(schedule *models.Schedule) *models.Schedule {
+	if schedule.IsZero() {
+		return nil
+	}
+	return schedule
+}
+
+// storedRule trims and type-checks an audience rule before it is stored, rejecting rules that do
+// not compile.
+func storedRule(rule string) (string, error) {
+	rule = strings.TrimSpace(rule)
+	if rule == "" {
+		return "", nil
+	}
+	if _, err := targeting.Compile(rule); err != nil {
+		return "", fail(http.StatusBadRequest, models.NewFieldValidationError("rule", "rule", "Invalid audience rule "+err.Error()))
+	}
+	return rule, nil
+}
+
+// storedConfig checks the conditional variants of a widget config, or of a locale override, before
+// it is stored, naming field in the error.
+func storedConfig(field string, config json.RawMessage) error {
+	if err := models.ValidateConfigVariants(config); err != nil {
+		return fail(http.StatusBadRequest, models.NewFieldValidationError(field, "variants", "Invalid config variants: "+err.Error()))
+	}
+	return nil
+}
+
+// storedLocale normalizes the locale tag of a request before it is stored, naming field in the error.
+func storedLocale(field, tag string) (string, error) {
+	locale, ok := i18n.Normalize(tag)
+	if !ok {
+		return "", fail(http.StatusBadRequest, models.NewFieldValidationError(field, "bcp47", "Invalid locale "+strconv.Quote(tag)+", expected a language tag such as es or es-MX"))
+	}
+	return locale, nil
+}
+
+// storedNames normalizes the locales of localized page names and rejects empty names.
+func storedNames(names models.PageNames) (models.PageNames, error) {
+	if len(names) > models.MaxLocales {
+		return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("names", "max", "A page can be named in at most "+strconv.Itoa(models.MaxLocales)+" locales"))
+	}
+	stored := make(models.PageNames, len(names))
+	for tag, name := range names {
+		locale, err := storedLocale("names", tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := stored[locale]; dup {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("names", "unique", "Locale "+locale+" is named more than once"))
+		}
+		if name = strings.TrimSpace(name); name == "" {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("names", "required", "The "+locale+" page name cannot be empty"))
+		}
+		stored[locale] = name
+	}
+	return stored, nil
+}
+
+// storedLocales normalizes the locales of localized widget configs and checks that each override
+// is a JSON object, to be merged over the base config.
+func storedLocales(locales models.WidgetLocales) (models.WidgetLocales, error) {
+	if len(locales) > models.MaxLocales {
+		return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("locales", "max", "A widget can be localized in at most "+strconv.Itoa(models.MaxLocales)+" locales"))
+	}
+	stored := make(models.WidgetLocales, len(locales))
+	for tag, config := range locales {
+		locale, err := storedLocale("locales", tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := stored[locale]; dup {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("locales", "unique", "Locale "+locale+" is localized more than once"))
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal(config, &object); err != nil || object == nil {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("locales", "json", "The "+locale+" config must be a JSON object"))
+		}
+		if err := storedConfig("locales", config); err != nil {
+			return nil, err
+		}
+		stored[locale] = config
+	}
+	return stored, nil
+}
+
+// storedSegment parses the segment reference of a request and checks, inside the unit of work
+// saving it, that the segment exists. An empty ID means none.
+func storedSegment(ctx context.Context, tx repository.Stores, raw string) (*uuid.UUID, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("segment_id", "uuid", "Invalid segment ID format"))
+	}
+	segment, err := tx.Segments.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if segment == nil {
+		return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("segment_id", "exists", "Segment not found"))
+	}
+	return &id, nil
+}
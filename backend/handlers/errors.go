@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// errPageNotFound reports a page that does not exist.
+	errPageNotFound = fail(http.StatusNotFound, models.NewNotFoundError("Page not found"))
+	// errWidgetNotFound reports a widget that does not exist.
+	errWidgetNotFound = fail(http.StatusNotFound, models.NewNotFoundError("Widget not found"))
+	// errRouteConflict reports a page route already used by another page.
+	errRouteConflict = fail(http.StatusConflict, models.NewConflictError("Page route already exists"))
+)
+
+// requestError is an expected failure decided inside a unit of work, such as a missing page.
+// Returning it from the unit of work rolls the transaction back; writeError then reports it.
+type requestError struct {
+	status int
+	resp   models.ErrorResponse
+}
+
+// Error returns the client-facing message of the failure.
+func (e *requestError) Error() string {
+	return e.resp.Error.Message
+}
+
+// fail initializes and returns a requestError answering with the given status and error response.
+func fail(status int, resp models.ErrorResponse) error {
+	return &requestError{status: status, resp: resp}
+}
+
+// writeError writes err as the response it carries when it is a requestError, and as a
+// server-side failure described by message otherwise.
+func writeError(c *gin.Context, err error, message string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		response.Error(c, reqErr.status, reqErr.resp)
+		return
+	}
+	serverError(c, err, message)
+}
+
+// serverError records err for the request log and writes the matching server-side failure.
+// Data layer calls that ran out of time produce a 504 so that clients can tell an overloaded
+// database from a fault and retry; every other error produces a 500 carrying message.
+func serverError(c *gin.Context, err error, message string) {
+	_ = c.Error(err)
+	if repository.IsTimeout(err) {
+		response.Error(c, http.StatusGatewayTimeout, models.NewTimeoutError("The request did not complete within its deadline"))
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, models.NewInternalServerError(message))
+}
@@ -0,0 +1,546 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"appdrop/middleware"
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/requestid"
+	"appdrop/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Limits on the variants of an experiment.
+const (
+	minVariants      = 2
+	maxVariants      = 10
+	maxVariantKey    = 100
+	maxVariantWidget = 100
+)
+
+var (
+	// errExperimentNotFound reports an experiment that does not exist.
+	errExperimentNotFound = fail(http.StatusNotFound, models.NewNotFoundError("Experiment not found"))
+	// errExperimentRunning reports a page that already runs another experiment.
+	errExperimentRunning = fail(http.StatusConflict, models.NewConflictError("The page already runs an experiment. Stop it first."))
+)
+
+// ExperimentHandler orchestrates HTTP request processing for A/B experiments on page layouts.
+// Reads use the stores directly; lifecycle changes run through the unit of work.
+type ExperimentHandler struct {
+	experimentRepo repository.ExperimentStore
+	pageRepo       repository.PageStore
+	uow            repository.UnitOfWork
+}
+
+// NewExperimentHandler initializes and returns a new instance of ExperimentHandler with its required dependencies.
+func NewExperimentHandler(experimentRepo repository.ExperimentStore, pageRepo repository.PageStore, uow repository.UnitOfWork) *ExperimentHandler {
+	return &ExperimentHandler{
+		experimentRepo: experimentRepo,
+		pageRepo:       pageRepo,
+		uow:            uow,
+	}
+}
+
+// ListExperiments processes requests to list the experiments of a page, newest first.
+func (h *ExperimentHandler) ListExperiments(c *gin.Context) {
+	pageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
+		return
+	}
+
+	page, err := h.pageRepo.GetByID(c.Request.Context(), pageID)
+	if err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if page == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		return
+	}
+
+	experiments, err := h.experimentRepo.ListByPage(c.Request.Context(), pageID)
+	if err != nil {
+		serverError(c, err, "Failed to fetch experiments")
+		return
+	}
+	if experiments == nil {
+		experiments = []models.Experiment{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiments": experiments,
+		"total":       len(experiments),
+	})
+}
+
+// GetExperiment processes requests to retrieve a single experiment.
+func (h *ExperimentHandler) GetExperiment(c *gin.Context) {
+	id, ok := experimentID(c)
+	if !ok {
+		return
+	}
+
+	experiment, err := h.experimentRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		serverError(c, err, "Failed to fetch experiment")
+		return
+	}
+	if experiment == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Experiment not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
+
+// CreateExperiment processes requests to create a draft experiment on a page. The page lookup,
+// the check that overridden widgets belong to it, and the insert run as one unit of work.
+func (h *ExperimentHandler) CreateExperiment(c *gin.Context) {
+	pageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
+		return
+	}
+
+	var req models.CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("name", "required", "Experiment name is required and cannot be empty"))
+		return
+	}
+
+	experiment := &models.Experiment{PageID: pageID, Name: strings.TrimSpace(req.Name), Status: models.ExperimentDraft}
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		page, err := tx.Pages.GetByID(ctx, pageID)
+		if err != nil {
+			return err
+		}
+		if page == nil {
+			return errPageNotFound
+		}
+
+		if experiment.Variants, err = storedVariants(ctx, tx, pageID, req.Variants); err != nil {
+			return err
+		}
+		return tx.Experiments.Create(ctx, experiment)
+	})
+	if err != nil {
+		writeError(c, err, "Failed to create experiment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, experiment)
+}
+
+// UpdateExperiment processes requests to rename an experiment or, while it is a draft, replace its
+// variants. Changing the variants of a started experiment would move users between them.
+func (h *ExperimentHandler) UpdateExperiment(c *gin.Context) {
+	id, ok := experimentID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("name", "required", "Experiment name cannot be empty"))
+			return
+		}
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+
+	var experiment *models.Experiment
+	err := h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		existing, err := tx.Experiments.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errExperimentNotFound
+		}
+
+		if req.Variants != nil {
+			if existing.Status != models.ExperimentDraft {
+				return fail(http.StatusConflict, models.NewConflictError("Variants can only be changed while the experiment is a draft"))
+			}
+			if updates["variants"], err = storedVariants(ctx, tx, existing.PageID, req.Variants); err != nil {
+				return err
+			}
+		}
+
+		if len(updates) == 0 {
+			experiment = existing
+			return nil
+		}
+		experiment, err = tx.Experiments.Update(ctx, id, updates)
+		return err
+	})
+	if err != nil {
+		writeError(c, err, "Failed to update experiment")
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
+
+// DeleteExperiment processes requests to delete an experiment that is not running.
+func (h *ExperimentHandler) DeleteExperiment(c *gin.Context) {
+	id, ok := experimentID(c)
+	if !ok {
+		return
+	}
+
+	err := h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		experiment, err := tx.Experiments.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if experiment == nil {
+			return errExperimentNotFound
+		}
+		if experiment.Status == models.ExperimentRunning {
+			return fail(http.StatusConflict, models.NewConflictError("Cannot delete a running experiment. Stop it first."))
+		}
+
+		if err := tx.Experiments.Delete(ctx, id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errExperimentNotFound
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(c, err, "Failed to delete experiment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Experiment deleted successfully"})
+}
+
+// StartExperiment processes requests to start serving the variants of a draft or stopped
+// experiment. A page runs at most one experiment at a time. Restarting a stopped experiment keeps
+// its first start time, and users get the variants they had before.
+func (h *ExperimentHandler) StartExperiment(c *gin.Context) {
+	h.transition(c, "Failed to start experiment", func(ctx context.Context, tx repository.Stores, experiment *models.Experiment) (map[string]interface{}, error) {
+		switch experiment.Status {
+		case models.ExperimentRunning:
+			return nil, fail(http.StatusConflict, models.NewConflictError("The experiment is already running"))
+		case models.ExperimentPromoted:
+			return nil, fail(http.StatusConflict, models.NewConflictError("A promoted experiment cannot be restarted"))
+		}
+
+		running, err := tx.Experiments.Running(ctx, experiment.PageID)
+		if err != nil {
+			return nil, err
+		}
+		if running != nil {
+			return nil, errExperimentRunning
+		}
+
+		updates := map[string]interface{}{"status": models.ExperimentRunning, "stopped_at": (*time.Time)(nil)}
+		if experiment.StartedAt == nil {
+			now := time.Now().UTC()
+			updates["started_at"] = &now
+		}
+		return updates, nil
+	})
+}
+
+// StopExperiment processes requests to stop a running experiment, after which the page serves its
+// base content to everyone.
+func (h *ExperimentHandler) StopExperiment(c *gin.Context) {
+	h.transition(c, "Failed to stop experiment", func(_ context.Context, _ repository.Stores, experiment *models.Experiment) (map[string]interface{}, error) {
+		if experiment.Status != models.ExperimentRunning {
+			return nil, fail(http.StatusConflict, models.NewConflictError("Only a running experiment can be stopped"))
+		}
+		now := time.Now().UTC()
+		return map[string]interface{}{"status": models.ExperimentStopped, "stopped_at": &now}, nil
+	})
+}
+
+// transition loads an experiment and applies the status change decided by change, in one unit of work.
+func (h *ExperimentHandler) transition(c *gin.Context, message string, change func(ctx context.Context, tx repository.Stores, experiment *models.Experiment) (map[string]interface{}, error)) {
+	id, ok := experimentID(c)
+	if !ok {
+		return
+	}
+
+	var experiment *models.Experiment
+	err := h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		existing, err := tx.Experiments.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errExperimentNotFound
+		}
+
+		updates, err := change(ctx, tx, existing)
+		if err != nil {
+			return err
+		}
+		experiment, err = tx.Experiments.Update(ctx, id, updates)
+		if errors.Is(err, repository.ErrExperimentRunning) {
+			return errExperimentRunning
+		}
+		return err
+	})
+	if err != nil {
+		writeError(c, err, message)
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
+
+// PromoteExperiment processes requests to make a variant of a running or stopped experiment the
+// page's base content, ending the experiment. A variant with its own widget list replaces the page's
+// widgets; one with overrides merges them into the configs of the page's widgets; a control variant
+// leaves them as they are. The change, the experiment update and an audit entry are stored as one
+// unit of work.
+func (h *ExperimentHandler) PromoteExperiment(c *gin.Context) {
+	id, ok := experimentID(c)
+	if !ok {
+		return
+	}
+
+	var req models.PromoteExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+		return
+	}
+
+	var experiment *models.Experiment
+	var widgets []models.Widget
+	var auditID uuid.UUID
+	err := h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		existing, err := tx.Experiments.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errExperimentNotFound
+		}
+		if existing.Status != models.ExperimentRunning && existing.Status != models.ExperimentStopped {
+			return fail(http.StatusConflict, models.NewConflictError("Only a running or stopped experiment can be promoted"))
+		}
+		variant := existing.Variant(strings.TrimSpace(req.Variant))
+		if variant == nil {
+			return fail(http.StatusBadRequest, models.NewFieldValidationError("variant", "oneof", "The experiment has no variant "+req.Variant))
+		}
+
+		base, err := tx.Widgets.GetByPageID(ctx, existing.PageID, nil)
+		if err != nil {
+			return err
+		}
+		replaced, updated, err := promoteVariant(ctx, tx.Widgets, existing.PageID, variant, base)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": models.ExperimentPromoted, "winner": variant.Key}
+		if existing.StoppedAt == nil {
+			now := time.Now().UTC()
+			updates["stopped_at"] = &now
+		}
+		if experiment, err = tx.Experiments.Update(ctx, id, updates); err != nil {
+			return err
+		}
+		if widgets, err = tx.Widgets.GetByPageID(ctx, existing.PageID, nil); err != nil {
+			return err
+		}
+
+		details, err := json.Marshal(gin.H{
+			"experiment_id":    existing.ID,
+			"experiment":       existing.Name,
+			"page_id":          existing.PageID,
+			"variant":          variant,
+			"widgets_replaced": replaced,
+			"widgets_updated":  updated,
+		})
+		if err != nil {
+			return err
+		}
+		entry := &models.AuditEntry{
+			Action:    models.AuditActionExperimentPromote,
+			Actor:     middleware.Actor(c),
+			RequestID: requestid.FromContext(ctx),
+			Details:   details,
+		}
+		if err := tx.Audit.Record(ctx, entry); err != nil {
+			return err
+		}
+		auditID = entry.ID
+		return nil
+	})
+	if err != nil {
+		writeError(c, err, "Failed to promote experiment variant")
+		return
+	}
+	if widgets == nil {
+		widgets = []models.Widget{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Variant promoted to the page's base content",
+		"experiment": experiment,
+		"widgets":    widgets,
+		"audit_id":   auditID,
+	})
+}
+
+// promoteVariant stores a variant as the page's base widgets, returning how many widgets it
+// replaced and how many configs it updated. A variant's own widgets keep their IDs.
+func promoteVariant(ctx context.Context, store repository.WidgetStore, pageID uuid.UUID, variant *models.Variant, base []models.Widget) (replaced, updated int, err error) {
+	if len(variant.Widgets) > 0 {
+		for _, w := range base {
+			if err := store.Delete(ctx, w.ID); err != nil {
+				return 0, 0, err
+			}
+		}
+		served, err := variant.Apply(pageID, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := range served {
+			if err := store.Create(ctx, &served[i]); err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(base), 0, nil
+	}
+
+	served, err := variant.Apply(pageID, base)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, w := range served {
+		if _, ok := variant.Overrides[w.ID]; !ok {
+			continue
+		}
+		if _, err := store.Update(ctx, w.ID, map[string]interface{}{"config": w.Config}); err != nil {
+			return 0, 0, err
+		}
+		updated++
+	}
+	return 0, updated, nil
+}
+
+// storedVariants validates the variants of a request for the given page and returns them as
+// stored: keys trimmed, widgets given IDs and empty configs. Overrides must target widgets of the
+// page and be JSON objects, merged into the widgets' configs.
+func storedVariants(ctx context.Context, tx repository.Stores, pageID uuid.UUID, variants models.Variants) (models.Variants, error) {
+	if len(variants) < minVariants || len(variants) > maxVariants {
+		return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("variants", "len", fmt.Sprintf("An experiment needs between %d and %d variants", minVariants, maxVariants)))
+	}
+
+	stored := make(models.Variants, len(variants))
+	keys := make(map[string]bool, len(variants))
+	total := 0
+	for i, v := range variants {
+		field := fmt.Sprintf("variants[%d]", i)
+		invalid := func(suffix, code, message string) error {
+			return fail(http.StatusBadRequest, models.NewFieldValidationError(field+suffix, code, message))
+		}
+
+		v.Key = strings.TrimSpace(v.Key)
+		switch {
+		case v.Key == "":
+			return nil, invalid(".key", "required", "Variant key is required")
+		case len(v.Key) > maxVariantKey:
+			return nil, invalid(".key", "max", fmt.Sprintf("Variant keys are limited to %d characters", maxVariantKey))
+		case keys[v.Key]:
+			return nil, invalid(".key", "unique", "Duplicate variant key "+v.Key)
+		case v.Weight < 0:
+			return nil, invalid(".weight", "min", "Variant weight cannot be negative")
+		case len(v.Widgets) > 0 && len(v.Overrides) > 0:
+			return nil, invalid("", "excluded_with", "A variant has either its own widgets or overrides, not both")
+		case len(v.Widgets) > maxVariantWidget:
+			return nil, invalid(".widgets", "max", fmt.Sprintf("A variant has at most %d widgets", maxVariantWidget))
+		}
+		keys[v.Key] = true
+		total += v.Weight
+
+		if len(v.Widgets) > 0 {
+			widgets := make([]models.VariantWidget, len(v.Widgets))
+			for j, w := range v.Widgets {
+				if !models.IsValidWidgetType(w.Type) {
+					return nil, invalid(fmt.Sprintf(".widgets[%d].type", j), "oneof", "Invalid widget type. Must be one of: banner, product_grid, text, image, spacer")
+				}
+				if len(w.Config) == 0 {
+					w.Config = json.RawMessage("{}")
+				} else if !json.Valid(w.Config) {
+					return nil, invalid(fmt.Sprintf(".widgets[%d].config", j), "json", "Invalid JSON format for widget config")
+				}
+				if err := models.ValidateConfigVariants(w.Config); err != nil {
+					return nil, invalid(fmt.Sprintf(".widgets[%d].config", j), "variants", "Invalid config variants: "+err.Error())
+				}
+				w.ID = uuid.New()
+				widgets[j] = w
+			}
+			v.Widgets = widgets
+		}
+
+		if len(v.Overrides) > 0 {
+			ids := make([]uuid.UUID, 0, len(v.Overrides))
+			for widgetID, patch := range v.Overrides {
+				var object map[string]interface{}
+				if err := json.Unmarshal(patch, &object); err != nil || object == nil {
+					return nil, invalid(".overrides."+widgetID.String(), "json", "An override must be a JSON object merged into the widget config")
+				}
+				if err := models.ValidateConfigVariants(patch); err != nil {
+					return nil, invalid(".overrides."+widgetID.String(), "variants", "Invalid config variants: "+err.Error())
+				}
+				ids = append(ids, widgetID)
+			}
+			belong, err := tx.Widgets.CheckWidgetsBelongToPage(ctx, pageID, ids)
+			if err != nil {
+				return nil, err
+			}
+			if !belong {
+				return nil, invalid(".overrides", "exists", "Overrides can only target widgets of the page")
+			}
+		}
+		if err := storedTokens(ctx, tx.Themes, field, v.TokenReferences()); err != nil {
+			return nil, err
+		}
+
+		stored[i] = v
+	}
+	if total == 0 {
+		return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("variants", "weight", "At least one variant needs a positive weight"))
+	}
+	return stored, nil
+}
+
+// experimentID parses the experiment ID path parameter, answering with a 400 when it is malformed.
+func experimentID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid experiment ID format"))
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,1087 @@
+// Package handlers contains tests exercising the HTTP handlers against the in-memory stores.
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"appdrop/i18n"
+	"appdrop/middleware"
+	"appdrop/models"
+	"appdrop/repository/memory"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// newTestRouter wires the page and widget handlers, behind the given middleware, to a fresh in-memory store.
+func newTestRouter(use ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	db := memory.NewDB()
+	pageRepo := memory.NewPageRepository(db)
+	widgetRepo := memory.NewWidgetRepository(db)
+	segmentRepo := memory.NewSegmentRepository(db)
+	experimentRepo := memory.NewExperimentRepository(db)
+	themeRepo := memory.NewThemeRepository(db)
+	uow := memory.NewUnitOfWork(db)
+	pagination := Pagination{DefaultPerPage: 10, MaxPerPage: 100}
+	pageHandler := NewPageHandler(pageRepo, widgetRepo, segmentRepo, experimentRepo, themeRepo, uow, pagination)
+	widgetHandler := NewWidgetHandler(widgetRepo, pageRepo, segmentRepo, experimentRepo, themeRepo, uow, pagination)
+	segmentHandler := NewSegmentHandler(segmentRepo, widgetRepo, uow, pagination)
+	experimentHandler := NewExperimentHandler(experimentRepo, pageRepo, uow)
+	searchHandler := NewSearchHandler(memory.NewSearchRepository(db), pagination)
+	translationHandler := NewTranslationHandler(pageRepo, widgetRepo, uow)
+	themeHandler := NewThemeHandler(themeRepo, uow)
+
+	router := gin.New()
+	router.Use(use...)
+	router.GET("/pages", pageHandler.ListPages)
+	router.GET("/pages/:id", pageHandler.GetPage)
+	router.GET("/pages/:id/preview", pageHandler.PreviewPage)
+	router.GET("/pages/:id/widgets", widgetHandler.GetWidgets)
+	router.PUT("/pages/:id", pageHandler.UpdatePage)
+	router.POST("/pages", pageHandler.CreatePage)
+	router.DELETE("/pages/:id", pageHandler.DeletePage)
+	router.POST("/pages/:id/widgets", widgetHandler.CreateWidget)
+	router.GET("/widgets", widgetHandler.ListWidgets)
+	router.PUT("/widgets/:id", widgetHandler.UpdateWidget)
+	router.POST("/widgets/replace", widgetHandler.ReplaceInConfigs)
+	router.GET("/search", searchHandler.Search)
+	router.POST("/segments", segmentHandler.CreateSegment)
+	router.DELETE("/segments/:id", segmentHandler.DeleteSegment)
+	router.PUT("/segments/:id/users", segmentHandler.SetSegmentUsers)
+	router.POST("/segments/:id/test", segmentHandler.TestSegment)
+	router.GET("/segments/:id/widgets", segmentHandler.ListSegmentWidgets)
+	router.POST("/pages/:id/experiments", experimentHandler.CreateExperiment)
+	router.PUT("/experiments/:id", experimentHandler.UpdateExperiment)
+	router.DELETE("/experiments/:id", experimentHandler.DeleteExperiment)
+	router.POST("/experiments/:id/start", experimentHandler.StartExperiment)
+	router.POST("/experiments/:id/stop", experimentHandler.StopExperiment)
+	router.POST("/experiments/:id/promote", experimentHandler.PromoteExperiment)
+	router.GET("/translations/export", translationHandler.ExportTranslations)
+	router.POST("/translations/import", translationHandler.ImportTranslations)
+	router.GET("/theme", themeHandler.GetTheme)
+	router.PUT("/theme", themeHandler.UpdateTheme)
+	manage := router.Group("/manage", Unfiltered())
+	manage.GET("/pages", pageHandler.ListPages)
+	manage.GET("/pages/:id", pageHandler.GetPage)
+	manage.GET("/pages/:id/widgets", widgetHandler.GetWidgets)
+	manage.GET("/widgets", widgetHandler.ListWidgets)
+	return router
+}
+
+// doJSON sends a request with an optional JSON body and decodes the response into out.
+func doJSON(t *testing.T, router *gin.Engine, method, path, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("Failed to decode %s %s response %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// TestPageLifecycle verifies page creation, route conflicts, widget positioning and the home page delete rule.
+func TestPageLifecycle(t *testing.T) {
+	router := newTestRouter()
+
+	var home models.Page
+	if code := doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home","is_home":true}`, &home); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating page, got %d", code)
+	}
+
+	var conflict models.ErrorResponse
+	if code := doJSON(t, router, http.MethodPost, "/pages", `{"name":"Again","route":"/home"}`, &conflict); code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate route, got %d", code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := doJSON(t, router, http.MethodPost, "/pages/"+home.ID.String()+"/widgets", `{"type":"text"}`, nil); code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating widget, got %d", code)
+		}
+	}
+
+	var fetched models.Page
+	if code := doJSON(t, router, http.MethodGet, "/pages/"+home.ID.String(), "", &fetched); code != http.StatusOK {
+		t.Fatalf("Expected 200 fetching page, got %d", code)
+	}
+	if len(fetched.Widgets) != 2 || fetched.Widgets[1].Position != 2 {
+		t.Errorf("Expected two widgets appended at positions 1 and 2, got %+v", fetched.Widgets)
+	}
+
+	if code := doJSON(t, router, http.MethodDelete, "/pages/"+home.ID.String(), "", nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 deleting the home page, got %d", code)
+	}
+}
+
+// TestListPagesCursor verifies keyset pagination through the next_cursor links and the rejection
+// of a cursor issued for a different sort order.
+func TestListPagesCursor(t *testing.T) {
+	router := newTestRouter()
+	for _, name := range []string{"c", "a", "b"} {
+		if code := doJSON(t, router, http.MethodPost, "/pages", `{"name":"`+name+`","route":"/`+name+`"}`, nil); code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating page, got %d", code)
+		}
+	}
+
+	var names []string
+	path := "/pages?sort=-name&per_page=2&cursor="
+	for path != "" {
+		var resp models.PageCursorResponse
+		if code := doJSON(t, router, http.MethodGet, path, "", &resp); code != http.StatusOK {
+			t.Fatalf("Expected 200 listing %s, got %d", path, code)
+		}
+		for _, p := range resp.Pages {
+			names = append(names, p.Name)
+		}
+		path = ""
+		if resp.NextCursor != "" {
+			path = "/pages?sort=-name&per_page=2&cursor=" + resp.NextCursor
+		}
+	}
+	if strings.Join(names, ",") != "c,b,a" {
+		t.Errorf("Expected c,b,a walking the cursor, got %v", names)
+	}
+
+	var first models.PageCursorResponse
+	doJSON(t, router, http.MethodGet, "/pages?sort=name&per_page=1&cursor=", "", &first)
+	var resp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodGet, "/pages?sort=route&cursor="+first.NextCursor, "", &resp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a cursor of another sort, got %d", code)
+	}
+	if code := doJSON(t, router, http.MethodGet, "/pages?sort=position", "", &resp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown sort, got %d", code)
+	}
+}
+
+// TestSearch verifies that widget hits name their widget and page and that a query is required.
+func TestSearch(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Deals","route":"/deals"}`, &page)
+	var widget models.Widget
+	doJSON(t, router, http.MethodPost, "/pages/"+page.ID.String()+"/widgets", `{"type":"banner","config":{"title":"Black Friday"}}`, &widget)
+
+	var resp models.SearchResponse
+	if code := doJSON(t, router, http.MethodGet, "/search?q=black+friday", "", &resp); code != http.StatusOK {
+		t.Fatalf("Expected 200 searching, got %d", code)
+	}
+	if resp.Total != 1 || resp.Results[0].WidgetID == nil || *resp.Results[0].WidgetID != widget.ID || resp.Results[0].PageID != page.ID {
+		t.Errorf("Expected one hit on the banner of Deals, got %+v", resp)
+	}
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodGet, "/search?q=+", "", &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a blank query, got %d", code)
+	}
+}
+
+// TestListWidgetsConfigFilters verifies that config filters are read from the raw query string.
+func TestListWidgetsConfigFilters(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Grid","route":"/grid"}`, &page)
+	for _, config := range []string{`{"columns":2}`, `{"columns":4}`} {
+		doJSON(t, router, http.MethodPost, "/pages/"+page.ID.String()+"/widgets", `{"type":"product_grid","config":`+config+`}`, nil)
+	}
+
+	var resp models.WidgetCursorResponse
+	if code := doJSON(t, router, http.MethodGet, "/widgets?type=product_grid,banner&config.columns%3E=3", "", &resp); code != http.StatusOK {
+		t.Fatalf("Expected 200 listing widgets, got %d", code)
+	}
+	if len(resp.Widgets) != 1 || string(resp.Widgets[0].Config) != `{"columns":4}` || resp.Widgets[0].PageRoute != "/grid" {
+		t.Errorf("Expected the four-column grid on /grid, got %+v", resp.Widgets)
+	}
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodGet, "/widgets?config.columns>=many", "", &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a non-numeric range filter, got %d", code)
+	}
+}
+
+// TestReplaceInConfigs verifies that a find-and-replace previews its diff without storing it, and
+// that applying it rewrites every matching string and reports the audit entry.
+func TestReplaceInConfigs(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Sale","route":"/sale"}`, &page)
+	doJSON(t, router, http.MethodPost, "/pages/"+page.ID.String()+"/widgets",
+		`{"type":"banner","config":{"image_url":"http://old.example.com/a.png","title":"old.example.com deals","slides":[{"src":"http://old.example.com/b.png"}],"height":120}}`, nil)
+
+	body := `{"scope":"*","match":"http://old\\.example\\.com/(\\w+)","regex":true,"replacement":"https://cdn.example.com/${1}"}`
+	var preview models.WidgetReplaceResponse
+	if code := doJSON(t, router, http.MethodPost, "/widgets/replace", body, &preview); code != http.StatusOK {
+		t.Fatalf("Expected 200 previewing, got %d", code)
+	}
+	if preview.Applied || preview.AuditID != nil || preview.Widgets != 1 || preview.Replacements != 2 {
+		t.Fatalf("Expected an unapplied preview of two replacements, got %+v", preview)
+	}
+	changes := preview.Diffs[0].Changes
+	if changes[0].Path != "image_url" || changes[0].After != "https://cdn.example.com/a.png" || changes[1].Path != "slides.0.src" {
+		t.Errorf("Expected changes to image_url and slides.0.src, got %+v", changes)
+	}
+
+	var list models.WidgetCursorResponse
+	doJSON(t, router, http.MethodGet, "/widgets", "", &list)
+	if !strings.Contains(string(list.Widgets[0].Config), "http://old.example.com/a.png") {
+		t.Errorf("Expected the preview to leave the config unchanged, got %s", list.Widgets[0].Config)
+	}
+
+	var applied models.WidgetReplaceResponse
+	if code := doJSON(t, router, http.MethodPost, "/widgets/replace?apply=true", body, &applied); code != http.StatusOK {
+		t.Fatalf("Expected 200 applying, got %d", code)
+	}
+	if !applied.Applied || applied.AuditID == nil || applied.Replacements != 2 {
+		t.Errorf("Expected an applied change with an audit entry, got %+v", applied)
+	}
+	doJSON(t, router, http.MethodGet, "/widgets", "", &list)
+	want := `{"height":120,"image_url":"https://cdn.example.com/a.png","slides":[{"src":"https://cdn.example.com/b.png"}],"title":"old.example.com deals"}`
+	if string(list.Widgets[0].Config) != want {
+		t.Errorf("Expected config %s, got %s", want, list.Widgets[0].Config)
+	}
+
+	var scoped models.WidgetReplaceResponse
+	doJSON(t, router, http.MethodPost, "/widgets/replace", `{"scope":"title","match":"old.example.com","replacement":"Example"}`, &scoped)
+	if scoped.Widgets != 1 || scoped.Diffs[0].Changes[0].Path != "title" || len(scoped.Diffs[0].Changes) != 1 {
+		t.Errorf("Expected a scoped replacement of the title only, got %+v", scoped)
+	}
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodPost, "/widgets/replace", `{"scope":"*","match":"x*","regex":true}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a pattern matching the empty string, got %d", code)
+	}
+	if code := doJSON(t, router, http.MethodPost, "/widgets/replace", `{"scope":"a..b","match":"x"}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid scope, got %d", code)
+	}
+}
+
+// TestSchedules verifies that delivery endpoints leave out content outside its schedule and refuse
+// all=true, that all=true on the management routes and the preview endpoint still show it, and that
+// invalid schedules are rejected.
+func TestSchedules(t *testing.T) {
+	router := newTestRouter()
+	now := time.Now().UTC()
+	later := now.Add(48 * time.Hour).Format(time.RFC3339)
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home"}`, &page)
+	pagePath := "/pages/" + page.ID.String()
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"text"}`, nil)
+	var sale models.Widget
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","schedule":{"visible_from":"`+later+`"}}`, &sale)
+	if sale.Schedule == nil || sale.Schedule.VisibleFrom == nil {
+		t.Fatalf("Expected the created widget to carry its schedule, got %+v", sale)
+	}
+
+	var delivered models.Page
+	doJSON(t, router, http.MethodGet, pagePath, "", &delivered)
+	if len(delivered.Widgets) != 1 || delivered.Widgets[0].Type != "text" {
+		t.Errorf("Expected only the unscheduled widget to be delivered, got %+v", delivered.Widgets)
+	}
+	var widgets struct{ Widgets []models.Widget }
+	doJSON(t, router, http.MethodGet, pagePath+"/widgets", "", &widgets)
+	if len(widgets.Widgets) != 1 {
+		t.Errorf("Expected one live widget, got %d", len(widgets.Widgets))
+	}
+	doJSON(t, router, http.MethodGet, "/manage"+pagePath+"/widgets?all=true", "", &widgets)
+	if len(widgets.Widgets) != 2 {
+		t.Errorf("Expected all=true to include the scheduled widget, got %d", len(widgets.Widgets))
+	}
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodGet, pagePath+"/widgets?all=true", "", &errResp); code != http.StatusForbidden {
+		t.Errorf("Expected all=true to be refused on a delivery route, got %d", code)
+	}
+
+	var preview models.PagePreview
+	at := now.Add(72 * time.Hour).Format(time.RFC3339)
+	if code := doJSON(t, router, http.MethodGet, pagePath+"/preview?at="+at, "", &preview); code != http.StatusOK {
+		t.Fatalf("Expected 200 previewing, got %d", code)
+	}
+	if !preview.Live || len(preview.Page.Widgets) != 2 {
+		t.Errorf("Expected both widgets to be live in three days, got %+v", preview)
+	}
+
+	doJSON(t, router, http.MethodPut, pagePath, `{"schedule":{"timezone":"Europe/Paris","visible_until":"2020-01-01T00:00"}}`, nil)
+	if code := doJSON(t, router, http.MethodGet, pagePath, "", &errResp); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an expired page, got %d", code)
+	}
+	var list models.PageListResponse
+	doJSON(t, router, http.MethodGet, "/pages", "", &list)
+	if len(list.Pages) != 0 {
+		t.Errorf("Expected the expired page to be left out of the listing, got %+v", list.Pages)
+	}
+	doJSON(t, router, http.MethodGet, pagePath+"/preview", "", &preview)
+	if preview.Live {
+		t.Error("Expected the preview to report the expired page as not live")
+	}
+
+	doJSON(t, router, http.MethodPut, pagePath, `{"schedule":{}}`, nil)
+	if code := doJSON(t, router, http.MethodGet, pagePath, "", &delivered); code != http.StatusOK || delivered.Schedule != nil {
+		t.Errorf("Expected an empty schedule to remove it, got %d %+v", code, delivered.Schedule)
+	}
+
+	for _, schedule := range []string{
+		`{"timezone":"Mars/Olympus"}`,
+		`{"visible_from":"soon"}`,
+		`{"visible_from":"2026-02-01","visible_until":"2026-01-01"}`,
+		`{"weekly":[{"days":["someday"],"start":"09:00","end":"17:00"}]}`,
+		`{"weekly":[{"days":["mon"],"start":"9am","end":"17:00"}]}`,
+	} {
+		if code := doJSON(t, router, http.MethodPut, pagePath, `{"schedule":`+schedule+`}`, &errResp); code != http.StatusBadRequest || len(errResp.Error.Errors) != 1 {
+			t.Errorf("Expected 400 with a field error for schedule %s, got %d %+v", schedule, code, errResp)
+		}
+	}
+}
+
+// TestFilteredPagination verifies that listings leave out hidden content before paginating, so that
+// slices are full, totals count what is delivered and cursors stop at the last visible item.
+func TestFilteredPagination(t *testing.T) {
+	router := newTestRouter()
+	later := time.Now().UTC().Add(48 * time.Hour).Format(time.RFC3339)
+	hidden := `,"schedule":{"visible_from":"` + later + `"}`
+
+	for i, name := range []string{"A", "B", "C", "D"} {
+		body := `{"name":"` + name + `","route":"/` + name + `"`
+		if i%2 == 1 {
+			body += hidden
+		}
+		var page models.Page
+		doJSON(t, router, http.MethodPost, "/pages", body+`}`, &page)
+		widget := `{"type":"text"`
+		if i%2 == 1 {
+			widget += hidden
+		}
+		doJSON(t, router, http.MethodPost, "/pages/"+page.ID.String()+"/widgets", widget+`}`, nil)
+		doJSON(t, router, http.MethodPost, "/pages/"+page.ID.String()+"/widgets", `{"type":"banner"`+hidden+`}`, nil)
+	}
+
+	var numbered models.PageListResponse
+	doJSON(t, router, http.MethodGet, "/pages?per_page=1&page=2&sort=name", "", &numbered)
+	if numbered.Total != 2 || numbered.TotalPages != 2 || len(numbered.Pages) != 1 || numbered.Pages[0].Name != "C" {
+		t.Errorf("Expected the second of two visible pages, got %+v", numbered)
+	}
+
+	var names []string
+	path := "/pages?per_page=1&sort=name&cursor="
+	for i := 0; i < 3; i++ {
+		var slice models.PageCursorResponse
+		doJSON(t, router, http.MethodGet, path, "", &slice)
+		for _, p := range slice.Pages {
+			names = append(names, p.Name)
+		}
+		if slice.NextCursor == "" {
+			break
+		}
+		path = "/pages?per_page=1&sort=name&cursor=" + slice.NextCursor
+	}
+	if strings.Join(names, ",") != "A,C" {
+		t.Errorf("Expected the keyset slices A and C without an empty last slice, got %v", names)
+	}
+
+	var full models.WidgetCursorResponse
+	doJSON(t, router, http.MethodGet, "/widgets?per_page=2", "", &full)
+	if len(full.Widgets) != 2 || full.NextCursor != "" {
+		t.Errorf("Expected both visible widgets in a full slice without a cursor, got %+v", full)
+	}
+	var widgets models.WidgetCursorResponse
+	doJSON(t, router, http.MethodGet, "/widgets?per_page=1", "", &widgets)
+	if len(widgets.Widgets) != 1 || widgets.NextCursor == "" {
+		t.Fatalf("Expected one visible widget and a cursor, got %+v", widgets)
+	}
+	var last models.WidgetCursorResponse
+	doJSON(t, router, http.MethodGet, "/widgets?per_page=1&cursor="+widgets.NextCursor, "", &last)
+	if len(last.Widgets) != 1 || last.NextCursor != "" || last.Widgets[0].ID == widgets.Widgets[0].ID || last.Widgets[0].Type != "text" {
+		t.Errorf("Expected the other visible widget without a cursor, got %+v", last)
+	}
+}
+
+// TestTargeting verifies that delivery endpoints evaluate audience rules against the client context
+// sent in headers or query parameters, that the preview endpoint explains what a simulated client
+// misses, and that rules which do not type-check are rejected on save.
+func TestTargeting(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home","rule":"platform in [\"ios\",\"android\"]"}`, &page)
+	if page.Rule == "" {
+		t.Fatalf("Expected the created page to carry its rule, got %+v", page)
+	}
+	pagePath := "/pages/" + page.ID.String()
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"text"}`, nil)
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","rule":"app_version >= \"3.2\" && country in [\"US\",\"CA\"]"}`, nil)
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodGet, pagePath, "", &errResp); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a client outside the page's audience, got %d", code)
+	}
+
+	var delivered models.Page
+	doJSON(t, router, http.MethodGet, pagePath+"?platform=ios&app_version=3.1&country=us", "", &delivered)
+	if len(delivered.Widgets) != 1 {
+		t.Errorf("Expected an older app to miss the banner, got %+v", delivered.Widgets)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, pagePath+"/widgets", nil)
+	req.Header.Set("X-Client-Platform", "iOS")
+	req.Header.Set("X-Client-App-Version", "3.10.0")
+	req.Header.Set("X-Client-Country", "CA")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var widgets struct{ Widgets []models.Widget }
+	if err := json.Unmarshal(w.Body.Bytes(), &widgets); err != nil || w.Code != http.StatusOK || len(widgets.Widgets) != 2 {
+		t.Errorf("Expected the header context to match both widgets, got %d %s", w.Code, w.Body.String())
+	}
+
+	var list models.WidgetCursorResponse
+	doJSON(t, router, http.MethodGet, "/widgets?platform=web", "", &list)
+	if len(list.Widgets) != 0 {
+		t.Errorf("Expected no widgets of a page outside the audience, got %d", len(list.Widgets))
+	}
+
+	var preview models.PagePreview
+	if code := doJSON(t, router, http.MethodGet, pagePath+"/preview?platform=android&app_version=4.0&country=FR", "", &preview); code != http.StatusOK {
+		t.Fatalf("Expected 200 previewing, got %d", code)
+	}
+	if !preview.Live || len(preview.Page.Widgets) != 1 || len(preview.Hidden) != 1 || preview.Hidden[0].Reason != models.HiddenByRule {
+		t.Errorf("Expected the banner to be hidden by its rule, got %+v", preview)
+	}
+	doJSON(t, router, http.MethodGet, pagePath+"/preview", "", &preview)
+	if preview.Live || preview.Reason != models.HiddenByRule {
+		t.Errorf("Expected a client without a platform to miss the page, got %+v", preview)
+	}
+
+	for _, rule := range []string{
+		`platform >= \"ios\"`,
+		`app_version == 3`,
+		`tier == \"gold\"`,
+		`country in []`,
+		`(new_user`,
+	} {
+		if code := doJSON(t, router, http.MethodPut, pagePath, `{"rule":"`+rule+`"}`, &errResp); code != http.StatusBadRequest || len(errResp.Error.Errors) != 1 {
+			t.Errorf("Expected 400 with a field error for rule %s, got %d %+v", rule, code, errResp)
+		}
+	}
+
+	doJSON(t, router, http.MethodPut, pagePath, `{"rule":""}`, nil)
+	var cleared models.Page
+	if code := doJSON(t, router, http.MethodGet, pagePath, "", &cleared); code != http.StatusOK || cleared.Rule != "" {
+		t.Errorf("Expected an empty rule to remove it, got %d %q", code, cleared.Rule)
+	}
+}
+
+// TestSegments verifies that pages and widgets can reference a segment defined by a rule and an
+// uploaded user list, that delivery and the test endpoint check clients against it, and that a
+// referenced segment cannot be deleted.
+func TestSegments(t *testing.T) {
+	router := newTestRouter()
+
+	var segment models.Segment
+	if code := doJSON(t, router, http.MethodPost, "/segments", `{"name":"Beta","rule":"platform == \"ios\"","user_ids":["u1"," u2","u1",""]}`, &segment); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a segment, got %d", code)
+	}
+	if segment.UserCount != 2 {
+		t.Errorf("Expected blank and repeated user IDs to be dropped, got %d users", segment.UserCount)
+	}
+	segmentPath := "/segments/" + segment.ID.String()
+
+	req := httptest.NewRequest(http.MethodPut, segmentPath+"/users", strings.NewReader("user_id,email\nu2,a@example.com\nu3,b@example.com\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &segment); err != nil || w.Code != http.StatusOK || segment.UserCount != 2 {
+		t.Fatalf("Expected a CSV upload to replace the user list, got %d %s", w.Code, w.Body.String())
+	}
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home"}`, &page)
+	pagePath := "/pages/" + page.ID.String()
+	var widget models.Widget
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","segment_id":"`+segment.ID.String()+`"}`, &widget); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a widget in a segment, got %d", code)
+	}
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"text"}`, nil)
+
+	var delivered models.Page
+	doJSON(t, router, http.MethodGet, pagePath+"?platform=ios&user_id=u1", "", &delivered)
+	if len(delivered.Widgets) != 1 {
+		t.Errorf("Expected a user removed from the list to miss the banner, got %+v", delivered.Widgets)
+	}
+	req = httptest.NewRequest(http.MethodGet, pagePath+"/widgets", nil)
+	req.Header.Set("X-Client-Platform", "ios")
+	req.Header.Set("X-Client-User-Id", "u3")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var widgets struct{ Widgets []models.Widget }
+	if err := json.Unmarshal(w.Body.Bytes(), &widgets); err != nil || len(widgets.Widgets) != 2 {
+		t.Errorf("Expected a listed iOS user to see the banner, got %d %s", w.Code, w.Body.String())
+	}
+
+	var result models.SegmentTestResult
+	if code := doJSON(t, router, http.MethodPost, segmentPath+"/test?user_id=u3", `{"platform":"android"}`, &result); code != http.StatusOK {
+		t.Fatalf("Expected 200 testing a segment, got %d", code)
+	}
+	if result.Member || result.MatchesRule == nil || *result.MatchesRule || result.Listed == nil || !*result.Listed {
+		t.Errorf("Expected a listed Android user to fail the rule only, got %+v", result)
+	}
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodPost, segmentPath+"/test", `{"tier":"gold"}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 testing with an unknown attribute, got %d", code)
+	}
+
+	var list models.WidgetCursorResponse
+	doJSON(t, router, http.MethodGet, segmentPath+"/widgets", "", &list)
+	if len(list.Widgets) != 1 || list.Widgets[0].ID != widget.ID {
+		t.Errorf("Expected the banner to be listed as referencing the segment, got %+v", list.Widgets)
+	}
+
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"text","segment_id":"`+uuid.NewString()+`"}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 referencing an unknown segment, got %d", code)
+	}
+	if code := doJSON(t, router, http.MethodDelete, segmentPath, "", &errResp); code != http.StatusConflict {
+		t.Errorf("Expected 409 deleting a referenced segment, got %d", code)
+	}
+	doJSON(t, router, http.MethodPut, "/widgets/"+widget.ID.String(), `{"segment_id":""}`, nil)
+	if code := doJSON(t, router, http.MethodDelete, segmentPath, "", nil); code != http.StatusOK {
+		t.Errorf("Expected 200 deleting a segment no longer referenced, got %d", code)
+	}
+}
+
+// TestExperiments verifies the experiment lifecycle: variants are validated on save, delivery
+// serves each user the variant it is assigned and reports it, a page runs one experiment at a time,
+// and promoting a variant makes it the page's base content.
+func TestExperiments(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home"}`, &page)
+	pagePath := "/pages/" + page.ID.String()
+	var grid models.Widget
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"product_grid","config":{"columns":2}}`, &grid)
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"text"}`, nil)
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/experiments", `{"name":"Grid","variants":[{"key":"a","weight":1}]}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a single variant, got %d", code)
+	}
+	foreign := `{"name":"Grid","variants":[{"key":"a","weight":1},{"key":"b","weight":1,"overrides":{"` + uuid.NewString() + `":{"columns":3}}}]}`
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/experiments", foreign, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 overriding a widget of another page, got %d", code)
+	}
+
+	body := `{"name":"Grid columns","variants":[{"key":"two","weight":50},{"key":"three","weight":50,"overrides":{"` + grid.ID.String() + `":{"columns":3}}}]}`
+	var experiment models.Experiment
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/experiments", body, &experiment); code != http.StatusCreated || experiment.Status != models.ExperimentDraft {
+		t.Fatalf("Expected 201 creating a draft experiment, got %d %+v", code, experiment)
+	}
+	experimentPath := "/experiments/" + experiment.ID.String()
+
+	var delivered models.Page
+	doJSON(t, router, http.MethodGet, pagePath+"?user_id=u1", "", &delivered)
+	if delivered.Experiment != nil {
+		t.Errorf("Expected a draft experiment not to be served, got %+v", delivered.Experiment)
+	}
+
+	if code := doJSON(t, router, http.MethodPost, experimentPath+"/start", "", &experiment); code != http.StatusOK || experiment.Status != models.ExperimentRunning {
+		t.Fatalf("Expected 200 starting the experiment, got %d %+v", code, experiment)
+	}
+	var other models.Experiment
+	doJSON(t, router, http.MethodPost, pagePath+"/experiments", body, &other)
+	if code := doJSON(t, router, http.MethodPost, "/experiments/"+other.ID.String()+"/start", "", &errResp); code != http.StatusConflict {
+		t.Errorf("Expected 409 starting a second experiment on the page, got %d", code)
+	}
+	if code := doJSON(t, router, http.MethodPut, experimentPath, body, &errResp); code != http.StatusConflict {
+		t.Errorf("Expected 409 changing the variants of a running experiment, got %d", code)
+	}
+
+	served := map[string]bool{}
+	for i := 0; i < 40 && len(served) < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s?user_id=user-%d", pagePath, i), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var got models.Page
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Experiment == nil || len(got.Widgets) != 2 {
+			t.Fatalf("Expected an experiment variant to be served, got %d %s", w.Code, w.Body.String())
+		}
+		variant := got.Experiment.Variant
+		if w.Header().Get("X-Experiment-Variant") != variant || w.Header().Get("X-Experiment-Id") != experiment.ID.String() {
+			t.Errorf("Expected the headers to name variant %s, got %v", variant, w.Header())
+		}
+		wantColumns := map[string]string{"two": `{"columns":2}`, "three": `{"columns":3}`}[variant]
+		if string(got.Widgets[0].Config) != wantColumns {
+			t.Errorf("Expected variant %s to serve grid config %s, got %s", variant, wantColumns, got.Widgets[0].Config)
+		}
+		served[variant] = true
+	}
+	if len(served) != 2 {
+		t.Errorf("Expected both variants to be served across 40 users, got %v", served)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, pagePath+"/widgets?type=product_grid", nil)
+	req.Header.Set("X-Client-Device-Id", "device-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var widgets struct {
+		Widgets    []models.Widget
+		Experiment *models.ExperimentAssignment
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &widgets); err != nil || widgets.Experiment == nil || len(widgets.Widgets) != 1 {
+		t.Errorf("Expected the device to be assigned a variant, got %d %s", w.Code, w.Body.String())
+	}
+	doJSON(t, router, http.MethodGet, "/manage"+pagePath+"?user_id=u1&all=true", "", &delivered)
+	if delivered.Experiment != nil {
+		t.Errorf("Expected all=true to return the base content, got %+v", delivered.Experiment)
+	}
+
+	if code := doJSON(t, router, http.MethodPost, experimentPath+"/promote", `{"variant":"four"}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 promoting an unknown variant, got %d", code)
+	}
+	var promoted struct {
+		Experiment models.Experiment
+		Widgets    []models.Widget
+	}
+	if code := doJSON(t, router, http.MethodPost, experimentPath+"/promote", `{"variant":"three"}`, &promoted); code != http.StatusOK {
+		t.Fatalf("Expected 200 promoting a variant, got %d", code)
+	}
+	if promoted.Experiment.Status != models.ExperimentPromoted || promoted.Experiment.Winner != "three" || len(promoted.Widgets) != 2 {
+		t.Errorf("Expected the experiment to be promoted, got %+v", promoted)
+	}
+	var base models.Page
+	doJSON(t, router, http.MethodGet, pagePath+"?user_id=u1", "", &base)
+	if base.Experiment != nil || len(base.Widgets) != 2 || string(base.Widgets[0].Config) != `{"columns":3}` {
+		t.Errorf("Expected the promoted variant to be the base content, got %+v", base)
+	}
+
+	if code := doJSON(t, router, http.MethodPost, "/experiments/"+other.ID.String()+"/start", "", &other); code != http.StatusOK {
+		t.Errorf("Expected another experiment to start once the page is free, got %d", code)
+	}
+	if code := doJSON(t, router, http.MethodDelete, "/experiments/"+other.ID.String(), "", &errResp); code != http.StatusConflict {
+		t.Errorf("Expected 409 deleting a running experiment, got %d", code)
+	}
+	doJSON(t, router, http.MethodPost, "/experiments/"+other.ID.String()+"/stop", "", nil)
+	if code := doJSON(t, router, http.MethodDelete, "/experiments/"+other.ID.String(), "", nil); code != http.StatusOK {
+		t.Errorf("Expected 200 deleting a stopped experiment, got %d", code)
+	}
+}
+
+// TestExperimentPromotionKeepsWidgetIDs verifies that promoting a variant with its own widget list
+// stores the widgets under the IDs delivery served them with.
+func TestExperimentPromotionKeepsWidgetIDs(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home"}`, &page)
+	pagePath := "/pages/" + page.ID.String()
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"text"}`, nil)
+
+	body := `{"name":"Layout","variants":[{"key":"base","weight":0},{"key":"hero","weight":100,"widgets":[{"type":"banner","config":{"title":"a"}},{"type":"text"}]}]}`
+	var experiment models.Experiment
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/experiments", body, &experiment); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating the experiment, got %d", code)
+	}
+	experimentPath := "/experiments/" + experiment.ID.String()
+	doJSON(t, router, http.MethodPost, experimentPath+"/start", "", nil)
+
+	var served models.Page
+	doJSON(t, router, http.MethodGet, pagePath+"?user_id=u1", "", &served)
+	if served.Experiment == nil || served.Experiment.Variant != "hero" || len(served.Widgets) != 2 {
+		t.Fatalf("Expected the hero variant to be served, got %+v", served)
+	}
+
+	if code := doJSON(t, router, http.MethodPost, experimentPath+"/promote", `{"variant":"hero"}`, nil); code != http.StatusOK {
+		t.Fatalf("Expected 200 promoting the variant, got %d", code)
+	}
+	var base models.Page
+	doJSON(t, router, http.MethodGet, pagePath+"?user_id=u1", "", &base)
+	if base.Experiment != nil || len(base.Widgets) != 2 {
+		t.Fatalf("Expected the promoted variant to be the base content, got %+v", base)
+	}
+	for i, w := range base.Widgets {
+		if w.ID != served.Widgets[i].ID || w.ID != experiment.Variants[1].Widgets[i].ID {
+			t.Errorf("Expected widget %d to keep ID %s, got %s", i, served.Widgets[i].ID, w.ID)
+		}
+	}
+}
+
+// TestLocalization verifies that delivery serves localized names and configs by Accept-Language,
+// falling back through parent locales to the page default, and that all=true returns the raw content.
+func TestLocalization(t *testing.T) {
+	router := newTestRouter()
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodPost, "/pages", `{"name":"Sale","route":"/sale","names":{"not a tag":"x"}}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed locale, got %d", code)
+	}
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Sale","route":"/sale","names":{"es":"Oferta","ar":"تخفيضات","es_mx":"Ofertón"}}`, &page)
+	if page.DefaultLocale != "en" || page.Names["es-MX"] != "Ofertón" {
+		t.Fatalf("Expected normalized locales defaulting to en, got %q %v", page.DefaultLocale, page.Names)
+	}
+	pagePath := "/pages/" + page.ID.String()
+
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","locales":{"es":"Oferta"}}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a localized config that is not an object, got %d", code)
+	}
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","config":{"title":"Sale","image":"sale.png"},"locales":{"es":{"title":"Oferta"},"es-MX":{"image":"mx.png"},"ar":{"title":"تخفيضات"}}}`, nil)
+
+	get := func(path, acceptLanguage string) (*httptest.ResponseRecorder, models.Page) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var got models.Page
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", path, w.Code, w.Body.String())
+		}
+		return w, got
+	}
+
+	tests := []struct {
+		acceptLanguage string
+		locale         string
+		dir            string
+		name           string
+		config         string
+	}{
+		{"", "en", "ltr", "Sale", `{"image":"sale.png","title":"Sale"}`},
+		{"es-MX,es;q=0.9", "es-MX", "ltr", "Ofertón", `{"image":"mx.png","title":"Oferta"}`},
+		{"es-AR", "es", "ltr", "Oferta", `{"image":"sale.png","title":"Oferta"}`},
+		{"fr, ar-EG;q=0.5", "ar", "rtl", "تخفيضات", `{"image":"sale.png","title":"تخفيضات"}`},
+		{"de", "en", "ltr", "Sale", `{"image":"sale.png","title":"Sale"}`},
+	}
+	for _, tt := range tests {
+		w, got := get(pagePath, tt.acceptLanguage)
+		if got.Locale != tt.locale || got.Direction != tt.dir || got.Name != tt.name || w.Header().Get("Content-Language") != tt.locale {
+			t.Errorf("Accept-Language %q: expected %s %s %q, got %s %s %q (Content-Language %q)", tt.acceptLanguage, tt.locale, tt.dir, tt.name, got.Locale, got.Direction, got.Name, w.Header().Get("Content-Language"))
+		}
+		var config, want map[string]interface{}
+		json.Unmarshal([]byte(tt.config), &want)
+		if len(got.Widgets) == 1 {
+			json.Unmarshal(got.Widgets[0].Config, &config)
+		}
+		if len(got.Widgets) != 1 || !reflect.DeepEqual(config, want) || got.Widgets[0].Locales != nil || got.Names != nil {
+			t.Errorf("Accept-Language %q: expected config %s without raw locales, got %+v", tt.acceptLanguage, tt.config, got)
+		}
+	}
+
+	_, got := get(pagePath+"?locale=ar", "es")
+	if got.Locale != "ar" {
+		t.Errorf("Expected the locale parameter to override Accept-Language, got %q", got.Locale)
+	}
+	if code := doJSON(t, router, http.MethodGet, pagePath+"?locale=!!", "", &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed locale parameter, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, pagePath+"/widgets", nil)
+	req.Header.Set("Accept-Language", "ar")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var widgets struct {
+		Widgets []models.Widget
+		Locale  string
+		Dir     string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &widgets); err != nil || widgets.Locale != "ar" || widgets.Dir != "rtl" || len(widgets.Widgets) != 1 {
+		t.Errorf("Expected the widgets to be served in ar, got %d %s", w.Code, w.Body.String())
+	}
+
+	var list models.PageListResponse
+	doJSON(t, router, http.MethodGet, "/pages?locale=es", "", &list)
+	if len(list.Pages) != 1 || list.Pages[0].Name != "Oferta" {
+		t.Errorf("Expected listed pages to be named in es, got %+v", list.Pages)
+	}
+
+	_, raw := get("/manage"+pagePath+"?all=true", "es")
+	if raw.Locale != "" || raw.Name != "Sale" || len(raw.Names) != 3 || len(raw.Widgets[0].Locales) != 3 {
+		t.Errorf("Expected all=true to return the raw content, got %+v", raw)
+	}
+
+	var updated models.Page
+	if code := doJSON(t, router, http.MethodPut, pagePath, `{"default_locale":"es","names":{}}`, &updated); code != http.StatusOK || updated.DefaultLocale != "es" || updated.Names != nil {
+		t.Errorf("Expected the default locale to change and the names to be removed, got %d %+v", code, updated)
+	}
+}
+
+// TestTranslations verifies the XLIFF and PO exports of translatable strings, and that imports
+// write translations into localized content while reporting missing, stale and unknown keys.
+func TestTranslations(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Sale","route":"/sale"}`, &page)
+	pagePath := "/pages/" + page.ID.String()
+	var banner, text models.Widget
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","config":{"title":"Sale","image_url":"sale.png","button":{"label":"Shop"}},"locales":{"es":{"title":"Oferta"}}}`, &banner)
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"text","config":{"content":"Hello"}}`, &text)
+
+	send := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(http.MethodGet, "/translations/export", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 exporting without a target locale, got %d", w.Code)
+	}
+
+	w := send(http.MethodGet, "/translations/export?locale=es", "", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/xliff+xml") {
+		t.Fatalf("Expected an XLIFF export, got %d %v", w.Code, w.Header())
+	}
+	exported, err := i18n.ReadXLIFF(w.Body)
+	if err != nil {
+		t.Fatalf("ReadXLIFF: %v", err)
+	}
+	var keys []string
+	for _, u := range exported.Units {
+		keys = append(keys, u.ID+"="+u.Source+">"+u.Target)
+	}
+	wantKeys := []string{
+		models.PageNameKey(page.ID) + "=Sale>",
+		models.WidgetFieldKey(banner.ID, "button.label") + "=Shop>",
+		models.WidgetFieldKey(banner.ID, "title") + "=Sale>Oferta",
+		models.WidgetFieldKey(text.ID, "content") + "=Hello>",
+	}
+	if !reflect.DeepEqual(keys, wantKeys) || exported.SourceLang != "en" || exported.TargetLang != "es" {
+		t.Errorf("Expected units %v, got %v", wantKeys, keys)
+	}
+
+	w = send(http.MethodGet, "/translations/export?locale=es&format=po", "", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `msgctxt "`+models.WidgetFieldKey(banner.ID, "title")+`"`) {
+		t.Errorf("Expected a PO export, got %d %s", w.Code, w.Body.String())
+	}
+
+	po := "msgid \"\"\nmsgstr \"\"\n\"Language: es\\n\"\n\n" +
+		"msgctxt \"" + models.PageNameKey(page.ID) + "\"\nmsgid \"Sale\"\nmsgstr \"Ofertas\"\n\n" +
+		"msgctxt \"" + models.WidgetFieldKey(banner.ID, "button.label") + "\"\nmsgid \"Shop\"\nmsgstr \"Comprar\"\n\n" +
+		"msgctxt \"" + models.WidgetFieldKey(banner.ID, "title") + "\"\nmsgid \"Old title\"\nmsgstr \"Título viejo\"\n\n" +
+		"msgctxt \"" + models.WidgetFieldKey(uuid.New(), "title") + "\"\nmsgid \"Gone\"\nmsgstr \"Ido\"\n"
+
+	var report models.TranslationImportResponse
+	w = send(http.MethodPost, "/translations/import?dry_run=true", "text/x-gettext-translation", po)
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || w.Code != http.StatusOK || report.Applied || report.Imported != 2 {
+		t.Fatalf("Expected a dry run importing 2 translations, got %d %s", w.Code, w.Body.String())
+	}
+	if len(report.Stale) != 1 || report.Stale[0].CurrentSource != "Sale" || len(report.Unknown) != 1 ||
+		!reflect.DeepEqual(report.Missing, []string{models.WidgetFieldKey(text.ID, "content")}) {
+		t.Errorf("Expected one stale, one unknown and one missing key, got %+v", report)
+	}
+	var stored models.Page
+	doJSON(t, router, http.MethodGet, "/manage"+pagePath+"?all=true", "", &stored)
+	if stored.Names != nil {
+		t.Errorf("Expected a dry run not to write anything, got %v", stored.Names)
+	}
+
+	if w := send(http.MethodPost, "/translations/import?locale=ar", "", po); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 importing a file into another locale, got %d", w.Code)
+	}
+
+	w = send(http.MethodPost, "/translations/import", "", po)
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || w.Code != http.StatusOK || !report.Applied || report.AuditID == nil || report.Format != "po" {
+		t.Fatalf("Expected the import to be applied, got %d %s", w.Code, w.Body.String())
+	}
+	doJSON(t, router, http.MethodGet, pagePath+"?locale=es", "", &stored)
+	if stored.Name != "Ofertas" || len(stored.Widgets) != 2 {
+		t.Fatalf("Expected the page to be named in es, got %+v", stored)
+	}
+	var config map[string]interface{}
+	json.Unmarshal(stored.Widgets[0].Config, &config)
+	want := map[string]interface{}{"title": "Oferta", "image_url": "sale.png", "button": map[string]interface{}{"label": "Comprar"}}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Expected the stale title to be kept and the label translated, got %v", config)
+	}
+
+	exported.TargetLang = "ar"
+	for i := range exported.Units {
+		exported.Units[i].Target = "ar:" + exported.Units[i].Source
+	}
+	var buf bytes.Buffer
+	i18n.WriteXLIFF(&buf, *exported)
+	w = send(http.MethodPost, "/translations/import", "application/xliff+xml", buf.String())
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || report.Imported != 4 || len(report.Missing) != 0 {
+		t.Errorf("Expected all 4 XLIFF translations to be imported, got %d %s", w.Code, w.Body.String())
+	}
+	doJSON(t, router, http.MethodGet, pagePath+"?locale=ar", "", &stored)
+	if stored.Name != "ar:Sale" || stored.Direction != "rtl" {
+		t.Errorf("Expected the page to be named in ar, got %+v", stored)
+	}
+
+	if w := send(http.MethodPost, "/translations/import", "application/xliff+xml", "<xliff"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed file, got %d", w.Code)
+	}
+}
+
+// TestConfigVariants verifies that widget configs with invalid conditional variants are rejected,
+// and that delivery flattens them for the client's theme, platform and form factor.
+func TestConfigVariants(t *testing.T) {
+	router := newTestRouter()
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home"}`, &page)
+	pagePath := "/pages/" + page.ID.String()
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","config":{"$variants":{"tier=gold":{}}}}`, &errResp); code != http.StatusBadRequest || len(errResp.Error.Errors) != 1 || errResp.Error.Errors[0].Field != "config" {
+		t.Errorf("Expected 400 with a config error for an unknown condition, got %d %+v", code, errResp)
+	}
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","locales":{"es":{"$variants":{"theme=sepia":{}}}}}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid variants of a locale, got %d", code)
+	}
+
+	var widget models.Widget
+	doJSON(t, router, http.MethodPost, pagePath+"/widgets", `{"type":"banner","config":{"color":"white","columns":2,"$variants":{"theme=dark":{"color":"black"},"form_factor=tablet&platform=android":{"columns":3}}}}`, &widget)
+	if code := doJSON(t, router, http.MethodPut, "/widgets/"+widget.ID.String(), `{"config":{"$variants":{"theme=dark":1}}}`, &errResp); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 updating a config with a variant that is not an object, got %d", code)
+	}
+
+	config := func(got json.RawMessage) map[string]interface{} {
+		var m map[string]interface{}
+		json.Unmarshal(got, &m)
+		return m
+	}
+
+	req := httptest.NewRequest(http.MethodGet, pagePath, nil)
+	req.Header.Set("X-Client-Theme", "Dark")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var delivered models.Page
+	if err := json.Unmarshal(w.Body.Bytes(), &delivered); err != nil || len(delivered.Widgets) != 1 {
+		t.Fatalf("GET %s: %d %s", pagePath, w.Code, w.Body.String())
+	}
+	if got, want := config(delivered.Widgets[0].Config), map[string]interface{}{"color": "black", "columns": 2.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the dark variant, got %v", got)
+	}
+
+	var widgets struct{ Widgets []models.Widget }
+	doJSON(t, router, http.MethodGet, pagePath+"/widgets?theme=dark&platform=android&form_factor=tablet", "", &widgets)
+	if len(widgets.Widgets) != 1 {
+		t.Fatalf("Expected one widget, got %+v", widgets)
+	}
+	if got, want := config(widgets.Widgets[0].Config), map[string]interface{}{"color": "black", "columns": 3.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected both variants merged, got %v", got)
+	}
+
+	var raw models.Page
+	doJSON(t, router, http.MethodGet, "/manage"+pagePath+"?all=true&theme=dark", "", &raw)
+	if got := config(raw.Widgets[0].Config); got["color"] != "white" || got[models.ConfigVariantsKey] == nil {
+		t.Errorf("Expected all=true to return the raw variants, got %v", got)
+	}
+}
+
+// TestTheme verifies theme validation, that widget configs may only reference tokens the theme
+// defines, that delivery resolves them for the client's theme, and that the theme cannot drop
+// tokens still referenced.
+func TestTheme(t *testing.T) {
+	router := newTestRouter()
+
+	var errResp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodGet, "/theme", "", &errResp); code != http.StatusNotFound {
+		t.Errorf("Expected 404 before a theme is saved, got %d", code)
+	}
+	if code := doJSON(t, router, http.MethodPut, "/theme", `{"colors":{"primary":"blue","text":"#777","background":"#888"},"contrast":[{"text":"text","background":"background"}]}`, &errResp); code != http.StatusBadRequest || len(errResp.Error.Errors) != 1 || errResp.Error.Errors[0].Field != "colors.primary" {
+		t.Errorf("Expected 400 for a color that is not hex, got %d %+v", code, errResp)
+	}
+	if code := doJSON(t, router, http.MethodPut, "/theme", `{"colors":{"text":"#777","background":"#888"},"contrast":[{"text":"text","background":"background"}]}`, &errResp); code != http.StatusBadRequest || len(errResp.Error.Errors) != 1 || errResp.Error.Errors[0].Code != "contrast" {
+		t.Errorf("Expected 400 for a low contrast pair, got %d %+v", code, errResp)
+	}
+
+	var page models.Page
+	doJSON(t, router, http.MethodPost, "/pages", `{"name":"Home","route":"/home"}`, &page)
+	pagePath := "/pages/" + page.ID.String()
+	banner := `{"type":"banner","config":{"color":"$color.primary","background":"$color.background","padding":"$spacing.md","title":"$5 off"}}`
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/widgets", banner, &errResp); code != http.StatusBadRequest || len(errResp.Error.Errors) != 1 || errResp.Error.Errors[0].Field != "config" {
+		t.Errorf("Expected 400 referencing tokens before a theme is saved, got %d %+v", code, errResp)
+	}
+
+	theme := `{"colors":{"primary":"#0055FF","background":"#fff","text":"#111111"},"dark_colors":{"background":"#000000","text":"#eeeeee"},` +
+		`"typography":{"title":{"font_family":"Inter","font_size":24,"font_weight":700}},"radii":{"sm":4},"spacing":{"md":16},` +
+		`"contrast":[{"text":"text","background":"background"}]}`
+	var saved models.Theme
+	if code := doJSON(t, router, http.MethodPut, "/theme", theme, &saved); code != http.StatusOK || saved.Colors["primary"] != "#0055ff" || saved.Colors["background"] != "#ffffff" || saved.UpdatedAt.IsZero() {
+		t.Fatalf("Expected 200 with normalized colors, got %d %+v", code, saved)
+	}
+	var got models.Theme
+	if code := doJSON(t, router, http.MethodGet, "/theme", "", &got); code != http.StatusOK || got.DarkColors["background"] != "#000000" {
+		t.Errorf("Expected the saved theme, got %d %+v", code, got)
+	}
+
+	var widget models.Widget
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/widgets", banner, &widget); code != http.StatusCreated {
+		t.Fatalf("Expected 201 referencing defined tokens, got %d", code)
+	}
+	if code := doJSON(t, router, http.MethodPut, "/widgets/"+widget.ID.String(), `{"locales":{"es":{"radius":"$radius.xl"}}}`, &errResp); code != http.StatusBadRequest || errResp.Error.Errors[0].Field != "locales" {
+		t.Errorf("Expected 400 for a localized config referencing an unknown token, got %d %+v", code, errResp)
+	}
+	unknown := `{"name":"Colors","variants":[{"key":"a","weight":1},{"key":"b","weight":1,"overrides":{"` + widget.ID.String() + `":{"color":"$color.accent"}}}]}`
+	if code := doJSON(t, router, http.MethodPost, pagePath+"/experiments", unknown, &errResp); code != http.StatusBadRequest || errResp.Error.Errors[0].Field != "variants[1]" {
+		t.Errorf("Expected 400 for an experiment variant referencing an unknown token, got %d %+v", code, errResp)
+	}
+
+	config := func(got json.RawMessage) map[string]interface{} {
+		var m map[string]interface{}
+		json.Unmarshal(got, &m)
+		return m
+	}
+	var delivered models.Page
+	doJSON(t, router, http.MethodGet, pagePath, "", &delivered)
+	if got, want := config(delivered.Widgets[0].Config), map[string]interface{}{"color": "#0055ff", "background": "#ffffff", "padding": 16.0, "title": "$5 off"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the tokens resolved with the light palette, got %v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, pagePath+"/widgets", nil)
+	req.Header.Set("X-Client-Theme", "dark")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var widgets struct{ Widgets []models.Widget }
+	if err := json.Unmarshal(w.Body.Bytes(), &widgets); err != nil || len(widgets.Widgets) != 1 {
+		t.Fatalf("GET widgets: %d %s", w.Code, w.Body.String())
+	}
+	if got := config(widgets.Widgets[0].Config); got["background"] != "#000000" || got["color"] != "#0055ff" {
+		t.Errorf("Expected the tokens resolved with the dark palette, got %v", got)
+	}
+
+	var raw models.Page
+	doJSON(t, router, http.MethodGet, "/manage"+pagePath+"?all=true", "", &raw)
+	if got := config(raw.Widgets[0].Config); got["color"] != "$color.primary" {
+		t.Errorf("Expected all=true to return the raw references, got %v", got)
+	}
+
+	if code := doJSON(t, router, http.MethodPut, "/theme", `{"colors":{"background":"#fff"},"spacing":{"md":16}}`, &errResp); code != http.StatusConflict || !strings.Contains(errResp.Error.Message, "$color.primary") {
+		t.Errorf("Expected 409 removing a referenced token, got %d %+v", code, errResp)
+	}
+}
+
+// TestExpiredDeadline verifies that a request whose deadline passes before its queries run fails with a 504.
+func TestExpiredDeadline(t *testing.T) {
+	router := newTestRouter(middleware.Deadline(time.Nanosecond))
+
+	var resp models.ErrorResponse
+	if code := doJSON(t, router, http.MethodGet, "/pages/"+uuid.New().String(), "", &resp); code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected 504, got %d", code)
+	}
+	if resp.Error.Code != models.ErrorCodeTimeout {
+		t.Errorf("Expected error code %s, got %s", models.ErrorCodeTimeout, resp.Error.Code)
+	}
+}
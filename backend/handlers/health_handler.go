@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"appdrop/database"
+	"appdrop/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckResult reports the outcome and latency of a single readiness sub-check.
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthHandler orchestrates HTTP request processing for liveness and readiness probes.
+type HealthHandler struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewHealthHandler initializes and returns a new instance of HealthHandler.
+// The timeout bounds every readiness sub-check so a hung database cannot stall the probe.
+func NewHealthHandler(db *sql.DB, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{db: db, timeout: timeout}
+}
+
+// Livez processes liveness probes. It succeeds whenever the process is able to serve HTTP.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"service": "Mini App Config API",
+		"build":   version.Get(),
+	})
+}
+
+// Readyz processes readiness probes by pinging the database and verifying the schema version.
+// It responds with 503 when any sub-check fails so load balancers stop routing traffic to the instance.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	checks := []CheckResult{
+		h.run(c.Request.Context(), "database", h.checkDatabase),
+		h.run(c.Request.Context(), "schema", h.checkSchema),
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, check := range checks {
+		if check.Status != "ok" {
+			status, code = "not_ready", http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	c.JSON(code, gin.H{
+		"status":  status,
+		"service": "Mini App Config API",
+		"build":   version.Get(),
+		"checks":  checks,
+	})
+}
+
+// run executes a sub-check under the configured timeout and records its latency.
+func (h *HealthHandler) run(parent context.Context, name string, check func(context.Context) error) CheckResult {
+	ctx, cancel := context.WithTimeout(parent, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Name:      name,
+		Status:    "ok",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkDatabase verifies that a connection to the database can be established.
+func (h *HealthHandler) checkDatabase(ctx context.Context) error {
+	return h.db.PingContext(ctx)
+}
+
+// checkSchema verifies that the applied migration version matches the version this binary was built for.
+func (h *HealthHandler) checkSchema(ctx context.Context) error {
+	current, err := database.CurrentSchemaVersion(ctx, h.db)
+	if err != nil {
+		return err
+	}
+	if expected := database.SchemaVersion(); current != expected {
+		return fmt.Errorf("schema version %d does not match expected version %d", current, expected)
+	}
+	return nil
+}
@@ -3,36 +3,62 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"appdrop/models"
 	"appdrop/repository"
+	"appdrop/response"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// Pagination bounds the page sizes accepted by list endpoints.
+type Pagination struct {
+	DefaultPerPage int
+	MaxPerPage     int
+}
+
 // PageHandler orchestrates HTTP request processing for Page-related resources.
+// Reads use the stores directly; multi-step changes run through the unit of work.
 type PageHandler struct {
-	pageRepo   *repository.PageRepository
-	widgetRepo *repository.WidgetRepository
+	pageRepo       repository.PageStore
+	widgetRepo     repository.WidgetStore
+	segmentRepo    repository.SegmentStore
+	experimentRepo repository.ExperimentStore
+	themeRepo      repository.ThemeStore
+	uow            repository.UnitOfWork
+	pagination     Pagination
 }
 
 // NewPageHandler initializes and returns a new instance of PageHandler with its required dependencies.
-func NewPageHandler(pageRepo *repository.PageRepository, widgetRepo *repository.WidgetRepository) *PageHandler {
+func NewPageHandler(pageRepo repository.PageStore, widgetRepo repository.WidgetStore, segmentRepo repository.SegmentStore, experimentRepo repository.ExperimentStore, themeRepo repository.ThemeStore, uow repository.UnitOfWork, pagination Pagination) *PageHandler {
 	return &PageHandler{
-		pageRepo:   pageRepo,
-		widgetRepo: widgetRepo,
+		pageRepo:       pageRepo,
+		widgetRepo:     widgetRepo,
+		segmentRepo:    segmentRepo,
+		experimentRepo: experimentRepo,
+		themeRepo:      themeRepo,
+		uow:            uow,
+		pagination:     pagination,
 	}
 }
 
-// ListPages processes requests to retrieve a paginated collection of all available pages.
+// ListPages processes requests to retrieve a sorted, filtered and paginated collection of pages.
+// Pages are numbered with page and per_page by default; sending a cursor parameter, empty for the
+// first request, switches to keyset pagination, which stays stable while pages are added or removed.
+// Pages the client does not see, by their schedule, rule or segment, are left out before paginating,
+// so that slices are full and the total counts the pages delivered, and the others are named in the
+// client's locale, unless all=true is passed.
 func (h *PageHandler) ListPages(c *gin.Context) {
 	page := 1
-	perPage := 10
+	perPage := h.pagination.DefaultPerPage
 
 	if p := c.Query("page"); p != "" {
 		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
@@ -40,97 +66,404 @@ func (h *PageHandler) ListPages(c *gin.Context) {
 		}
 	}
 	if pp := c.Query("per_page"); pp != "" {
-		if parsed, err := strconv.Atoi(pp); err == nil && parsed > 0 && parsed <= 100 {
+		if parsed, err := strconv.Atoi(pp); err == nil && parsed > 0 && parsed <= h.pagination.MaxPerPage {
 			perPage = parsed
 		}
 	}
 
-	pages, total, err := h.pageRepo.GetAll(page, perPage)
+	q, err := parsePageQuery(c)
+	if err != nil {
+		writeError(c, err, "Failed to fetch pages")
+		return
+	}
+	q.Limit = perPage
+
+	aud, err := deliveryAudience(c)
+	if err != nil {
+		writeError(c, err, "Failed to fetch pages")
+		return
+	}
+
+	cursor, keyset := c.GetQuery("cursor")
+	if keyset {
+		if cursor != "" {
+			if q.After, err = repository.DecodePageCursor(cursor); err != nil || q.After.Sort != q.Sort || q.After.Descending != q.Descending {
+				response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("cursor", "cursor", "Invalid cursor for this sort order"))
+				return
+			}
+		}
+	} else {
+		q.Offset = (page - 1) * perPage
+		q.CountTotal = true
+	}
+
+	var list visiblePages
+	if aud.filtered {
+		list, err = h.listVisible(c.Request.Context(), q, &aud, q.Offset, perPage, !keyset)
+	} else {
+		var stored repository.PageList
+		stored, err = h.pageRepo.List(c.Request.Context(), q)
+		list = visiblePages{pages: stored.Pages, total: stored.Total, next: stored.Next}
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch pages"))
+		serverError(c, err, "Failed to fetch pages")
 		return
 	}
 
-	if pages == nil {
-		pages = []models.Page{}
+	pages := make([]models.Page, 0, len(list.pages))
+	for _, p := range list.pages {
+		if err := aud.localize(&p, nil); err != nil {
+			serverError(c, err, "Failed to fetch pages")
+			return
+		}
+		pages = append(pages, p)
+	}
+
+	if keyset {
+		resp := models.PageCursorResponse{Pages: pages, PerPage: perPage}
+		if list.next != nil {
+			resp.NextCursor = list.next.Encode()
+		}
+		c.JSON(http.StatusOK, resp)
+		return
 	}
 
-	totalPages := (total + perPage - 1) / perPage
+	totalPages := (list.total + perPage - 1) / perPage
 
 	c.JSON(http.StatusOK, models.PageListResponse{
 		Pages:      pages,
-		Total:      total,
+		Total:      list.total,
 		Page:       page,
 		PerPage:    perPage,
 		TotalPages: totalPages,
 	})
 }
 
+// visiblePageBatch is the number of pages read at a time while filling a slice of a listing with
+// the pages a filtered audience sees.
+const visiblePageBatch = 100
+
+// visiblePages is one slice of a page listing as delivered to an audience. Total counts the pages
+// the audience sees in the whole listing, when asked for, and Next resumes the listing after the
+// slice, or is nil when the audience sees no further page.
+type visiblePages struct {
+	pages []models.Page
+	total int
+	next  *repository.PageCursor
+}
+
+// listVisible reads the listing q in batches and keeps the pages aud sees, skipping the first skip
+// of them and collecting up to limit, so that schedules, rules and segments are applied before
+// paginating. With count set, the whole listing is read to count the visible pages; otherwise
+// reading stops once it is known whether a visible page follows the slice.
+func (h *PageHandler) listVisible(ctx context.Context, q repository.PageQuery, aud *audience, skip, limit int, count bool) (visiblePages, error) {
+	var list visiblePages
+	q.Offset, q.CountTotal, q.Limit = 0, false, visiblePageBatch
+
+	var lastBatch repository.PageQuery
+	lastIndex, more := -1, false
+	for {
+		batch, err := h.pageRepo.List(ctx, q)
+		if err != nil {
+			return list, err
+		}
+		segmentIDs := make([]*uuid.UUID, len(batch.Pages))
+		for i := range batch.Pages {
+			segmentIDs[i] = batch.Pages[i].SegmentID
+		}
+		if err := aud.resolveSegments(ctx, h.segmentRepo, segmentIDs...); err != nil {
+			return list, err
+		}
+
+		for i, p := range batch.Pages {
+			if !aud.sees(p.Schedule, p.Rule, p.SegmentID) {
+				continue
+			}
+			list.total++
+			switch {
+			case list.total <= skip:
+			case len(list.pages) < limit:
+				list.pages = append(list.pages, p)
+				lastBatch, lastIndex = q, i
+			default:
+				more = true
+			}
+			if more && !count {
+				break
+			}
+		}
+		if batch.Next == nil || (more && !count) {
+			break
+		}
+		q.After = batch.Next
+	}
+
+	if more {
+		// The cursor of the last page collected is read by listing its batch up to that page.
+		lastBatch.Limit = lastIndex + 1
+		upTo, err := h.pageRepo.List(ctx, lastBatch)
+		if err != nil {
+			return list, err
+		}
+		list.next = upTo.Next
+	}
+	return list, nil
+}
+
+// parsePageQuery reads the sort and filter parameters of a page listing. The sort parameter names
+// a column, prefixed with "-" for descending order; without it pages are listed newest first.
+func parsePageQuery(c *gin.Context) (repository.PageQuery, error) {
+	q := repository.PageQuery{Sort: repository.PageSortCreatedAt, Descending: true}
+
+	if s := c.Query("sort"); s != "" {
+		q.Sort = repository.PageSort(strings.TrimPrefix(s, "-"))
+		q.Descending = strings.HasPrefix(s, "-")
+		if !q.Sort.IsValid() {
+			return q, fail(http.StatusBadRequest, models.NewFieldValidationError("sort", "oneof", "Invalid sort. Must be one of: created_at, updated_at, name, route, widget_count, optionally prefixed with -"))
+		}
+	}
+
+	if v := c.Query("is_home"); v != "" {
+		isHome, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fail(http.StatusBadRequest, models.NewFieldValidationError("is_home", "boolean", "is_home must be true or false"))
+		}
+		q.IsHome = &isHome
+	}
+
+	q.NamePrefix = c.Query("name_prefix")
+	q.RoutePrefix = c.Query("route_prefix")
+
+	if v := c.Query("updated_since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fail(http.StatusBadRequest, models.NewFieldValidationError("updated_since", "datetime", "updated_since must be an RFC 3339 timestamp"))
+		}
+		q.UpdatedSince = since
+	}
+
+	if t := c.Query("widget_type"); t != "" {
+		if !models.IsValidWidgetType(t) {
+			return q, fail(http.StatusBadRequest, models.NewFieldValidationError("widget_type", "oneof", "Invalid widget type filter"))
+		}
+		q.WidgetType = t
+	}
+
+	return q, nil
+}
+
 // GetPage processes requests to retrieve the detailed state of a specific page, including its widgets.
+// A page outside its schedule or audience is reported as not found, and only the widgets delivered to
+// the client are returned, unless all=true is passed. When an experiment runs on the page, the client
+// gets the widgets of its assigned variant, named in the experiment field and headers. Content is
+// served in the locale picked from the locale parameter, the Accept-Language header and the page's
+// default locale, named in the locale field and the Content-Language header, and widget configs are
+// flattened by merging in the conditional variants matching the client's theme, platform and form factor,
+// with their design token references replaced by the values of the app theme.
 func (h *PageHandler) GetPage(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
+		return
+	}
+
+	aud, err := deliveryAudience(c)
+	if err != nil {
+		writeError(c, err, "Failed to fetch page")
 		return
 	}
 
-	page, err := h.pageRepo.GetByIDWithWidgets(id)
+	page, err := h.pageRepo.GetByIDWithWidgets(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch page"))
+		serverError(c, err, "Failed to fetch page")
 		return
 	}
 	if page == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		return
+	}
+	if page.Widgets, page.Experiment, err = aud.serveExperiment(c.Request.Context(), h.experimentRepo, page.ID, page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if err := aud.resolveSegments(c.Request.Context(), h.segmentRepo, append(widgetSegments(page.Widgets), page.SegmentID)...); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if !aud.sees(page.Schedule, page.Rule, page.SegmentID) {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		return
+	}
+	page.Widgets = aud.visibleWidgets(page.Widgets)
+	if err := aud.localize(page, page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if err := aud.resolveConfigs(page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if err := aud.resolveTokens(c.Request.Context(), h.themeRepo, page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
 		return
 	}
 
+	reportLocale(c, page)
+	reportExperiment(c, page.Experiment)
 	c.JSON(http.StatusOK, page)
 }
 
+// PreviewPage processes requests to show what of a page is delivered to a simulated client: at the
+// time given by the at parameter, an RFC 3339 timestamp defaulting to now, and with the targeting
+// attributes, including the user_id checked against segment lists, given as headers or query
+// parameters like on delivery requests. The experiment variant assigned to the user or device ID and
+// the locale picked for the client are applied as on delivery, and so are the conditional variants of
+// widget configs and their design tokens. Unlike GetPage it also answers for a page that is not delivered,
+// reporting live as false and why.
+func (h *PageHandler) PreviewPage(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
+		return
+	}
+
+	aud := audience{at: time.Now(), client: clientContext(c), filtered: true}
+	aud.unit = experimentUnit(c, aud.client)
+	if aud.locales, err = preferredLocales(c); err != nil {
+		writeError(c, err, "Failed to fetch page")
+		return
+	}
+	if v := c.Query("at"); v != "" {
+		if aud.at, err = time.Parse(time.RFC3339, v); err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("at", "datetime", "at must be an RFC 3339 timestamp"))
+			return
+		}
+	}
+
+	page, err := h.pageRepo.GetByIDWithWidgets(c.Request.Context(), id)
+	if err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if page == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		return
+	}
+	if page.Widgets, page.Experiment, err = aud.serveExperiment(c.Request.Context(), h.experimentRepo, page.ID, page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if err := aud.resolveSegments(c.Request.Context(), h.segmentRepo, append(widgetSegments(page.Widgets), page.SegmentID)...); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+
+	if err := aud.localize(page, page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if err := aud.resolveConfigs(page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+	if err := aud.resolveTokens(c.Request.Context(), h.themeRepo, page.Widgets); err != nil {
+		serverError(c, err, "Failed to fetch page")
+		return
+	}
+
+	preview := models.PagePreview{At: aud.at, Context: aud.client, Page: page, Hidden: []models.HiddenWidget{}}
+	preview.Reason = aud.hiddenReason(page.Schedule, page.Rule, page.SegmentID)
+	preview.Live = preview.Reason == ""
+
+	visible := make([]models.Widget, 0, len(page.Widgets))
+	for _, w := range page.Widgets {
+		if reason := aud.hiddenReason(w.Schedule, w.Rule, w.SegmentID); reason != "" {
+			preview.Hidden = append(preview.Hidden, models.HiddenWidget{Widget: w, Reason: reason})
+			continue
+		}
+		visible = append(visible, w)
+	}
+	page.Widgets = visible
+
+	c.JSON(http.StatusOK, preview)
+}
+
 // CreatePage processes requests to instantiate and persist a new page configuration.
+// The route check, home page hand-over and insert run as one unit of work.
 func (h *PageHandler) CreatePage(c *gin.Context) {
 	var req models.CreatePageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid request body: "+err.Error()))
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
 		return
 	}
 
 	if strings.TrimSpace(req.Name) == "" {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Page name is required and cannot be empty"))
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("name", "required", "Page name is required and cannot be empty"))
 		return
 	}
 
 	if strings.TrimSpace(req.Route) == "" {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Page route is required and cannot be empty"))
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("route", "required", "Page route is required and cannot be empty"))
 		return
 	}
 
-	exists, err := h.pageRepo.CheckRouteExists(req.Route, nil)
+	rule, err := storedRule(req.Rule)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to check route"))
-		return
-	}
-	if exists {
-		c.JSON(http.StatusConflict, models.NewConflictError("Page route already exists"))
+		writeError(c, err, "Failed to create page")
 		return
 	}
 
-	if req.IsHome {
-		if err := h.pageRepo.UnsetHomePage(); err != nil {
-			c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to update home page"))
+	page := &models.Page{
+		Name:          strings.TrimSpace(req.Name),
+		Route:         strings.TrimSpace(req.Route),
+		IsHome:        req.IsHome,
+		Schedule:      storedSchedule(req.Schedule),
+		Rule:          rule,
+		DefaultLocale: models.DefaultLocale,
+	}
+	if req.DefaultLocale != "" {
+		if page.DefaultLocale, err = storedLocale("default_locale", req.DefaultLocale); err != nil {
+			writeError(c, err, "Failed to create page")
 			return
 		}
 	}
-
-	page := &models.Page{
-		Name:   strings.TrimSpace(req.Name),
-		Route:  strings.TrimSpace(req.Route),
-		IsHome: req.IsHome,
+	if page.Names, err = storedNames(req.Names); err != nil {
+		writeError(c, err, "Failed to create page")
+		return
 	}
 
-	if err := h.pageRepo.Create(page); err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to create page"))
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		segmentID, err := storedSegment(ctx, tx, req.SegmentID)
+		if err != nil {
+			return err
+		}
+		page.SegmentID = segmentID
+
+		exists, err := tx.Pages.CheckRouteExists(ctx, page.Route, nil)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errRouteConflict
+		}
+
+		if page.IsHome {
+			if err := tx.Pages.UnsetHomePage(ctx); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Pages.Create(ctx, page); err != nil {
+			if errors.Is(err, repository.ErrRouteConflict) {
+				return errRouteConflict
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(c, err, "Failed to create page")
 		return
 	}
 
@@ -138,27 +471,18 @@ func (h *PageHandler) CreatePage(c *gin.Context) {
 }
 
 // UpdatePage processes requests to modify the attributes of an existing page.
+// The lookup, route check, home page hand-over and update run as one unit of work.
 func (h *PageHandler) UpdatePage(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
-		return
-	}
-
-	existingPage, err := h.pageRepo.GetByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch page"))
-		return
-	}
-	if existingPage == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
 		return
 	}
 
 	var req models.UpdatePageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid request body: "+err.Error()))
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
 		return
 	}
 
@@ -166,7 +490,7 @@ func (h *PageHandler) UpdatePage(c *gin.Context) {
 
 	if req.Name != nil {
 		if strings.TrimSpace(*req.Name) == "" {
-			c.JSON(http.StatusBadRequest, models.NewValidationError("Page name cannot be empty"))
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("name", "required", "Page name cannot be empty"))
 			return
 		}
 		updates["name"] = strings.TrimSpace(*req.Name)
@@ -174,39 +498,92 @@ func (h *PageHandler) UpdatePage(c *gin.Context) {
 
 	if req.Route != nil {
 		if strings.TrimSpace(*req.Route) == "" {
-			c.JSON(http.StatusBadRequest, models.NewValidationError("Page route cannot be empty"))
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("route", "required", "Page route cannot be empty"))
+			return
+		}
+		updates["route"] = strings.TrimSpace(*req.Route)
+	}
+
+	if req.IsHome != nil {
+		updates["is_home"] = *req.IsHome
+	}
+
+	if req.Schedule != nil {
+		updates["schedule"] = storedSchedule(req.Schedule)
+	}
+
+	if req.Rule != nil {
+		rule, err := storedRule(*req.Rule)
+		if err != nil {
+			writeError(c, err, "Failed to update page")
 			return
 		}
-		exists, err := h.pageRepo.CheckRouteExists(*req.Route, &id)
+		updates["rule"] = rule
+	}
+
+	if req.DefaultLocale != nil {
+		locale, err := storedLocale("default_locale", *req.DefaultLocale)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to check route"))
+			writeError(c, err, "Failed to update page")
 			return
 		}
-		if exists {
-			c.JSON(http.StatusConflict, models.NewConflictError("Page route already exists"))
+		updates["default_locale"] = locale
+	}
+
+	if req.Names != nil {
+		names, err := storedNames(req.Names)
+		if err != nil {
+			writeError(c, err, "Failed to update page")
 			return
 		}
-		updates["route"] = strings.TrimSpace(*req.Route)
+		updates["names"] = names
 	}
 
-	if req.IsHome != nil {
-		if *req.IsHome && !existingPage.IsHome {
-			if err := h.pageRepo.UnsetHomePage(); err != nil {
-				c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to update home page"))
-				return
+	var page *models.Page
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		existingPage, err := tx.Pages.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existingPage == nil {
+			return errPageNotFound
+		}
+
+		if req.SegmentID != nil {
+			if updates["segment_id"], err = storedSegment(ctx, tx, *req.SegmentID); err != nil {
+				return err
 			}
 		}
-		updates["is_home"] = *req.IsHome
-	}
 
-	if len(updates) == 0 {
-		c.JSON(http.StatusOK, existingPage)
-		return
-	}
+		if route, ok := updates["route"].(string); ok {
+			exists, err := tx.Pages.CheckRouteExists(ctx, route, &id)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return errRouteConflict
+			}
+		}
+
+		if req.IsHome != nil && *req.IsHome && !existingPage.IsHome {
+			if err := tx.Pages.UnsetHomePage(ctx); err != nil {
+				return err
+			}
+		}
+
+		if len(updates) == 0 {
+			page = existingPage
+			return nil
+		}
 
-	page, err := h.pageRepo.Update(id, updates)
+		page, err = tx.Pages.Update(ctx, id, updates)
+		if errors.Is(err, repository.ErrRouteConflict) {
+			return errRouteConflict
+		}
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to update page"))
+		writeError(c, err, "Failed to update page")
 		return
 	}
 
@@ -214,35 +591,38 @@ func (h *PageHandler) UpdatePage(c *gin.Context) {
 }
 
 // DeletePage processes requests to remove a page and its associated configurations from the system.
+// The home page check and the delete run as one unit of work.
 func (h *PageHandler) DeletePage(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
 		return
 	}
 
-	page, err := h.pageRepo.GetByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch page"))
-		return
-	}
-	if page == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Page not found"))
-		return
-	}
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		page, err := tx.Pages.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if page == nil {
+			return errPageNotFound
+		}
 
-	if page.IsHome {
-		c.JSON(http.StatusConflict, models.NewConflictError("Cannot delete the home page. Set another page as home first."))
-		return
-	}
+		if page.IsHome {
+			return fail(http.StatusConflict, models.NewConflictError("Cannot delete the home page. Set another page as home first."))
+		}
 
-	if err := h.pageRepo.Delete(id); err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, models.NewNotFoundError("Page not found"))
-			return
+		if err := tx.Pages.Delete(ctx, id); err != nil {
+			if err == sql.ErrNoRows {
+				return errPageNotFound
+			}
+			return err
 		}
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to delete page"))
+		return nil
+	})
+	if err != nil {
+		writeError(c, err, "Failed to delete page")
 		return
 	}
 
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchQueryLength caps the length of a search query, in characters.
+const maxSearchQueryLength = 200
+
+// SearchHandler orchestrates HTTP request processing for free-text search across pages and widgets.
+type SearchHandler struct {
+	searchRepo repository.SearchStore
+	pagination Pagination
+}
+
+// NewSearchHandler initializes and returns a new instance of SearchHandler with its required dependencies.
+func NewSearchHandler(searchRepo repository.SearchStore, pagination Pagination) *SearchHandler {
+	return &SearchHandler{searchRepo: searchRepo, pagination: pagination}
+}
+
+// Search processes requests to find pages by name or route and widgets by the text in their config.
+// Results are ranked best first; limit bounds their number like per_page does for page listings.
+func (h *SearchHandler) Search(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("q", "required", "Search query is required"))
+		return
+	}
+	if utf8.RuneCountInString(text) > maxSearchQueryLength {
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("q", "max", "Search query must be at most 200 characters"))
+		return
+	}
+
+	limit := h.pagination.DefaultPerPage
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= h.pagination.MaxPerPage {
+			limit = parsed
+		}
+	}
+
+	hits, err := h.searchRepo.Search(c.Request.Context(), repository.SearchQuery{Text: text, Limit: limit})
+	if err != nil {
+		serverError(c, err, "Failed to search")
+		return
+	}
+
+	if hits == nil {
+		hits = []models.SearchHit{}
+	}
+
+	c.JSON(http.StatusOK, models.SearchResponse{
+		Query:   text,
+		Results: hits,
+		Total:   len(hits),
+	})
+}
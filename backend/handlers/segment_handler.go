@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/response"
+	"appdrop/targeting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Limits on uploaded segment user lists.
+const (
+	maxSegmentUsers  = 1000000
+	maxSegmentUserID = 255
+)
+
+// errSegmentNotFound reports a segment that does not exist.
+var errSegmentNotFound = fail(http.StatusNotFound, models.NewNotFoundError("Segment not found"))
+
+// SegmentHandler orchestrates HTTP request processing for audience segments.
+type SegmentHandler struct {
+	segmentRepo repository.SegmentStore
+	widgetRepo  repository.WidgetStore
+	uow         repository.UnitOfWork
+	pagination  Pagination
+}
+
+// NewSegmentHandler initializes and returns a new instance of SegmentHandler with its required dependencies.
+func NewSegmentHandler(segmentRepo repository.SegmentStore, widgetRepo repository.WidgetStore, uow repository.UnitOfWork, pagination Pagination) *SegmentHandler {
+	return &SegmentHandler{
+		segmentRepo: segmentRepo,
+		widgetRepo:  widgetRepo,
+		uow:         uow,
+		pagination:  pagination,
+	}
+}
+
+// ListSegments processes requests to list every segment, ordered by name.
+func (h *SegmentHandler) ListSegments(c *gin.Context) {
+	segments, err := h.segmentRepo.List(c.Request.Context())
+	if err != nil {
+		serverError(c, err, "Failed to fetch segments")
+		return
+	}
+	if segments == nil {
+		segments = []models.Segment{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"segments": segments,
+		"total":    len(segments),
+	})
+}
+
+// GetSegment processes requests to retrieve a single segment.
+func (h *SegmentHandler) GetSegment(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	segment, err := h.segmentRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		serverError(c, err, "Failed to fetch segment")
+		return
+	}
+	if segment == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Segment not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// CreateSegment processes requests to create a segment from a rule, a list of user IDs, or both.
+// The segment and its user list are stored as one unit of work.
+func (h *SegmentHandler) CreateSegment(c *gin.Context) {
+	var req models.CreateSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+		return
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("name", "required", "Segment name is required and cannot be empty"))
+		return
+	}
+	rule, err := storedRule(req.Rule)
+	if err != nil {
+		writeError(c, err, "Failed to create segment")
+		return
+	}
+	userIDs, err := cleanUserIDs(req.UserIDs)
+	if err != nil {
+		writeError(c, err, "Failed to create segment")
+		return
+	}
+	if rule == "" && len(userIDs) == 0 {
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("rule", "required_without", "A segment needs a rule, a list of user IDs, or both"))
+		return
+	}
+
+	segment := &models.Segment{Name: strings.TrimSpace(req.Name), Rule: rule}
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		if err := tx.Segments.Create(ctx, segment); err != nil {
+			return err
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+		if err := tx.Segments.SetUsers(ctx, segment.ID, userIDs); err != nil {
+			return err
+		}
+		stored, err := tx.Segments.GetByID(ctx, segment.ID)
+		if err != nil {
+			return err
+		}
+		*segment = *stored
+		return nil
+	})
+	if err != nil {
+		writeError(c, err, "Failed to create segment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, segment)
+}
+
+// UpdateSegment processes requests to rename a segment or change its rule. Pages and widgets
+// referencing the segment follow the change on their next delivery.
+func (h *SegmentHandler) UpdateSegment(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("name", "required", "Segment name cannot be empty"))
+			return
+		}
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Rule != nil {
+		rule, err := storedRule(*req.Rule)
+		if err != nil {
+			writeError(c, err, "Failed to update segment")
+			return
+		}
+		updates["rule"] = rule
+	}
+
+	segment, err := h.segmentRepo.Update(c.Request.Context(), id, updates)
+	if err != nil {
+		serverError(c, err, "Failed to update segment")
+		return
+	}
+	if segment == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Segment not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// DeleteSegment processes requests to delete a segment and its user list. A segment still
+// referenced by pages or widgets is kept, answering with a conflict that counts the references.
+func (h *SegmentHandler) DeleteSegment(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	err := h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		pages, widgets, err := tx.Segments.References(ctx, id)
+		if err != nil {
+			return err
+		}
+		if pages+widgets > 0 {
+			return errSegmentInUse(pages, widgets)
+		}
+
+		err = tx.Segments.Delete(ctx, id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return errSegmentNotFound
+		case errors.Is(err, repository.ErrSegmentInUse):
+			return errSegmentInUse(pages, widgets)
+		}
+		return err
+	})
+	if err != nil {
+		writeError(c, err, "Failed to delete segment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Segment deleted successfully"})
+}
+
+// errSegmentInUse reports a segment that cannot be deleted while pages or widgets reference it.
+func errSegmentInUse(pages, widgets int) error {
+	return fail(http.StatusConflict, models.NewConflictError(fmt.Sprintf(
+		"Segment is referenced by %d page(s) and %d widget(s). Remove the references first.", pages, widgets)))
+}
+
+// SetSegmentUsers processes requests to replace the user list of a segment. The list is sent as
+// JSON ({"user_ids": [...]}), or as text or CSV with one user ID per line, in the first column;
+// a CSV header naming user_id is skipped. An empty list removes it.
+func (h *SegmentHandler) SetSegmentUsers(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	var raw []string
+	switch c.ContentType() {
+	case "text/plain", "text/csv":
+		var err error
+		if raw, err = readUserIDs(c.Request.Body); err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid user list: "+err.Error()))
+			return
+		}
+	default:
+		var req models.SegmentUsersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+			return
+		}
+		raw = req.UserIDs
+	}
+	userIDs, err := cleanUserIDs(raw)
+	if err != nil {
+		writeError(c, err, "Failed to update segment users")
+		return
+	}
+
+	var segment *models.Segment
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		if err := tx.Segments.SetUsers(ctx, id, userIDs); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errSegmentNotFound
+			}
+			return err
+		}
+		segment, err = tx.Segments.GetByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeError(c, err, "Failed to update segment users")
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// readUserIDs reads one user ID per line from a text or CSV body, keeping the first column.
+func readUserIDs(body io.Reader) ([]string, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var userIDs []string
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return userIDs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "user_id") {
+			continue
+		}
+		if len(userIDs) == maxSegmentUsers {
+			return nil, fmt.Errorf("at most %d user IDs are allowed", maxSegmentUsers)
+		}
+		userIDs = append(userIDs, record[0])
+	}
+}
+
+// cleanUserIDs trims the user IDs of an upload and drops blank and repeated ones, rejecting lists
+// and IDs over their limits.
+func cleanUserIDs(raw []string) ([]string, error) {
+	if len(raw) > maxSegmentUsers {
+		return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("user_ids", "max", fmt.Sprintf("At most %d user IDs are allowed", maxSegmentUsers)))
+	}
+	seen := make(map[string]struct{}, len(raw))
+	userIDs := make([]string, 0, len(raw))
+	for _, userID := range raw {
+		userID = strings.TrimSpace(userID)
+		if userID == "" {
+			continue
+		}
+		if len(userID) > maxSegmentUserID {
+			return nil, fail(http.StatusBadRequest, models.NewFieldValidationError("user_ids", "max", fmt.Sprintf("User IDs are limited to %d characters", maxSegmentUserID)))
+		}
+		if _, ok := seen[userID]; ok {
+			continue
+		}
+		seen[userID] = struct{}{}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, nil
+}
+
+// TestSegment processes requests to check whether a client belongs to a segment. The client is
+// described like on delivery requests, by X-Client-* headers or query parameters, and by a JSON
+// object of attributes in the body, which takes precedence. The user_id attribute is checked
+// against the segment's user list.
+func (h *SegmentHandler) TestSegment(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	client := clientContext(c)
+	if c.Request.ContentLength != 0 {
+		var attrs map[string]string
+		if err := c.ShouldBindJSON(&attrs); err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewBadRequestError("The body must be a JSON object of string attributes"))
+			return
+		}
+		for attr, value := range attrs {
+			if _, known := clientHeaders[attr]; !known {
+				response.Error(c, http.StatusBadRequest, models.NewFieldValidationError(attr, "oneof", "Unknown client attribute "+strconv.Quote(attr)))
+				return
+			}
+			client[attr] = strings.TrimSpace(value)
+		}
+	}
+
+	segment, err := h.segmentRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		serverError(c, err, "Failed to fetch segment")
+		return
+	}
+	if segment == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Segment not found"))
+		return
+	}
+	memberships, err := h.segmentRepo.Memberships(c.Request.Context(), []uuid.UUID{id}, client["user_id"])
+	if err != nil || len(memberships) != 1 {
+		if err == nil {
+			err = errors.New("segment membership not found")
+		}
+		serverError(c, err, "Failed to test segment")
+		return
+	}
+	m := memberships[0]
+
+	result := models.SegmentTestResult{Segment: segment, Context: client, Member: inSegment(m, client)}
+	if m.Rule != "" {
+		matches := targeting.Matches(m.Rule, client)
+		result.MatchesRule = &matches
+	}
+	if m.HasUsers {
+		result.Listed = &m.Listed
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListSegmentWidgets processes requests to list the widgets that reference a segment, newest
+// first, with the same keyset pagination as the widget listing.
+func (h *SegmentHandler) ListSegmentWidgets(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	q := repository.WidgetQuery{SegmentIDs: []uuid.UUID{id}, Limit: h.pagination.DefaultPerPage}
+	if pp := c.Query("per_page"); pp != "" {
+		if parsed, err := strconv.Atoi(pp); err == nil && parsed > 0 && parsed <= h.pagination.MaxPerPage {
+			q.Limit = parsed
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := repository.DecodeWidgetCursor(cursor)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("cursor", "cursor", "Invalid cursor"))
+			return
+		}
+		q.After = after
+	}
+
+	segment, err := h.segmentRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		serverError(c, err, "Failed to fetch segment")
+		return
+	}
+	if segment == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Segment not found"))
+		return
+	}
+
+	list, err := h.widgetRepo.List(c.Request.Context(), q)
+	if err != nil {
+		serverError(c, err, "Failed to fetch widgets")
+		return
+	}
+
+	resp := models.WidgetCursorResponse{Widgets: list.Widgets, PerPage: q.Limit}
+	if resp.Widgets == nil {
+		resp.Widgets = []models.WidgetWithPage{}
+	}
+	if list.Next != nil {
+		resp.NextCursor = list.Next.Encode()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// segmentID parses the segment ID path parameter, answering with a 400 when it is malformed.
+func segmentID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid segment ID format"))
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"appdrop/middleware"
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/requestid"
+	"appdrop/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ThemeHandler orchestrates HTTP request processing for the app theme and its design tokens.
+type ThemeHandler struct {
+	themeRepo repository.ThemeStore
+	uow       repository.UnitOfWork
+}
+
+// NewThemeHandler initializes and returns a new instance of ThemeHandler with its required dependencies.
+func NewThemeHandler(themeRepo repository.ThemeStore, uow repository.UnitOfWork) *ThemeHandler {
+	return &ThemeHandler{
+		themeRepo: themeRepo,
+		uow:       uow,
+	}
+}
+
+// GetTheme processes requests to retrieve the app theme, with both its light and dark palettes.
+// It answers with a 404 until a theme is first saved.
+func (h *ThemeHandler) GetTheme(c *gin.Context) {
+	theme, err := h.themeRepo.Get(c.Request.Context())
+	if err != nil {
+		serverError(c, err, "Failed to fetch theme")
+		return
+	}
+	if theme == nil {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Theme not found"))
+		return
+	}
+	c.JSON(http.StatusOK, theme)
+}
+
+// UpdateTheme processes requests to replace the app theme. Colors have to be hex colors, and the
+// contrast pairs have to meet their ratio in light and dark mode. A theme that would no longer
+// define a token referenced by a widget, or by an experiment that can still be delivered, is
+// refused with a 409. The change is stored with an audit entry in one unit of work.
+func (h *ThemeHandler) UpdateTheme(c *gin.Context) {
+	var req models.ThemeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+		return
+	}
+
+	theme := req.Theme()
+	if errs := theme.Normalize(); len(errs) > 0 {
+		response.Error(c, http.StatusBadRequest, models.NewValidationError("Invalid theme").WithFieldErrors(errs...))
+		return
+	}
+
+	err := h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		refs, err := themeReferences(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if unknown := theme.UnknownReferences(refs); len(unknown) > 0 {
+			return fail(http.StatusConflict, models.NewConflictError("The theme has to keep defining the tokens referenced by widget configs: "+strings.Join(unknown, ", ")))
+		}
+		if err := tx.Themes.Save(ctx, theme); err != nil {
+			return err
+		}
+
+		details, err := json.Marshal(gin.H{
+			"colors":      len(theme.Colors),
+			"dark_colors": len(theme.DarkColors),
+			"typography":  len(theme.Typography),
+			"radii":       len(theme.Radii),
+			"spacing":     len(theme.Spacing),
+			"contrast":    len(theme.Contrast),
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Audit.Record(ctx, &models.AuditEntry{
+			Action:    models.AuditActionThemeUpdate,
+			Actor:     middleware.Actor(c),
+			RequestID: requestid.FromContext(ctx),
+			Details:   details,
+		})
+	})
+	if err != nil {
+		writeError(c, err, "Failed to update theme")
+		return
+	}
+	c.JSON(http.StatusOK, theme)
+}
+
+// themeReferences returns the token references of every widget, including its localized configs,
+// and of the variants of experiments that have not been promoted.
+func themeReferences(ctx context.Context, tx repository.Stores) ([]string, error) {
+	list, err := tx.Pages.List(ctx, repository.PageQuery{})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, page := range list.Pages {
+		widgets, err := tx.Widgets.GetByPageID(ctx, page.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		for i := range widgets {
+			for _, ref := range widgets[i].TokenReferences() {
+				seen[ref] = true
+			}
+		}
+
+		experiments, err := tx.Experiments.ListByPage(ctx, page.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range experiments {
+			if e.Status == models.ExperimentPromoted {
+				continue
+			}
+			for i := range e.Variants {
+				for _, ref := range e.Variants[i].TokenReferences() {
+					seen[ref] = true
+				}
+			}
+		}
+	}
+
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// storedTokens checks that the token references of configs about to be stored are defined by the
+// app theme, naming field in the error. The theme is only loaded when there are references.
+func storedTokens(ctx context.Context, themes repository.ThemeStore, field string, refs []string) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	theme, err := themes.Get(ctx)
+	if err != nil {
+		return err
+	}
+	if unknown := theme.UnknownReferences(refs); len(unknown) > 0 {
+		return fail(http.StatusBadRequest, models.NewFieldValidationError(field, "token", "Unknown design tokens: "+strings.Join(unknown, ", ")))
+	}
+	return nil
+}
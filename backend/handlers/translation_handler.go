@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"appdrop/i18n"
+	"appdrop/middleware"
+	"appdrop/models"
+	"appdrop/repository"
+	"appdrop/requestid"
+	"appdrop/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxTranslationFileSize caps the size of an imported translation file.
+const maxTranslationFileSize = 10 << 20
+
+// translationContentTypes maps each translation file format to the media type it is served as.
+var translationContentTypes = map[string]string{
+	models.TranslationFormatXLIFF: "application/xliff+xml; charset=utf-8",
+	models.TranslationFormatPO:    "text/x-gettext-translation; charset=utf-8",
+}
+
+// translationExtensions maps each translation file format to the extension of exported files.
+var translationExtensions = map[string]string{
+	models.TranslationFormatXLIFF: ".xlf",
+	models.TranslationFormatPO:    ".po",
+}
+
+// TranslationHandler orchestrates HTTP request processing for the export of translatable strings
+// to XLIFF and PO files, and the import of their translations into localized content.
+type TranslationHandler struct {
+	pageRepo   repository.PageStore
+	widgetRepo repository.WidgetStore
+	uow        repository.UnitOfWork
+}
+
+// NewTranslationHandler initializes and returns a new instance of TranslationHandler with its required dependencies.
+func NewTranslationHandler(pageRepo repository.PageStore, widgetRepo repository.WidgetStore, uow repository.UnitOfWork) *TranslationHandler {
+	return &TranslationHandler{
+		pageRepo:   pageRepo,
+		widgetRepo: widgetRepo,
+		uow:        uow,
+	}
+}
+
+// translationScope selects the strings taking part in an export or import: those of the pages
+// whose default locale is the source locale, optionally narrowed down to some pages, translated
+// into the target locale.
+type translationScope struct {
+	source  string
+	target  string
+	pageIDs []uuid.UUID
+}
+
+// translationSources holds the translatable strings of a scope, as catalog units in export order
+// whose targets are the stored translations, with the pages and widgets they come from.
+type translationSources struct {
+	units   []i18n.Unit
+	index   map[string]int
+	pages   map[uuid.UUID]*models.Page
+	widgets map[uuid.UUID]*models.Widget
+}
+
+// loadTranslationSources collects the strings of a scope: the name of each page, ordered by route,
+// followed by the translatable config fields of its widgets, in position and path order.
+func loadTranslationSources(ctx context.Context, pages repository.PageStore, widgets repository.WidgetStore, scope translationScope) (*translationSources, error) {
+	list, err := pages.List(ctx, repository.PageQuery{Sort: repository.PageSortRoute})
+	if err != nil {
+		return nil, err
+	}
+	src := &translationSources{
+		index:   make(map[string]int),
+		pages:   make(map[uuid.UUID]*models.Page),
+		widgets: make(map[uuid.UUID]*models.Widget),
+	}
+	add := func(unit i18n.Unit) {
+		src.index[unit.ID] = len(src.units)
+		src.units = append(src.units, unit)
+	}
+
+	for i := range list.Pages {
+		page := &list.Pages[i]
+		if page.DefaultLocale != scope.source || (len(scope.pageIDs) > 0 && !containsUUID(scope.pageIDs, page.ID)) {
+			continue
+		}
+		src.pages[page.ID] = page
+		group := "page." + page.ID.String()
+		add(i18n.Unit{ID: models.PageNameKey(page.ID), Group: group, Note: "Name of page " + page.Route, Source: page.Name, Target: page.Names[scope.target]})
+
+		pageWidgets, err := widgets.GetByPageID(ctx, page.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		for j := range pageWidgets {
+			w := &pageWidgets[j]
+			src.widgets[w.ID] = w
+			fields := w.TranslatableFields()
+			paths := make([]string, 0, len(fields))
+			for path := range fields {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				target, _ := w.Translation(scope.target, path)
+				note := w.Type + " widget " + strconv.Itoa(w.Position) + " on page " + page.Route + ", " + path
+				add(i18n.Unit{ID: models.WidgetFieldKey(w.ID, path), Group: group, Note: note, Source: fields[path], Target: target})
+			}
+		}
+	}
+	return src, nil
+}
+
+// containsUUID reports whether ids holds id.
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTranslationScope reads the source locale, the target locale and the page_id filter of a
+// translation request. Both locales may come from the languages named by an imported file instead:
+// the source locale defaults to en, and the target locale is required unless the file names it,
+// in which case the parameter has to match it.
+func parseTranslationScope(c *gin.Context, fileSource, fileLocale string) (translationScope, error) {
+	scope := translationScope{source: models.DefaultLocale}
+	var err error
+	source := strings.TrimSpace(c.Query("source"))
+	if source == "" {
+		source = fileSource
+	}
+	if source != "" {
+		if scope.source, err = storedLocale("source", source); err != nil {
+			return scope, err
+		}
+	}
+
+	target := strings.TrimSpace(c.Query("locale"))
+	switch {
+	case target == "" && fileLocale == "":
+		return scope, fail(http.StatusBadRequest, models.NewFieldValidationError("locale", "required", "locale is required, naming the locale to translate into"))
+	case target == "":
+		target = fileLocale
+	}
+	if scope.target, err = storedLocale("locale", target); err != nil {
+		return scope, err
+	}
+	if fileLocale != "" {
+		if fromFile, ok := i18n.Normalize(fileLocale); !ok || fromFile != scope.target {
+			return scope, fail(http.StatusBadRequest, models.NewFieldValidationError("locale", "match", "The file translates into "+fileLocale+", not "+scope.target))
+		}
+	}
+	if scope.target == scope.source {
+		return scope, fail(http.StatusBadRequest, models.NewFieldValidationError("locale", "ne", "locale must differ from the source locale"))
+	}
+
+	for _, v := range splitQueryValues(c.QueryArray("page_id")) {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return scope, fail(http.StatusBadRequest, models.NewFieldValidationError("page_id", "uuid", "Invalid page ID format"))
+		}
+		scope.pageIDs = append(scope.pageIDs, id)
+	}
+	return scope, nil
+}
+
+// translationFormat reads the format parameter of a translation request, defaulting to def.
+func translationFormat(c *gin.Context, def string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(c.Query("format")))
+	if format == "" {
+		return def, nil
+	}
+	if _, ok := translationContentTypes[format]; !ok {
+		return "", fail(http.StatusBadRequest, models.NewFieldValidationError("format", "oneof", "Invalid format. Must be one of: xliff, po"))
+	}
+	return format, nil
+}
+
+// ExportTranslations processes requests to export the translatable strings of the pages in the
+// source locale, their names and the titles, text, alt text and labels of their widget configs,
+// as an XLIFF 2.0 (format=xliff, the default) or gettext PO (format=po) file for the target
+// locale. Each string is keyed by the page or widget it belongs to and its config path, and
+// carries its current translation, if any.
+func (h *TranslationHandler) ExportTranslations(c *gin.Context) {
+	format, err := translationFormat(c, models.TranslationFormatXLIFF)
+	if err != nil {
+		writeError(c, err, "Failed to export translations")
+		return
+	}
+	scope, err := parseTranslationScope(c, "", "")
+	if err != nil {
+		writeError(c, err, "Failed to export translations")
+		return
+	}
+
+	src, err := loadTranslationSources(c.Request.Context(), h.pageRepo, h.widgetRepo, scope)
+	if err != nil {
+		serverError(c, err, "Failed to export translations")
+		return
+	}
+
+	catalog := i18n.Catalog{SourceLang: scope.source, TargetLang: scope.target, Units: src.units}
+	var buf bytes.Buffer
+	if format == models.TranslationFormatPO {
+		err = i18n.WritePO(&buf, catalog)
+	} else {
+		err = i18n.WriteXLIFF(&buf, catalog)
+	}
+	if err != nil {
+		serverError(c, err, "Failed to export translations")
+		return
+	}
+
+	filename := "translations-" + scope.source + "-" + scope.target + translationExtensions[format]
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, translationContentTypes[format], buf.Bytes())
+}
+
+// importPlan is the outcome of matching an imported catalog against the current strings: the
+// report, and the page names and widget overrides to store.
+type importPlan struct {
+	resp    models.TranslationImportResponse
+	names   map[uuid.UUID]models.PageNames
+	locales map[uuid.UUID]models.WidgetLocales
+}
+
+// planImport matches the units of an imported catalog against the current strings of the scope.
+// Translations of unchanged sources are merged into the localized content; empty and fuzzy ones
+// count as missing, those of changed sources as stale and those of unknown keys as unknown.
+func planImport(ctx context.Context, pages repository.PageStore, widgets repository.WidgetStore, scope translationScope, catalog *i18n.Catalog) (*importPlan, error) {
+	src, err := loadTranslationSources(ctx, pages, widgets, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &importPlan{
+		resp:    models.TranslationImportResponse{Locale: scope.target, Missing: []string{}, Stale: []models.StaleTranslation{}, Unknown: []string{}},
+		names:   make(map[uuid.UUID]models.PageNames),
+		locales: make(map[uuid.UUID]models.WidgetLocales),
+	}
+	translated := make(map[string]bool)
+	for _, unit := range catalog.Units {
+		i, ok := src.index[unit.ID]
+		if !ok {
+			plan.resp.Unknown = append(plan.resp.Unknown, unit.ID)
+			continue
+		}
+		current := src.units[i]
+		if unit.Target == "" || unit.Fuzzy {
+			continue
+		}
+		if unit.Source != current.Source {
+			plan.resp.Stale = append(plan.resp.Stale, models.StaleTranslation{Key: unit.ID, Source: unit.Source, CurrentSource: current.Source})
+			translated[unit.ID] = true
+			continue
+		}
+		translated[unit.ID] = true
+		if err := plan.add(src, unit.ID, scope.target, unit.Target); err != nil {
+			return nil, err
+		}
+		plan.resp.Imported++
+	}
+	for _, unit := range src.units {
+		if !translated[unit.ID] {
+			plan.resp.Missing = append(plan.resp.Missing, unit.ID)
+		}
+	}
+
+	for id, names := range plan.names {
+		if plan.names[id], err = storedNames(names); err != nil {
+			return nil, err
+		}
+	}
+	for id, locales := range plan.locales {
+		if plan.locales[id], err = storedLocales(locales); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// add records the translation of the string with the given key, which has to be one of src.
+func (p *importPlan) add(src *translationSources, key, locale, text string) error {
+	kind, rest, _ := strings.Cut(key, ".")
+	rawID, path, _ := strings.Cut(rest, ".")
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return err
+	}
+
+	if kind == "page" {
+		names, ok := p.names[id]
+		if !ok {
+			names = make(models.PageNames, len(src.pages[id].Names)+1)
+			for l, name := range src.pages[id].Names {
+				names[l] = name
+			}
+			p.names[id] = names
+		}
+		names[locale] = text
+		return nil
+	}
+
+	widget := *src.widgets[id]
+	if locales, ok := p.locales[id]; ok {
+		widget.Locales = locales
+	}
+	if err := widget.Translate(locale, path, text); err != nil {
+		return err
+	}
+	p.locales[id] = widget.Locales
+	return nil
+}
+
+// ImportTranslations processes requests to import an XLIFF 2.0 or gettext PO file of translations
+// exported by ExportTranslations. The format is taken from the format parameter, the Content-Type
+// or the file itself, and the target locale from the locale parameter or the file. Translations are
+// written into the localized page names and widget config overrides in one unit of work, together
+// with an audit entry, and the response reports the missing, stale and unknown keys. With
+// dry_run=true nothing is written.
+func (h *TranslationHandler) ImportTranslations(c *gin.Context) {
+	dryRun := false
+	if v := c.Query("dry_run"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("dry_run", "boolean", "dry_run must be true or false"))
+			return
+		}
+		dryRun = parsed
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTranslationFileSize+1))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Failed to read the translation file"))
+		return
+	}
+	if len(body) > maxTranslationFileSize {
+		response.Error(c, http.StatusRequestEntityTooLarge, models.NewBadRequestError("The translation file exceeds 10 MiB"))
+		return
+	}
+
+	format, err := translationFormat(c, sniffTranslationFormat(c.ContentType(), body))
+	if err != nil {
+		writeError(c, err, "Failed to import translations")
+		return
+	}
+	var catalog *i18n.Catalog
+	if format == models.TranslationFormatPO {
+		catalog, err = i18n.ReadPO(bytes.NewReader(body))
+	} else {
+		catalog, err = i18n.ReadXLIFF(bytes.NewReader(body))
+	}
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid translation file: "+err.Error()))
+		return
+	}
+
+	scope, err := parseTranslationScope(c, catalog.SourceLang, catalog.TargetLang)
+	if err != nil {
+		writeError(c, err, "Failed to import translations")
+		return
+	}
+
+	var plan *importPlan
+	if dryRun {
+		plan, err = planImport(c.Request.Context(), h.pageRepo, h.widgetRepo, scope, catalog)
+	} else {
+		err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+			plan, err = planImport(ctx, tx.Pages, tx.Widgets, scope, catalog)
+			if err != nil || plan.resp.Imported == 0 {
+				return err
+			}
+			for id, names := range plan.names {
+				if _, err := tx.Pages.Update(ctx, id, map[string]interface{}{"names": names}); err != nil {
+					return err
+				}
+			}
+			for id, locales := range plan.locales {
+				if _, err := tx.Widgets.Update(ctx, id, map[string]interface{}{"locales": locales}); err != nil {
+					return err
+				}
+			}
+
+			details, err := json.Marshal(gin.H{
+				"source":   scope.source,
+				"locale":   scope.target,
+				"format":   format,
+				"imported": plan.resp.Imported,
+				"stale":    len(plan.resp.Stale),
+				"unknown":  len(plan.resp.Unknown),
+				"pages":    len(plan.names),
+				"widgets":  len(plan.locales),
+			})
+			if err != nil {
+				return err
+			}
+			entry := &models.AuditEntry{
+				Action:    models.AuditActionTranslationImport,
+				Actor:     middleware.Actor(c),
+				RequestID: requestid.FromContext(ctx),
+				Details:   details,
+			}
+			if err := tx.Audit.Record(ctx, entry); err != nil {
+				return err
+			}
+			plan.resp.AuditID = &entry.ID
+			return nil
+		})
+	}
+	if err != nil {
+		writeError(c, err, "Failed to import translations")
+		return
+	}
+
+	plan.resp.Format = format
+	plan.resp.Applied = !dryRun && plan.resp.Imported > 0
+	c.JSON(http.StatusOK, plan.resp)
+}
+
+// sniffTranslationFormat guesses the format of an imported file from its media type, and from
+// its content when the media type names neither format: XML documents are taken as XLIFF.
+func sniffTranslationFormat(contentType string, body []byte) string {
+	switch contentType {
+	case "application/xliff+xml", "application/x-xliff+xml", "application/xml", "text/xml":
+		return models.TranslationFormatXLIFF
+	case "text/x-gettext-translation", "text/x-po", "application/x-po":
+		return models.TranslationFormatPO
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))), []byte("<")) {
+		return models.TranslationFormatXLIFF
+	}
+	return models.TranslationFormatPO
+}
@@ -1,93 +1,141 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"appdrop/middleware"
 	"appdrop/models"
 	"appdrop/repository"
+	"appdrop/requestid"
+	"appdrop/response"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // WidgetHandler orchestrates HTTP request processing for Widget-related resources.
+// Reads use the stores directly; multi-step changes run through the unit of work.
 type WidgetHandler struct {
-	widgetRepo *repository.WidgetRepository
-	pageRepo   *repository.PageRepository
+	widgetRepo     repository.WidgetStore
+	pageRepo       repository.PageStore
+	segmentRepo    repository.SegmentStore
+	experimentRepo repository.ExperimentStore
+	themeRepo      repository.ThemeStore
+	uow            repository.UnitOfWork
+	pagination     Pagination
 }
 
 // NewWidgetHandler initializes and returns a new instance of WidgetHandler with its required dependencies.
-func NewWidgetHandler(widgetRepo *repository.WidgetRepository, pageRepo *repository.PageRepository) *WidgetHandler {
+func NewWidgetHandler(widgetRepo repository.WidgetStore, pageRepo repository.PageStore, segmentRepo repository.SegmentStore, experimentRepo repository.ExperimentStore, themeRepo repository.ThemeStore, uow repository.UnitOfWork, pagination Pagination) *WidgetHandler {
 	return &WidgetHandler{
-		widgetRepo: widgetRepo,
-		pageRepo:   pageRepo,
+		widgetRepo:     widgetRepo,
+		pageRepo:       pageRepo,
+		segmentRepo:    segmentRepo,
+		experimentRepo: experimentRepo,
+		themeRepo:      themeRepo,
+		uow:            uow,
+		pagination:     pagination,
 	}
 }
 
 // CreateWidget processes requests to instantiate and persist a new widget within a specific page context.
+// The page lookup, position assignment and insert run as one unit of work, in which the design
+// tokens referenced by the config and its localized configs are checked against the app theme.
 func (h *WidgetHandler) CreateWidget(c *gin.Context) {
 	pageIDStr := c.Param("id")
 	pageID, err := uuid.Parse(pageIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
-		return
-	}
-
-	page, err := h.pageRepo.GetByID(pageID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch page"))
-		return
-	}
-	if page == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
 		return
 	}
 
 	var req models.CreateWidgetRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid request body: "+err.Error()))
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
 		return
 	}
 
 	if !models.IsValidWidgetType(req.Type) {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid widget type. Must be one of: banner, product_grid, text, image, spacer"))
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("type", "oneof", "Invalid widget type. Must be one of: banner, product_grid, text, image, spacer"))
 		return
 	}
 
 	if len(req.Config) > 0 {
 		var configTest interface{}
 		if err := json.Unmarshal(req.Config, &configTest); err != nil {
-			c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid JSON format for widget config"))
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("config", "json", "Invalid JSON format for widget config"))
 			return
 		}
 	}
 
-	position := req.Position
-	if position == 0 {
-		maxPos, err := h.widgetRepo.GetMaxPosition(pageID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to determine widget position"))
-			return
-		}
-		position = maxPos + 1
-	}
-
 	config := req.Config
 	if config == nil {
 		config = json.RawMessage("{}")
 	}
+	if err := storedConfig("config", config); err != nil {
+		writeError(c, err, "Failed to create widget")
+		return
+	}
+
+	rule, err := storedRule(req.Rule)
+	if err != nil {
+		writeError(c, err, "Failed to create widget")
+		return
+	}
+
+	locales, err := storedLocales(req.Locales)
+	if err != nil {
+		writeError(c, err, "Failed to create widget")
+		return
+	}
 
 	widget := &models.Widget{
 		PageID:   pageID,
 		Type:     req.Type,
-		Position: position,
+		Position: req.Position,
 		Config:   config,
+		Schedule: storedSchedule(req.Schedule),
+		Rule:     rule,
+		Locales:  locales,
 	}
 
-	if err := h.widgetRepo.Create(widget); err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to create widget"))
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		page, err := tx.Pages.GetByID(ctx, pageID)
+		if err != nil {
+			return err
+		}
+		if page == nil {
+			return errPageNotFound
+		}
+
+		if widget.SegmentID, err = storedSegment(ctx, tx, req.SegmentID); err != nil {
+			return err
+		}
+		if err := storedTokens(ctx, tx.Themes, "config", models.TokenReferences(widget.Config)); err != nil {
+			return err
+		}
+		if err := storedTokens(ctx, tx.Themes, "locales", widget.Locales.TokenReferences()); err != nil {
+			return err
+		}
+
+		if req.Position == 0 {
+			maxPos, err := tx.Widgets.GetMaxPosition(ctx, pageID)
+			if err != nil {
+				return err
+			}
+			widget.Position = maxPos + 1
+		}
+
+		return tx.Widgets.Create(ctx, widget)
+	})
+	if err != nil {
+		writeError(c, err, "Failed to create widget")
 		return
 	}
 
@@ -95,27 +143,19 @@ func (h *WidgetHandler) CreateWidget(c *gin.Context) {
 }
 
 // UpdateWidget processes requests to modify the attributes or configuration of an existing widget.
+// The lookup and update run as one unit of work, in which the design tokens referenced by a new
+// config or new localized configs are checked against the app theme.
 func (h *WidgetHandler) UpdateWidget(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid widget ID format"))
-		return
-	}
-
-	existingWidget, err := h.widgetRepo.GetByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch widget"))
-		return
-	}
-	if existingWidget == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Widget not found"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid widget ID format"))
 		return
 	}
 
 	var req models.UpdateWidgetRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid request body: "+err.Error()))
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
 		return
 	}
 
@@ -123,7 +163,7 @@ func (h *WidgetHandler) UpdateWidget(c *gin.Context) {
 
 	if req.Type != nil {
 		if !models.IsValidWidgetType(*req.Type) {
-			c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid widget type. Must be one of: banner, product_grid, text, image, spacer"))
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("type", "oneof", "Invalid widget type. Must be one of: banner, product_grid, text, image, spacer"))
 			return
 		}
 		updates["type"] = *req.Type
@@ -136,20 +176,74 @@ func (h *WidgetHandler) UpdateWidget(c *gin.Context) {
 	if req.Config != nil {
 		var configTest interface{}
 		if err := json.Unmarshal(*req.Config, &configTest); err != nil {
-			c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid JSON format for widget config"))
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("config", "json", "Invalid JSON format for widget config"))
+			return
+		}
+		if err := storedConfig("config", *req.Config); err != nil {
+			writeError(c, err, "Failed to update widget")
 			return
 		}
 		updates["config"] = *req.Config
 	}
 
-	if len(updates) == 0 {
-		c.JSON(http.StatusOK, existingWidget)
-		return
+	if req.Schedule != nil {
+		updates["schedule"] = storedSchedule(req.Schedule)
+	}
+
+	if req.Rule != nil {
+		rule, err := storedRule(*req.Rule)
+		if err != nil {
+			writeError(c, err, "Failed to update widget")
+			return
+		}
+		updates["rule"] = rule
 	}
 
-	widget, err := h.widgetRepo.Update(id, updates)
+	if req.Locales != nil {
+		locales, err := storedLocales(req.Locales)
+		if err != nil {
+			writeError(c, err, "Failed to update widget")
+			return
+		}
+		updates["locales"] = locales
+	}
+
+	var widget *models.Widget
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		existingWidget, err := tx.Widgets.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existingWidget == nil {
+			return errWidgetNotFound
+		}
+
+		if req.SegmentID != nil {
+			if updates["segment_id"], err = storedSegment(ctx, tx, *req.SegmentID); err != nil {
+				return err
+			}
+		}
+		if req.Config != nil {
+			if err := storedTokens(ctx, tx.Themes, "config", models.TokenReferences(*req.Config)); err != nil {
+				return err
+			}
+		}
+		if locales, ok := updates["locales"].(models.WidgetLocales); ok {
+			if err := storedTokens(ctx, tx.Themes, "locales", locales.TokenReferences()); err != nil {
+				return err
+			}
+		}
+
+		if len(updates) == 0 {
+			widget = existingWidget
+			return nil
+		}
+
+		widget, err = tx.Widgets.Update(ctx, id, updates)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to update widget"))
+		writeError(c, err, "Failed to update widget")
 		return
 	}
 
@@ -161,26 +255,16 @@ func (h *WidgetHandler) DeleteWidget(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid widget ID format"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid widget ID format"))
 		return
 	}
 
-	widget, err := h.widgetRepo.GetByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch widget"))
-		return
-	}
-	if widget == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Widget not found"))
-		return
-	}
-
-	if err := h.widgetRepo.Delete(id); err != nil {
+	if err := h.widgetRepo.Delete(c.Request.Context(), id); err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, models.NewNotFoundError("Widget not found"))
+			response.Error(c, http.StatusNotFound, models.NewNotFoundError("Widget not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to delete widget"))
+		serverError(c, err, "Failed to delete widget")
 		return
 	}
 
@@ -188,63 +272,63 @@ func (h *WidgetHandler) DeleteWidget(c *gin.Context) {
 }
 
 // ReorderWidgets processes requests to synchronously update the sequential arrangement of widgets on a page.
+// The page lookup, widget count check, reorder and read-back run as one unit of work, so the new
+// order cannot be computed against a page that gains or loses widgets concurrently.
 func (h *WidgetHandler) ReorderWidgets(c *gin.Context) {
 	pageIDStr := c.Param("id")
 	pageID, err := uuid.Parse(pageIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
-		return
-	}
-
-	page, err := h.pageRepo.GetByID(pageID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch page"))
-		return
-	}
-	if page == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
 		return
 	}
 
 	var req models.ReorderWidgetsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid request body: "+err.Error()))
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
 		return
 	}
 
 	if len(req.WidgetIDs) == 0 {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("Widget IDs array cannot be empty"))
+		response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("widget_ids", "min", "Widget IDs array cannot be empty"))
 		return
 	}
 
 	seen := make(map[uuid.UUID]bool)
 	for _, id := range req.WidgetIDs {
 		if seen[id] {
-			c.JSON(http.StatusBadRequest, models.NewValidationError("Duplicate widget ID in the list"))
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("widget_ids", "unique", "Duplicate widget ID in the list"))
 			return
 		}
 		seen[id] = true
 	}
 
-	widgetCount, err := h.widgetRepo.GetWidgetCountByPageID(pageID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to verify widgets"))
-		return
-	}
+	var widgets []models.Widget
+	err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+		page, err := tx.Pages.GetByID(ctx, pageID)
+		if err != nil {
+			return err
+		}
+		if page == nil {
+			return errPageNotFound
+		}
 
-	if len(req.WidgetIDs) != widgetCount {
-		c.JSON(http.StatusBadRequest, models.NewValidationError("The number of widget IDs must match the total widgets on the page"))
-		return
-	}
+		widgetCount, err := tx.Widgets.GetWidgetCountByPageID(ctx, pageID)
+		if err != nil {
+			return err
+		}
+		if len(req.WidgetIDs) != widgetCount {
+			return fail(http.StatusBadRequest, models.NewFieldValidationError("widget_ids", "len", "The number of widget IDs must match the total widgets on the page"))
+		}
 
-	if err := h.widgetRepo.Reorder(pageID, req.WidgetIDs); err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to reorder widgets: "+err.Error()))
-		return
-	}
+		if err := tx.Widgets.Reorder(ctx, pageID, req.WidgetIDs); err != nil {
+			return err
+		}
 
-	widgets, err := h.widgetRepo.GetByPageID(pageID, nil)
+		widgets, err = tx.Widgets.GetByPageID(ctx, pageID, nil)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch updated widgets"))
+		writeError(c, err, "Failed to reorder widgets")
 		return
 	}
 
@@ -255,45 +339,391 @@ func (h *WidgetHandler) ReorderWidgets(c *gin.Context) {
 }
 
 // GetWidgets processes requests to retrieve all widgets for a page, with optional type-based filtering.
+// Like GetPage, it only returns what is delivered to the client unless all=true is passed, and serves
+// the widgets of the client's experiment variant, reported in the experiment field and headers, in
+// the client's locale, reported in the locale and dir fields, with configs flattened for the client's
+// theme, platform and form factor and design tokens resolved.
 func (h *WidgetHandler) GetWidgets(c *gin.Context) {
 	pageIDStr := c.Param("id")
 	pageID, err := uuid.Parse(pageIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
+		response.Error(c, http.StatusBadRequest, models.NewBadRequestError("Invalid page ID format"))
 		return
 	}
 
-	page, err := h.pageRepo.GetByID(pageID)
+	aud, err := deliveryAudience(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch page"))
+		writeError(c, err, "Failed to fetch widgets")
+		return
+	}
+
+	page, err := h.pageRepo.GetByID(c.Request.Context(), pageID)
+	if err != nil {
+		serverError(c, err, "Failed to fetch page")
 		return
 	}
 	if page == nil {
-		c.JSON(http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Page not found"))
 		return
 	}
 
 	var widgetType *string
 	if t := c.Query("type"); t != "" {
 		if !models.IsValidWidgetType(t) {
-			c.JSON(http.StatusBadRequest, models.NewValidationError("Invalid widget type filter"))
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("type", "oneof", "Invalid widget type filter"))
 			return
 		}
 		widgetType = &t
 	}
 
-	widgets, err := h.widgetRepo.GetByPageID(pageID, widgetType)
+	widgets, err := h.widgetRepo.GetByPageID(c.Request.Context(), pageID, widgetType)
+	if err != nil {
+		serverError(c, err, "Failed to fetch widgets")
+		return
+	}
+	widgets, assignment, err := aud.serveExperiment(c.Request.Context(), h.experimentRepo, pageID, widgets)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewInternalServerError("Failed to fetch widgets"))
+		serverError(c, err, "Failed to fetch widgets")
 		return
 	}
+	if widgetType != nil && assignment != nil {
+		widgets = widgetsOfType(widgets, *widgetType)
+	}
 
+	if err := aud.resolveSegments(c.Request.Context(), h.segmentRepo, append(widgetSegments(widgets), page.SegmentID)...); err != nil {
+		serverError(c, err, "Failed to fetch widgets")
+		return
+	}
+	if !aud.sees(page.Schedule, page.Rule, page.SegmentID) {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Page not found"))
+		return
+	}
+
+	widgets = aud.visibleWidgets(widgets)
 	if widgets == nil {
 		widgets = []models.Widget{}
 	}
+	if err := aud.localize(page, widgets); err != nil {
+		serverError(c, err, "Failed to fetch widgets")
+		return
+	}
+	if err := aud.resolveConfigs(widgets); err != nil {
+		serverError(c, err, "Failed to fetch widgets")
+		return
+	}
+	if err := aud.resolveTokens(c.Request.Context(), h.themeRepo, widgets); err != nil {
+		serverError(c, err, "Failed to fetch widgets")
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"widgets": widgets,
 		"total":   len(widgets),
-	})
+	}
+	if page.Locale != "" {
+		resp["locale"] = page.Locale
+		resp["dir"] = page.Direction
+	}
+	if assignment != nil {
+		resp["experiment"] = assignment
+	}
+	reportLocale(c, page)
+	reportExperiment(c, assignment)
+	c.JSON(http.StatusOK, resp)
+}
+
+// widgetsOfType keeps the widgets of the given type, for variants that bring their own widget list.
+func widgetsOfType(widgets []models.Widget, widgetType string) []models.Widget {
+	kept := make([]models.Widget, 0, len(widgets))
+	for _, w := range widgets {
+		if w.Type == widgetType {
+			kept = append(kept, w)
+		}
+	}
+	return kept
+}
+
+// maxConfigFilters caps the number of config predicates accepted by a widget listing.
+const maxConfigFilters = 10
+
+// ListWidgets processes requests to list widgets across all pages, newest first, with keyset pagination.
+// type and page_id accept several values, repeated or comma-separated. Parameters starting with
+// "config." filter on the widget config: config.link=/sale, config.columns>=3, config.link (the key
+// exists) or !config.link (the key is missing). Every filter has to match. Widgets the client does
+// not see, or whose page it does not see, are left out before paginating, so that slices are full
+// and a cursor is only returned when more widgets follow, unless all=true is passed.
+func (h *WidgetHandler) ListWidgets(c *gin.Context) {
+	var q repository.WidgetQuery
+
+	for _, t := range splitQueryValues(c.QueryArray("type")) {
+		if !models.IsValidWidgetType(t) {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("type", "oneof", "Invalid widget type filter"))
+			return
+		}
+		q.Types = append(q.Types, t)
+	}
+
+	for _, v := range splitQueryValues(c.QueryArray("page_id")) {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("page_id", "uuid", "Invalid page ID format"))
+			return
+		}
+		q.PageIDs = append(q.PageIDs, id)
+	}
+
+	for _, segment := range strings.Split(c.Request.URL.RawQuery, "&") {
+		filter, err := url.QueryUnescape(segment)
+		if err != nil {
+			continue
+		}
+		negated := strings.HasPrefix(filter, "!")
+		field := strings.TrimPrefix(filter, "!")
+		if !strings.HasPrefix(field, "config.") {
+			continue
+		}
+		filter = strings.TrimPrefix(field, "config.")
+		if negated {
+			filter = "!" + filter
+		}
+
+		predicate, err := repository.ParseConfigPredicate(filter)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("config", "config_filter", field+": "+err.Error()))
+			return
+		}
+		if len(q.Config) == maxConfigFilters {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("config", "max", "At most 10 config filters are allowed"))
+			return
+		}
+		q.Config = append(q.Config, predicate)
+	}
+
+	q.Limit = h.pagination.DefaultPerPage
+	if pp := c.Query("per_page"); pp != "" {
+		if parsed, err := strconv.Atoi(pp); err == nil && parsed > 0 && parsed <= h.pagination.MaxPerPage {
+			q.Limit = parsed
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := repository.DecodeWidgetCursor(cursor)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("cursor", "cursor", "Invalid cursor"))
+			return
+		}
+		q.After = after
+	}
+
+	aud, err := deliveryAudience(c)
+	if err != nil {
+		writeError(c, err, "Failed to fetch widgets")
+		return
+	}
+
+	if !aud.filtered {
+		list, err := h.widgetRepo.List(c.Request.Context(), q)
+		if err != nil {
+			serverError(c, err, "Failed to fetch widgets")
+			return
+		}
+		resp := models.WidgetCursorResponse{Widgets: list.Widgets, PerPage: q.Limit}
+		if resp.Widgets == nil {
+			resp.Widgets = []models.WidgetWithPage{}
+		}
+		if list.Next != nil {
+			resp.NextCursor = list.Next.Encode()
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	resp := models.WidgetCursorResponse{Widgets: make([]models.WidgetWithPage, 0, q.Limit), PerPage: q.Limit}
+	perPage, more := q.Limit, false
+	q.Limit = visibleWidgetBatch
+	for !more {
+		list, err := h.widgetRepo.List(c.Request.Context(), q)
+		if err != nil {
+			serverError(c, err, "Failed to fetch widgets")
+			return
+		}
+
+		segmentIDs := make([]*uuid.UUID, 0, 2*len(list.Widgets))
+		for i := range list.Widgets {
+			segmentIDs = append(segmentIDs, list.Widgets[i].SegmentID, list.Widgets[i].PageSegmentID)
+		}
+		if err := aud.resolveSegments(c.Request.Context(), h.segmentRepo, segmentIDs...); err != nil {
+			serverError(c, err, "Failed to fetch widgets")
+			return
+		}
+
+		for _, w := range list.Widgets {
+			if !aud.sees(w.Schedule, w.Rule, w.SegmentID) || !aud.sees(w.PageSchedule, w.PageRule, w.PageSegmentID) {
+				continue
+			}
+			if len(resp.Widgets) == perPage {
+				more = true
+				break
+			}
+			resp.Widgets = append(resp.Widgets, w)
+		}
+		if list.Next == nil {
+			break
+		}
+		q.After = list.Next
+	}
+	if more {
+		last := resp.Widgets[len(resp.Widgets)-1]
+		resp.NextCursor = repository.WidgetCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// visibleWidgetBatch is the number of widgets read at a time while filling a slice of a listing with
+// the widgets a filtered audience sees.
+const visibleWidgetBatch = 100
+
+// splitQueryValues flattens repeated and comma-separated query values, dropping empty ones.
+func splitQueryValues(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// replacePlan is the outcome of matching a find-and-replace against the stored widgets.
+type replacePlan struct {
+	diffs        []models.WidgetReplaceDiff
+	configs      map[uuid.UUID]json.RawMessage
+	replacements int
+}
+
+// planReplace lists the widgets selected by q and rewrites their configs with replacer, without
+// storing anything.
+func planReplace(ctx context.Context, widgets repository.WidgetStore, q repository.WidgetQuery, replacer *configReplacer) (*replacePlan, error) {
+	list, err := widgets.List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &replacePlan{diffs: []models.WidgetReplaceDiff{}, configs: make(map[uuid.UUID]json.RawMessage)}
+	for _, w := range list.Widgets {
+		config, changes, err := replacer.rewrite(w.Config)
+		if err != nil || len(changes) == 0 {
+			continue
+		}
+		if len(plan.diffs) == maxReplaceWidgets {
+			return nil, fail(http.StatusBadRequest, models.NewBadRequestError("The replacement would change more than 1000 widgets; narrow it down with types or page_ids"))
+		}
+		plan.diffs = append(plan.diffs, models.WidgetReplaceDiff{
+			WidgetID:   w.ID,
+			WidgetType: w.Type,
+			PageID:     w.PageID,
+			PageName:   w.PageName,
+			PageRoute:  w.PageRoute,
+			Changes:    changes,
+		})
+		plan.configs[w.ID] = config
+		plan.replacements += len(changes)
+	}
+	return plan, nil
+}
+
+// ReplaceInConfigs processes requests to find and replace text across widget configs. By default it
+// only previews the change, returning the diff of every affected widget. With apply=true the same
+// diff is recomputed and stored in one unit of work, together with an audit entry describing it,
+// unless the changed configs reference design tokens the app theme does not define.
+func (h *WidgetHandler) ReplaceInConfigs(c *gin.Context) {
+	var req models.WidgetReplaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, response.FromBindingError(err))
+		return
+	}
+
+	apply := false
+	if v := c.Query("apply"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("apply", "boolean", "apply must be true or false"))
+			return
+		}
+		apply = parsed
+	}
+
+	for _, t := range req.Types {
+		if !models.IsValidWidgetType(t) {
+			response.Error(c, http.StatusBadRequest, models.NewFieldValidationError("types", "oneof", "Invalid widget type filter"))
+			return
+		}
+	}
+
+	replacer, err := newConfigReplacer(req)
+	if err != nil {
+		writeError(c, err, "Failed to replace in widget configs")
+		return
+	}
+
+	q := repository.WidgetQuery{Types: req.Types, PageIDs: req.PageIDs}
+	if replacer.scope != nil {
+		q.Config = []repository.ConfigPredicate{*replacer.scope}
+	}
+
+	resp := models.WidgetReplaceResponse{Applied: apply}
+	var plan *replacePlan
+	if !apply {
+		plan, err = planReplace(c.Request.Context(), h.widgetRepo, q, replacer)
+	} else {
+		err = h.uow.Do(c.Request.Context(), func(ctx context.Context, tx repository.Stores) error {
+			plan, err = planReplace(ctx, tx.Widgets, q, replacer)
+			if err != nil || len(plan.diffs) == 0 {
+				return err
+			}
+			configs := make([]json.RawMessage, 0, len(plan.configs))
+			for _, config := range plan.configs {
+				configs = append(configs, config)
+			}
+			if err := storedTokens(ctx, tx.Themes, "replacement", models.TokenReferences(configs...)); err != nil {
+				return err
+			}
+			for _, diff := range plan.diffs {
+				if _, err := tx.Widgets.Update(ctx, diff.WidgetID, map[string]interface{}{"config": plan.configs[diff.WidgetID]}); err != nil {
+					return err
+				}
+			}
+
+			details, err := json.Marshal(gin.H{
+				"request":      req,
+				"replacements": plan.replacements,
+				"diffs":        plan.diffs,
+			})
+			if err != nil {
+				return err
+			}
+			entry := &models.AuditEntry{
+				Action:    models.AuditActionWidgetReplace,
+				Actor:     middleware.Actor(c),
+				RequestID: requestid.FromContext(ctx),
+				Details:   details,
+			}
+			if err := tx.Audit.Record(ctx, entry); err != nil {
+				return err
+			}
+			resp.AuditID = &entry.ID
+			return nil
+		})
+	}
+	if err != nil {
+		writeError(c, err, "Failed to replace in widget configs")
+		return
+	}
+
+	resp.Widgets = len(plan.diffs)
+	resp.Replacements = plan.replacements
+	resp.Diffs = plan.diffs
+	c.JSON(http.StatusOK, resp)
 }
@@ -0,0 +1,21 @@
+package i18n
+
+// Catalog is the content of a translation file: the strings of one source locale, each with its
+// translation into one target locale, if any.
+type Catalog struct {
+	SourceLang string
+	TargetLang string
+	Units      []Unit
+}
+
+// Unit is one translatable string of a catalog, identified by an ID stable across exports. Units
+// with the same Group are written together, as one <file> of an XLIFF document. Note gives
+// translators context, and Fuzzy marks a target that still needs review, which is not imported.
+type Unit struct {
+	ID     string
+	Group  string
+	Note   string
+	Source string
+	Target string
+	Fuzzy  bool
+}
@@ -0,0 +1,194 @@
+// Package i18n normalizes locale tags, parses Accept-Language headers and builds the fallback
+// chains by which localized page names and widget configs are selected, such as es-MX, es, en.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Text directions reported for a locale.
+const (
+	LTR = "ltr"
+	RTL = "rtl"
+)
+
+// MaxTagLength caps the length of a locale tag.
+const MaxTagLength = 35
+
+// rtlLanguages lists the languages written right to left.
+var rtlLanguages = map[string]bool{
+	"ar": true, "arc": true, "ckb": true, "dv": true, "fa": true, "he": true,
+	"ks": true, "ku": true, "ps": true, "sd": true, "ug": true, "ur": true, "yi": true,
+}
+
+// Normalize returns the canonical form of a BCP 47 language tag: a lower-case language,
+// a title-case script, an upper-case region and lower-case variants, joined by hyphens, as in
+// "es-MX" or "zh-Hant-TW". Underscores are accepted as separators. ok is false when tag is not a
+// well-formed tag of the form language[-script][-region][-variant...].
+func Normalize(tag string) (normalized string, ok bool) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || len(tag) > MaxTagLength {
+		return "", false
+	}
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+
+	lang := strings.ToLower(parts[0])
+	if len(lang) < 2 || len(lang) > 3 || !isAlpha(lang) {
+		return "", false
+	}
+	out := []string{lang}
+
+	rest := parts[1:]
+	if len(rest) > 0 && len(rest[0]) == 4 && isAlpha(rest[0]) {
+		out = append(out, strings.ToUpper(rest[0][:1])+strings.ToLower(rest[0][1:]))
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && ((len(rest[0]) == 2 && isAlpha(rest[0])) || (len(rest[0]) == 3 && isDigits(rest[0]))) {
+		out = append(out, strings.ToUpper(rest[0]))
+		rest = rest[1:]
+	}
+	for _, variant := range rest {
+		if len(variant) < 4 || len(variant) > 8 || !isAlnum(variant) {
+			return "", false
+		}
+		out = append(out, strings.ToLower(variant))
+	}
+	return strings.Join(out, "-"), true
+}
+
+// Parent returns the tag with its last subtag removed, such as "es" for "es-MX", or "" for a bare
+// language.
+func Parent(tag string) string {
+	if i := strings.LastIndexByte(tag, '-'); i >= 0 {
+		return tag[:i]
+	}
+	return ""
+}
+
+// Lineage returns a normalized tag followed by its parents, most specific first: "zh-Hant-TW",
+// "zh-Hant", "zh".
+func Lineage(tag string) []string {
+	var tags []string
+	for ; tag != ""; tag = Parent(tag) {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Direction returns RTL for locales of languages written right to left, and LTR otherwise.
+func Direction(tag string) string {
+	lang := tag
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		lang = tag[:i]
+	}
+	if rtlLanguages[strings.ToLower(lang)] {
+		return RTL
+	}
+	return LTR
+}
+
+// ParseAcceptLanguage returns the well-formed tags of an Accept-Language header, normalized and
+// ordered by decreasing quality, keeping the header's order among equal qualities. The wildcard,
+// malformed tags and tags with q=0 are dropped.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		q := 1.0
+		for _, param := range fields[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+		tag, ok := Normalize(fields[0])
+		if !ok || q == 0 {
+			continue
+		}
+		ranges = append(ranges, weighted{tag, q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags
+}
+
+// Chain returns the locales to try for a client preferring the given normalized tags, in order:
+// each tag followed by its parents, without duplicates, ending with fallback when it is not
+// already in the chain. A parent is tried after the tag it comes from, but before the next
+// preferred tag only when no later tag belongs to the same language, so that "es-MX, es-ES"
+// tries es-ES before falling back to es.
+func Chain(preferred []string, fallback string) []string {
+	seen := make(map[string]bool)
+	var chain []string
+	add := func(tag string) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			chain = append(chain, tag)
+		}
+	}
+	for i, tag := range preferred {
+		add(tag)
+		for parent := Parent(tag); parent != ""; parent = Parent(parent) {
+			if !laterMatch(preferred[i+1:], parent) {
+				add(parent)
+			}
+		}
+	}
+	add(fallback)
+	return chain
+}
+
+// laterMatch reports whether one of tags is parent or one of its descendants.
+func laterMatch(tags []string, parent string) bool {
+	for _, tag := range tags {
+		if tag == parent || strings.HasPrefix(tag, parent+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// isAlpha reports whether s consists of ASCII letters.
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits reports whether s consists of ASCII digits.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlnum reports whether s consists of ASCII letters and digits.
+func isAlnum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isAlpha(s[i:i+1]) && !isDigits(s[i:i+1]) {
+			return false
+		}
+	}
+	return true
+}
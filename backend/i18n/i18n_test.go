@@ -0,0 +1,155 @@
+// Package i18n contains tests for locale tag normalization, Accept-Language parsing and fallback chains.
+package i18n
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestNormalize verifies the canonical casing of tags and the rejection of malformed ones.
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"en", "en", true},
+		{"ES_mx", "es-MX", true},
+		{"zh-hant-tw", "zh-Hant-TW", true},
+		{"es-419", "es-419", true},
+		{"de-DE-1996", "de-DE-1996", true},
+		{" ar ", "ar", true},
+		{"", "", false},
+		{"e", "", false},
+		{"english", "", false},
+		{"en-", "", false},
+		{"en-US-x", "", false},
+		{"*", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Normalize(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestParseAcceptLanguage verifies quality ordering and the dropping of wildcards, malformed tags and q=0.
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"es-MX", []string{"es-MX"}},
+		{"en;q=0.5, ar-sa, fr;q=0.8", []string{"ar-SA", "fr", "en"}},
+		{"de, *;q=0.1, it;q=0, !!", []string{"de"}},
+		{"pt-BR;q=0.9, pt;q=0.9", []string{"pt-BR", "pt"}},
+	}
+	for _, tt := range tests {
+		if got := ParseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// TestChain verifies that parents are tried after the tags they come from, before the fallback.
+func TestChain(t *testing.T) {
+	tests := []struct {
+		preferred []string
+		fallback  string
+		want      []string
+	}{
+		{nil, "en", []string{"en"}},
+		{[]string{"es-MX"}, "en", []string{"es-MX", "es", "en"}},
+		{[]string{"es-MX", "es-ES"}, "en", []string{"es-MX", "es-ES", "es", "en"}},
+		{[]string{"es-MX", "fr", "en"}, "en", []string{"es-MX", "es", "fr", "en"}},
+		{[]string{"zh-Hant-TW"}, "", []string{"zh-Hant-TW", "zh-Hant", "zh"}},
+	}
+	for _, tt := range tests {
+		if got := Chain(tt.preferred, tt.fallback); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Chain(%v, %q) = %v, want %v", tt.preferred, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+// TestDirection verifies that right-to-left languages are recognized with or without a region.
+func TestDirection(t *testing.T) {
+	for tag, want := range map[string]string{"ar": RTL, "ar-EG": RTL, "he": RTL, "fa-IR": RTL, "en": LTR, "es-MX": LTR} {
+		if got := Direction(tag); got != want {
+			t.Errorf("Direction(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+// testCatalog holds the units exercised by the XLIFF and PO round trips: several groups, a note,
+// multi-line and quoted text, an untranslated and a fuzzy unit.
+var testCatalog = Catalog{
+	SourceLang: "en",
+	TargetLang: "es-MX",
+	Units: []Unit{
+		{ID: "page.1.name", Group: "page.1", Note: "Page /sale", Source: "Sale", Target: "Oferta"},
+		{ID: "widget.2.title", Group: "page.1", Note: "banner on /sale", Source: `Say "hi"`, Target: `Di "hola"`},
+		{ID: "widget.2.content", Group: "page.1", Source: "Line one\nLine two\n", Target: "Línea uno\nLínea dos\n"},
+		{ID: "widget.3.alt", Group: "page.4", Source: "A <b> & c"},
+		{ID: "widget.3.label", Group: "page.4", Source: "Buy", Target: "Comprar", Fuzzy: true},
+	},
+}
+
+// TestXLIFFRoundTrip verifies that a catalog written as XLIFF 2.0 reads back unchanged.
+func TestXLIFFRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXLIFF(&buf, testCatalog); err != nil {
+		t.Fatalf("WriteXLIFF: %v", err)
+	}
+	if !strings.Contains(buf.String(), `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="es-MX">`) {
+		t.Errorf("Expected an XLIFF 2.0 root element, got:\n%s", buf.String())
+	}
+	got, err := ReadXLIFF(&buf)
+	if err != nil {
+		t.Fatalf("ReadXLIFF: %v", err)
+	}
+	if !reflect.DeepEqual(*got, testCatalog) {
+		t.Errorf("Expected the catalog to round-trip, got %+v", *got)
+	}
+
+	if _, err := ReadXLIFF(strings.NewReader(`<xliff xmlns="urn:oasis:names:tc:xliff:document:1.2" version="1.2"/>`)); err == nil {
+		t.Error("Expected an XLIFF 1.2 document to be rejected")
+	}
+}
+
+// TestPORoundTrip verifies that a catalog written as a PO file reads back unchanged, groups aside,
+// and that hand-edited files without blank lines or with obsolete entries are read.
+func TestPORoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePO(&buf, testCatalog); err != nil {
+		t.Fatalf("WritePO: %v", err)
+	}
+	got, err := ReadPO(&buf)
+	if err != nil {
+		t.Fatalf("ReadPO: %v", err)
+	}
+	want := testCatalog
+	want.Units = append([]Unit(nil), testCatalog.Units...)
+	for i := range want.Units {
+		want.Units[i].Group = ""
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("Expected the catalog to round-trip, got %+v", *got)
+	}
+
+	edited := "msgid \"\"\nmsgstr \"Language: ar\\n\"\nmsgctxt \"a\"\nmsgid \"One\"\nmsgstr \"واحد\"\n#~ msgctxt \"old\"\n#~ msgid \"Old\"\n#~ msgstr \"قديم\"\nmsgctxt \"b\"\nmsgid \"Two\"\nmsgstr \"\"\n\"اثنان\"\n"
+	got, err = ReadPO(strings.NewReader(edited))
+	if err != nil {
+		t.Fatalf("ReadPO: %v", err)
+	}
+	if got.TargetLang != "ar" || len(got.Units) != 2 || got.Units[0].Target != "واحد" || got.Units[1].ID != "b" || got.Units[1].Target != "اثنان" {
+		t.Errorf("Expected two entries in ar, got %+v", *got)
+	}
+
+	if _, err := ReadPO(strings.NewReader("msgid \"unterminated\n")); err == nil {
+		t.Error("Expected a malformed string to be rejected")
+	}
+}
@@ -0,0 +1,254 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WritePO writes the catalog as a gettext PO file. Each unit's ID is its msgctxt, so that units
+// with the same source text stay distinct, and its note an extracted comment. The header names
+// the target language, and the source language in X-Source-Language.
+func WritePO(w io.Writer, c Catalog) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	header := []string{
+		"Content-Type: text/plain; charset=UTF-8\n",
+		"Content-Transfer-Encoding: 8bit\n",
+		"Language: " + c.TargetLang + "\n",
+		"X-Source-Language: " + c.SourceLang + "\n",
+	}
+	for _, line := range header {
+		bw.WriteString(strconv.Quote(line) + "\n")
+	}
+
+	for _, u := range c.Units {
+		bw.WriteString("\n")
+		for _, line := range strings.Split(u.Note, "\n") {
+			if line != "" {
+				bw.WriteString("#. " + line + "\n")
+			}
+		}
+		if u.Fuzzy {
+			bw.WriteString("#, fuzzy\n")
+		}
+		writePOString(bw, "msgctxt", u.ID)
+		writePOString(bw, "msgid", u.Source)
+		writePOString(bw, "msgstr", u.Target)
+	}
+	return bw.Flush()
+}
+
+// writePOString writes a keyword and its quoted string, split after each newline into
+// continuation lines when the string spans several lines.
+func writePOString(w *bufio.Writer, keyword, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		w.WriteString(keyword + " " + quotePO(s) + "\n")
+		return
+	}
+	w.WriteString(keyword + " \"\"\n")
+	for _, line := range lines {
+		w.WriteString(quotePO(line) + "\n")
+	}
+}
+
+// quotePO quotes a string with the C escapes understood by gettext, leaving other characters as
+// they are since PO files are written in UTF-8.
+func quotePO(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// poEntry accumulates the fields of one PO entry while it is parsed.
+type poEntry struct {
+	notes    []string
+	fuzzy    bool
+	obsolete bool
+	fields   map[string]*strings.Builder
+	last     string
+}
+
+// ReadPO parses a gettext PO file. The msgctxt of each entry is its unit ID, its extracted
+// comments its note, and its first msgstr its target; entries flagged fuzzy are marked so, and
+// obsolete entries (#~) are skipped. The Language and X-Source-Language header fields set the
+// catalog's languages.
+func ReadPO(r io.Reader) (*Catalog, error) {
+	c := &Catalog{}
+	entry := &poEntry{fields: map[string]*strings.Builder{}}
+
+	flush := func() {
+		defer func() { *entry = poEntry{fields: map[string]*strings.Builder{}} }()
+		if entry.obsolete || len(entry.fields) == 0 {
+			return
+		}
+		get := func(name string) string {
+			if b, ok := entry.fields[name]; ok {
+				return b.String()
+			}
+			return ""
+		}
+		id, ctxt := get("msgid"), get("msgctxt")
+		target := get("msgstr")
+		if _, ok := entry.fields["msgstr[0]"]; ok {
+			target = get("msgstr[0]")
+		}
+		if id == "" && ctxt == "" {
+			c.parseHeader(target)
+			return
+		}
+		c.Units = append(c.Units, Unit{
+			ID:     ctxt,
+			Note:   strings.Join(entry.notes, "\n"),
+			Source: id,
+			Target: target,
+			Fuzzy:  entry.fuzzy,
+		})
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		startsEntry := strings.HasPrefix(line, "#") || strings.HasPrefix(line, "msgctxt") || strings.HasPrefix(line, "msgid ")
+		if (entry.translated() && startsEntry) || (entry.obsolete && !strings.HasPrefix(line, "#~")) {
+			// The next entry starts without a blank line in between.
+			flush()
+		}
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#~"):
+			entry.obsolete = true
+		case strings.HasPrefix(line, "#."):
+			entry.notes = append(entry.notes, strings.TrimSpace(line[2:]))
+		case strings.HasPrefix(line, "#,"):
+			for _, flag := range strings.Split(line[2:], ",") {
+				if strings.TrimSpace(flag) == "fuzzy" {
+					entry.fuzzy = true
+				}
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, `"`):
+			if entry.last == "" {
+				return nil, fmt.Errorf("line %d: string without a keyword", n)
+			}
+			s, err := unquotePO(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			entry.fields[entry.last].WriteString(s)
+		default:
+			keyword, rest, _ := strings.Cut(line, " ")
+			if !isPOKeyword(keyword) {
+				return nil, fmt.Errorf("line %d: unknown keyword %q", n, keyword)
+			}
+			if _, dup := entry.fields[keyword]; dup {
+				return nil, fmt.Errorf("line %d: duplicate %s", n, keyword)
+			}
+			s, err := unquotePO(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			entry.fields[keyword] = &strings.Builder{}
+			entry.fields[keyword].WriteString(s)
+			entry.last = keyword
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return c, nil
+}
+
+// translated reports whether the entry has reached its msgstr, so that a following comment or
+// msgid starts the next entry.
+func (e *poEntry) translated() bool {
+	return e.last == "msgstr" || strings.HasPrefix(e.last, "msgstr[")
+}
+
+// isPOKeyword reports whether keyword starts a field of a PO entry.
+func isPOKeyword(keyword string) bool {
+	switch keyword {
+	case "msgctxt", "msgid", "msgid_plural", "msgstr":
+		return true
+	}
+	return strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]")
+}
+
+// unquotePO decodes a quoted PO string.
+func unquotePO(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("expected a quoted string, got %s", s)
+	}
+	var b strings.Builder
+	body := s[1 : len(s)-1]
+	for i := 0; i < len(body); i++ {
+		if body[i] == '"' {
+			return "", fmt.Errorf("unescaped quote in %s", s)
+		}
+		if body[i] != '\\' {
+			b.WriteByte(body[i])
+			continue
+		}
+		if i++; i == len(body) {
+			return "", fmt.Errorf("unterminated escape in %s", s)
+		}
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\', '"':
+			b.WriteByte(body[i])
+		default:
+			return "", fmt.Errorf("unsupported escape \\%c in %s", body[i], s)
+		}
+	}
+	return b.String(), nil
+}
+
+// parseHeader reads the languages of the catalog from the header entry of a PO file.
+func (c *Catalog) parseHeader(header string) {
+	for _, line := range strings.Split(header, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "Language":
+			c.TargetLang = strings.TrimSpace(value)
+		case "X-Source-Language":
+			c.SourceLang = strings.TrimSpace(value)
+		}
+	}
+}
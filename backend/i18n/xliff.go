@@ -0,0 +1,121 @@
+package i18n
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xliffNamespace is the namespace of XLIFF 2.0 documents.
+const xliffNamespace = "urn:oasis:names:tc:xliff:document:2.0"
+
+// Segment states of XLIFF 2.0. A target in the initial state has not been confirmed by a translator.
+const (
+	xliffInitial    = "initial"
+	xliffTranslated = "translated"
+)
+
+// xliffDocument, xliffFile, xliffUnit and xliffSegment map the XLIFF 2.0 core elements used by
+// catalogs. Inline markup inside sources and targets is not supported; only its text is kept.
+type xliffDocument struct {
+	XMLName xml.Name    `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string      `xml:"version,attr"`
+	SrcLang string      `xml:"srcLang,attr"`
+	TrgLang string      `xml:"trgLang,attr,omitempty"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	ID    string      `xml:"id,attr"`
+	Units []xliffUnit `xml:"unit"`
+}
+
+type xliffUnit struct {
+	ID       string         `xml:"id,attr"`
+	Notes    []string       `xml:"notes>note,omitempty"`
+	Segments []xliffSegment `xml:"segment"`
+}
+
+type xliffSegment struct {
+	State  string  `xml:"state,attr,omitempty"`
+	Source string  `xml:"source"`
+	Target *string `xml:"target"`
+}
+
+// WriteXLIFF writes the catalog as an XLIFF 2.0 document, one <file> per group of units and one
+// <unit> with a single segment per string. Targets that are not yet translated are left out.
+func WriteXLIFF(w io.Writer, c Catalog) error {
+	doc := xliffDocument{Version: "2.0", SrcLang: c.SourceLang, TrgLang: c.TargetLang}
+	for _, u := range c.Units {
+		if len(doc.Files) == 0 || doc.Files[len(doc.Files)-1].ID != fileID(u.Group) {
+			doc.Files = append(doc.Files, xliffFile{ID: fileID(u.Group)})
+		}
+		unit := xliffUnit{ID: u.ID, Segments: []xliffSegment{{State: xliffInitial, Source: u.Source}}}
+		if u.Note != "" {
+			unit.Notes = []string{u.Note}
+		}
+		if u.Target != "" {
+			target := u.Target
+			unit.Segments[0].Target = &target
+			if !u.Fuzzy {
+				unit.Segments[0].State = xliffTranslated
+			}
+		}
+		file := &doc.Files[len(doc.Files)-1]
+		file.Units = append(file.Units, unit)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// fileID returns the ID of the <file> holding a group of units.
+func fileID(group string) string {
+	if group == "" {
+		return "f1"
+	}
+	return group
+}
+
+// ReadXLIFF parses an XLIFF 2.0 document. The segments of a unit are joined into one source and
+// one target, and a translated unit whose segments are all in the initial state is marked fuzzy.
+func ReadXLIFF(r io.Reader) (*Catalog, error) {
+	var doc xliffDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid XLIFF: %w", err)
+	}
+	if !strings.HasPrefix(doc.Version, "2.") {
+		return nil, fmt.Errorf("unsupported XLIFF version %q, expected 2.0", doc.Version)
+	}
+
+	c := &Catalog{SourceLang: doc.SrcLang, TargetLang: doc.TrgLang}
+	for _, f := range doc.Files {
+		for _, u := range f.Units {
+			unit := Unit{ID: u.ID, Group: f.ID, Note: strings.Join(u.Notes, "\n"), Fuzzy: len(u.Segments) > 0}
+			var source, target strings.Builder
+			for _, s := range u.Segments {
+				source.WriteString(s.Source)
+				if s.Target != nil {
+					target.WriteString(*s.Target)
+				}
+				if s.State != xliffInitial {
+					unit.Fuzzy = false
+				}
+			}
+			unit.Source = source.String()
+			unit.Target = target.String()
+			unit.Fuzzy = unit.Fuzzy && unit.Target != ""
+			c.Units = append(c.Units, unit)
+		}
+	}
+	return c, nil
+}
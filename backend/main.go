@@ -1,79 +1,108 @@
 // Package main serves as the entry point for the Mini App Configuration API.
-// It initializes the database connection, repositories, handlers, and the web server.
+// It dispatches the command-line subcommands, the default of which starts the web server.
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 
-	"appdrop/database"
-	"appdrop/handlers"
-	"appdrop/middleware"
-	"appdrop/repository"
+	"appdrop/config"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
-// main is the primary execution function that sets up the application infrastructure,
-// configures the routing engine, and starts the HTTP server.
-func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
-	}
-
-	dbConfig := database.NewConfigFromEnv()
-	if err := database.Connect(dbConfig); err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	defer database.Close()
-
-	db := database.GetDB()
-	pageRepo := repository.NewPageRepository(db)
-	widgetRepo := repository.NewWidgetRepository(db)
-
-	pageHandler := handlers.NewPageHandler(pageRepo, widgetRepo)
-	widgetHandler := handlers.NewWidgetHandler(widgetRepo, pageRepo)
+// usage describes the available subcommands.
+const usage = `Usage: appdrop [command] [flags]
 
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.New()
+Commands:
+  serve           Start the HTTP API (default)
+  config print    Print the effective configuration with secrets redacted
+  migrate up      Apply every pending schema migration
+  migrate down    Roll back the latest migration, or the last N with "migrate down N"
+  migrate status  List migrations and whether each has been applied
+  migrate create  Create an empty migration file: "migrate create <name>"
 
-	router.Use(middleware.Recovery())
-	router.Use(middleware.Logger())
-	router.Use(middleware.CORS())
+Run "appdrop <command> -h" to list the flags of a command.
+`
 
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "healthy",
-			"service": "Mini App Config API",
-			"version": "1.0.0",
-		})
-	})
+// main is the primary execution function that loads the environment and runs the requested subcommand.
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	api := router.Group("/")
-	{
-		api.GET("/pages", pageHandler.ListPages)
-		api.GET("/pages/:id", pageHandler.GetPage)
-		api.POST("/pages", pageHandler.CreatePage)
-		api.PUT("/pages/:id", pageHandler.UpdatePage)
-		api.DELETE("/pages/:id", pageHandler.DeletePage)
+// run dispatches the subcommand named by args and returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	envErr := godotenv.Load()
 
-		api.GET("/pages/:id/widgets", widgetHandler.GetWidgets)
-		api.POST("/pages/:id/widgets", widgetHandler.CreateWidget)
-		api.POST("/pages/:id/widgets/reorder", widgetHandler.ReorderWidgets)
-		api.PUT("/widgets/:id", widgetHandler.UpdateWidget)
-		api.DELETE("/widgets/:id", widgetHandler.DeleteWidget)
+	command := "serve"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		command, args = args[0], args[1:]
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	switch command {
+	case "serve":
+		cfg, code, ok := loadConfig("appdrop serve", args, stderr)
+		if !ok {
+			return code
+		}
+		logger := newLogger(cfg.Log.Level)
+		slog.SetDefault(logger)
+		if envErr != nil {
+			logger.Info("No .env file found, using system environment variables")
+		}
+		if err := serve(cfg, logger); err != nil {
+			logger.Error("Server terminated with error", "error", err)
+			return 1
+		}
+		return 0
+	case "config":
+		if len(args) == 0 || args[0] != "print" {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		cfg, code, ok := loadConfig("appdrop config print", args[1:], stderr)
+		if !ok {
+			return code
+		}
+		if err := cfg.WriteYAML(stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	case "migrate":
+		return runMigrate(args, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
 	}
+}
 
-	log.Printf("Mini App Config API running on port %s", port)
-	log.Printf("API Documentation: http://localhost:%s/health", port)
+// loadConfig loads the layered configuration, printing every problem and returning the exit code on failure.
+func loadConfig(name string, args []string, stderr io.Writer) (*config.Config, int, bool) {
+	cfg, err := config.Load(name, args, os.LookupEnv, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, 0, false
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, 2, false
+	}
+	return cfg, 0, true
+}
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+// newLogger builds the JSON structured logger used for application and request logs.
+// The level is parsed from names such as "debug", "info", "warn" or "error", defaulting to info.
+func newLogger(level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
 	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: lvl}))
 }
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PageCounter reports the number of stored pages.
+type PageCounter interface {
+	Count(ctx context.Context) (int, error)
+}
+
+// WidgetTypeCounter reports the number of stored widgets grouped by widget type.
+type WidgetTypeCounter interface {
+	CountByType(ctx context.Context) (map[string]int, error)
+}
+
+// Middleware returns a Gin handler that records the count and latency of every request.
+// Requests that match no route are grouped under a single "unmatched" route label to bound cardinality.
+func (r *Registry) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		r.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// Handler returns a Gin handler that serves the registry in the Prometheus text exposition format.
+func (r *Registry) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := WriteText(c.Writer, r.Gather()); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// DBStatsCollector exposes connection pool statistics of the given database handle as gauges and counters.
+func DBStatsCollector(db *sql.DB) Collector {
+	return CollectorFunc(func() ([]Family, error) {
+		stats := db.Stats()
+		return []Family{
+			gauge("appdrop_db_max_open_connections", "Maximum number of open connections to the database.", float64(stats.MaxOpenConnections)),
+			gauge("appdrop_db_open_connections", "Number of established connections, both in use and idle.", float64(stats.OpenConnections)),
+			gauge("appdrop_db_in_use_connections", "Number of connections currently in use.", float64(stats.InUse)),
+			gauge("appdrop_db_idle_connections", "Number of idle connections.", float64(stats.Idle)),
+			counter("appdrop_db_wait_count_total", "Total number of connections waited for.", float64(stats.WaitCount)),
+			counter("appdrop_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", stats.WaitDuration.Seconds()),
+		}, nil
+	})
+}
+
+// ContentCollector exposes business gauges describing the stored app configuration.
+// Collection is not tied to a scrape request, so the stores' per-query timeout bounds each count.
+func ContentCollector(pages PageCounter, widgets WidgetTypeCounter) Collector {
+	return CollectorFunc(func() ([]Family, error) {
+		ctx := context.Background()
+		pageCount, err := pages.Count(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byType, err := widgets.CountByType(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		types := make([]string, 0, len(byType))
+		for t := range byType {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+
+		widgetFamily := Family{
+			Name: "appdrop_widgets",
+			Help: "Number of stored widgets by widget type.",
+			Type: TypeGauge,
+		}
+		for _, t := range types {
+			widgetFamily.Samples = append(widgetFamily.Samples, Sample{
+				Labels: []Label{{"type", t}},
+				Value:  float64(byType[t]),
+			})
+		}
+
+		return []Family{
+			gauge("appdrop_pages", "Number of stored pages.", float64(pageCount)),
+			widgetFamily,
+		}, nil
+	})
+}
+
+// gauge builds a single-sample gauge family.
+func gauge(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Value: value}}}
+}
+
+// counter builds a single-sample counter family.
+func counter(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Value: value}}}
+}
@@ -0,0 +1,271 @@
+// Package metrics records runtime measurements and exposes them in the Prometheus text exposition format.
+// It implements the small subset of the format the API needs so no client library is required.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the latency histogram upper bounds, in seconds, used for HTTP request durations.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricType identifies the Prometheus type of a metric family.
+type MetricType string
+
+const (
+	// TypeCounter represents a monotonically increasing value.
+	TypeCounter MetricType = "counter"
+	// TypeGauge represents a value that can rise and fall.
+	TypeGauge MetricType = "gauge"
+	// TypeHistogram represents a distribution of observations across buckets.
+	TypeHistogram MetricType = "histogram"
+)
+
+// Label is a single name/value pair attached to a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single measurement within a metric family.
+// Suffix is appended to the family name, as with the "_bucket", "_sum" and "_count" series of histograms.
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family groups the samples of a single metric name with its help text and type.
+type Family struct {
+	Name    string
+	Help    string
+	Type    MetricType
+	Samples []Sample
+}
+
+// Collector produces metric families at scrape time, such as gauges read from the database.
+type Collector interface {
+	Collect() ([]Family, error)
+}
+
+// CollectorFunc adapts an ordinary function to the Collector interface.
+type CollectorFunc func() ([]Family, error)
+
+// Collect invokes the underlying function.
+func (f CollectorFunc) Collect() ([]Family, error) {
+	return f()
+}
+
+// requestKey identifies the label set of an HTTP request series.
+type requestKey struct {
+	method string
+	route  string
+	status string
+}
+
+// histogram accumulates observations into cumulative buckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Registry holds the HTTP request series and the collectors consulted on every scrape.
+type Registry struct {
+	buckets    []float64
+	mu         sync.Mutex
+	requests   map[requestKey]uint64
+	durations  map[requestKey]*histogram
+	collectors []Collector
+}
+
+// NewRegistry initializes an empty Registry using the default latency buckets.
+func NewRegistry() *Registry {
+	return &Registry{
+		buckets:   DefaultBuckets,
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[requestKey]*histogram),
+	}
+}
+
+// Register adds a collector whose families are included in every exposition.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// ObserveRequest records a completed HTTP request for the given route template and status.
+func (r *Registry) ObserveRequest(method, route string, status int, duration time.Duration) {
+	key := requestKey{method: method, route: route, status: strconv.Itoa(status)}
+	seconds := duration.Seconds()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[key]++
+
+	h, ok := r.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.buckets))}
+		r.durations[key] = h
+	}
+	for i, upper := range r.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Gather assembles the HTTP families and the output of every registered collector.
+// Collector failures are reported through appdrop_scrape_collector_errors rather than aborting the scrape.
+func (r *Registry) Gather() []Family {
+	r.mu.Lock()
+	families := []Family{r.requestFamily(), r.durationFamily()}
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	failed := 0
+	for _, c := range collectors {
+		collected, err := c.Collect()
+		if err != nil {
+			failed++
+			continue
+		}
+		families = append(families, collected...)
+	}
+
+	families = append(families, Family{
+		Name:    "appdrop_scrape_collector_errors",
+		Help:    "Number of collectors that failed during this scrape.",
+		Type:    TypeGauge,
+		Samples: []Sample{{Value: float64(failed)}},
+	})
+	return families
+}
+
+// requestFamily builds the HTTP request counter family. The caller must hold r.mu.
+func (r *Registry) requestFamily() Family {
+	family := Family{
+		Name: "appdrop_http_requests_total",
+		Help: "Total number of HTTP requests by method, route template and status.",
+		Type: TypeCounter,
+	}
+	for _, key := range sortedKeys(r.requests) {
+		family.Samples = append(family.Samples, Sample{Labels: key.labels(), Value: float64(r.requests[key])})
+	}
+	return family
+}
+
+// durationFamily builds the HTTP latency histogram family. The caller must hold r.mu.
+func (r *Registry) durationFamily() Family {
+	family := Family{
+		Name: "appdrop_http_request_duration_seconds",
+		Help: "HTTP request latency by method, route template and status.",
+		Type: TypeHistogram,
+	}
+	for _, key := range sortedKeys(r.durations) {
+		h := r.durations[key]
+		labels := key.labels()
+		for i, upper := range r.buckets {
+			family.Samples = append(family.Samples, Sample{
+				Suffix: "_bucket",
+				Labels: append(labels[:len(labels):len(labels)], Label{"le", formatFloat(upper)}),
+				Value:  float64(h.counts[i]),
+			})
+		}
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_bucket", Labels: append(labels[:len(labels):len(labels)], Label{"le", "+Inf"}), Value: float64(h.count)},
+			Sample{Suffix: "_sum", Labels: labels, Value: h.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(h.count)},
+		)
+	}
+	return family
+}
+
+// sortedKeys returns the request keys of a series map in a stable order so consecutive scrapes are comparable.
+func sortedKeys[V any](series map[requestKey]V) []requestKey {
+	keys := make([]requestKey, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+	return keys
+}
+
+// labels converts the key into its ordered label set.
+func (k requestKey) labels() []Label {
+	return []Label{{"method", k.method}, {"route", k.route}, {"status", k.status}}
+}
+
+// WriteText encodes the families in the Prometheus text exposition format, version 0.0.4.
+func WriteText(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, s := range family.Samples {
+			bw.WriteString(family.Name + s.Suffix)
+			writeLabels(bw, s.Labels)
+			bw.WriteString(" " + formatFloat(s.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// writeLabels encodes a label set in braces, omitting the braces entirely when there are no labels.
+func writeLabels(w *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	w.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(l.Name + `="` + escapeLabel(l.Value) + `"`)
+	}
+	w.WriteByte('}')
+}
+
+// escapeLabel escapes backslashes, double quotes and newlines in label values.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// escapeHelp escapes backslashes and newlines in help text.
+func escapeHelp(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v)
+}
+
+// formatFloat renders a sample value using the shortest exact representation.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,44 @@
+// Package metrics contains unit tests for verifying the Prometheus text exposition output.
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteTextExposition verifies counters, histograms and collector gauges are encoded in the text format.
+func TestWriteTextExposition(t *testing.T) {
+	registry := NewRegistry()
+	registry.ObserveRequest("GET", "/pages/:id", 200, 30*time.Millisecond)
+	registry.ObserveRequest("GET", "/pages/:id", 200, 2*time.Second)
+	registry.Register(CollectorFunc(func() ([]Family, error) {
+		return []Family{{
+			Name:    "appdrop_widgets",
+			Help:    "Number of stored widgets by widget type.",
+			Type:    TypeGauge,
+			Samples: []Sample{{Labels: []Label{{"type", `ban"ner`}}, Value: 3}},
+		}}, nil
+	}))
+
+	var out strings.Builder
+	if err := WriteText(&out, registry.Gather()); err != nil {
+		t.Fatalf("WriteText returned error: %v", err)
+	}
+	text := out.String()
+
+	expected := []string{
+		"# TYPE appdrop_http_requests_total counter",
+		`appdrop_http_requests_total{method="GET",route="/pages/:id",status="200"} 2`,
+		`appdrop_http_request_duration_seconds_bucket{method="GET",route="/pages/:id",status="200",le="0.05"} 1`,
+		`appdrop_http_request_duration_seconds_bucket{method="GET",route="/pages/:id",status="200",le="+Inf"} 2`,
+		`appdrop_http_request_duration_seconds_sum{method="GET",route="/pages/:id",status="200"} 2.03`,
+		`appdrop_widgets{type="ban\"ner"} 3`,
+		"appdrop_scrape_collector_errors 0",
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", line, text)
+		}
+	}
+}
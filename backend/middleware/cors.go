@@ -0,0 +1,298 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig describes a Cross-Origin Resource Sharing policy applied to one route group.
+// Allowed origins are exact origins such as "https://admin.example.com", subdomain wildcards
+// such as "https://*.example.com", or "*" to allow any origin.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// originPattern is a parsed entry of the allowed origins list.
+type originPattern struct {
+	any      bool
+	scheme   string
+	host     string
+	port     string
+	wildcard bool
+}
+
+// CORSPolicy applies a CORSConfig to requests, reflecting allowed origins back to the browser.
+type CORSPolicy struct {
+	origins        []originPattern
+	methods        map[string]bool
+	allowMethods   string
+	allowHeaders   string
+	exposeHeaders  string
+	maxAge         string
+	credentials    bool
+	wildcardOrigin bool
+}
+
+// NewCORSPolicy compiles the configuration into a policy. Origin entries that cannot be parsed are ignored;
+// configuration validation is expected to have rejected them already.
+func NewCORSPolicy(config CORSConfig) *CORSPolicy {
+	p := &CORSPolicy{
+		methods:       make(map[string]bool),
+		allowMethods:  strings.Join(config.AllowedMethods, ", "),
+		allowHeaders:  strings.Join(config.AllowedHeaders, ", "),
+		exposeHeaders: strings.Join(config.ExposedHeaders, ", "),
+		maxAge:        strconv.Itoa(int(config.MaxAge.Seconds())),
+		credentials:   config.AllowCredentials,
+	}
+	for _, m := range config.AllowedMethods {
+		p.methods[strings.ToUpper(m)] = true
+	}
+	for _, o := range config.AllowedOrigins {
+		if pattern, ok := parseOriginPattern(o); ok {
+			p.origins = append(p.origins, pattern)
+			p.wildcardOrigin = p.wildcardOrigin || pattern.any
+		}
+	}
+	return p
+}
+
+// parseOriginPattern parses an allowed origin entry, reporting false when it is malformed.
+func parseOriginPattern(s string) (originPattern, bool) {
+	if s == "*" {
+		return originPattern{any: true}, true
+	}
+
+	u, err := url.Parse(strings.ToLower(s))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return originPattern{}, false
+	}
+
+	pattern := originPattern{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+	if strings.HasPrefix(pattern.host, "*.") {
+		pattern.wildcard = true
+		pattern.host = pattern.host[1:]
+	}
+	if strings.Contains(pattern.host, "*") || pattern.host == "" || pattern.host == "." {
+		return originPattern{}, false
+	}
+	return pattern, true
+}
+
+// matches reports whether the parsed request origin satisfies the pattern.
+func (p originPattern) matches(origin *url.URL) bool {
+	if p.any {
+		return true
+	}
+	if origin.Scheme != p.scheme || origin.Port() != p.port {
+		return false
+	}
+	host := origin.Hostname()
+	if p.wildcard {
+		return strings.HasSuffix(host, p.host) && len(host) > len(p.host)
+	}
+	return host == p.host
+}
+
+// AllowsOrigin reports whether the browser origin is permitted by the policy.
+func (p *CORSPolicy) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, pattern := range p.origins {
+		if pattern.matches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMethod reports whether the policy permits the HTTP method.
+func (p *CORSPolicy) AllowsMethod(method string) bool {
+	return p.methods[strings.ToUpper(method)]
+}
+
+// setOriginHeaders writes the headers shared by preflight and actual responses for an allowed origin.
+// Origins are reflected rather than answered with "*" whenever credentials are enabled or the
+// allowlist is not a plain wildcard, and Vary tells caches that the response depends on Origin.
+func (p *CORSPolicy) setOriginHeaders(c *gin.Context, origin string) {
+	if p.wildcardOrigin && !p.credentials {
+		c.Header("Access-Control-Allow-Origin", "*")
+	} else {
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Writer.Header().Add("Vary", "Origin")
+	}
+	if p.credentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Middleware returns a Gin handler that decorates actual cross-origin requests of the route group.
+// Requests from origins outside the allowlist are served without CORS headers, so browsers block the response.
+func (p *CORSPolicy) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if origin := c.GetHeader("Origin"); p.AllowsOrigin(origin) {
+			p.setOriginHeaders(c, origin)
+			if p.exposeHeaders != "" {
+				c.Header("Access-Control-Expose-Headers", p.exposeHeaders)
+			}
+		}
+		c.Next()
+	}
+}
+
+// CORSRoutes records the CORS policy of every route, so that preflight requests, which do not match
+// any registered route, are answered with the policy of the route they precede. Groups sharing a path,
+// such as delivery GET /pages and management POST /pages, are told apart by the requested method.
+type CORSRoutes struct {
+	routes []corsRoute
+}
+
+// corsRoute is a recorded route: its method, the segments of its path template and its policy.
+type corsRoute struct {
+	method   string
+	segments []string
+	policy   *CORSPolicy
+}
+
+// NewCORSRoutes initializes an empty CORSRoutes.
+func NewCORSRoutes() *CORSRoutes {
+	return &CORSRoutes{}
+}
+
+// Group creates a router group under relativePath whose routes are served with policy, followed by
+// handlers, and recorded for preflight requests.
+func (r *CORSRoutes) Group(parent gin.IRouter, relativePath string, policy *CORSPolicy, handlers ...gin.HandlerFunc) *CORSGroup {
+	group := parent.Group(relativePath, append([]gin.HandlerFunc{policy.Middleware()}, handlers...)...)
+	return &CORSGroup{RouterGroup: group, policy: policy, routes: r}
+}
+
+// add records a route with its policy.
+func (r *CORSRoutes) add(method, template string, policy *CORSPolicy) {
+	r.routes = append(r.routes, corsRoute{method: method, segments: splitPath(template), policy: policy})
+}
+
+// policy returns the policy of the route that a request with the method and path would be served by,
+// preferring static path segments over parameters as the router does, or nil when no route matches.
+func (r *CORSRoutes) policy(method, urlPath string) *CORSPolicy {
+	segments := splitPath(urlPath)
+	var best *CORSPolicy
+	bestStatic := -1
+	for _, route := range r.routes {
+		if route.method != method {
+			continue
+		}
+		if static, ok := route.match(segments); ok && static > bestStatic {
+			best, bestStatic = route.policy, static
+		}
+	}
+	return best
+}
+
+// match reports whether the path segments fit the route template, along with the number of static
+// segments of the template, which ranks the routes that fit.
+func (route corsRoute) match(segments []string) (int, bool) {
+	if len(segments) != len(route.segments) {
+		return 0, false
+	}
+	static := 0
+	for i, s := range route.segments {
+		switch {
+		case strings.HasPrefix(s, ":"):
+			if segments[i] == "" {
+				return 0, false
+			}
+		case s == segments[i]:
+			static++
+		default:
+			return 0, false
+		}
+	}
+	return static, true
+}
+
+// splitPath returns the segments of a slash-separated path.
+func splitPath(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+// CORSGroup is a router group whose routes are recorded with the group's CORS policy, so that their
+// preflights are answered by it. Routes are registered with the usual Gin methods.
+type CORSGroup struct {
+	*gin.RouterGroup
+	policy *CORSPolicy
+	routes *CORSRoutes
+}
+
+// Handle registers a route on the group and records it for preflight requests.
+func (g *CORSGroup) Handle(method, relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	g.routes.add(method, path.Join(g.BasePath(), relativePath), g.policy)
+	return g.RouterGroup.Handle(method, relativePath, handlers...)
+}
+
+// GET registers a GET route on the group.
+func (g *CORSGroup) GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+// POST registers a POST route on the group.
+func (g *CORSGroup) POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+// PUT registers a PUT route on the group.
+func (g *CORSGroup) PUT(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPut, relativePath, handlers...)
+}
+
+// PATCH registers a PATCH route on the group.
+func (g *CORSGroup) PATCH(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPatch, relativePath, handlers...)
+}
+
+// DELETE registers a DELETE route on the group.
+func (g *CORSGroup) DELETE(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodDelete, relativePath, handlers...)
+}
+
+// CORSPreflight initializes and returns a middleware handler that answers CORS preflight requests.
+// Because preflights do not match any registered route, the policy is the one recorded in routes for
+// the route serving the requested path with the method named in Access-Control-Request-Method, and
+// it still has to allow the requesting origin and the method. Other preflights get no CORS headers,
+// so browsers block the request. Non-preflight requests pass through untouched.
+func CORSPreflight(routes *CORSRoutes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestedMethod := c.GetHeader("Access-Control-Request-Method")
+		if c.Request.Method != http.MethodOptions || requestedMethod == "" {
+			c.Next()
+			return
+		}
+
+		origin := c.GetHeader("Origin")
+		if p := routes.policy(strings.ToUpper(requestedMethod), c.Request.URL.Path); p != nil && p.AllowsOrigin(origin) && p.AllowsMethod(requestedMethod) {
+			p.setOriginHeaders(c, origin)
+			c.Header("Access-Control-Allow-Methods", p.allowMethods)
+			if p.allowHeaders != "" {
+				c.Header("Access-Control-Allow-Headers", p.allowHeaders)
+			}
+			c.Header("Access-Control-Max-Age", p.maxAge)
+		}
+
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,148 @@
+// Package middleware contains unit tests for verifying CORS origin matching and preflight handling.
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestCORSPolicyAllowsOrigin verifies exact, wildcard-subdomain and port matching of allowed origins.
+func TestCORSPolicyAllowsOrigin(t *testing.T) {
+	policy := NewCORSPolicy(CORSConfig{
+		AllowedOrigins: []string{"https://admin.example.com", "https://*.apps.example.com", "http://localhost:3000"},
+		AllowedMethods: []string{"GET"},
+	})
+
+	cases := map[string]bool{
+		"https://admin.example.com":     true,
+		"https://ADMIN.example.com":     true,
+		"http://admin.example.com":      false,
+		"https://evil.example.com":      false,
+		"https://shop.apps.example.com": true,
+		"https://apps.example.com":      false,
+		"https://shopapps.example.com":  false,
+		"http://localhost:3000":         true,
+		"http://localhost:4000":         false,
+		"null":                          false,
+		"":                              false,
+	}
+	for origin, want := range cases {
+		if got := policy.AllowsOrigin(origin); got != want {
+			t.Errorf("AllowsOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+// TestCORSPreflightSelectsPolicy verifies that preflights are answered by the policy of the route group
+// serving the requested path and method, even when another group allows the same method, and that
+// credentialed policies reflect the origin instead of answering "*".
+func TestCORSPreflightSelectsPolicy(t *testing.T) {
+	delivery := NewCORSPolicy(CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "HEAD"},
+		MaxAge:         time.Minute,
+	})
+	management := NewCORSPolicy(CORSConfig{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes := NewCORSRoutes()
+	router.Use(CORSPreflight(routes))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	routes.Group(router, "/", delivery).GET("/pages", ok)
+	managed := routes.Group(router, "/", management)
+	managed.POST("/pages", ok)
+	managed.DELETE("/pages/:id", ok)
+	managed.GET("/segments", ok)
+
+	preflight := func(path, origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("/pages", "https://anywhere.test", http.MethodGet)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected wildcard delivery preflight, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	w = preflight("/pages/42", "https://admin.example.com", http.MethodDelete)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+		t.Errorf("Expected reflected origin, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected credentials and Vary: Origin, got %v", w.Header())
+	}
+
+	for _, path := range []string{"/segments"} {
+		w = preflight(path, "https://admin.example.com", http.MethodGet)
+		if w.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+			w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" {
+			t.Errorf("Expected the management policy for GET %s, got %v", path, w.Header())
+		}
+		w = preflight(path, "https://anywhere.test", http.MethodGet)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Expected GET %s not to fall back to the delivery policy, got %q", path, got)
+		}
+	}
+
+	w = preflight("/pages/42", "https://evil.test", http.MethodDelete)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers for disallowed origin, got %q", got)
+	}
+	w = preflight("/unknown", "https://anywhere.test", http.MethodGet)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers for an unknown route, got %q", got)
+	}
+}
+
+// TestCORSPreflightManageRoutes verifies that a credentialed dashboard GET under /manage is preflighted
+// with the management policy, although the delivery policy serves GET /pages to any origin.
+func TestCORSPreflightManageRoutes(t *testing.T) {
+	delivery := NewCORSPolicy(CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "HEAD"}})
+	management := NewCORSPolicy(CORSConfig{
+		AllowedOrigins:   []string{"http://localhost:3000"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization"},
+		AllowCredentials: true,
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes := NewCORSRoutes()
+	router.Use(CORSPreflight(routes))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	routes.Group(router, "/", delivery).GET("/pages", ok)
+	routes.Group(router, "/manage", management).GET("/pages", ok)
+
+	req := httptest.NewRequest(http.MethodOptions, "/manage/pages", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("Expected the dashboard origin to be reflected, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("Expected credentials to be allowed, got %v", w.Header())
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("Expected Authorization to be allowed, got %q", w.Header().Get("Access-Control-Allow-Headers"))
+	}
+}
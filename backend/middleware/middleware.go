@@ -2,68 +2,114 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net/http"
 	"time"
 
+	"appdrop/models"
+	"appdrop/requestid"
+	"appdrop/response"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// maxRequestIDLength bounds client-supplied request identifiers to keep log lines and headers compact.
+const maxRequestIDLength = 128
+
+// RequestID initializes and returns a middleware handler that assigns a correlation identifier to every request.
+// A well-formed X-Request-ID supplied by the client is honored; otherwise a new UUID is generated.
+// The identifier is echoed in the response headers and stored in the request context.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !isValidRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Header(requestid.Header, id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+
+		c.Next()
+	}
+}
+
+// isValidRequestID accepts non-empty identifiers of printable ASCII characters within the length bound.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
 // Logger initializes and returns a middleware handler for structured request/response logging.
-// It captures execution latency, status codes, and client information for each incoming request.
-func Logger() gin.HandlerFunc {
+// Each request produces a single JSON record with the route template, status, latency, response size,
+// client information and any errors attached to the context by handlers.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
 
 		c.Next()
 
-		latency := time.Since(start)
 		statusCode := c.Writer.Status()
-		clientIP := c.ClientIP()
-		method := c.Request.Method
-
-		if query != "" {
-			path = path + "?" + query
+		attrs := []slog.Attr{
+			slog.String("request_id", requestid.FromContext(c.Request.Context())),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("query", c.Request.URL.RawQuery),
+			slog.Int("status", statusCode),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
 		}
-
-		log.Printf("[%s] %d | %v | %s | %s %s",
-			time.Now().Format("2006/01/02 - 15:04:05"),
-			statusCode,
-			latency,
-			clientIP,
-			method,
-			path,
-		)
-
 		if len(c.Errors) > 0 {
-			for _, e := range c.Errors {
-				log.Printf("Error: %s", e.Error())
-			}
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case statusCode >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case statusCode >= http.StatusBadRequest:
+			level = slog.LevelWarn
 		}
+
+		logger.LogAttrs(c.Request.Context(), level, "request completed", attrs...)
 	}
 }
 
-// CORS initializes and returns a middleware handler for Cross-Origin Resource Sharing (CORS) configuration.
-// It sets permissive headers to facilitate communication with front-end applications hosted on different origins.
-func CORS() gin.HandlerFunc {
+// Recovery initializes and returns a panic-recovery middleware built on the Gin framework.
+// It ensures that the server gracefully recovers from unexpected runtime panics and returns a 500 error instead of crashing.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, _ any) {
+		response.Abort(c, http.StatusInternalServerError, models.NewInternalServerError("An unexpected error occurred"))
+	})
+}
+
+// Deadline initializes and returns a middleware handler that bounds the request context by timeout.
+// Handlers pass that context to the data layer, so queries still running when it expires are
+// canceled rather than holding a connection, and the request fails with a 504.
+func Deadline(timeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
 
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
 
-// Recovery initializes and returns a panic-recovery middleware provided by the Gin framework.
-// It ensures that the server gracefully recovers from unexpected runtime panics and returns a 500 error instead of crashing.
-func Recovery() gin.HandlerFunc {
-	return gin.Recovery()
+// NotFound initializes and returns a handler that reports unknown routes in the negotiated error format.
+func NotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response.Error(c, http.StatusNotFound, models.NewNotFoundError("Route not found"))
+	}
 }
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"appdrop/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRequestIDAndLogger verifies that client request IDs are honored, invalid ones are replaced,
+// and that the structured log record carries the identifier and route template.
+func TestRequestIDAndLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	router := gin.New()
+	router.Use(RequestID(), Logger(logger))
+	router.GET("/pages/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, requestid.FromContext(c.Request.Context()))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/pages/42", nil)
+	req.Header.Set(requestid.Header, "client-supplied-id")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(requestid.Header); got != "client-supplied-id" {
+		t.Errorf("Expected echoed request ID, got %q", got)
+	}
+	if w.Body.String() != "client-supplied-id" {
+		t.Errorf("Expected request ID in context, got %q", w.Body.String())
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode log record: %v", err)
+	}
+	if record["request_id"] != "client-supplied-id" || record["route"] != "/pages/:id" {
+		t.Errorf("Unexpected log record: %v", record)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/pages/42", nil)
+	req.Header.Set(requestid.Header, "contains spaces")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(requestid.Header); got == "" || got == "contains spaces" {
+		t.Errorf("Expected a generated request ID, got %q", got)
+	}
+}
@@ -0,0 +1,348 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"appdrop/models"
+	"appdrop/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is the request header used to identify a client for rate limiting and quota accounting.
+const APIKeyHeader = "X-API-Key"
+
+// bucketIdleTTL defines how long an untouched token bucket is retained before it is swept from memory.
+const bucketIdleTTL = 10 * time.Minute
+
+// RateLimitConfig describes the token-bucket parameters applied to a single route group.
+type RateLimitConfig struct {
+	// Rate is the number of tokens replenished per second.
+	Rate float64
+	// Burst is the maximum number of tokens a bucket can hold.
+	Burst int
+}
+
+// Enabled reports whether the configuration describes an active limit.
+func (c RateLimitConfig) Enabled() bool {
+	return c.Rate > 0 && c.Burst > 0
+}
+
+// bucket tracks the available tokens for a single client key.
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter enforces a token-bucket limit per client key for one route group.
+type RateLimiter struct {
+	name      string
+	config    RateLimitConfig
+	quotas    *QuotaTracker
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter initializes a RateLimiter for the named route group.
+// The optional QuotaTracker is charged for every request that passes the limiter.
+func NewRateLimiter(name string, config RateLimitConfig, quotas *QuotaTracker) *RateLimiter {
+	return &RateLimiter{
+		name:    name,
+		config:  config,
+		quotas:  quotas,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// take attempts to consume a single token for the given key.
+// It returns whether the request is allowed along with the tokens left in the bucket afterwards.
+func (l *RateLimiter) take(key string) (bool, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.config.Burst), lastSeen: now}
+		l.buckets[key] = b
+	} else {
+		elapsed := now.Sub(b.lastSeen).Seconds()
+		b.tokens = math.Min(float64(l.config.Burst), b.tokens+elapsed*l.config.Rate)
+		b.lastSeen = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, b.tokens
+	}
+
+	return false, b.tokens
+}
+
+// untilTokens converts a token deficit into the time required to replenish it.
+func (l *RateLimiter) untilTokens(deficit float64) time.Duration {
+	if deficit <= 0 {
+		return 0
+	}
+	return time.Duration(deficit / l.config.Rate * float64(time.Second))
+}
+
+// sweep discards buckets that have been idle long enough to have fully refilled.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTTL {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware returns a Gin handler that enforces the limiter and the daily quota for each request.
+// Rejected requests receive a 429 response in the standard error shape along with a Retry-After header.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := ClientKey(c)
+
+		if l.config.Enabled() {
+			allowed, tokens := l.take(key)
+
+			c.Header("RateLimit-Limit", strconv.Itoa(l.config.Burst))
+			c.Header("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+			c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(l.untilTokens(float64(l.config.Burst)-tokens))))
+
+			if !allowed {
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(l.untilTokens(1-tokens))))
+				response.Abort(c, http.StatusTooManyRequests,
+					models.NewRateLimitError("Rate limit exceeded for "+l.name+" requests. Retry later."))
+				return
+			}
+		}
+
+		if l.quotas != nil {
+			usage, ok := l.quotas.Charge(key)
+			if !ok {
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(usage.ResetAt.Sub(l.now()))))
+				response.Abort(c, http.StatusTooManyRequests,
+					models.NewQuotaExceededError("Daily request quota exhausted. Retry after the quota resets."))
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// clientKeyContextKey is the Gin context key under which Identify stores the identity of a client with a valid API key.
+const clientKeyContextKey = "appdrop.client_key"
+
+// Identify initializes a middleware handler that recognizes clients by the API keys configured for the service.
+// A request whose X-API-Key matches one of them is identified by the key's fingerprint; any other request,
+// including one with an unknown key, is identified by its IP address.
+func Identify(apiKeys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" && validAPIKey(apiKeys, apiKey) {
+			c.Set(clientKeyContextKey, "key:"+KeyFingerprint(apiKey))
+		}
+		c.Next()
+	}
+}
+
+// validAPIKey reports whether apiKey is one of the configured keys, comparing each in constant time.
+func validAPIKey(apiKeys []string, apiKey string) bool {
+	valid := false
+	for _, key := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// ClientKey derives the rate limiting identity of a request.
+// A valid API key, as recognized by Identify, is preferred, and the client IP address is used as a fallback.
+func ClientKey(c *gin.Context) string {
+	if key := c.GetString(clientKeyContextKey); key != "" {
+		return key
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Actor returns the identity recorded as the author of audit entries, which is the client's rate limiting identity.
+// An API key is recorded by its fingerprint, never in plain text.
+func Actor(c *gin.Context) string {
+	return ClientKey(c)
+}
+
+// KeyFingerprint returns a short, non-reversible identifier of an API key: the first 16 hex digits of its SHA-256 hash.
+func KeyFingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ceilSeconds rounds a duration up to whole seconds, never returning less than one.
+func ceilSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// QuotaUsage reports the daily request consumption for a single client key.
+// Remaining is -1 when no daily limit is enforced.
+type QuotaUsage struct {
+	Key       string    `json:"key"`
+	Used      int       `json:"used"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// quotaCounter holds the number of requests charged to a key during a single UTC day.
+type quotaCounter struct {
+	day   string
+	count int
+}
+
+// QuotaTracker counts requests per client key over a UTC calendar day.
+// A limit of zero disables enforcement while still recording usage.
+type QuotaTracker struct {
+	limit    int
+	now      func() time.Time
+	mu       sync.Mutex
+	counters map[string]*quotaCounter
+	sweptDay string
+}
+
+// NewQuotaTracker initializes a QuotaTracker with the given daily request limit.
+func NewQuotaTracker(dailyLimit int) *QuotaTracker {
+	return &QuotaTracker{
+		limit:    dailyLimit,
+		now:      time.Now,
+		counters: make(map[string]*quotaCounter),
+	}
+}
+
+// Charge records a request against the key and reports whether it fits within the daily quota.
+func (q *QuotaTracker) Charge(key string) (QuotaUsage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	day := now.Format("2006-01-02")
+	q.sweep(day)
+
+	counter, ok := q.counters[key]
+	if !ok || counter.day != day {
+		counter = &quotaCounter{day: day}
+		q.counters[key] = counter
+	}
+
+	if q.limit > 0 && counter.count >= q.limit {
+		return q.usage(key, counter, now), false
+	}
+
+	counter.count++
+	return q.usage(key, counter, now), true
+}
+
+// Get returns the current usage for a key, or false if the key has not been seen today.
+func (q *QuotaTracker) Get(key string) (QuotaUsage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	counter, ok := q.counters[key]
+	if !ok || counter.day != now.Format("2006-01-02") {
+		return QuotaUsage{}, false
+	}
+	return q.usage(key, counter, now), true
+}
+
+// List returns today's usage for every known key, ordered by descending consumption.
+func (q *QuotaTracker) List() []QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	day := now.Format("2006-01-02")
+
+	q.sweep(day)
+
+	usages := make([]QuotaUsage, 0, len(q.counters))
+	for key, counter := range q.counters {
+		usages = append(usages, q.usage(key, counter, now))
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Used != usages[j].Used {
+			return usages[i].Used > usages[j].Used
+		}
+		return usages[i].Key < usages[j].Key
+	})
+	return usages
+}
+
+// sweep discards the counters of previous days once per UTC day, so that only today's clients are retained.
+func (q *QuotaTracker) sweep(day string) {
+	if q.sweptDay == day {
+		return
+	}
+	q.sweptDay = day
+	for key, counter := range q.counters {
+		if counter.day != day {
+			delete(q.counters, key)
+		}
+	}
+}
+
+// usage builds a QuotaUsage snapshot for the given counter.
+func (q *QuotaTracker) usage(key string, counter *quotaCounter, now time.Time) QuotaUsage {
+	remaining := -1
+	if q.limit > 0 {
+		remaining = q.limit - counter.count
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+	year, month, day := now.Date()
+	return QuotaUsage{
+		Key:       key,
+		Used:      counter.count,
+		Limit:     q.limit,
+		Remaining: remaining,
+		ResetAt:   time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// AdminAuth initializes a middleware handler that restricts access to administrative endpoints.
+// Requests must present the configured token in the X-Admin-Token header; an empty token disables the endpoints entirely.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			response.Abort(c, http.StatusForbidden, models.NewForbiddenError("Admin API is disabled"))
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(token)) != 1 {
+			response.Abort(c, http.StatusUnauthorized, models.NewUnauthorizedError("Invalid admin token"))
+			return
+		}
+		c.Next()
+	}
+}
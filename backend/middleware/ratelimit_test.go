@@ -0,0 +1,155 @@
+// Package middleware contains unit tests for verifying rate limiting and quota accounting behavior.
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"appdrop/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testAPIKeys lists the API keys accepted by the test routers.
+var testAPIKeys = []string{"alpha", "beta", "secret-key"}
+
+// newLimitedRouter builds a router with a single limited route for exercising the middleware.
+func newLimitedRouter(limiter *RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Identify(testAPIKeys))
+	router.GET("/limited", limiter.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// performRequest issues a GET request with an optional API key against the router.
+func performRequest(router *gin.Engine, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	if apiKey != "" {
+		req.Header.Set(APIKeyHeader, apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestRateLimiterRejectsAfterBurst verifies that a client is rejected with the standard error shape
+// once its burst is consumed, and that other clients are unaffected.
+func TestRateLimiterRejectsAfterBurst(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter("delivery", RateLimitConfig{Rate: 1, Burst: 2}, nil)
+	limiter.now = func() time.Time { return now }
+	router := newLimitedRouter(limiter)
+
+	for i := 0; i < 2; i++ {
+		if w := performRequest(router, "alpha"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i+1, w.Code)
+		}
+	}
+
+	w := performRequest(router, "alpha")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", w.Header().Get("RateLimit-Remaining"))
+	}
+
+	var body models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	if body.Error.Code != models.ErrorCodeRateLimited {
+		t.Errorf("Expected code %s, got %s", models.ErrorCodeRateLimited, body.Error.Code)
+	}
+
+	if w := performRequest(router, "beta"); w.Code != http.StatusOK {
+		t.Errorf("Expected a different key to be allowed, got %d", w.Code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := performRequest(router, "unknown-"+strconv.Itoa(i)); w.Code != http.StatusOK {
+			t.Fatalf("Unknown key %d: expected status 200, got %d", i+1, w.Code)
+		}
+	}
+	if w := performRequest(router, "unknown-2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected unknown keys to share the client IP bucket, got %d", w.Code)
+	}
+
+	now = now.Add(time.Second)
+	if w := performRequest(router, "alpha"); w.Code != http.StatusOK {
+		t.Errorf("Expected request after refill to be allowed, got %d", w.Code)
+	}
+}
+
+// TestQuotaTrackerEnforcesDailyLimit verifies that the daily quota is enforced per key and resets at UTC midnight.
+func TestQuotaTrackerEnforcesDailyLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC)
+	quotas := NewQuotaTracker(2)
+	quotas.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, ok := quotas.Charge("key:alpha"); !ok {
+			t.Fatalf("Charge %d: expected request within quota", i+1)
+		}
+	}
+
+	usage, ok := quotas.Charge("key:alpha")
+	if ok {
+		t.Fatal("Expected request beyond quota to be rejected")
+	}
+	if usage.Remaining != 0 || usage.Used != 2 {
+		t.Errorf("Expected used=2 remaining=0, got used=%d remaining=%d", usage.Used, usage.Remaining)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := quotas.Charge("key:alpha"); !ok {
+		t.Error("Expected quota to reset on the next UTC day")
+	}
+	if got := len(quotas.List()); got != 1 {
+		t.Errorf("Expected 1 tracked key, got %d", got)
+	}
+
+	quotas.Charge("ip:192.0.2.1")
+	now = now.Add(24 * time.Hour)
+	quotas.Charge("ip:192.0.2.2")
+	if got := len(quotas.counters); got != 1 {
+		t.Errorf("Expected the previous day's counters to be swept on charge, got %d", got)
+	}
+}
+
+// TestActorFingerprintsAPIKey verifies that audit actors never carry the API key in plain text.
+func TestActorFingerprintsAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var actor string
+	router := gin.New()
+	router.Use(Identify(testAPIKeys))
+	router.GET("/limited", func(c *gin.Context) {
+		actor = Actor(c)
+	})
+
+	performRequest(router, "secret-key")
+	if actor != "key:"+KeyFingerprint("secret-key") || strings.Contains(actor, "secret-key") {
+		t.Errorf("Expected the key fingerprint as actor, got %q", actor)
+	}
+	if len(KeyFingerprint("secret-key")) != 16 || KeyFingerprint("secret-key") == KeyFingerprint("other-key") {
+		t.Errorf("Expected distinct 16-digit fingerprints, got %q", KeyFingerprint("secret-key"))
+	}
+
+	for _, apiKey := range []string{"", "forged-key"} {
+		performRequest(router, apiKey)
+		if !strings.HasPrefix(actor, "ip:") {
+			t.Errorf("Expected the client IP as actor for key %q, got %q", apiKey, actor)
+		}
+	}
+}
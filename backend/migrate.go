@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+
+	"appdrop/database"
+)
+
+// runMigrate dispatches the migrate subcommands and returns the process exit code.
+func runMigrate(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	action, args := args[0], args[1:]
+
+	if action == "create" {
+		return createMigration(args, stdout, stderr)
+	}
+
+	steps := 1
+	if action == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n < 1 {
+				fmt.Fprintln(stderr, "migrate down: the number of steps must be at least 1")
+				return 2
+			}
+			steps, args = n, args[1:]
+		}
+	}
+	if action != "up" && action != "down" && action != "status" {
+		fmt.Fprintf(stderr, "unknown migrate command %q\n\n%s", action, usage)
+		return 2
+	}
+
+	cfg, code, ok := loadConfig("appdrop migrate "+action, args, stderr)
+	if !ok {
+		return code
+	}
+	logger := newLogger(cfg.Log.Level)
+	slog.SetDefault(logger)
+
+	if err := connectDatabase(cfg); err != nil {
+		logger.Error("Migration failed", "error", err)
+		return 1
+	}
+	defer database.Close()
+
+	migrator, err := database.NewMigrator(database.GetDB(), database.Driver(), logger)
+	if err != nil {
+		logger.Error("Migration failed", "error", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx, steps)
+	case "status":
+		err = printMigrationStatus(ctx, migrator, stdout)
+	}
+	if err != nil {
+		logger.Error("Migration failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// printMigrationStatus writes one row per migration with its applied time and any checksum drift.
+func printMigrationStatus(ctx context.Context, migrator *database.Migrator, w io.Writer) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", "-"
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		if s.Modified {
+			state += " (modified)"
+		}
+		if s.Missing {
+			state += " (file missing)"
+		}
+		fmt.Fprintf(tw, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return tw.Flush()
+}
+
+// createMigration writes a new, empty migration file for each driver into the migrations source directory.
+func createMigration(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("appdrop migrate create", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", "migrations", "directory holding the per-driver migration source directories")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: appdrop migrate create [-dir migrations] <name>")
+		return 2
+	}
+
+	paths, err := database.CreateMigration(*dir, fs.Arg(0))
+	for _, path := range paths {
+		fmt.Fprintln(stdout, path)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
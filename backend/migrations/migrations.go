@@ -0,0 +1,29 @@
+// Package migrations embeds the versioned SQL schema migrations into the application binary.
+// Each supported database driver has its own directory of files named NNN_description.sql,
+// each holding an up section followed by an optional down section introduced by a
+// "-- +migrate Down" line.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// files holds every migration file shipped with this build.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Dirs lists the migration directory of each supported driver.
+var Dirs = []string{"postgres", "sqlite"}
+
+// For returns the migrations written for the named database driver.
+func For(driver string) (fs.FS, error) {
+	for _, dir := range Dirs {
+		if dir == driver {
+			return fs.Sub(files, dir)
+		}
+	}
+	return nil, fmt.Errorf("no migrations for database driver %q", driver)
+}
@@ -1,6 +1,8 @@
 -- Mini App Config API Database Schema
 -- Version: 1.0.0
 
+-- +migrate Up
+
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
@@ -85,3 +87,8 @@ CREATE TRIGGER update_widgets_updated_at
 --     ((SELECT id FROM pages WHERE route = '/home'), 'banner', 1, '{"title": "Welcome Banner", "image_url": "https://example.com/banner.jpg"}'),
 --     ((SELECT id FROM pages WHERE route = '/home'), 'product_grid', 2, '{"columns": 2, "limit": 10}'),
 --     ((SELECT id FROM pages WHERE route = '/home'), 'text', 3, '{"content": "Featured Products", "style": "heading"}');
+
+-- +migrate Down
+DROP TABLE IF EXISTS widgets;
+DROP TABLE IF EXISTS pages;
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -0,0 +1,20 @@
+-- Mini App Config API Schema Version Tracking
+-- Version: 2
+
+-- +migrate Up
+
+-- ============================================
+-- SCHEMA_MIGRATIONS TABLE
+-- ============================================
+-- Records which migrations have been applied so the API can verify the schema on readiness checks.
+-- The migration runner creates this table itself; this file remains for databases set up by hand.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO schema_migrations (version) VALUES (1), (2)
+ON CONFLICT (version) DO NOTHING;
+
+-- +migrate Down
+-- schema_migrations is owned by the migration runner and is intentionally left in place.
@@ -0,0 +1,27 @@
+-- Mini App Config API Page Listing Indexes
+-- Version: 3
+
+-- +migrate Up
+
+-- ============================================
+-- PAGE LISTING INDEXES
+-- ============================================
+-- Keyset pagination seeks on (sort column, id), so each sortable column is indexed together with id
+CREATE INDEX IF NOT EXISTS idx_pages_name_id ON pages(name, id);
+CREATE INDEX IF NOT EXISTS idx_pages_created_at_id ON pages(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_pages_updated_at_id ON pages(updated_at, id);
+
+-- Prefix filters: case-insensitive on name, exact on route
+CREATE INDEX IF NOT EXISTS idx_pages_name_prefix ON pages(lower(name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_pages_route_prefix ON pages(route text_pattern_ops);
+
+-- "Has a widget of type X" filter and per-type widget counts
+CREATE INDEX IF NOT EXISTS idx_widgets_type_page_id ON widgets(type, page_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_widgets_type_page_id;
+DROP INDEX IF EXISTS idx_pages_route_prefix;
+DROP INDEX IF EXISTS idx_pages_name_prefix;
+DROP INDEX IF EXISTS idx_pages_updated_at_id;
+DROP INDEX IF EXISTS idx_pages_created_at_id;
+DROP INDEX IF EXISTS idx_pages_name_id;
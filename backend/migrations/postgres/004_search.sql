@@ -0,0 +1,45 @@
+-- Mini App Config API Full-Text Search
+-- Version: 4
+
+-- +migrate Up
+
+-- ============================================
+-- SEARCHABLE WIDGET TEXT
+-- ============================================
+-- Joins the text fields of a widget config (models.SearchableConfigFields); URLs, colors and
+-- other values are left out of the index
+CREATE OR REPLACE FUNCTION widget_search_text(config JSONB) RETURNS TEXT
+LANGUAGE SQL IMMUTABLE PARALLEL SAFE AS $$
+    SELECT coalesce(config->>'title', '') || ' ' ||
+           coalesce(config->>'subtitle', '') || ' ' ||
+           coalesce(config->>'content', '') || ' ' ||
+           coalesce(config->>'text', '') || ' ' ||
+           coalesce(config->>'caption', '') || ' ' ||
+           coalesce(config->>'alt', '') || ' ' ||
+           coalesce(config->>'alt_text', '') || ' ' ||
+           coalesce(config->>'label', '') || ' ' ||
+           coalesce(config->>'button_text', '')
+$$;
+
+-- ============================================
+-- SEARCH VECTORS
+-- ============================================
+-- Page names weigh more than routes; route separators are split so "/black-friday" matches "friday"
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name), 'A') ||
+        setweight(to_tsvector('simple', translate(route, '/-_.', '    ')), 'B')
+    ) STORED;
+
+ALTER TABLE widgets ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (setweight(to_tsvector('simple', widget_search_text(config)), 'B')) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_pages_search ON pages USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_widgets_search ON widgets USING GIN (search_vector);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_widgets_search;
+DROP INDEX IF EXISTS idx_pages_search;
+ALTER TABLE widgets DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE pages DROP COLUMN IF EXISTS search_vector;
+DROP FUNCTION IF EXISTS widget_search_text(JSONB);
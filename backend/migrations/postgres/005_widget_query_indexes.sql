@@ -0,0 +1,17 @@
+-- Mini App Config API Widget Query Indexes
+-- Version: 5
+
+-- +migrate Up
+
+-- ============================================
+-- WIDGET QUERY INDEXES
+-- ============================================
+-- Config filters use containment (@>) and JSON path tests (@?), both served by jsonb_path_ops
+CREATE INDEX IF NOT EXISTS idx_widgets_config ON widgets USING GIN (config jsonb_path_ops);
+
+-- Cross-page listings are ordered newest first and resumed from (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_widgets_created_at_id ON widgets(created_at, id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_widgets_created_at_id;
+DROP INDEX IF EXISTS idx_widgets_config;
@@ -0,0 +1,24 @@
+-- Mini App Config API Audit Log
+-- Version: 6
+
+-- +migrate Up
+
+-- ============================================
+-- AUDIT_LOG TABLE
+-- ============================================
+-- Records bulk changes, such as find-and-replace across widget configs, with the client and
+-- request that made them
+CREATE TABLE IF NOT EXISTS audit_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    action VARCHAR(100) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for listing the latest entries
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS audit_log;
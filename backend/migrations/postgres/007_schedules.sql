@@ -0,0 +1,16 @@
+-- Mini App Config API Schedules
+-- Version: 7
+
+-- +migrate Up
+
+-- ============================================
+-- PAGE AND WIDGET SCHEDULES
+-- ============================================
+-- Visibility windows and weekly rules (models.Schedule), evaluated by the delivery endpoints at
+-- request time. NULL means always live.
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS schedule JSONB;
+ALTER TABLE widgets ADD COLUMN IF NOT EXISTS schedule JSONB;
+
+-- +migrate Down
+ALTER TABLE widgets DROP COLUMN IF EXISTS schedule;
+ALTER TABLE pages DROP COLUMN IF EXISTS schedule;
@@ -0,0 +1,16 @@
+-- Mini App Config API Targeting Rules
+-- Version: 8
+
+-- +migrate Up
+
+-- ============================================
+-- PAGE AND WIDGET AUDIENCE RULES
+-- ============================================
+-- Targeting expressions (package targeting) evaluated by the delivery endpoints against the
+-- client's context. An empty rule matches every client.
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS rule TEXT NOT NULL DEFAULT '';
+ALTER TABLE widgets ADD COLUMN IF NOT EXISTS rule TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE widgets DROP COLUMN IF EXISTS rule;
+ALTER TABLE pages DROP COLUMN IF EXISTS rule;
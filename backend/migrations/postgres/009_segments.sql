@@ -0,0 +1,49 @@
+-- Mini App Config API Segments
+-- Version: 9
+
+-- +migrate Up
+
+-- ============================================
+-- SEGMENTS TABLE
+-- ============================================
+-- Reusable audiences referenced by pages and widgets, defined by a targeting rule, a user list or both
+CREATE TABLE IF NOT EXISTS segments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    rule TEXT NOT NULL DEFAULT '',
+    user_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_segments_updated_at ON segments;
+CREATE TRIGGER update_segments_updated_at
+    BEFORE UPDATE ON segments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- ============================================
+-- SEGMENT_USERS TABLE
+-- ============================================
+-- Uploaded user lists, one row per member, so that membership is a primary key lookup
+CREATE TABLE IF NOT EXISTS segment_users (
+    segment_id UUID NOT NULL REFERENCES segments(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    PRIMARY KEY (segment_id, user_id)
+);
+
+-- ============================================
+-- SEGMENT REFERENCES
+-- ============================================
+-- Pages and widgets restricted to a segment; a referenced segment cannot be deleted
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS segment_id UUID REFERENCES segments(id) ON DELETE RESTRICT;
+ALTER TABLE widgets ADD COLUMN IF NOT EXISTS segment_id UUID REFERENCES segments(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_pages_segment_id ON pages(segment_id) WHERE segment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_widgets_segment_id ON widgets(segment_id) WHERE segment_id IS NOT NULL;
+
+-- +migrate Down
+ALTER TABLE widgets DROP COLUMN IF EXISTS segment_id;
+ALTER TABLE pages DROP COLUMN IF EXISTS segment_id;
+DROP TABLE IF EXISTS segment_users;
+DROP TABLE IF EXISTS segments;
@@ -0,0 +1,36 @@
+-- Mini App Config API Experiments
+-- Version: 10
+
+-- +migrate Up
+
+-- ============================================
+-- EXPERIMENTS TABLE
+-- ============================================
+-- A/B tests on page layouts. Variants (models.Variants) carry their weights and either their own
+-- widget list or config overrides for the page's widgets.
+CREATE TABLE IF NOT EXISTS experiments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'running', 'stopped', 'promoted')),
+    variants JSONB NOT NULL DEFAULT '[]',
+    winner VARCHAR(100) NOT NULL DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE,
+    stopped_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_page_id ON experiments(page_id, created_at DESC);
+
+-- At most one experiment runs on a page, and delivery looks it up by page
+CREATE UNIQUE INDEX IF NOT EXISTS idx_experiments_running ON experiments(page_id) WHERE status = 'running';
+
+DROP TRIGGER IF EXISTS update_experiments_updated_at ON experiments;
+CREATE TRIGGER update_experiments_updated_at
+    BEFORE UPDATE ON experiments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- +migrate Down
+DROP TABLE IF EXISTS experiments;
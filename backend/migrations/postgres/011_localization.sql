@@ -0,0 +1,19 @@
+-- Mini App Config API Localization
+-- Version: 11
+
+-- +migrate Up
+
+-- ============================================
+-- LOCALIZED CONTENT
+-- ============================================
+-- Pages name the locale of their base content and carry their names in other locales
+-- (models.PageNames); widgets carry config overrides per locale (models.WidgetLocales), merged
+-- over their base config when a delivery request selects that locale
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS default_locale VARCHAR(35) NOT NULL DEFAULT 'en';
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS names JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE widgets ADD COLUMN IF NOT EXISTS locales JSONB NOT NULL DEFAULT '{}';
+
+-- +migrate Down
+ALTER TABLE widgets DROP COLUMN IF EXISTS locales;
+ALTER TABLE pages DROP COLUMN IF EXISTS names;
+ALTER TABLE pages DROP COLUMN IF EXISTS default_locale;
@@ -0,0 +1,18 @@
+-- Mini App Config API Theme
+-- Version: 12
+
+-- +migrate Up
+
+-- ============================================
+-- APP_THEME TABLE
+-- ============================================
+-- The app's design tokens (models.Theme): color palettes, typography, radii and spacing, which
+-- widget configs reference by name. There is a single theme, so the table holds at most one row.
+CREATE TABLE IF NOT EXISTS app_theme (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    tokens JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS app_theme;
@@ -0,0 +1,72 @@
+-- Mini App Config API Database Schema (SQLite)
+-- Version: 1.0.0
+-- Mirrors the PostgreSQL schema: IDs are UUID strings generated by the application, widget
+-- configs are JSON text, and timestamps are ISO 8601 UTC text with millisecond precision.
+
+-- +migrate Up
+
+-- ============================================
+-- PAGES TABLE
+-- ============================================
+-- Represents a screen/page in the mobile app (e.g., Home, Collection, Product Detail)
+CREATE TABLE IF NOT EXISTS pages (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    route TEXT NOT NULL UNIQUE,
+    is_home BOOLEAN NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Index for faster route lookups
+CREATE INDEX IF NOT EXISTS idx_pages_route ON pages(route);
+
+-- Index for home page lookup
+CREATE INDEX IF NOT EXISTS idx_pages_is_home ON pages(is_home) WHERE is_home = 1;
+
+-- ============================================
+-- WIDGETS TABLE
+-- ============================================
+-- Represents UI components on a page (e.g., Banner, ProductGrid, Text)
+CREATE TABLE IF NOT EXISTS widgets (
+    id TEXT PRIMARY KEY,
+    page_id TEXT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('banner', 'product_grid', 'text', 'image', 'spacer')),
+    position INTEGER NOT NULL DEFAULT 0,
+    config TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(config)),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Index for faster page_id lookups
+CREATE INDEX IF NOT EXISTS idx_widgets_page_id ON widgets(page_id);
+
+-- Index for position ordering
+CREATE INDEX IF NOT EXISTS idx_widgets_position ON widgets(page_id, position);
+
+-- Index for type filtering
+CREATE INDEX IF NOT EXISTS idx_widgets_type ON widgets(type);
+
+-- ============================================
+-- TRIGGERS FOR updated_at
+-- ============================================
+-- Refresh updated_at on every update that does not set it explicitly
+CREATE TRIGGER IF NOT EXISTS update_pages_updated_at
+    AFTER UPDATE ON pages
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE pages SET updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_widgets_updated_at
+    AFTER UPDATE ON widgets
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE widgets SET updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE id = NEW.id;
+END;
+
+-- +migrate Down
+DROP TABLE IF EXISTS widgets;
+DROP TABLE IF EXISTS pages;
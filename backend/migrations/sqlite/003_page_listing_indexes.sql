@@ -0,0 +1,23 @@
+-- Mini App Config API Page Listing Indexes (SQLite)
+-- Version: 3
+-- Version 2 only exists for PostgreSQL, where it set up schema tracking by hand.
+
+-- +migrate Up
+
+-- ============================================
+-- PAGE LISTING INDEXES
+-- ============================================
+-- Keyset pagination seeks on (sort column, id), so each sortable column is indexed together with id.
+-- The unique constraint on route already covers route ordering and prefix lookups.
+CREATE INDEX IF NOT EXISTS idx_pages_name_id ON pages(name, id);
+CREATE INDEX IF NOT EXISTS idx_pages_created_at_id ON pages(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_pages_updated_at_id ON pages(updated_at, id);
+
+-- "Has a widget of type X" filter and per-type widget counts
+CREATE INDEX IF NOT EXISTS idx_widgets_type_page_id ON widgets(type, page_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_widgets_type_page_id;
+DROP INDEX IF EXISTS idx_pages_updated_at_id;
+DROP INDEX IF EXISTS idx_pages_created_at_id;
+DROP INDEX IF EXISTS idx_pages_name_id;
@@ -0,0 +1,29 @@
+-- Mini App Config API Search (SQLite)
+-- Version: 4
+-- SQLite searches by term matching rather than a full-text index. This view exposes the text
+-- fields of each widget config (models.SearchableConfigFields) so they can be matched in one place.
+
+-- +migrate Up
+
+-- ============================================
+-- SEARCHABLE WIDGET TEXT
+-- ============================================
+CREATE VIEW IF NOT EXISTS widget_search AS
+SELECT
+    id,
+    page_id,
+    trim(
+        coalesce(json_extract(config, '$.title') || ' ', '') ||
+        coalesce(json_extract(config, '$.subtitle') || ' ', '') ||
+        coalesce(json_extract(config, '$.content') || ' ', '') ||
+        coalesce(json_extract(config, '$.text') || ' ', '') ||
+        coalesce(json_extract(config, '$.caption') || ' ', '') ||
+        coalesce(json_extract(config, '$.alt') || ' ', '') ||
+        coalesce(json_extract(config, '$.alt_text') || ' ', '') ||
+        coalesce(json_extract(config, '$.label') || ' ', '') ||
+        coalesce(json_extract(config, '$.button_text'), '')
+    ) AS body
+FROM widgets;
+
+-- +migrate Down
+DROP VIEW IF EXISTS widget_search;
@@ -0,0 +1,15 @@
+-- Mini App Config API Widget Query Indexes (SQLite)
+-- Version: 5
+-- SQLite has no index type for JSON containment, so config filters scan the widgets that the
+-- type and page filters leave.
+
+-- +migrate Up
+
+-- ============================================
+-- WIDGET QUERY INDEXES
+-- ============================================
+-- Cross-page listings are ordered newest first and resumed from (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_widgets_created_at_id ON widgets(created_at, id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_widgets_created_at_id;
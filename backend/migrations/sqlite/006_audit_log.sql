@@ -0,0 +1,24 @@
+-- Mini App Config API Audit Log (SQLite)
+-- Version: 6
+
+-- +migrate Up
+
+-- ============================================
+-- AUDIT_LOG TABLE
+-- ============================================
+-- Records bulk changes, such as find-and-replace across widget configs, with the client and
+-- request that made them
+CREATE TABLE IF NOT EXISTS audit_log (
+    id TEXT PRIMARY KEY,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(details)),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- Index for listing the latest entries
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,16 @@
+-- Mini App Config API Schedules (SQLite)
+-- Version: 7
+
+-- +migrate Up
+
+-- ============================================
+-- PAGE AND WIDGET SCHEDULES
+-- ============================================
+-- Visibility windows and weekly rules (models.Schedule), evaluated by the delivery endpoints at
+-- request time. NULL means always live.
+ALTER TABLE pages ADD COLUMN schedule TEXT CHECK (schedule IS NULL OR json_valid(schedule));
+ALTER TABLE widgets ADD COLUMN schedule TEXT CHECK (schedule IS NULL OR json_valid(schedule));
+
+-- +migrate Down
+ALTER TABLE widgets DROP COLUMN schedule;
+ALTER TABLE pages DROP COLUMN schedule;
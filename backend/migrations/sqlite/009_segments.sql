@@ -0,0 +1,54 @@
+-- Mini App Config API Segments (SQLite)
+-- Version: 9
+
+-- +migrate Up
+
+-- ============================================
+-- SEGMENTS TABLE
+-- ============================================
+-- Reusable audiences referenced by pages and widgets, defined by a targeting rule, a user list or both
+CREATE TABLE IF NOT EXISTS segments (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    rule TEXT NOT NULL DEFAULT '',
+    user_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE TRIGGER IF NOT EXISTS update_segments_updated_at
+    AFTER UPDATE ON segments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE segments SET updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE id = NEW.id;
+END;
+
+-- ============================================
+-- SEGMENT_USERS TABLE
+-- ============================================
+-- Uploaded user lists, one row per member and clustered on the key, so that membership is a
+-- primary key lookup
+CREATE TABLE IF NOT EXISTS segment_users (
+    segment_id TEXT NOT NULL REFERENCES segments(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    PRIMARY KEY (segment_id, user_id)
+) WITHOUT ROWID;
+
+-- ============================================
+-- SEGMENT REFERENCES
+-- ============================================
+-- Pages and widgets restricted to a segment; a referenced segment cannot be deleted
+ALTER TABLE pages ADD COLUMN segment_id TEXT REFERENCES segments(id) ON DELETE RESTRICT;
+ALTER TABLE widgets ADD COLUMN segment_id TEXT REFERENCES segments(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_pages_segment_id ON pages(segment_id) WHERE segment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_widgets_segment_id ON widgets(segment_id) WHERE segment_id IS NOT NULL;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_widgets_segment_id;
+DROP INDEX IF EXISTS idx_pages_segment_id;
+ALTER TABLE widgets DROP COLUMN segment_id;
+ALTER TABLE pages DROP COLUMN segment_id;
+DROP TABLE IF EXISTS segment_users;
+DROP TABLE IF EXISTS segments;
@@ -0,0 +1,38 @@
+-- Mini App Config API Experiments (SQLite)
+-- Version: 10
+
+-- +migrate Up
+
+-- ============================================
+-- EXPERIMENTS TABLE
+-- ============================================
+-- A/B tests on page layouts. Variants (models.Variants) carry their weights and either their own
+-- widget list or config overrides for the page's widgets.
+CREATE TABLE IF NOT EXISTS experiments (
+    id TEXT PRIMARY KEY,
+    page_id TEXT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'running', 'stopped', 'promoted')),
+    variants TEXT NOT NULL DEFAULT '[]' CHECK (json_valid(variants)),
+    winner TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP,
+    stopped_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_page_id ON experiments(page_id, created_at DESC);
+
+-- At most one experiment runs on a page, and delivery looks it up by page
+CREATE UNIQUE INDEX IF NOT EXISTS idx_experiments_running ON experiments(page_id) WHERE status = 'running';
+
+CREATE TRIGGER IF NOT EXISTS update_experiments_updated_at
+    AFTER UPDATE ON experiments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE experiments SET updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE id = NEW.id;
+END;
+
+-- +migrate Down
+DROP TABLE IF EXISTS experiments;
@@ -0,0 +1,19 @@
+-- Mini App Config API Localization (SQLite)
+-- Version: 11
+
+-- +migrate Up
+
+-- ============================================
+-- LOCALIZED CONTENT
+-- ============================================
+-- Pages name the locale of their base content and carry their names in other locales
+-- (models.PageNames); widgets carry config overrides per locale (models.WidgetLocales), merged
+-- over their base config when a delivery request selects that locale
+ALTER TABLE pages ADD COLUMN default_locale TEXT NOT NULL DEFAULT 'en';
+ALTER TABLE pages ADD COLUMN names TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(names));
+ALTER TABLE widgets ADD COLUMN locales TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(locales));
+
+-- +migrate Down
+ALTER TABLE widgets DROP COLUMN locales;
+ALTER TABLE pages DROP COLUMN names;
+ALTER TABLE pages DROP COLUMN default_locale;
@@ -0,0 +1,18 @@
+-- Mini App Config API Theme (SQLite)
+-- Version: 12
+
+-- +migrate Up
+
+-- ============================================
+-- APP_THEME TABLE
+-- ============================================
+-- The app's design tokens (models.Theme): color palettes, typography, radii and spacing, which
+-- widget configs reference by name. There is a single theme, so the table holds at most one row.
+CREATE TABLE IF NOT EXISTS app_theme (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    tokens TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(tokens)),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS app_theme;
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditActionWidgetReplace records a bulk find-and-replace applied to widget configs.
+const AuditActionWidgetReplace = "widgets.replace"
+
+// AuditEntry records a bulk or otherwise notable change: what was done, by which client and
+// request, and the action-specific Details needed to review it later.
+type AuditEntry struct {
+	ID        uuid.UUID       `json:"id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id,omitempty"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConfigVariantsKey is the widget config key holding conditional variants: JSON merge patches
+// keyed by the conditions under which they apply, such as "theme=dark" or
+// "platform=android&form_factor=tablet". Delivery merges the variants matching the client into
+// the config and drops the key; management endpoints return it as stored.
+const ConfigVariantsKey = "$variants"
+
+// MaxConfigVariants caps the number of conditional variants of a config.
+const MaxConfigVariants = 20
+
+// ConditionAttributes lists the client attributes that variant conditions may test, with the
+// values each accepts.
+var ConditionAttributes = map[string][]string{
+	"theme":       {"light", "dark"},
+	"platform":    {"ios", "android", "web"},
+	"form_factor": {"phone", "tablet", "desktop"},
+}
+
+// Conditions is a parsed variant key: the value each tested attribute must have, all of which
+// have to hold.
+type Conditions map[string]string
+
+// ParseConditions parses a variant key made of attribute=value conditions joined by "&".
+// Attributes and values are case-insensitive, and each attribute may be tested once.
+func ParseConditions(key string) (Conditions, error) {
+	conds := Conditions{}
+	for _, part := range strings.Split(key, "&") {
+		attr, value, ok := strings.Cut(part, "=")
+		attr = strings.ToLower(strings.TrimSpace(attr))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !ok || attr == "" || value == "" {
+			return nil, fmt.Errorf("condition %q must have the form attribute=value", part)
+		}
+		allowed, known := ConditionAttributes[attr]
+		if !known {
+			return nil, fmt.Errorf("unknown attribute %q in condition %q, expected theme, platform or form_factor", attr, part)
+		}
+		if !containsValue(allowed, value) {
+			return nil, fmt.Errorf("invalid %s %q, expected one of %s", attr, value, strings.Join(allowed, ", "))
+		}
+		if _, dup := conds[attr]; dup {
+			return nil, fmt.Errorf("attribute %q is tested more than once in %q", attr, key)
+		}
+		conds[attr] = value
+	}
+	return conds, nil
+}
+
+// String returns the canonical form of the conditions, with attributes in alphabetical order.
+func (c Conditions) String() string {
+	attrs := make([]string, 0, len(c))
+	for attr := range c {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	parts := make([]string, len(attrs))
+	for i, attr := range attrs {
+		parts[i] = attr + "=" + c[attr]
+	}
+	return strings.Join(parts, "&")
+}
+
+// Match reports whether a client with the given attributes satisfies every condition. Attributes
+// the client did not send satisfy none.
+func (c Conditions) Match(client map[string]string) bool {
+	for attr, value := range c {
+		if !strings.EqualFold(strings.TrimSpace(client[attr]), value) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsValue reports whether list holds s.
+func containsValue(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateConfigVariants checks the conditional variants of a config, if any: at most
+// MaxConfigVariants, keyed by valid and distinct conditions, each a JSON object that does not
+// itself hold variants.
+func ValidateConfigVariants(config json.RawMessage) error {
+	variants, err := configVariants(config)
+	if err != nil || variants == nil {
+		return err
+	}
+	if len(variants) > MaxConfigVariants {
+		return fmt.Errorf("a config can have at most %d variants", MaxConfigVariants)
+	}
+	seen := make(map[string]string, len(variants))
+	for key, patch := range variants {
+		conds, err := ParseConditions(key)
+		if err != nil {
+			return err
+		}
+		if other, dup := seen[conds.String()]; dup {
+			return fmt.Errorf("variants %q and %q have the same conditions", other, key)
+		}
+		seen[conds.String()] = key
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &object); err != nil || object == nil {
+			return fmt.Errorf("variant %q must be a JSON object", key)
+		}
+		if _, nested := object[ConfigVariantsKey]; nested {
+			return fmt.Errorf("variant %q cannot hold variants of its own", key)
+		}
+	}
+	return nil
+}
+
+// configVariants returns the variants held by a config, or nil when it has none.
+func configVariants(config json.RawMessage) (map[string]json.RawMessage, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(config, &object); err != nil {
+		return nil, nil
+	}
+	raw, ok := object[ConfigVariantsKey]
+	if !ok {
+		return nil, nil
+	}
+	var variants map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &variants); err != nil || variants == nil {
+		return nil, fmt.Errorf("%s must be an object of variants keyed by conditions", ConfigVariantsKey)
+	}
+	return variants, nil
+}
+
+// ResolveConfig flattens a config for a client: the variants whose conditions the client
+// satisfies are merged into the config, those with fewer conditions first so that the most
+// specific variant wins, and the variants key is removed. Configs without variants are returned
+// unchanged.
+func ResolveConfig(config json.RawMessage, client map[string]string) (json.RawMessage, error) {
+	variants, err := configVariants(config)
+	if err != nil || variants == nil {
+		return config, err
+	}
+
+	type match struct {
+		key   string
+		conds Conditions
+	}
+	var matches []match
+	for key := range variants {
+		conds, err := ParseConditions(key)
+		if err != nil {
+			continue
+		}
+		if conds.Match(client) {
+			matches = append(matches, match{key, conds})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if len(matches[i].conds) != len(matches[j].conds) {
+			return len(matches[i].conds) < len(matches[j].conds)
+		}
+		return matches[i].conds.String() < matches[j].conds.String()
+	})
+
+	resolved, err := MergeConfig(config, json.RawMessage(`{"`+ConfigVariantsKey+`":null}`))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range matches {
+		if resolved, err = MergeConfig(resolved, variants[m.key]); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
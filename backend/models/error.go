@@ -16,12 +16,38 @@ const (
 	ErrorCodeInternalServer ErrorCode = "INTERNAL_SERVER_ERROR"
 	// ErrorCodeBadRequest indicates that the client request was malformed or syntactically incorrect.
 	ErrorCodeBadRequest ErrorCode = "BAD_REQUEST"
+	// ErrorCodeUnauthorized indicates that the request lacked valid credentials for the requested resource.
+	ErrorCodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	// ErrorCodeForbidden indicates that the requested operation is not permitted for any caller.
+	ErrorCodeForbidden ErrorCode = "FORBIDDEN"
+	// ErrorCodeRateLimited indicates that the client exceeded the request rate allowed for its route group.
+	ErrorCodeRateLimited ErrorCode = "RATE_LIMITED"
+	// ErrorCodeQuotaExceeded indicates that the client exhausted its daily request quota.
+	ErrorCodeQuotaExceeded ErrorCode = "QUOTA_EXCEEDED"
+	// ErrorCodeTimeout indicates that the request or one of its queries exceeded its deadline.
+	ErrorCodeTimeout ErrorCode = "TIMEOUT"
 )
 
+// FieldError describes a single validation failure tied to a specific request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error returns the message of the field failure, so that models can report one as an error.
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
 // APIError encapsulates the details of a specific error, including a machine-readable code and a human-readable message.
+// Field-level failures are listed in Errors when the error stems from request validation.
+// RequestID correlates the error with the server log line for the same request.
 type APIError struct {
-	Code    ErrorCode `json:"code"`
-	Message string    `json:"message"`
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
 }
 
 // ErrorResponse defines the standard structure for all error responses returned by the API.
@@ -44,6 +70,17 @@ func NewValidationError(message string) ErrorResponse {
 	return NewErrorResponse(ErrorCodeValidation, message)
 }
 
+// NewFieldValidationError initializes a validation ErrorResponse describing a failure of a single request field.
+func NewFieldValidationError(field, code, message string) ErrorResponse {
+	return NewValidationError(message).WithFieldErrors(FieldError{Field: field, Code: code, Message: message})
+}
+
+// WithFieldErrors returns a copy of the ErrorResponse with the given field-level failures appended.
+func (e ErrorResponse) WithFieldErrors(errs ...FieldError) ErrorResponse {
+	e.Error.Errors = append(append([]FieldError{}, e.Error.Errors...), errs...)
+	return e
+}
+
 // NewNotFoundError initializes an ErrorResponse specifically for resource-not-found failures.
 func NewNotFoundError(message string) ErrorResponse {
 	return NewErrorResponse(ErrorCodeNotFound, message)
@@ -63,3 +100,28 @@ func NewInternalServerError(message string) ErrorResponse {
 func NewBadRequestError(message string) ErrorResponse {
 	return NewErrorResponse(ErrorCodeBadRequest, message)
 }
+
+// NewUnauthorizedError initializes an ErrorResponse specifically for missing or invalid credentials.
+func NewUnauthorizedError(message string) ErrorResponse {
+	return NewErrorResponse(ErrorCodeUnauthorized, message)
+}
+
+// NewForbiddenError initializes an ErrorResponse specifically for operations that are not permitted.
+func NewForbiddenError(message string) ErrorResponse {
+	return NewErrorResponse(ErrorCodeForbidden, message)
+}
+
+// NewRateLimitError initializes an ErrorResponse specifically for requests rejected by the rate limiter.
+func NewRateLimitError(message string) ErrorResponse {
+	return NewErrorResponse(ErrorCodeRateLimited, message)
+}
+
+// NewQuotaExceededError initializes an ErrorResponse specifically for requests beyond the daily quota.
+func NewQuotaExceededError(message string) ErrorResponse {
+	return NewErrorResponse(ErrorCodeQuotaExceeded, message)
+}
+
+// NewTimeoutError initializes an ErrorResponse specifically for requests that ran past their deadline.
+func NewTimeoutError(message string) ErrorResponse {
+	return NewErrorResponse(ErrorCodeTimeout, message)
+}
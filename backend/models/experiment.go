@@ -0,0 +1,210 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Experiment statuses. An experiment is edited as a draft, serves its variants while running, and
+// ends stopped or promoted, when one of its variants has become the page's base content.
+const (
+	ExperimentDraft    = "draft"
+	ExperimentRunning  = "running"
+	ExperimentStopped  = "stopped"
+	ExperimentPromoted = "promoted"
+)
+
+// AuditActionExperimentPromote records an experiment variant promoted to a page's base content.
+const AuditActionExperimentPromote = "experiments.promote"
+
+// Experiment is an A/B test on the layout of a page. While it runs, each user or device is assigned
+// to one of its variants by a deterministic hash, in proportion to the variants' weights, and the
+// delivery endpoints serve that variant instead of the page's base content.
+type Experiment struct {
+	ID        uuid.UUID  `json:"id"`
+	PageID    uuid.UUID  `json:"page_id"`
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Variants  Variants   `json:"variants"`
+	Winner    string     `json:"winner,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Variant is one arm of an experiment, identified by a key unique within it. A variant either
+// replaces the page's widgets with its own list, or overrides the configs of some of them, as JSON
+// merge patches keyed by widget ID. A variant with neither serves the base content, as a control.
+type Variant struct {
+	Key       string                        `json:"key"`
+	Weight    int                           `json:"weight"`
+	Widgets   []VariantWidget               `json:"widgets,omitempty"`
+	Overrides map[uuid.UUID]json.RawMessage `json:"overrides,omitempty"`
+}
+
+// VariantWidget is a widget of a variant's own widget list. Its ID is assigned when the variant is
+// saved, so clients see the same IDs for as long as the variant is served, and promoting the
+// variant stores its widgets under the same IDs.
+type VariantWidget struct {
+	ID     uuid.UUID       `json:"id"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+// Variants is the list of an experiment's variants, stored as one JSON column.
+type Variants []Variant
+
+// ExperimentAssignment reports which variant of a running experiment a delivery response serves.
+type ExperimentAssignment struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Variant string    `json:"variant"`
+}
+
+// CreateExperimentRequest defines the expected payload for the experiment creation endpoint.
+type CreateExperimentRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Variants Variants `json:"variants" binding:"required"`
+}
+
+// UpdateExperimentRequest defines the expected payload for the experiment update endpoint, where
+// fields are optional. Variants can only be changed while the experiment is a draft.
+type UpdateExperimentRequest struct {
+	Name     *string  `json:"name,omitempty"`
+	Variants Variants `json:"variants,omitempty"`
+}
+
+// PromoteExperimentRequest names the variant to promote to the page's base content.
+type PromoteExperimentRequest struct {
+	Variant string `json:"variant" binding:"required"`
+}
+
+// Assign returns the variant served to the user or device identified by unit. The unit is hashed
+// together with the experiment ID, so a unit keeps its variant for the life of the experiment while
+// its assignments in different experiments are independent. Variants with no weight get no traffic.
+func (e *Experiment) Assign(unit string) *Variant {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(e.ID.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(unit))
+	bucket := int(h.Sum64() % uint64(total))
+
+	for i := range e.Variants {
+		if bucket < e.Variants[i].Weight {
+			return &e.Variants[i]
+		}
+		bucket -= e.Variants[i].Weight
+	}
+	return nil
+}
+
+// Variant returns the variant with the given key, or nil.
+func (e *Experiment) Variant(key string) *Variant {
+	for i := range e.Variants {
+		if e.Variants[i].Key == key {
+			return &e.Variants[i]
+		}
+	}
+	return nil
+}
+
+// Apply returns the widgets served by the variant in place of the page's base widgets: its own
+// list, positioned in order, or the base widgets with their overridden configs merged in.
+// Overrides for widgets that are no longer on the page are ignored.
+func (v *Variant) Apply(pageID uuid.UUID, base []Widget) ([]Widget, error) {
+	if len(v.Widgets) > 0 {
+		widgets := make([]Widget, len(v.Widgets))
+		for i, w := range v.Widgets {
+			widgets[i] = Widget{ID: w.ID, PageID: pageID, Type: w.Type, Position: i + 1, Config: w.Config}
+		}
+		return widgets, nil
+	}
+	if len(v.Overrides) == 0 {
+		return base, nil
+	}
+
+	widgets := make([]Widget, len(base))
+	for i, w := range base {
+		if patch, ok := v.Overrides[w.ID]; ok {
+			config, err := MergeConfig(w.Config, patch)
+			if err != nil {
+				return nil, err
+			}
+			w.Config = config
+		}
+		widgets[i] = w
+	}
+	return widgets, nil
+}
+
+// MergeConfig applies patch to a widget config as a JSON merge patch (RFC 7386): object members
+// are merged recursively, null removes a member, and any other value replaces it.
+func MergeConfig(config, patch json.RawMessage) (json.RawMessage, error) {
+	var target, changes interface{}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &target); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(target, changes))
+}
+
+// mergePatch merges a decoded patch into a decoded target, following RFC 7386.
+func mergePatch(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]interface{})
+	if !ok {
+		merged = make(map[string]interface{}, len(changes))
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergePatch(merged[key], value)
+	}
+	return merged
+}
+
+// Value stores the variants as JSON text, implementing driver.Valuer for the variants column.
+func (v Variants) Value() (driver.Value, error) {
+	if v == nil {
+		v = Variants{}
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads variants stored as JSON, implementing sql.Scanner for the variants column.
+func (v *Variants) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, v)
+	case string:
+		return json.Unmarshal([]byte(s), v)
+	}
+	return fmt.Errorf("cannot scan %T into experiment variants", src)
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultLocale is the locale of the base content of pages created without one.
+const DefaultLocale = "en"
+
+// MaxLocales caps the number of locales a page names or a widget overrides its config for.
+const MaxLocales = 50
+
+// PageNames holds the localized names of a page, keyed by locale tag. The page's own name is its
+// name in its default locale.
+type PageNames map[string]string
+
+// WidgetLocales holds the localized config overrides of a widget, keyed by locale tag. Each
+// override is a JSON merge patch applied over the widget's base config.
+type WidgetLocales map[string]json.RawMessage
+
+// Localize returns the name of the page in the given locales, trying them from the least to the
+// most specific, such as ["es", "es-MX"], and keeping the page's own name when none is named.
+func (p *Page) Localize(lineage []string) string {
+	name := p.Name
+	for _, locale := range lineage {
+		if n, ok := p.Names[locale]; ok {
+			name = n
+		}
+	}
+	return name
+}
+
+// Localize returns the config of the widget in the given locales, merging their overrides over the
+// base config from the least to the most specific, such as ["es", "es-MX"].
+func (w *Widget) Localize(lineage []string) (json.RawMessage, error) {
+	config := w.Config
+	for _, locale := range lineage {
+		patch, ok := w.Locales[locale]
+		if !ok {
+			continue
+		}
+		merged, err := MergeConfig(config, patch)
+		if err != nil {
+			return nil, err
+		}
+		config = merged
+	}
+	return config, nil
+}
+
+// Value stores the names as JSON text, implementing driver.Valuer for the names column.
+func (n PageNames) Value() (driver.Value, error) {
+	if n == nil {
+		n = PageNames{}
+	}
+	raw, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads names stored as JSON, implementing sql.Scanner for the names column. An empty object
+// scans as nil.
+func (n *PageNames) Scan(src interface{}) error {
+	*n = nil
+	if err := scanJSON(src, n); err != nil {
+		return fmt.Errorf("cannot scan %T into page names: %w", src, err)
+	}
+	if len(*n) == 0 {
+		*n = nil
+	}
+	return nil
+}
+
+// Value stores the overrides as JSON text, implementing driver.Valuer for the locales column.
+func (l WidgetLocales) Value() (driver.Value, error) {
+	if l == nil {
+		l = WidgetLocales{}
+	}
+	raw, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads overrides stored as JSON, implementing sql.Scanner for the locales column. An empty
+// object scans as nil.
+func (l *WidgetLocales) Scan(src interface{}) error {
+	*l = nil
+	if err := scanJSON(src, l); err != nil {
+		return fmt.Errorf("cannot scan %T into widget locales: %w", src, err)
+	}
+	if len(*l) == 0 {
+		*l = nil
+	}
+	return nil
+}
+
+// scanJSON decodes a JSON column read as bytes or text into dst.
+func scanJSON(src interface{}, dst interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, dst)
+	case string:
+		return json.Unmarshal([]byte(s), dst)
+	}
+	return fmt.Errorf("unsupported column type")
+}
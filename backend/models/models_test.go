@@ -2,7 +2,13 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // TestIsValidWidgetType verifies that the widget type validation logic correctly identifies
@@ -100,3 +106,303 @@ func TestValidWidgetTypes(t *testing.T) {
 		}
 	}
 }
+
+// TestNewRateLimitError verifies the shorthand constructor for rate limited requests.
+func TestNewRateLimitError(t *testing.T) {
+	response := NewRateLimitError("slow down")
+
+	if response.Error.Code != ErrorCodeRateLimited {
+		t.Errorf("Expected code %s, got %s", ErrorCodeRateLimited, response.Error.Code)
+	}
+}
+
+// TestScheduleLiveAt verifies visibility bounds, local times read in the schedule's timezone,
+// and weekly windows that run past midnight.
+func TestScheduleLiveAt(t *testing.T) {
+	var schedule Schedule
+	raw := `{"timezone":"America/New_York","visible_from":"2026-11-27T00:00","visible_until":"2026-11-30T00:00:00-05:00",
+		"weekly":[{"days":["FRI","sat"],"start":"18:00","end":"02:00"},{"days":["sun"],"start":"10:00","end":"24:00"}]}`
+	if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	newYork, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"before visible_from", time.Date(2026, 11, 26, 20, 0, 0, 0, newYork), false},
+		{"friday evening", time.Date(2026, 11, 27, 18, 0, 0, 0, newYork), true},
+		{"friday afternoon", time.Date(2026, 11, 27, 17, 59, 0, 0, newYork), false},
+		{"after midnight into saturday", time.Date(2026, 11, 28, 1, 59, 0, 0, newYork), true},
+		{"saturday morning", time.Date(2026, 11, 28, 2, 0, 0, 0, newYork), false},
+		{"after midnight into sunday", time.Date(2026, 11, 29, 0, 30, 0, 0, newYork), true},
+		{"sunday late", time.Date(2026, 11, 29, 23, 59, 0, 0, newYork), true},
+		{"at visible_until", time.Date(2026, 11, 30, 0, 0, 0, 0, newYork), false},
+		{"friday evening in UTC", time.Date(2026, 11, 27, 23, 30, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.LiveAt(tt.at); got != tt.want {
+				t.Errorf("LiveAt(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+
+	var none *Schedule
+	if !none.LiveAt(time.Now()) || !none.IsZero() {
+		t.Error("Expected a nil schedule to always be live")
+	}
+}
+
+// TestExperimentAssign verifies that variant assignment is deterministic per unit, follows the
+// variants' weights, and skips variants without weight.
+func TestExperimentAssign(t *testing.T) {
+	e := &Experiment{ID: uuid.New(), Variants: Variants{{Key: "a", Weight: 75}, {Key: "off", Weight: 0}, {Key: "b", Weight: 25}}}
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		unit := fmt.Sprintf("user-%d", i)
+		v := e.Assign(unit)
+		if v == nil {
+			t.Fatalf("Expected a variant for %s", unit)
+		}
+		if again := e.Assign(unit); again.Key != v.Key {
+			t.Fatalf("Expected %s to keep variant %s, got %s", unit, v.Key, again.Key)
+		}
+		counts[v.Key]++
+	}
+	if counts["off"] != 0 {
+		t.Errorf("Expected no traffic for a variant without weight, got %d", counts["off"])
+	}
+	if counts["a"] < 2800 || counts["a"] > 3200 {
+		t.Errorf("Expected about 3000 of 4000 units in the 75%% variant, got %v", counts)
+	}
+
+	if (&Experiment{Variants: Variants{{Key: "a"}}}).Assign("u") != nil {
+		t.Error("Expected no variant when no variant has weight")
+	}
+}
+
+// TestVariantApply verifies that variants serve their own widgets or merge their overrides into
+// the base widgets as JSON merge patches.
+func TestVariantApply(t *testing.T) {
+	pageID := uuid.New()
+	grid := Widget{ID: uuid.New(), PageID: pageID, Type: "product_grid", Position: 1, Config: json.RawMessage(`{"columns":2,"style":{"gap":8,"border":true}}`)}
+	text := Widget{ID: uuid.New(), PageID: pageID, Type: "text", Position: 2, Config: json.RawMessage(`{"text":"Hi"}`)}
+	base := []Widget{grid, text}
+
+	control := &Variant{Key: "control"}
+	if got, err := control.Apply(pageID, base); err != nil || len(got) != 2 || string(got[0].Config) != string(grid.Config) {
+		t.Errorf("Expected the control to serve the base widgets, got %+v, %v", got, err)
+	}
+
+	overrides := &Variant{Key: "three", Overrides: map[uuid.UUID]json.RawMessage{grid.ID: json.RawMessage(`{"columns":3,"style":{"border":null}}`)}}
+	got, err := overrides.Apply(pageID, base)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Apply: %+v, %v", got, err)
+	}
+	if string(got[0].Config) != `{"columns":3,"style":{"gap":8}}` || string(got[1].Config) != `{"text":"Hi"}` {
+		t.Errorf("Expected the override to be merged into the grid only, got %s and %s", got[0].Config, got[1].Config)
+	}
+	if string(base[0].Config) != string(grid.Config) {
+		t.Errorf("Expected the base widgets to be left unchanged, got %s", base[0].Config)
+	}
+
+	own := &Variant{Key: "own", Widgets: []VariantWidget{{ID: uuid.New(), Type: "banner", Config: json.RawMessage(`{}`)}}}
+	if got, err := own.Apply(pageID, base); err != nil || len(got) != 1 || got[0].Type != "banner" || got[0].PageID != pageID || got[0].Position != 1 {
+		t.Errorf("Expected the variant's own widgets, got %+v, %v", got, err)
+	}
+}
+
+// TestWidgetTranslation verifies the translatable fields found in a config, including nested
+// objects but not arrays, and that translations merge into the locale overrides.
+func TestWidgetTranslation(t *testing.T) {
+	w := Widget{
+		ID:      uuid.New(),
+		Config:  json.RawMessage(`{"title":"Sale","image_url":"sale.png","button":{"label":"Shop","url":"/shop"},"slides":[{"title":"One"}],"bad key":{"title":"x"},"alt":""}`),
+		Locales: WidgetLocales{"es": json.RawMessage(`{"title":"Oferta"}`)},
+	}
+	fields := w.TranslatableFields()
+	if want := map[string]string{"title": "Sale", "button.label": "Shop"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Expected fields %v, got %v", want, fields)
+	}
+
+	if text, ok := w.Translation("es", "title"); !ok || text != "Oferta" {
+		t.Errorf("Expected the es title, got %q %v", text, ok)
+	}
+	if _, ok := w.Translation("es", "button.label"); ok {
+		t.Error("Expected no es button label yet")
+	}
+
+	before := w.Locales
+	if err := w.Translate("es", "button.label", "Comprar"); err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if err := w.Translate("fr", "title", "Soldes"); err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if text, ok := w.Translation("es", "button.label"); !ok || text != "Comprar" {
+		t.Errorf("Expected the es button label, got %q %v", text, ok)
+	}
+	if text, _ := w.Translation("es", "title"); text != "Oferta" {
+		t.Errorf("Expected the es title to be kept, got %q", text)
+	}
+	if len(before) != 1 || len(w.Locales) != 2 {
+		t.Errorf("Expected Translate to replace the locales map, got %v and %v", before, w.Locales)
+	}
+}
+
+// TestResolveConfig verifies that the variants matching a client are merged into a config, the
+// most specific last, and that invalid variants are rejected.
+func TestResolveConfig(t *testing.T) {
+	config := json.RawMessage(`{"color":"white","columns":2,"$variants":{` +
+		`"theme=dark":{"color":"black"},` +
+		`"form_factor=tablet":{"columns":3},` +
+		`"Platform=Android & theme=dark":{"color":"gray"}}}`)
+
+	tests := []struct {
+		name   string
+		client map[string]string
+		want   string
+	}{
+		{"no attributes", nil, `{"color":"white","columns":2}`},
+		{"dark", map[string]string{"theme": "dark"}, `{"color":"black","columns":2}`},
+		{"dark android tablet", map[string]string{"theme": "DARK", "platform": "android", "form_factor": "tablet"}, `{"color":"gray","columns":3}`},
+		{"light android", map[string]string{"theme": "light", "platform": "android"}, `{"color":"white","columns":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveConfig(config, tt.client)
+			if err != nil {
+				t.Fatalf("ResolveConfig: %v", err)
+			}
+			var gotMap, wantMap map[string]interface{}
+			json.Unmarshal(got, &gotMap)
+			json.Unmarshal([]byte(tt.want), &wantMap)
+			if !reflect.DeepEqual(gotMap, wantMap) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	plain := json.RawMessage(`{"b":1,"a":2}`)
+	if got, err := ResolveConfig(plain, map[string]string{"theme": "dark"}); err != nil || string(got) != string(plain) {
+		t.Errorf("Expected a config without variants to be unchanged, got %s, %v", got, err)
+	}
+
+	if err := ValidateConfigVariants(config); err != nil {
+		t.Errorf("Expected valid variants, got %v", err)
+	}
+	for _, invalid := range []string{
+		`{"$variants":[]}`,
+		`{"$variants":{"tier=gold":{}}}`,
+		`{"$variants":{"theme=sepia":{}}}`,
+		`{"$variants":{"theme":{}}}`,
+		`{"$variants":{"theme=dark&theme=light":{}}}`,
+		`{"$variants":{"theme=dark&platform=ios":{},"platform=ios&theme=dark":{}}}`,
+		`{"$variants":{"theme=dark":"black"}}`,
+		`{"$variants":{"theme=dark":{"$variants":{}}}}`,
+	} {
+		if err := ValidateConfigVariants(json.RawMessage(invalid)); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+// TestThemeNormalize verifies the hex color, token and contrast checks of a theme, and that
+// colors are normalized to lowercase #rrggbb.
+func TestThemeNormalize(t *testing.T) {
+	if ratio, err := ContrastRatio("#000", "#FFFFFF"); err != nil || ratio != 21 {
+		t.Errorf("Expected black on white to contrast 21:1, got %v, %v", ratio, err)
+	}
+	if ratio, _ := ContrastRatio("#777777", "#ffffff"); ratio < 4.47 || ratio > 4.48 {
+		t.Errorf("Expected #777777 on white to contrast about 4.48:1, got %v", ratio)
+	}
+
+	theme := &Theme{
+		Colors:     map[string]string{"text": "#111", "background": "#FFFFFF"},
+		DarkColors: map[string]string{"text": "#eeeeee", "background": "#121212"},
+		Typography: map[string]TextStyle{"body": {FontFamily: " Inter ", FontSize: 16, FontWeight: 400, LineHeight: 1.5}},
+		Spacing:    map[string]float64{"md": 16},
+		Contrast:   []ContrastPair{{Text: "text", Background: "background"}},
+	}
+	if errs := theme.Normalize(); len(errs) != 0 {
+		t.Fatalf("Expected a valid theme, got %+v", errs)
+	}
+	if theme.Colors["text"] != "#111111" || theme.Colors["background"] != "#ffffff" || theme.Typography["body"].FontFamily != "Inter" || theme.Radii == nil {
+		t.Errorf("Expected the theme to be normalized, got %+v", theme)
+	}
+
+	tests := []struct {
+		name  string
+		theme Theme
+		field string
+	}{
+		{"bad hex", Theme{Colors: map[string]string{"primary": "blue"}}, "colors.primary"},
+		{"alpha hex", Theme{Colors: map[string]string{"primary": "#0055ff80"}}, "colors.primary"},
+		{"bad name", Theme{Colors: map[string]string{"primary color": "#000"}}, "colors.primary color"},
+		{"dark only", Theme{Colors: map[string]string{"a": "#000"}, DarkColors: map[string]string{"b": "#fff"}}, "dark_colors.b"},
+		{"no font", Theme{Typography: map[string]TextStyle{"body": {FontSize: 16}}}, "typography.body.font_family"},
+		{"font weight", Theme{Typography: map[string]TextStyle{"body": {FontFamily: "Inter", FontSize: 16, FontWeight: 450}}}, "typography.body.font_weight"},
+		{"negative radius", Theme{Radii: map[string]float64{"sm": -1}}, "radii.sm"},
+		{"unknown pair color", Theme{Colors: map[string]string{"a": "#000"}, Contrast: []ContrastPair{{Text: "a", Background: "b"}}}, "contrast[0].background"},
+		{"low contrast", Theme{Colors: map[string]string{"a": "#777", "b": "#888"}, Contrast: []ContrastPair{{Text: "a", Background: "b"}}}, "contrast[0]"},
+		{"low dark contrast", Theme{Colors: map[string]string{"a": "#000", "b": "#fff"}, DarkColors: map[string]string{"b": "#111"}, Contrast: []ContrastPair{{Text: "a", Background: "b", MinRatio: 3}}}, "contrast[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.theme.Normalize()
+			if len(errs) != 1 || errs[0].Field != tt.field {
+				t.Errorf("Expected one failure on %s, got %+v", tt.field, errs)
+			}
+		})
+	}
+}
+
+// TestResolveTokens verifies that token references are found at any depth, resolved with the dark
+// palette in dark mode, and that other strings and unknown references are left alone.
+func TestResolveTokens(t *testing.T) {
+	theme := &Theme{
+		Colors:     map[string]string{"primary": "#0055ff", "background": "#ffffff"},
+		DarkColors: map[string]string{"background": "#000000"},
+		Typography: map[string]TextStyle{"title": {FontFamily: "Inter", FontSize: 24}},
+		Radii:      map[string]float64{"sm": 4},
+		Spacing:    map[string]float64{"md": 16},
+	}
+	config := json.RawMessage(`{"color":"$color.primary","style":{"bg":"$color.background","padding":["$spacing.md","$radius.sm"]},"font":"$typography.title","price":"$5 off","other":"$color.missing"}`)
+
+	refs := TokenReferences(config)
+	if want := []string{"$color.background", "$color.missing", "$color.primary", "$radius.sm", "$spacing.md", "$typography.title"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("Expected references %v, got %v", want, refs)
+	}
+	if unknown := theme.UnknownReferences(refs); !reflect.DeepEqual(unknown, []string{"$color.missing"}) {
+		t.Errorf("Expected $color.missing to be unknown, got %v", unknown)
+	}
+	if unknown := (*Theme)(nil).UnknownReferences(refs); len(unknown) != len(refs) {
+		t.Errorf("Expected a missing theme to define no tokens, got %v", unknown)
+	}
+
+	for _, dark := range []bool{false, true} {
+		resolved, err := theme.ResolveTokens(config, dark)
+		if err != nil {
+			t.Fatalf("ResolveTokens: %v", err)
+		}
+		background := "#ffffff"
+		if dark {
+			background = "#000000"
+		}
+		var got, want map[string]interface{}
+		json.Unmarshal(resolved, &got)
+		json.Unmarshal([]byte(`{"color":"#0055ff","style":{"bg":"`+background+`","padding":[16,4]},"font":{"font_family":"Inter","font_size":24},"price":"$5 off","other":"$color.missing"}`), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("dark=%v: expected %v, got %v", dark, want, got)
+		}
+	}
+
+	plain := json.RawMessage(`{"b":"$5","a":1}`)
+	if got, err := theme.ResolveTokens(plain, false); err != nil || string(got) != string(plain) {
+		t.Errorf("Expected a config without references to be unchanged, got %s, %v", got, err)
+	}
+}
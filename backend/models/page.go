@@ -9,27 +9,56 @@ import (
 // Page represents a high-level screen or container within the mobile application.
 // It serves as a parent entity for multiple UI components (widgets).
 type Page struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Route     string    `json:"route"`
-	IsHome    bool      `json:"is_home"`
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Route     string     `json:"route"`
+	IsHome    bool       `json:"is_home"`
+	Schedule  *Schedule  `json:"schedule,omitempty"`
+	Rule      string     `json:"rule,omitempty"`
+	SegmentID *uuid.UUID `json:"segment_id,omitempty"`
+
+	DefaultLocale string    `json:"default_locale"`
+	Names         PageNames `json:"names,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Widgets   []Widget  `json:"widgets,omitempty"`
+
+	// Locale and Direction report the locale whose content a delivery response serves, and
+	// whether it is written left to right or right to left.
+	Locale    string `json:"locale,omitempty"`
+	Direction string `json:"dir,omitempty"`
+
+	// Experiment reports the experiment variant whose widgets a delivery response serves.
+	Experiment *ExperimentAssignment `json:"experiment,omitempty"`
 }
 
 // CreatePageRequest defines the expected payload for the page creation endpoint.
 type CreatePageRequest struct {
-	Name   string `json:"name" binding:"required"`
-	Route  string `json:"route" binding:"required"`
-	IsHome bool   `json:"is_home"`
+	Name      string    `json:"name" binding:"required"`
+	Route     string    `json:"route" binding:"required"`
+	IsHome    bool      `json:"is_home"`
+	Schedule  *Schedule `json:"schedule,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	SegmentID string    `json:"segment_id,omitempty"`
+
+	DefaultLocale string    `json:"default_locale,omitempty"`
+	Names         PageNames `json:"names,omitempty"`
 }
 
 // UpdatePageRequest defines the expected payload for the page update endpoint, where fields are optional.
+// An empty schedule object, rule or segment ID removes the page's schedule, audience rule or segment,
+// and an empty names object removes its localized names.
 type UpdatePageRequest struct {
-	Name   *string `json:"name,omitempty"`
-	Route  *string `json:"route,omitempty"`
-	IsHome *bool   `json:"is_home,omitempty"`
+	Name      *string   `json:"name,omitempty"`
+	Route     *string   `json:"route,omitempty"`
+	IsHome    *bool     `json:"is_home,omitempty"`
+	Schedule  *Schedule `json:"schedule,omitempty"`
+	Rule      *string   `json:"rule,omitempty"`
+	SegmentID *string   `json:"segment_id,omitempty"`
+
+	DefaultLocale *string   `json:"default_locale,omitempty"`
+	Names         PageNames `json:"names,omitempty"`
 }
 
 // PageResponse encapsulates the data returned to the client for single-page queries.
@@ -51,3 +80,11 @@ type PageListResponse struct {
 	PerPage    int    `json:"per_page,omitempty"`
 	TotalPages int    `json:"total_pages,omitempty"`
 }
+
+// PageCursorResponse provides a structured wrapper for one keyset-paginated slice of Page entities.
+// NextCursor is omitted on the last slice.
+type PageCursorResponse struct {
+	Pages      []Page `json:"pages"`
+	PerPage    int    `json:"per_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
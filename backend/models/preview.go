@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Reasons for which content is not delivered to a client.
+const (
+	HiddenBySchedule = "schedule"
+	HiddenByRule     = "rule"
+	HiddenBySegment  = "segment"
+)
+
+// PagePreview reports what of a page is delivered at a given time to a client with the given
+// targeting attributes. Live tells whether the page itself is delivered, and Reason why not; the
+// page lists only the widgets delivered, and Hidden the others.
+type PagePreview struct {
+	At      time.Time         `json:"at"`
+	Context map[string]string `json:"context"`
+	Live    bool              `json:"live"`
+	Reason  string            `json:"reason,omitempty"`
+	Page    *Page             `json:"page"`
+	Hidden  []HiddenWidget    `json:"hidden_widgets"`
+}
+
+// HiddenWidget is a widget left out of a PagePreview, with the reason it is not delivered.
+type HiddenWidget struct {
+	Widget
+	Reason string `json:"reason"`
+}
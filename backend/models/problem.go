@@ -0,0 +1,42 @@
+package models
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details documents.
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails represents an RFC 9457 problem details document.
+// Code, Errors and RequestID are extension members carrying the application error code,
+// field-level failures and the request correlation identifier.
+type ProblemDetails struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      ErrorCode    `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// ProblemTypeURI returns the problem type reference for an error code, such as "/problems/validation-error".
+func ProblemTypeURI(code ErrorCode) string {
+	return "/problems/" + strings.ReplaceAll(strings.ToLower(string(code)), "_", "-")
+}
+
+// Problem converts the ErrorResponse into a problem details document for the given status and request path.
+func (e ErrorResponse) Problem(status int, instance string) ProblemDetails {
+	return ProblemDetails{
+		Type:      ProblemTypeURI(e.Error.Code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Error.Message,
+		Instance:  instance,
+		Code:      e.Error.Code,
+		Errors:    e.Error.Errors,
+		RequestID: e.Error.RequestID,
+	}
+}
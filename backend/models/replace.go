@@ -0,0 +1,49 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// ReplaceScopeAllStrings is the scope that searches every string value of a widget config, at any depth.
+const ReplaceScopeAllStrings = "*"
+
+// WidgetReplaceRequest defines the payload for a bulk find-and-replace across widget configs.
+// Scope is a dot-separated config path, such as "image_url" or "style.background", or "*" for every
+// string value. Match is a literal string, or a regular expression when Regex is set, in which case
+// Replacement may refer to capture groups as $1 or ${name}.
+type WidgetReplaceRequest struct {
+	Scope       string      `json:"scope" binding:"required"`
+	Match       string      `json:"match" binding:"required"`
+	Regex       bool        `json:"regex"`
+	Replacement string      `json:"replacement"`
+	Types       []string    `json:"types,omitempty"`
+	PageIDs     []uuid.UUID `json:"page_ids,omitempty"`
+}
+
+// ConfigChange is one string value rewritten inside a widget config, addressed by its dot-separated
+// path; array elements are addressed by their index.
+type ConfigChange struct {
+	Path   string `json:"path"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// WidgetReplaceDiff lists the changes a find-and-replace makes to one widget.
+type WidgetReplaceDiff struct {
+	WidgetID   uuid.UUID      `json:"widget_id"`
+	WidgetType string         `json:"widget_type"`
+	PageID     uuid.UUID      `json:"page_id"`
+	PageName   string         `json:"page_name"`
+	PageRoute  string         `json:"page_route"`
+	Changes    []ConfigChange `json:"changes"`
+}
+
+// WidgetReplaceResponse reports the outcome of a find-and-replace. Applied is false for a preview.
+// AuditID names the audit entry recorded for an applied change.
+type WidgetReplaceResponse struct {
+	Applied      bool                `json:"applied"`
+	Widgets      int                 `json:"widgets"`
+	Replacements int                 `json:"replacements"`
+	Diffs        []WidgetReplaceDiff `json:"diffs"`
+	AuditID      *uuid.UUID          `json:"audit_id,omitempty"`
+}
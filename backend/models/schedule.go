@@ -0,0 +1,229 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// The timezone database is embedded so that schedules resolve on hosts without zoneinfo files.
+	_ "time/tzdata"
+)
+
+// scheduleDays maps the day names accepted by weekly windows to their weekdays.
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// scheduleLocalLayouts lists the layouts accepted for visibility bounds given without a UTC offset,
+// which are read in the schedule's timezone.
+var scheduleLocalLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// Schedule restricts when a page or widget is delivered. The content is live from VisibleFrom,
+// inclusive, until VisibleUntil, exclusive; either bound may be left open. When Weekly lists
+// windows, the content is additionally only live inside one of them. Timezone is the IANA zone
+// in which local bounds and weekly windows are read, UTC by default.
+type Schedule struct {
+	Timezone     string         `json:"timezone,omitempty"`
+	VisibleFrom  *time.Time     `json:"visible_from,omitempty"`
+	VisibleUntil *time.Time     `json:"visible_until,omitempty"`
+	Weekly       []WeeklyWindow `json:"weekly,omitempty"`
+
+	location *time.Location
+}
+
+// WeeklyWindow is a recurring window on the given days, such as fri 18:00 to 23:00. Start and End
+// are "HH:MM" clock times; End may be "24:00", and an End at or before Start runs into the next day.
+type WeeklyWindow struct {
+	Days  []string `json:"days"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// scheduleError initializes and returns a FieldError for an invalid part of a schedule.
+func scheduleError(field, message string) *FieldError {
+	return &FieldError{Field: "schedule." + field, Code: "schedule", Message: message}
+}
+
+// UnmarshalJSON parses and validates a schedule. Bounds are RFC 3339 timestamps, or local date-times
+// such as "2026-11-27T00:00" read in the schedule's timezone. Failures are returned as *FieldError.
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Timezone     string         `json:"timezone"`
+		VisibleFrom  string         `json:"visible_from"`
+		VisibleUntil string         `json:"visible_until"`
+		Weekly       []WeeklyWindow `json:"weekly"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	parsed := Schedule{Timezone: raw.Timezone, location: time.UTC}
+	if raw.Timezone != "" {
+		loc, err := time.LoadLocation(raw.Timezone)
+		if err != nil || raw.Timezone == "Local" {
+			return scheduleError("timezone", fmt.Sprintf("Unknown timezone %q", raw.Timezone))
+		}
+		parsed.location = loc
+	}
+
+	var err error
+	if parsed.VisibleFrom, err = parsed.parseBound(raw.VisibleFrom); err != nil {
+		return scheduleError("visible_from", err.Error())
+	}
+	if parsed.VisibleUntil, err = parsed.parseBound(raw.VisibleUntil); err != nil {
+		return scheduleError("visible_until", err.Error())
+	}
+	if parsed.VisibleFrom != nil && parsed.VisibleUntil != nil && !parsed.VisibleUntil.After(*parsed.VisibleFrom) {
+		return scheduleError("visible_until", "visible_until must be later than visible_from")
+	}
+
+	for i, window := range raw.Weekly {
+		field := fmt.Sprintf("weekly[%d]", i)
+		if len(window.Days) == 0 {
+			return scheduleError(field+".days", "A weekly window needs at least one day")
+		}
+		for j, day := range window.Days {
+			window.Days[j] = strings.ToLower(day)
+			if _, ok := scheduleDays[window.Days[j]]; !ok {
+				return scheduleError(field+".days", fmt.Sprintf("Unknown day %q: use sun, mon, tue, wed, thu, fri or sat", day))
+			}
+		}
+		start, okStart := clockMinutes(window.Start)
+		end, okEnd := clockMinutes(window.End)
+		if !okStart || start == 24*60 {
+			return scheduleError(field+".start", "start must be a time between 00:00 and 23:59")
+		}
+		if !okEnd {
+			return scheduleError(field+".end", "end must be a time between 00:00 and 24:00")
+		}
+		if start == end {
+			return scheduleError(field+".end", "end must differ from start")
+		}
+		parsed.Weekly = append(parsed.Weekly, window)
+	}
+
+	*s = parsed
+	return nil
+}
+
+// parseBound reads a visibility bound, returning nil for an empty one.
+func (s *Schedule) parseBound(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		t = t.In(s.location)
+		return &t, nil
+	}
+	for _, layout := range scheduleLocalLayouts {
+		if t, err := time.ParseInLocation(layout, value, s.location); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid time %q: use RFC 3339 or a local date-time such as 2026-11-27T00:00", value)
+}
+
+// clockMinutes parses an "HH:MM" clock time between 00:00 and 24:00 into minutes after midnight.
+func clockMinutes(clock string) (int, bool) {
+	if len(clock) != 5 || clock[2] != ':' {
+		return 0, false
+	}
+	hours, errHours := strconv.ParseUint(clock[:2], 10, 8)
+	minutes, errMinutes := strconv.ParseUint(clock[3:], 10, 8)
+	if errHours != nil || errMinutes != nil {
+		return 0, false
+	}
+	total := int(hours*60 + minutes)
+	if minutes > 59 || total > 24*60 {
+		return 0, false
+	}
+	return total, true
+}
+
+// IsZero reports whether the schedule places no restriction, so that the content is always live.
+func (s *Schedule) IsZero() bool {
+	return s == nil || (s.VisibleFrom == nil && s.VisibleUntil == nil && len(s.Weekly) == 0)
+}
+
+// LiveAt reports whether content with this schedule is delivered at t. A nil schedule is always live.
+func (s *Schedule) LiveAt(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	if s.VisibleFrom != nil && t.Before(*s.VisibleFrom) {
+		return false
+	}
+	if s.VisibleUntil != nil && !t.Before(*s.VisibleUntil) {
+		return false
+	}
+	if len(s.Weekly) == 0 {
+		return true
+	}
+
+	loc := s.location
+	if loc == nil {
+		loc = time.UTC
+		if s.Timezone != "" {
+			if l, err := time.LoadLocation(s.Timezone); err == nil {
+				loc = l
+			}
+		}
+	}
+	local := t.In(loc)
+	for _, window := range s.Weekly {
+		if window.contains(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether the local time falls inside the window.
+func (w WeeklyWindow) contains(local time.Time) bool {
+	start, _ := clockMinutes(w.Start)
+	end, _ := clockMinutes(w.End)
+	minute := local.Hour()*60 + local.Minute()
+	weekday := local.Weekday()
+	for _, day := range w.Days {
+		d := scheduleDays[day]
+		if end > start {
+			if weekday == d && minute >= start && minute < end {
+				return true
+			}
+			continue
+		}
+		if (weekday == d && minute >= start) || (weekday == (d+1)%7 && minute < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value stores the schedule as JSON text, implementing driver.Valuer for the schedule columns.
+func (s Schedule) Value() (driver.Value, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads a schedule stored as JSON, implementing sql.Scanner for the schedule columns.
+func (s *Schedule) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("cannot scan %T into a schedule", src)
+}
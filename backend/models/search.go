@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// SearchKind tells whether a search hit is a page or a widget.
+type SearchKind string
+
+const (
+	// SearchKindPage marks a hit on a page name or route.
+	SearchKindPage SearchKind = "page"
+	// SearchKindWidget marks a hit on the text inside a widget config.
+	SearchKindWidget SearchKind = "widget"
+)
+
+// SearchableConfigFields lists the widget config keys whose string values are indexed for search.
+// Other config values, such as image URLs and colors, are not searched.
+var SearchableConfigFields = []string{"title", "subtitle", "content", "text", "caption", "alt", "alt_text", "label", "button_text"}
+
+// SearchHit is one ranked match of a search query. Every hit names the page it belongs to;
+// widget hits also name the matching widget. Snippet is the matched text with each matching
+// term wrapped in <mark> and </mark>; the text around the marks is returned as stored.
+type SearchHit struct {
+	Kind       SearchKind `json:"kind"`
+	PageID     uuid.UUID  `json:"page_id"`
+	PageName   string     `json:"page_name"`
+	PageRoute  string     `json:"page_route"`
+	WidgetID   *uuid.UUID `json:"widget_id,omitempty"`
+	WidgetType string     `json:"widget_type,omitempty"`
+	Snippet    string     `json:"snippet"`
+	Rank       float64    `json:"rank"`
+}
+
+// SearchResponse provides a structured wrapper for the ranked hits of a search query.
+type SearchResponse struct {
+	Query   string      `json:"query"`
+	Results []SearchHit `json:"results"`
+	Total   int         `json:"total"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Segment is a reusable audience that pages and widgets reference by ID. It is defined by an audience
+// rule, an uploaded list of user IDs, or both, in which case a client has to satisfy the rule and be
+// on the list. UserCount is the size of the list, which is stored apart from the segment.
+type Segment struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Rule      string    `json:"rule,omitempty"`
+	UserCount int       `json:"user_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateSegmentRequest defines the expected payload for the segment creation endpoint.
+type CreateSegmentRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Rule    string   `json:"rule,omitempty"`
+	UserIDs []string `json:"user_ids,omitempty"`
+}
+
+// UpdateSegmentRequest defines the expected payload for the segment update endpoint, where fields
+// are optional. An empty rule removes the segment's rule.
+type UpdateSegmentRequest struct {
+	Name *string `json:"name,omitempty"`
+	Rule *string `json:"rule,omitempty"`
+}
+
+// SegmentUsersRequest defines the JSON payload for replacing a segment's user list.
+type SegmentUsersRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// SegmentMembership is what delivery needs to decide whether a client belongs to a segment: its
+// rule, whether it has a user list, and whether the client's user ID is on it.
+type SegmentMembership struct {
+	SegmentID uuid.UUID
+	Rule      string
+	HasUsers  bool
+	Listed    bool
+}
+
+// SegmentTestResult reports whether a client belongs to a segment, and which of the segment's
+// conditions it meets. MatchesRule and Listed are omitted for conditions the segment does not have.
+type SegmentTestResult struct {
+	Segment     *Segment          `json:"segment"`
+	Context     map[string]string `json:"context"`
+	Member      bool              `json:"member"`
+	MatchesRule *bool             `json:"matches_rule,omitempty"`
+	Listed      *bool             `json:"listed,omitempty"`
+}
@@ -0,0 +1,466 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditActionThemeUpdate records a change to the app theme.
+const AuditActionThemeUpdate = "theme.update"
+
+// Design token groups, named by the first segment of a token reference such as $color.primary.
+const (
+	TokenGroupColor      = "color"
+	TokenGroupTypography = "typography"
+	TokenGroupRadius     = "radius"
+	TokenGroupSpacing    = "spacing"
+)
+
+// Limits on the tokens of a theme.
+const (
+	MaxThemeTokens    = 100
+	MaxContrastPairs  = 100
+	MaxThemeDimension = 1000
+	MaxFontSize       = 200
+	MaxFontFamily     = 100
+)
+
+// MinContrastRatio is the WCAG AA contrast ratio between normal text and its background, required
+// of the contrast pairs of a theme that set no ratio of their own.
+const MinContrastRatio = 4.5
+
+var (
+	// tokenName restricts token names, so that references can be matched without escaping.
+	tokenName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	// tokenReference matches a config string that references a design token.
+	tokenReference = regexp.MustCompile(`^\$(color|typography|radius|spacing)\.([A-Za-z0-9_-]{1,64})$`)
+	// hexColor matches #rgb and #rrggbb colors.
+	hexColor = regexp.MustCompile(`^#(?:[0-9A-Fa-f]{3}|[0-9A-Fa-f]{6})$`)
+)
+
+// Theme holds the app's design tokens: a color palette with overrides for dark mode, a typography
+// scale, corner radii and a spacing scale. Widget configs reference tokens by strings such as
+// "$color.primary" or "$spacing.md", which delivery replaces with the token's value. Contrast
+// lists the text and background colors whose contrast the theme guarantees in both modes.
+type Theme struct {
+	Colors     map[string]string    `json:"colors"`
+	DarkColors map[string]string    `json:"dark_colors,omitempty"`
+	Typography map[string]TextStyle `json:"typography"`
+	Radii      map[string]float64   `json:"radii"`
+	Spacing    map[string]float64   `json:"spacing"`
+	Contrast   []ContrastPair       `json:"contrast,omitempty"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+// TextStyle is a step of the typography scale. FontSize is in points, and LineHeight, when set, is
+// a multiple of it.
+type TextStyle struct {
+	FontFamily string  `json:"font_family"`
+	FontSize   float64 `json:"font_size"`
+	FontWeight int     `json:"font_weight,omitempty"`
+	LineHeight float64 `json:"line_height,omitempty"`
+}
+
+// ContrastPair names a text color and the background color it is shown on, which must contrast by
+// at least MinRatio, MinContrastRatio by default, in light and dark mode.
+type ContrastPair struct {
+	Text       string  `json:"text"`
+	Background string  `json:"background"`
+	MinRatio   float64 `json:"min_ratio,omitempty"`
+}
+
+// ThemeRequest defines the expected payload for the theme update endpoint, which replaces the
+// whole theme.
+type ThemeRequest struct {
+	Colors     map[string]string    `json:"colors"`
+	DarkColors map[string]string    `json:"dark_colors"`
+	Typography map[string]TextStyle `json:"typography"`
+	Radii      map[string]float64   `json:"radii"`
+	Spacing    map[string]float64   `json:"spacing"`
+	Contrast   []ContrastPair       `json:"contrast"`
+}
+
+// Theme returns the theme described by the request.
+func (r ThemeRequest) Theme() *Theme {
+	return &Theme{
+		Colors:     r.Colors,
+		DarkColors: r.DarkColors,
+		Typography: r.Typography,
+		Radii:      r.Radii,
+		Spacing:    r.Spacing,
+		Contrast:   r.Contrast,
+	}
+}
+
+// themeError initializes and returns a FieldError for an invalid part of a theme.
+func themeError(field, code, message string) FieldError {
+	return FieldError{Field: field, Code: code, Message: message}
+}
+
+// Normalize validates a theme and rewrites its colors as lowercase #rrggbb, returning every
+// failure found. Missing groups become empty, and an empty dark palette is dropped.
+func (t *Theme) Normalize() []FieldError {
+	var errs []FieldError
+	if t.Colors == nil {
+		t.Colors = map[string]string{}
+	}
+	if t.Typography == nil {
+		t.Typography = map[string]TextStyle{}
+	}
+	if t.Radii == nil {
+		t.Radii = map[string]float64{}
+	}
+	if t.Spacing == nil {
+		t.Spacing = map[string]float64{}
+	}
+	if len(t.DarkColors) == 0 {
+		t.DarkColors = nil
+	}
+
+	errs = append(errs, normalizeColors("colors", t.Colors, nil)...)
+	errs = append(errs, normalizeColors("dark_colors", t.DarkColors, t.Colors)...)
+
+	for _, name := range sortedKeys(t.Typography) {
+		field := "typography." + name
+		style := t.Typography[name]
+		style.FontFamily = strings.TrimSpace(style.FontFamily)
+		t.Typography[name] = style
+		switch {
+		case !tokenName.MatchString(name):
+			errs = append(errs, themeError(field, "token_name", tokenNameMessage(name)))
+		case style.FontFamily == "":
+			errs = append(errs, themeError(field+".font_family", "required", "Font family is required"))
+		case len(style.FontFamily) > MaxFontFamily:
+			errs = append(errs, themeError(field+".font_family", "max", fmt.Sprintf("Font families are limited to %d characters", MaxFontFamily)))
+		case !(style.FontSize > 0 && style.FontSize <= MaxFontSize):
+			errs = append(errs, themeError(field+".font_size", "range", fmt.Sprintf("Font size must be above 0 and at most %d", MaxFontSize)))
+		case style.FontWeight != 0 && (style.FontWeight < 100 || style.FontWeight > 900 || style.FontWeight%100 != 0):
+			errs = append(errs, themeError(field+".font_weight", "oneof", "Font weight must be one of 100, 200, ..., 900"))
+		case style.LineHeight != 0 && !(style.LineHeight >= 0.5 && style.LineHeight <= 4):
+			errs = append(errs, themeError(field+".line_height", "range", "Line height must be between 0.5 and 4 times the font size"))
+		}
+	}
+	errs = append(errs, checkDimensions("radii", t.Radii)...)
+	errs = append(errs, checkDimensions("spacing", t.Spacing)...)
+	groups := []struct {
+		name string
+		size int
+	}{{"colors", len(t.Colors)}, {"dark_colors", len(t.DarkColors)}, {"typography", len(t.Typography)}, {"radii", len(t.Radii)}, {"spacing", len(t.Spacing)}}
+	for _, group := range groups {
+		if group.size > MaxThemeTokens {
+			errs = append(errs, themeError(group.name, "max", fmt.Sprintf("A theme has at most %d tokens in %s", MaxThemeTokens, group.name)))
+		}
+	}
+
+	if len(t.Contrast) > MaxContrastPairs {
+		return append(errs, themeError("contrast", "max", fmt.Sprintf("A theme has at most %d contrast pairs", MaxContrastPairs)))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	for i, pair := range t.Contrast {
+		field := fmt.Sprintf("contrast[%d]", i)
+		if _, ok := t.Colors[pair.Text]; !ok {
+			errs = append(errs, themeError(field+".text", "exists", fmt.Sprintf("Unknown color %q", pair.Text)))
+			continue
+		}
+		if _, ok := t.Colors[pair.Background]; !ok {
+			errs = append(errs, themeError(field+".background", "exists", fmt.Sprintf("Unknown color %q", pair.Background)))
+			continue
+		}
+		required := pair.MinRatio
+		if required == 0 {
+			required = MinContrastRatio
+		} else if !(required >= 1 && required <= 21) {
+			errs = append(errs, themeError(field+".min_ratio", "range", "Contrast ratios range from 1 to 21"))
+			continue
+		}
+		for _, dark := range []bool{false, true} {
+			if dark && t.DarkColors == nil {
+				break
+			}
+			text, _ := t.Color(pair.Text, dark)
+			background, _ := t.Color(pair.Background, dark)
+			if ratio, _ := ContrastRatio(text, background); ratio < required {
+				mode := "light"
+				if dark {
+					mode = "dark"
+				}
+				errs = append(errs, themeError(field, "contrast", fmt.Sprintf("%s on %s has a contrast ratio of %.2f:1 in %s mode, below %s:1",
+					pair.Text, pair.Background, ratio, mode, strconv.FormatFloat(required, 'f', -1, 64))))
+			}
+		}
+	}
+	return errs
+}
+
+// normalizeColors validates and rewrites a palette in place. When base is given, the palette
+// overrides it and may only name its colors.
+func normalizeColors(group string, colors, base map[string]string) []FieldError {
+	var errs []FieldError
+	for _, name := range sortedKeys(colors) {
+		field := group + "." + name
+		color, ok := normalizeHexColor(colors[name])
+		switch {
+		case !tokenName.MatchString(name):
+			errs = append(errs, themeError(field, "token_name", tokenNameMessage(name)))
+		case base != nil && base[name] == "":
+			errs = append(errs, themeError(field, "exists", fmt.Sprintf("Dark mode can only override the colors of the palette, not %q", name)))
+		case !ok:
+			errs = append(errs, themeError(field, "hexcolor", fmt.Sprintf("Invalid color %q, expected #rgb or #rrggbb", colors[name])))
+		default:
+			colors[name] = color
+		}
+	}
+	return errs
+}
+
+// checkDimensions validates a scale of sizes in points.
+func checkDimensions(group string, sizes map[string]float64) []FieldError {
+	var errs []FieldError
+	for _, name := range sortedKeys(sizes) {
+		field := group + "." + name
+		switch size := sizes[name]; {
+		case !tokenName.MatchString(name):
+			errs = append(errs, themeError(field, "token_name", tokenNameMessage(name)))
+		case !(size >= 0 && size <= MaxThemeDimension):
+			errs = append(errs, themeError(field, "range", fmt.Sprintf("Sizes range from 0 to %d", MaxThemeDimension)))
+		}
+	}
+	return errs
+}
+
+// tokenNameMessage describes a token name that is not allowed.
+func tokenNameMessage(name string) string {
+	return fmt.Sprintf("Invalid token name %q: names are 1-64 letters, digits, '_' or '-'", name)
+}
+
+// sortedKeys returns the keys of a map in ascending order, so that failures are reported in a
+// stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// normalizeHexColor returns a #rgb or #rrggbb color as lowercase #rrggbb.
+func normalizeHexColor(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if !hexColor.MatchString(s) {
+		return "", false
+	}
+	s = strings.ToLower(s)
+	if len(s) == 4 {
+		s = string([]byte{'#', s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+	return s, true
+}
+
+// ContrastRatio returns the WCAG contrast ratio between two hex colors, from 1 for identical
+// luminance to 21 for black on white.
+func ContrastRatio(a, b string) (float64, error) {
+	la, err := relativeLuminance(a)
+	if err != nil {
+		return 0, err
+	}
+	lb, err := relativeLuminance(b)
+	if err != nil {
+		return 0, err
+	}
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05), nil
+}
+
+// relativeLuminance returns the WCAG relative luminance of a hex color.
+func relativeLuminance(color string) (float64, error) {
+	hex, ok := normalizeHexColor(color)
+	if !ok {
+		return 0, fmt.Errorf("invalid color %q", color)
+	}
+	var channels [3]float64
+	for i := range channels {
+		v, _ := strconv.ParseUint(hex[1+2*i:3+2*i], 16, 8)
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			channels[i] = c / 12.92
+		} else {
+			channels[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*channels[0] + 0.7152*channels[1] + 0.0722*channels[2], nil
+}
+
+// Color returns a color of the palette, taken from the dark palette in dark mode when it
+// overrides it.
+func (t *Theme) Color(name string, dark bool) (string, bool) {
+	if dark {
+		if color, ok := t.DarkColors[name]; ok {
+			return color, true
+		}
+	}
+	color, ok := t.Colors[name]
+	return color, ok
+}
+
+// Token returns the value of the token a reference such as "$color.primary" names: a hex color,
+// a TextStyle or a size. A nil theme defines no tokens.
+func (t *Theme) Token(ref string, dark bool) (interface{}, bool) {
+	group, name, ok := ParseTokenReference(ref)
+	if !ok || t == nil {
+		return nil, false
+	}
+	switch group {
+	case TokenGroupColor:
+		return t.Color(name, dark)
+	case TokenGroupTypography:
+		style, ok := t.Typography[name]
+		return style, ok
+	case TokenGroupRadius:
+		size, ok := t.Radii[name]
+		return size, ok
+	case TokenGroupSpacing:
+		size, ok := t.Spacing[name]
+		return size, ok
+	}
+	return nil, false
+}
+
+// ParseTokenReference splits a token reference such as "$color.primary" into its group and token
+// name, reporting whether s is one.
+func ParseTokenReference(s string) (group, name string, ok bool) {
+	m := tokenReference.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// TokenReferences returns the distinct token references found among the string values of the
+// configs, at any depth, in ascending order. A string is a reference when it is one as a whole.
+func TokenReferences(configs ...json.RawMessage) []string {
+	seen := make(map[string]bool)
+	for _, config := range configs {
+		if !mayReferenceTokens(config) {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(config, &value); err != nil {
+			continue
+		}
+		replaceReferences(value, func(ref string) (interface{}, bool) {
+			seen[ref] = true
+			return nil, false
+		})
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	return sortedKeys(seen)
+}
+
+// TokenReferences returns the distinct token references of the widget's config and localized
+// configs, in ascending order.
+func (w *Widget) TokenReferences() []string {
+	return TokenReferences(append([]json.RawMessage{w.Config}, w.Locales.configs()...)...)
+}
+
+// TokenReferences returns the distinct token references of the localized configs, in ascending
+// order.
+func (l WidgetLocales) TokenReferences() []string {
+	return TokenReferences(l.configs()...)
+}
+
+// configs returns the localized configs in locale order.
+func (l WidgetLocales) configs() []json.RawMessage {
+	configs := make([]json.RawMessage, 0, len(l))
+	for _, locale := range sortedKeys(l) {
+		configs = append(configs, l[locale])
+	}
+	return configs
+}
+
+// TokenReferences returns the distinct token references of the variant's widget configs and
+// overrides, in ascending order.
+func (v *Variant) TokenReferences() []string {
+	var configs []json.RawMessage
+	for _, w := range v.Widgets {
+		configs = append(configs, w.Config)
+	}
+	for _, patch := range v.Overrides {
+		configs = append(configs, patch)
+	}
+	return TokenReferences(configs...)
+}
+
+// UnknownReferences returns the references the theme does not define. A nil theme defines none.
+func (t *Theme) UnknownReferences(refs []string) []string {
+	var unknown []string
+	for _, ref := range refs {
+		if _, ok := t.Token(ref, false); !ok {
+			unknown = append(unknown, ref)
+		}
+	}
+	return unknown
+}
+
+// ResolveTokens replaces the token references of a config with the tokens' values, in dark mode
+// when dark is set. References the theme does not define are left as they are, and configs without
+// references are returned unchanged.
+func (t *Theme) ResolveTokens(config json.RawMessage, dark bool) (json.RawMessage, error) {
+	if !mayReferenceTokens(config) {
+		return config, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(config, &value); err != nil {
+		return nil, err
+	}
+	replaced := false
+	value = replaceReferences(value, func(ref string) (interface{}, bool) {
+		token, ok := t.Token(ref, dark)
+		replaced = replaced || ok
+		return token, ok
+	})
+	if !replaced {
+		return config, nil
+	}
+	return json.Marshal(value)
+}
+
+// mayReferenceTokens cheaply rules out configs without any string starting with "$".
+func mayReferenceTokens(config json.RawMessage) bool {
+	return bytes.Contains(config, []byte(`"$`))
+}
+
+// replaceReferences walks a decoded JSON value, replacing the references for which replace
+// returns a value, and returns the result.
+func replaceReferences(value interface{}, replace func(ref string) (interface{}, bool)) interface{} {
+	switch v := value.(type) {
+	case string:
+		if _, _, ok := ParseTokenReference(v); ok {
+			if token, ok := replace(v); ok {
+				return token
+			}
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = replaceReferences(item, replace)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = replaceReferences(item, replace)
+		}
+	}
+	return value
+}
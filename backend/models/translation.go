@@ -0,0 +1,146 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// AuditActionTranslationImport records translations imported into localized page names and
+// widget configs.
+const AuditActionTranslationImport = "translations.import"
+
+// Translation file formats accepted by the export and import endpoints.
+const (
+	TranslationFormatXLIFF = "xliff"
+	TranslationFormatPO    = "po"
+)
+
+// TranslatableConfigFields lists the widget config keys whose string values are exported for
+// translation, at the top level of a config or inside nested objects, such as "button.label".
+// They are the fields indexed for search: titles, text content, alt text and button labels.
+var TranslatableConfigFields = SearchableConfigFields
+
+// translationPathSegment matches the object keys that may lead to a translatable field, so that
+// field paths stay valid as the dot-separated IDs of XLIFF units.
+var translationPathSegment = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// PageNameKey returns the translation key of a page's name.
+func PageNameKey(pageID uuid.UUID) string {
+	return "page." + pageID.String() + ".name"
+}
+
+// WidgetFieldKey returns the translation key of a field of a widget config, at a dot-separated path.
+func WidgetFieldKey(widgetID uuid.UUID, path string) string {
+	return "widget." + widgetID.String() + "." + path
+}
+
+// TranslatableFields returns the translatable string values of the widget's base config, keyed
+// by their dot-separated paths. Arrays are not descended into, since a locale override, being a
+// merge patch, can only replace an array as a whole.
+func (w *Widget) TranslatableFields() map[string]string {
+	var config map[string]interface{}
+	if err := json.Unmarshal(w.Config, &config); err != nil {
+		return nil
+	}
+	fields := make(map[string]string)
+	collectTranslatable(config, "", fields)
+	return fields
+}
+
+// collectTranslatable adds the translatable string values of object to fields, prefixing their
+// paths with prefix.
+func collectTranslatable(object map[string]interface{}, prefix string, fields map[string]string) {
+	for key, value := range object {
+		if !translationPathSegment.MatchString(key) {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if v != "" && isTranslatableField(key) {
+				fields[prefix+key] = v
+			}
+		case map[string]interface{}:
+			collectTranslatable(v, prefix+key+".", fields)
+		}
+	}
+}
+
+// isTranslatableField reports whether values under key are exported for translation.
+func isTranslatableField(key string) bool {
+	for _, field := range TranslatableConfigFields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Translation returns the widget's translation of the field at path in the given locale, when
+// its override for that locale sets the field to a string.
+func (w *Widget) Translation(locale, path string) (string, bool) {
+	var value interface{}
+	if err := json.Unmarshal(w.Locales[locale], &value); err != nil {
+		return "", false
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		value = object[key]
+	}
+	text, ok := value.(string)
+	return text, ok
+}
+
+// Translate sets the translation of the field at path in the given locale, merging it into the
+// widget's override for that locale. The widget's Locales map is replaced, not modified.
+func (w *Widget) Translate(locale, path, text string) error {
+	var patch interface{} = text
+	keys := strings.Split(path, ".")
+	for i := len(keys) - 1; i >= 0; i-- {
+		patch = map[string]interface{}{keys[i]: patch}
+	}
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	override, err := MergeConfig(w.Locales[locale], raw)
+	if err != nil {
+		return err
+	}
+
+	locales := make(WidgetLocales, len(w.Locales)+1)
+	for l, config := range w.Locales {
+		locales[l] = config
+	}
+	locales[locale] = override
+	w.Locales = locales
+	return nil
+}
+
+// TranslationImportResponse reports the outcome of a translation import. Imported counts the
+// translations written, or that would be with dry_run=true. Missing lists the keys of current
+// strings that the file leaves untranslated, Stale the translations of sources that changed since
+// the export, which are not written, and Unknown the keys that match no current string.
+type TranslationImportResponse struct {
+	Locale   string             `json:"locale"`
+	Format   string             `json:"format"`
+	Applied  bool               `json:"applied"`
+	Imported int                `json:"imported"`
+	Missing  []string           `json:"missing"`
+	Stale    []StaleTranslation `json:"stale"`
+	Unknown  []string           `json:"unknown"`
+	AuditID  *uuid.UUID         `json:"audit_id,omitempty"`
+}
+
+// StaleTranslation is a translation whose source text in the imported file differs from the
+// current source text.
+type StaleTranslation struct {
+	Key           string `json:"key"`
+	Source        string `json:"source"`
+	CurrentSource string `json:"current_source"`
+}
@@ -49,22 +49,55 @@ type Widget struct {
 	Type      string          `json:"type"`
 	Position  int             `json:"position"`
 	Config    json.RawMessage `json:"config"`
+	Schedule  *Schedule       `json:"schedule,omitempty"`
+	Rule      string          `json:"rule,omitempty"`
+	SegmentID *uuid.UUID      `json:"segment_id,omitempty"`
+	Locales   WidgetLocales   `json:"locales,omitempty"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
 }
 
+// WidgetWithPage is a Widget listed across pages, together with the name, route, schedule,
+// audience rule and segment of its page.
+type WidgetWithPage struct {
+	Widget
+	PageName      string     `json:"page_name"`
+	PageRoute     string     `json:"page_route"`
+	PageSchedule  *Schedule  `json:"page_schedule,omitempty"`
+	PageRule      string     `json:"page_rule,omitempty"`
+	PageSegmentID *uuid.UUID `json:"page_segment_id,omitempty"`
+}
+
+// WidgetCursorResponse provides a structured wrapper for one keyset-paginated slice of widgets
+// listed across pages. NextCursor is omitted on the last slice.
+type WidgetCursorResponse struct {
+	Widgets    []WidgetWithPage `json:"widgets"`
+	PerPage    int              `json:"per_page"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
 // CreateWidgetRequest defines the data required to instantiate and persist a new Widget.
 type CreateWidgetRequest struct {
-	Type     string          `json:"type" binding:"required"`
-	Position int             `json:"position"`
-	Config   json.RawMessage `json:"config,omitempty"`
+	Type      string          `json:"type" binding:"required"`
+	Position  int             `json:"position"`
+	Config    json.RawMessage `json:"config,omitempty"`
+	Schedule  *Schedule       `json:"schedule,omitempty"`
+	Rule      string          `json:"rule,omitempty"`
+	SegmentID string          `json:"segment_id,omitempty"`
+	Locales   WidgetLocales   `json:"locales,omitempty"`
 }
 
 // UpdateWidgetRequest defines the structure for partially updating an existing Widget's configuration.
+// An empty schedule object, rule or segment ID removes the widget's schedule, audience rule or segment,
+// and an empty locales object removes its localized configs.
 type UpdateWidgetRequest struct {
-	Type     *string          `json:"type,omitempty"`
-	Position *int             `json:"position,omitempty"`
-	Config   *json.RawMessage `json:"config,omitempty"`
+	Type      *string          `json:"type,omitempty"`
+	Position  *int             `json:"position,omitempty"`
+	Config    *json.RawMessage `json:"config,omitempty"`
+	Schedule  *Schedule        `json:"schedule,omitempty"`
+	Rule      *string          `json:"rule,omitempty"`
+	SegmentID *string          `json:"segment_id,omitempty"`
+	Locales   WidgetLocales    `json:"locales,omitempty"`
 }
 
 // ReorderWidgetsRequest defines the payload for updating the sequential ordering of widgets on a page.
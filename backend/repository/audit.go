@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"appdrop/models"
+)
+
+// AuditStore records audit entries and lists the most recent ones.
+// Record assigns the entry's ID and creation time. List returns entries newest first.
+type AuditStore interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	List(ctx context.Context, limit int) ([]models.AuditEntry, error)
+}
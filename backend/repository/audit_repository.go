@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"appdrop/models"
+)
+
+// AuditRepository manages database operations for audit log entries.
+type AuditRepository struct {
+	db           DBTX
+	queryTimeout time.Duration
+}
+
+// NewAuditRepository initializes and returns a new instance of AuditRepository.
+func NewAuditRepository(db DBTX, queryTimeout time.Duration) *AuditRepository {
+	return &AuditRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Record persists a new audit entry.
+func (r *AuditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO audit_log (action, actor, request_id, details)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	return r.db.QueryRowContext(ctx, query, entry.Action, entry.Actor, entry.RequestID, string(auditDetails(entry.Details))).
+		Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// List retrieves the most recent audit entries, newest first.
+func (r *AuditRepository) List(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, action, actor, request_id, details, created_at
+		FROM audit_log
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.Action, &e.Actor, &e.RequestID, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Details = json.RawMessage(details)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// auditDetails defaults missing entry details to an empty object.
+func auditDetails(details json.RawMessage) json.RawMessage {
+	if len(details) == 0 {
+		return json.RawMessage("{}")
+	}
+	return details
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WithQueryTimeout bounds ctx by the per-query timeout applied to every repository call.
+// A zero timeout leaves the caller's deadline, if any, as the only limit.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// IsTimeout reports whether err means a repository call ran out of time. Besides an expired
+// context deadline this covers PostgreSQL's query_canceled error, which lib/pq returns when it
+// cancels a running statement because its context ended, and which statement_timeout also raises.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrExperimentRunning is returned when an experiment is started on a page that already runs one.
+var ErrExperimentRunning = errors.New("page already has a running experiment")
+
+// ExperimentStore defines the persistence operations available for page experiments.
+// Every operation honors the cancellation and deadline of its context.
+// Lookups return a nil experiment and a nil error when nothing matches, and Delete returns
+// sql.ErrNoRows when the experiment does not exist. Deleting a page deletes its experiments.
+// ListByPage returns experiments newest first. Running returns the page's running experiment, of
+// which there is at most one: Update returns ErrExperimentRunning when setting the status of a
+// second one to running.
+type ExperimentStore interface {
+	Create(ctx context.Context, experiment *models.Experiment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Experiment, error)
+	ListByPage(ctx context.Context, pageID uuid.UUID) ([]models.Experiment, error)
+	Running(ctx context.Context, pageID uuid.UUID) (*models.Experiment, error)
+	Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Experiment, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
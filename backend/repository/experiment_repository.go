@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// experimentColumns lists the columns scanned by scanExperiment, in order.
+const experimentColumns = `id, page_id, name, status, variants, winner, started_at, stopped_at, created_at, updated_at`
+
+// ExperimentRepository manages database operations for page experiments.
+type ExperimentRepository struct {
+	db           DBTX
+	queryTimeout time.Duration
+}
+
+// NewExperimentRepository initializes and returns a new instance of ExperimentRepository.
+func NewExperimentRepository(db DBTX, queryTimeout time.Duration) *ExperimentRepository {
+	return &ExperimentRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// scanExperiment reads one experiment row selected with experimentColumns.
+func scanExperiment(row interface{ Scan(...interface{}) error }) (*models.Experiment, error) {
+	e := &models.Experiment{}
+	err := row.Scan(
+		&e.ID, &e.PageID, &e.Name, &e.Status, &e.Variants, &e.Winner,
+		&e.StartedAt, &e.StoppedAt, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Create persists a new experiment, assigning its ID and timestamps.
+func (r *ExperimentRepository) Create(ctx context.Context, experiment *models.Experiment) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO experiments (page_id, name, status, variants)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, experiment.PageID, experiment.Name, experiment.Status, experiment.Variants).
+		Scan(&experiment.ID, &experiment.CreatedAt, &experiment.UpdatedAt)
+}
+
+// GetByID retrieves a single experiment by its unique identifier.
+func (r *ExperimentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Experiment, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+experimentColumns+` FROM experiments WHERE id = $1`, id)
+}
+
+// Running retrieves the running experiment of a page.
+func (r *ExperimentRepository) Running(ctx context.Context, pageID uuid.UUID) (*models.Experiment, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+experimentColumns+` FROM experiments WHERE page_id = $1 AND status = 'running'`, pageID)
+}
+
+// getOne runs a query returning at most one experiment, mapping no rows to a nil experiment.
+func (r *ExperimentRepository) getOne(ctx context.Context, query string, args ...interface{}) (*models.Experiment, error) {
+	experiment, err := scanExperiment(r.db.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return experiment, err
+}
+
+// ListByPage retrieves the experiments of a page, newest first.
+func (r *ExperimentRepository) ListByPage(ctx context.Context, pageID uuid.UUID) ([]models.Experiment, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE page_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []models.Experiment
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, *e)
+	}
+	return experiments, rows.Err()
+}
+
+// Update modifies an existing experiment with the provided column updates.
+func (r *ExperimentRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Experiment, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	setClauses := ""
+	args := []interface{}{}
+	for key, value := range updates {
+		if setClauses != "" {
+			setClauses += ", "
+		}
+		args = append(args, value)
+		setClauses += fmt.Sprintf("%s = $%d", key, len(args))
+	}
+	if setClauses == "" {
+		return r.GetByID(ctx, id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf(`
+		UPDATE experiments
+		SET %s
+		WHERE id = $%d
+		RETURNING %s
+	`, setClauses, len(args), experimentColumns)
+
+	experiment, err := r.getOne(ctx, query, args...)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrExperimentRunning
+	}
+	return experiment, err
+}
+
+// Delete removes an experiment.
+func (r *ExperimentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM experiments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
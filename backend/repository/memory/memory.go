@@ -0,0 +1,1519 @@
+// Package memory provides a thread-safe, in-memory implementation of the repository stores.
+// It mirrors the behavior of the PostgreSQL repositories, including route uniqueness, ordering
+// and cascading page deletes, and is intended for tests and database-free local runs.
+// Operations never block on I/O, so they only check that their context is still live before running.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"appdrop/models"
+	"appdrop/repository"
+
+	"github.com/google/uuid"
+)
+
+// DB holds the pages, widgets, segments, experiments, theme and audit entries shared by the
+// in-memory repositories. txMu serializes units of work; mu guards the data for each individual
+// operation. The theme is replaced as a whole and never modified in place.
+type DB struct {
+	txMu        sync.Mutex
+	mu          sync.RWMutex
+	seq         int64
+	pages       map[uuid.UUID]*pageRecord
+	widgets     map[uuid.UUID]*widgetRecord
+	segments    map[uuid.UUID]*segmentRecord
+	experiments map[uuid.UUID]*experimentRecord
+	theme       *models.Theme
+	audit       []models.AuditEntry
+}
+
+// pageRecord stores a page together with its insertion sequence, used to break timestamp ties.
+type pageRecord struct {
+	page models.Page
+	seq  int64
+}
+
+// widgetRecord stores a widget together with its insertion sequence, used to break position ties.
+type widgetRecord struct {
+	widget models.Widget
+	seq    int64
+}
+
+// segmentRecord stores a segment together with its user list. The list is replaced as a whole and
+// never modified in place, so snapshots can share it.
+type segmentRecord struct {
+	segment models.Segment
+	users   map[string]struct{}
+}
+
+// experimentRecord stores an experiment together with its insertion sequence, used to break
+// timestamp ties. Its variants are replaced as a whole and never modified in place.
+type experimentRecord struct {
+	experiment models.Experiment
+	seq        int64
+}
+
+// NewDB initializes and returns a new, empty in-memory data store.
+func NewDB() *DB {
+	return &DB{
+		pages:       make(map[uuid.UUID]*pageRecord),
+		widgets:     make(map[uuid.UUID]*widgetRecord),
+		segments:    make(map[uuid.UUID]*segmentRecord),
+		experiments: make(map[uuid.UUID]*experimentRecord),
+	}
+}
+
+// next returns the next insertion sequence number. The caller must hold the write lock.
+func (db *DB) next() int64 {
+	db.seq++
+	return db.seq
+}
+
+// routeTaken reports whether route belongs to a page other than excludeID. The caller must hold a lock.
+func (db *DB) routeTaken(route string, excludeID uuid.UUID) bool {
+	for id, record := range db.pages {
+		if record.page.Route == route && id != excludeID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSegment fails, like the foreign keys on segment_id, when id names no segment. The caller must hold a lock.
+func (db *DB) checkSegment(id *uuid.UUID) error {
+	if id == nil {
+		return nil
+	}
+	if _, ok := db.segments[*id]; !ok {
+		return fmt.Errorf("segment %s does not exist", *id)
+	}
+	return nil
+}
+
+// widgetsOf returns copies of the widgets on a page in ascending position order. The caller must hold a lock.
+func (db *DB) widgetsOf(pageID uuid.UUID, widgetType *string) []models.Widget {
+	var records []*widgetRecord
+	for _, record := range db.widgets {
+		if record.widget.PageID != pageID {
+			continue
+		}
+		if widgetType != nil && *widgetType != "" && record.widget.Type != *widgetType {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].widget.Position != records[j].widget.Position {
+			return records[i].widget.Position < records[j].widget.Position
+		}
+		return records[i].seq < records[j].seq
+	})
+
+	var widgets []models.Widget
+	for _, record := range records {
+		widgets = append(widgets, copyWidget(record.widget))
+	}
+	return widgets
+}
+
+// containsString reports whether list holds s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsID reports whether list holds id.
+func containsID(list []uuid.UUID, id uuid.UUID) bool {
+	for _, item := range list {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
+
+// copyWidget returns a widget whose config and localized configs do not share memory with the stored one.
+func copyWidget(w models.Widget) models.Widget {
+	w.Config = append(json.RawMessage(nil), w.Config...)
+	if w.Locales != nil {
+		locales := make(models.WidgetLocales, len(w.Locales))
+		for locale, config := range w.Locales {
+			locales[locale] = append(json.RawMessage(nil), config...)
+		}
+		w.Locales = locales
+	}
+	return w
+}
+
+// normalizeLocales validates and compacts the localized configs of a widget, storing none as nil
+// like the SQL stores read back an empty object.
+func normalizeLocales(locales models.WidgetLocales) (models.WidgetLocales, error) {
+	if len(locales) == 0 {
+		return nil, nil
+	}
+	normalized := make(models.WidgetLocales, len(locales))
+	for locale, config := range locales {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, config); err != nil {
+			return nil, fmt.Errorf("invalid %s widget config: %w", locale, err)
+		}
+		normalized[locale] = json.RawMessage(buf.Bytes())
+	}
+	return normalized, nil
+}
+
+// copyNames returns a copy of the localized names of a page, storing none as nil like the SQL
+// stores read back an empty object.
+func copyNames(names models.PageNames) models.PageNames {
+	if len(names) == 0 {
+		return nil
+	}
+	copied := make(models.PageNames, len(names))
+	for locale, name := range names {
+		copied[locale] = name
+	}
+	return copied
+}
+
+// normalizeConfig validates and compacts a widget config, defaulting to an empty object like the JSONB column.
+func normalizeConfig(config json.RawMessage) (json.RawMessage, error) {
+	if len(config) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, config); err != nil {
+		return nil, fmt.Errorf("invalid widget config: %w", err)
+	}
+	return json.RawMessage(buf.Bytes()), nil
+}
+
+// PageRepository is the in-memory implementation of repository.PageStore.
+type PageRepository struct {
+	db *DB
+}
+
+// NewPageRepository initializes and returns a new instance of PageRepository backed by db.
+func NewPageRepository(db *DB) *PageRepository {
+	return &PageRepository{db: db}
+}
+
+// Create persists a new Page entity, assigning its ID and timestamps.
+func (r *PageRepository) Create(ctx context.Context, page *models.Page) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.routeTaken(page.Route, uuid.Nil) {
+		return repository.ErrRouteConflict
+	}
+	if err := r.db.checkSegment(page.SegmentID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	page.ID = uuid.New()
+	page.CreatedAt = now
+	page.UpdatedAt = now
+
+	stored := *page
+	stored.Widgets = nil
+	stored.Names = copyNames(page.Names)
+	r.db.pages[page.ID] = &pageRecord{page: stored, seq: r.db.next()}
+	return nil
+}
+
+// GetByID retrieves a single Page entity by its unique identifier.
+func (r *PageRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	record, ok := r.db.pages[id]
+	if !ok {
+		return nil, nil
+	}
+	page := record.page
+	return &page, nil
+}
+
+// GetByRoute retrieves a Page entity by its uniquely associated route.
+func (r *PageRepository) GetByRoute(ctx context.Context, route string) (*models.Page, error) {
+	return r.find(ctx, func(p *models.Page) bool { return p.Route == route })
+}
+
+// GetHomePage retrieves the Page designated as the application's home page.
+func (r *PageRepository) GetHomePage(ctx context.Context) (*models.Page, error) {
+	return r.find(ctx, func(p *models.Page) bool { return p.IsHome })
+}
+
+// find returns a copy of the first page, in insertion order, that satisfies match.
+func (r *PageRepository) find(ctx context.Context, match func(p *models.Page) bool) (*models.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var found *pageRecord
+	for _, record := range r.db.pages {
+		if match(&record.page) && (found == nil || record.seq < found.seq) {
+			found = record
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	page := found.page
+	return &page, nil
+}
+
+// GetAll retrieves a paginated collection of Page entities, newest first.
+func (r *PageRepository) GetAll(ctx context.Context, page, perPage int) ([]models.Page, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	records := make([]*pageRecord, 0, len(r.db.pages))
+	for _, record := range r.db.pages {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].page.CreatedAt.Equal(records[j].page.CreatedAt) {
+			return records[i].page.CreatedAt.After(records[j].page.CreatedAt)
+		}
+		return records[i].seq > records[j].seq
+	})
+
+	offset := (page - 1) * perPage
+	if offset < 0 {
+		offset = 0
+	}
+	var pages []models.Page
+	for i := offset; i < len(records) && i < offset+perPage; i++ {
+		pages = append(pages, records[i].page)
+	}
+	return pages, len(records), nil
+}
+
+// List retrieves the pages matching q in the requested order, either from an offset or after a
+// keyset cursor. Name prefixes match regardless of case, route prefixes match exactly.
+func (r *PageRepository) List(ctx context.Context, q repository.PageQuery) (repository.PageList, error) {
+	if err := ctx.Err(); err != nil {
+		return repository.PageList{}, err
+	}
+	if err := repository.CheckPageQuery(&q); err != nil {
+		return repository.PageList{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]int)
+	types := make(map[uuid.UUID]map[string]bool)
+	for _, record := range r.db.widgets {
+		pageID := record.widget.PageID
+		counts[pageID]++
+		if types[pageID] == nil {
+			types[pageID] = make(map[string]bool)
+		}
+		types[pageID][record.widget.Type] = true
+	}
+
+	type entry struct {
+		page models.Page
+		key  *repository.PageCursor
+	}
+	var entries []entry
+	for id, record := range r.db.pages {
+		p := record.page
+		switch {
+		case q.IsHome != nil && p.IsHome != *q.IsHome,
+			q.NamePrefix != "" && !strings.HasPrefix(strings.ToLower(p.Name), strings.ToLower(q.NamePrefix)),
+			q.RoutePrefix != "" && !strings.HasPrefix(p.Route, q.RoutePrefix),
+			!q.UpdatedSince.IsZero() && p.UpdatedAt.Before(q.UpdatedSince),
+			q.WidgetType != "" && !types[id][q.WidgetType]:
+			continue
+		}
+		entries = append(entries, entry{page: p, key: repository.NewPageCursor(q, p, counts[id])})
+	}
+
+	var list repository.PageList
+	list.Total = len(entries)
+
+	order := func(a, b *repository.PageCursor) int {
+		n := compareKeys(a, b)
+		if q.Descending {
+			return -n
+		}
+		return n
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return order(entries[i].key, entries[j].key) < 0
+	})
+
+	start := q.Offset
+	if q.After != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			return order(entries[i].key, q.After) > 0
+		})
+		start += q.Offset
+	}
+	if start > len(entries) {
+		start = len(entries)
+	}
+	entries = entries[start:]
+
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		list.Next = entries[len(entries)-1].key
+	}
+	for _, e := range entries {
+		list.Pages = append(list.Pages, e.page)
+	}
+	if !q.CountTotal {
+		list.Total = 0
+	}
+	return list, nil
+}
+
+// compareKeys orders two page cursors of the same sort by their sort key, then by page ID.
+func compareKeys(a, b *repository.PageCursor) int {
+	n := 0
+	switch a.Sort {
+	case repository.PageSortName, repository.PageSortRoute:
+		n = strings.Compare(a.Text, b.Text)
+	case repository.PageSortWidgetCount:
+		n = a.Count - b.Count
+	default:
+		n = a.Time.Compare(b.Time)
+	}
+	if n != 0 {
+		return n
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// Update modifies an existing Page entity with the provided column updates.
+// Supported keys are the PostgreSQL column names, such as "name", "route" and "names".
+func (r *PageRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	record, ok := r.db.pages[id]
+	if !ok {
+		return nil, nil
+	}
+	if len(updates) == 0 {
+		page := record.page
+		return &page, nil
+	}
+
+	page := record.page
+	for key, value := range updates {
+		var ok bool
+		switch key {
+		case "name":
+			page.Name, ok = value.(string)
+		case "route":
+			page.Route, ok = value.(string)
+		case "is_home":
+			page.IsHome, ok = value.(bool)
+		case "schedule":
+			page.Schedule, ok = value.(*models.Schedule)
+		case "rule":
+			page.Rule, ok = value.(string)
+		case "segment_id":
+			page.SegmentID, ok = value.(*uuid.UUID)
+		case "default_locale":
+			page.DefaultLocale, ok = value.(string)
+		case "names":
+			var names models.PageNames
+			if names, ok = value.(models.PageNames); ok {
+				page.Names = copyNames(names)
+			}
+		default:
+			return nil, fmt.Errorf("unknown page column %q", key)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for page column %q", value, key)
+		}
+	}
+	if r.db.routeTaken(page.Route, id) {
+		return nil, repository.ErrRouteConflict
+	}
+	if err := r.db.checkSegment(page.SegmentID); err != nil {
+		return nil, err
+	}
+
+	page.UpdatedAt = time.Now().UTC()
+	record.page = page
+	return &page, nil
+}
+
+// Delete removes a Page entity and, like the ON DELETE CASCADE constraints, all of its widgets and
+// experiments.
+func (r *PageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.db.pages, id)
+	for widgetID, record := range r.db.widgets {
+		if record.widget.PageID == id {
+			delete(r.db.widgets, widgetID)
+		}
+	}
+	for experimentID, record := range r.db.experiments {
+		if record.experiment.PageID == id {
+			delete(r.db.experiments, experimentID)
+		}
+	}
+	return nil
+}
+
+// UnsetHomePage clears the home page flag from any Page entity that currently has it set.
+func (r *PageRepository) UnsetHomePage(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, record := range r.db.pages {
+		if record.page.IsHome {
+			record.page.IsHome = false
+			record.page.UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+// GetByIDWithWidgets retrieves a Page entity along with all its associated Widget entities.
+func (r *PageRepository) GetByIDWithWidgets(ctx context.Context, id uuid.UUID) (*models.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	record, ok := r.db.pages[id]
+	if !ok {
+		return nil, nil
+	}
+	page := record.page
+	page.Widgets = r.db.widgetsOf(id, nil)
+	return &page, nil
+}
+
+// CheckRouteExists determines if a given route is already assigned to a Page, optionally excluding a specific ID.
+func (r *PageRepository) CheckRouteExists(ctx context.Context, route string, excludeID *uuid.UUID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	exclude := uuid.Nil
+	if excludeID != nil {
+		exclude = *excludeID
+	}
+	return r.db.routeTaken(route, exclude), nil
+}
+
+// Count returns the total number of Page entities in the data store.
+func (r *PageRepository) Count(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	return len(r.db.pages), nil
+}
+
+// WidgetRepository is the in-memory implementation of repository.WidgetStore.
+type WidgetRepository struct {
+	db *DB
+}
+
+// NewWidgetRepository initializes and returns a new instance of WidgetRepository backed by db.
+func NewWidgetRepository(db *DB) *WidgetRepository {
+	return &WidgetRepository{db: db}
+}
+
+// Create persists a new Widget entity, assigning its timestamps and, unless it already has one, its ID.
+// Like the type check and foreign key constraints, it fails for unknown types or missing pages.
+func (r *WidgetRepository) Create(ctx context.Context, widget *models.Widget) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !models.IsValidWidgetType(widget.Type) {
+		return fmt.Errorf("invalid widget type %q", widget.Type)
+	}
+	config, err := normalizeConfig(widget.Config)
+	if err != nil {
+		return err
+	}
+	locales, err := normalizeLocales(widget.Locales)
+	if err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[widget.PageID]; !ok {
+		return fmt.Errorf("page %s does not exist", widget.PageID)
+	}
+	if err := r.db.checkSegment(widget.SegmentID); err != nil {
+		return err
+	}
+
+	if widget.ID == uuid.Nil {
+		widget.ID = uuid.New()
+	} else if _, ok := r.db.widgets[widget.ID]; ok {
+		return fmt.Errorf("widget %s already exists", widget.ID)
+	}
+	now := time.Now().UTC()
+	widget.CreatedAt = now
+	widget.UpdatedAt = now
+
+	stored := *widget
+	stored.Config = config
+	stored.Locales = locales
+	r.db.widgets[widget.ID] = &widgetRecord{widget: stored, seq: r.db.next()}
+	return nil
+}
+
+// GetByID retrieves a single Widget entity by its unique identifier.
+func (r *WidgetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Widget, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	record, ok := r.db.widgets[id]
+	if !ok {
+		return nil, nil
+	}
+	widget := copyWidget(record.widget)
+	return &widget, nil
+}
+
+// GetByPageID retrieves the widgets of a page in position order, optionally filtered by type.
+func (r *WidgetRepository) GetByPageID(ctx context.Context, pageID uuid.UUID, widgetType *string) ([]models.Widget, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	return r.db.widgetsOf(pageID, widgetType), nil
+}
+
+// List retrieves widgets across pages, newest first, together with the name and route of their page.
+func (r *WidgetRepository) List(ctx context.Context, q repository.WidgetQuery) (repository.WidgetList, error) {
+	if err := ctx.Err(); err != nil {
+		return repository.WidgetList{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var widgets []models.WidgetWithPage
+	for _, record := range r.db.widgets {
+		w := record.widget
+		if len(q.Types) > 0 && !containsString(q.Types, w.Type) {
+			continue
+		}
+		if len(q.PageIDs) > 0 && !containsID(q.PageIDs, w.PageID) {
+			continue
+		}
+		if len(q.SegmentIDs) > 0 && (w.SegmentID == nil || !containsID(q.SegmentIDs, *w.SegmentID)) {
+			continue
+		}
+		if q.After != nil && !q.After.Before(w.CreatedAt, w.ID) {
+			continue
+		}
+		if len(q.Config) > 0 {
+			var config map[string]interface{}
+			if err := json.Unmarshal(w.Config, &config); err != nil {
+				continue
+			}
+			matched := true
+			for _, p := range q.Config {
+				if !p.Match(config) {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		page := r.db.pages[w.PageID].page
+		widgets = append(widgets, models.WidgetWithPage{Widget: copyWidget(w), PageName: page.Name, PageRoute: page.Route, PageSchedule: page.Schedule, PageRule: page.Rule, PageSegmentID: page.SegmentID})
+	}
+
+	sort.Slice(widgets, func(i, j int) bool {
+		cursor := repository.WidgetCursor{CreatedAt: widgets[i].CreatedAt, ID: widgets[i].ID}
+		return cursor.Before(widgets[j].CreatedAt, widgets[j].ID)
+	})
+
+	list := repository.WidgetList{Widgets: widgets}
+	if q.Limit > 0 && len(widgets) > q.Limit {
+		list.Widgets = widgets[:q.Limit]
+		last := list.Widgets[q.Limit-1]
+		list.Next = &repository.WidgetCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return list, nil
+}
+
+// GetMaxPosition determines the highest position index currently assigned to widgets on a page.
+func (r *WidgetRepository) GetMaxPosition(ctx context.Context, pageID uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	maxPosition := 0
+	for _, record := range r.db.widgets {
+		if record.widget.PageID == pageID && record.widget.Position > maxPosition {
+			maxPosition = record.widget.Position
+		}
+	}
+	return maxPosition, nil
+}
+
+// Update modifies an existing Widget entity with the provided column updates.
+// Supported keys are the PostgreSQL column names, such as "type", "config" and "locales".
+func (r *WidgetRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Widget, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	record, ok := r.db.widgets[id]
+	if !ok {
+		return nil, nil
+	}
+
+	widget := record.widget
+	for key, value := range updates {
+		var ok bool
+		switch key {
+		case "type":
+			widget.Type, ok = value.(string)
+		case "position":
+			widget.Position, ok = value.(int)
+		case "schedule":
+			widget.Schedule, ok = value.(*models.Schedule)
+		case "rule":
+			widget.Rule, ok = value.(string)
+		case "segment_id":
+			widget.SegmentID, ok = value.(*uuid.UUID)
+		case "config":
+			var raw json.RawMessage
+			if raw, ok = value.(json.RawMessage); ok {
+				config, err := normalizeConfig(raw)
+				if err != nil {
+					return nil, err
+				}
+				widget.Config = config
+			}
+		case "locales":
+			var raw models.WidgetLocales
+			if raw, ok = value.(models.WidgetLocales); ok {
+				locales, err := normalizeLocales(raw)
+				if err != nil {
+					return nil, err
+				}
+				widget.Locales = locales
+			}
+		default:
+			return nil, fmt.Errorf("unknown widget column %q", key)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for widget column %q", value, key)
+		}
+	}
+	if !models.IsValidWidgetType(widget.Type) {
+		return nil, fmt.Errorf("invalid widget type %q", widget.Type)
+	}
+	if err := r.db.checkSegment(widget.SegmentID); err != nil {
+		return nil, err
+	}
+
+	if len(updates) > 0 {
+		widget.UpdatedAt = time.Now().UTC()
+	}
+	record.widget = widget
+	result := copyWidget(widget)
+	return &result, nil
+}
+
+// Delete removes a Widget entity from the data store by its ID.
+func (r *WidgetRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.widgets[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.db.widgets, id)
+	return nil
+}
+
+// Reorder assigns positions 1..n to the given widgets of a page. Like the transactional PostgreSQL
+// implementation, nothing changes when any of the widgets is not on the page.
+func (r *WidgetRepository) Reorder(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, widgetID := range widgetIDs {
+		record, ok := r.db.widgets[widgetID]
+		if !ok || record.widget.PageID != pageID {
+			return fmt.Errorf("widget %s not found on page %s", widgetID, pageID)
+		}
+	}
+
+	now := time.Now().UTC()
+	for i, widgetID := range widgetIDs {
+		record := r.db.widgets[widgetID]
+		record.widget.Position = i + 1
+		record.widget.UpdatedAt = now
+	}
+	return nil
+}
+
+// GetWidgetCountByPageID returns the total number of widgets associated with a specific page.
+func (r *WidgetRepository) GetWidgetCountByPageID(ctx context.Context, pageID uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	count := 0
+	for _, record := range r.db.widgets {
+		if record.widget.PageID == pageID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CheckWidgetsBelongToPage validates that a set of widget IDs all belong to the specified page identifier.
+func (r *WidgetRepository) CheckWidgetsBelongToPage(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	matched := make(map[uuid.UUID]bool)
+	for _, widgetID := range widgetIDs {
+		if record, ok := r.db.widgets[widgetID]; ok && record.widget.PageID == pageID {
+			matched[widgetID] = true
+		}
+	}
+	return len(matched) == len(widgetIDs), nil
+}
+
+// CountByType returns the number of widgets stored for each widget type.
+func (r *WidgetRepository) CountByType(ctx context.Context) (map[string]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, record := range r.db.widgets {
+		counts[record.widget.Type]++
+	}
+	return counts, nil
+}
+
+// SearchRepository implements repository.SearchStore over the in-memory data by term matching.
+type SearchRepository struct {
+	db *DB
+}
+
+// NewSearchRepository initializes and returns a new instance of SearchRepository backed by db.
+func NewSearchRepository(db *DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// Search returns the pages whose name and route, and the widgets whose searchable config text,
+// match the query, which is read with the same web search syntax as on PostgreSQL.
+func (r *SearchRepository) Search(ctx context.Context, q repository.SearchQuery) ([]models.SearchHit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	matcher := repository.NewTermMatcher(q.Text)
+	if matcher == nil {
+		return nil, nil
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var hits []models.SearchHit
+	for _, record := range r.db.pages {
+		if matcher.Matches(record.page.Name + " " + record.page.Route) {
+			hits = append(hits, matcher.PageHit(record.page))
+		}
+	}
+	for _, record := range r.db.widgets {
+		text := repository.WidgetSearchText(record.widget.Config)
+		if !matcher.Matches(text) {
+			continue
+		}
+		if page, ok := r.db.pages[record.widget.PageID]; ok {
+			hits = append(hits, matcher.WidgetHit(page.page, record.widget, text))
+		}
+	}
+	return repository.RankHits(hits, q.Limit), nil
+}
+
+// AuditRepository implements repository.AuditStore over the in-memory data.
+type AuditRepository struct {
+	db *DB
+}
+
+// NewAuditRepository initializes and returns a new instance of AuditRepository backed by db.
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record appends an audit entry, assigning its ID and creation time.
+func (r *AuditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now().UTC()
+	if len(entry.Details) == 0 {
+		entry.Details = json.RawMessage("{}")
+	}
+	stored := *entry
+	stored.Details = append(json.RawMessage(nil), entry.Details...)
+	r.db.audit = append(r.db.audit, stored)
+	return nil
+}
+
+// List returns up to limit audit entries, newest first.
+func (r *AuditRepository) List(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var entries []models.AuditEntry
+	for i := len(r.db.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, r.db.audit[i])
+	}
+	return entries, nil
+}
+
+// SegmentRepository implements repository.SegmentStore over the in-memory data.
+type SegmentRepository struct {
+	db *DB
+}
+
+// NewSegmentRepository initializes and returns a new instance of SegmentRepository backed by db.
+func NewSegmentRepository(db *DB) *SegmentRepository {
+	return &SegmentRepository{db: db}
+}
+
+// Create persists a new segment without users, assigning its ID and timestamps.
+func (r *SegmentRepository) Create(ctx context.Context, segment *models.Segment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now().UTC()
+	segment.ID = uuid.New()
+	segment.UserCount = 0
+	segment.CreatedAt = now
+	segment.UpdatedAt = now
+	r.db.segments[segment.ID] = &segmentRecord{segment: *segment}
+	return nil
+}
+
+// GetByID retrieves a single segment by its unique identifier.
+func (r *SegmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Segment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	record, ok := r.db.segments[id]
+	if !ok {
+		return nil, nil
+	}
+	segment := record.segment
+	return &segment, nil
+}
+
+// List returns every segment ordered by name.
+func (r *SegmentRepository) List(ctx context.Context) ([]models.Segment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var segments []models.Segment
+	for _, record := range r.db.segments {
+		segments = append(segments, record.segment)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].Name != segments[j].Name {
+			return segments[i].Name < segments[j].Name
+		}
+		return segments[i].ID.String() < segments[j].ID.String()
+	})
+	return segments, nil
+}
+
+// Update modifies an existing segment with the provided column updates ("name" and "rule").
+func (r *SegmentRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Segment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	record, ok := r.db.segments[id]
+	if !ok {
+		return nil, nil
+	}
+
+	segment := record.segment
+	for key, value := range updates {
+		var ok bool
+		switch key {
+		case "name":
+			segment.Name, ok = value.(string)
+		case "rule":
+			segment.Rule, ok = value.(string)
+		default:
+			return nil, fmt.Errorf("unknown segment column %q", key)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for segment column %q", value, key)
+		}
+	}
+	if len(updates) > 0 {
+		segment.UpdatedAt = time.Now().UTC()
+	}
+	record.segment = segment
+	return &segment, nil
+}
+
+// Delete removes a segment and its user list, refusing like the foreign keys while pages or
+// widgets reference it.
+func (r *SegmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.segments[id]; !ok {
+		return sql.ErrNoRows
+	}
+	if pages, widgets := r.db.segmentReferences(id); pages+widgets > 0 {
+		return repository.ErrSegmentInUse
+	}
+	delete(r.db.segments, id)
+	return nil
+}
+
+// SetUsers replaces the user list of a segment.
+func (r *SegmentRepository) SetUsers(ctx context.Context, id uuid.UUID, userIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	users := make(map[string]struct{}, len(userIDs))
+	for _, userID := range userIDs {
+		users[userID] = struct{}{}
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	record, ok := r.db.segments[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	record.users = users
+	record.segment.UserCount = len(users)
+	record.segment.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// Memberships reports, for each of the given segments that exists, its rule, whether it has a
+// user list and whether userID is on it.
+func (r *SegmentRepository) Memberships(ctx context.Context, ids []uuid.UUID, userID string) ([]models.SegmentMembership, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var memberships []models.SegmentMembership
+	for _, id := range ids {
+		record, ok := r.db.segments[id]
+		if !ok {
+			continue
+		}
+		_, listed := record.users[userID]
+		memberships = append(memberships, models.SegmentMembership{
+			SegmentID: id,
+			Rule:      record.segment.Rule,
+			HasUsers:  len(record.users) > 0,
+			Listed:    listed,
+		})
+	}
+	return memberships, nil
+}
+
+// References counts the pages and widgets that reference a segment.
+func (r *SegmentRepository) References(ctx context.Context, id uuid.UUID) (int, int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	pages, widgets := r.db.segmentReferences(id)
+	return pages, widgets, nil
+}
+
+// segmentReferences counts the pages and widgets referencing a segment. The caller must hold a lock.
+func (db *DB) segmentReferences(id uuid.UUID) (pages, widgets int) {
+	for _, record := range db.pages {
+		if record.page.SegmentID != nil && *record.page.SegmentID == id {
+			pages++
+		}
+	}
+	for _, record := range db.widgets {
+		if record.widget.SegmentID != nil && *record.widget.SegmentID == id {
+			widgets++
+		}
+	}
+	return pages, widgets
+}
+
+// ExperimentRepository implements repository.ExperimentStore over the in-memory data.
+type ExperimentRepository struct {
+	db *DB
+}
+
+// NewExperimentRepository initializes and returns a new instance of ExperimentRepository backed by db.
+func NewExperimentRepository(db *DB) *ExperimentRepository {
+	return &ExperimentRepository{db: db}
+}
+
+// Create persists a new experiment, assigning its ID and timestamps.
+func (r *ExperimentRepository) Create(ctx context.Context, experiment *models.Experiment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[experiment.PageID]; !ok {
+		return fmt.Errorf("page %s does not exist", experiment.PageID)
+	}
+
+	now := time.Now().UTC()
+	experiment.ID = uuid.New()
+	experiment.CreatedAt = now
+	experiment.UpdatedAt = now
+	r.db.experiments[experiment.ID] = &experimentRecord{experiment: *experiment, seq: r.db.next()}
+	return nil
+}
+
+// GetByID retrieves a single experiment by its unique identifier.
+func (r *ExperimentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Experiment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	record, ok := r.db.experiments[id]
+	if !ok {
+		return nil, nil
+	}
+	experiment := record.experiment
+	return &experiment, nil
+}
+
+// Running retrieves the running experiment of a page.
+func (r *ExperimentRepository) Running(ctx context.Context, pageID uuid.UUID) (*models.Experiment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	if record := r.db.runningExperiment(pageID, uuid.Nil); record != nil {
+		experiment := record.experiment
+		return &experiment, nil
+	}
+	return nil, nil
+}
+
+// runningExperiment returns the running experiment of a page other than excludeID, or nil. The
+// caller must hold a lock.
+func (db *DB) runningExperiment(pageID, excludeID uuid.UUID) *experimentRecord {
+	for id, record := range db.experiments {
+		if id != excludeID && record.experiment.PageID == pageID && record.experiment.Status == models.ExperimentRunning {
+			return record
+		}
+	}
+	return nil
+}
+
+// ListByPage returns the experiments of a page, newest first.
+func (r *ExperimentRepository) ListByPage(ctx context.Context, pageID uuid.UUID) ([]models.Experiment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var records []*experimentRecord
+	for _, record := range r.db.experiments {
+		if record.experiment.PageID == pageID {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].experiment.CreatedAt.Equal(records[j].experiment.CreatedAt) {
+			return records[i].experiment.CreatedAt.After(records[j].experiment.CreatedAt)
+		}
+		return records[i].seq > records[j].seq
+	})
+
+	experiments := make([]models.Experiment, len(records))
+	for i, record := range records {
+		experiments[i] = record.experiment
+	}
+	return experiments, nil
+}
+
+// Update modifies an existing experiment with the provided column updates, refusing like the
+// unique index to start a second experiment on a page.
+func (r *ExperimentRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Experiment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	record, ok := r.db.experiments[id]
+	if !ok {
+		return nil, nil
+	}
+
+	experiment := record.experiment
+	for key, value := range updates {
+		var ok bool
+		switch key {
+		case "name":
+			experiment.Name, ok = value.(string)
+		case "status":
+			experiment.Status, ok = value.(string)
+		case "variants":
+			experiment.Variants, ok = value.(models.Variants)
+		case "winner":
+			experiment.Winner, ok = value.(string)
+		case "started_at":
+			experiment.StartedAt, ok = value.(*time.Time)
+		case "stopped_at":
+			experiment.StoppedAt, ok = value.(*time.Time)
+		default:
+			return nil, fmt.Errorf("unknown experiment column %q", key)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for experiment column %q", value, key)
+		}
+	}
+	if experiment.Status == models.ExperimentRunning && r.db.runningExperiment(experiment.PageID, id) != nil {
+		return nil, repository.ErrExperimentRunning
+	}
+	if len(updates) > 0 {
+		experiment.UpdatedAt = time.Now().UTC()
+	}
+	record.experiment = experiment
+	return &experiment, nil
+}
+
+// Delete removes an experiment.
+func (r *ExperimentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.experiments[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.db.experiments, id)
+	return nil
+}
+
+// ThemeRepository implements repository.ThemeStore over the in-memory data.
+type ThemeRepository struct {
+	db *DB
+}
+
+// NewThemeRepository initializes and returns a new instance of ThemeRepository backed by db.
+func NewThemeRepository(db *DB) *ThemeRepository {
+	return &ThemeRepository{db: db}
+}
+
+// Get retrieves a copy of the app theme.
+func (r *ThemeRepository) Get(ctx context.Context) (*models.Theme, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	if r.db.theme == nil {
+		return nil, nil
+	}
+	return copyTheme(r.db.theme)
+}
+
+// Save replaces the app theme with a copy of theme, setting its update time.
+func (r *ThemeRepository) Save(ctx context.Context, theme *models.Theme) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	theme.UpdatedAt = time.Now().UTC()
+	stored, err := copyTheme(theme)
+	if err != nil {
+		return err
+	}
+	r.db.theme = stored
+	return nil
+}
+
+// copyTheme returns a theme that shares no memory with t, round-tripping it through the document
+// the SQL stores keep.
+func copyTheme(t *models.Theme) (*models.Theme, error) {
+	doc, err := repository.EncodeTheme(t)
+	if err != nil {
+		return nil, err
+	}
+	return repository.DecodeTheme([]byte(doc), t.UpdatedAt)
+}
+
+// UnitOfWork implements repository.UnitOfWork for the in-memory stores. Units of work run one
+// at a time, and one that fails restores the data as it was when it started. Store calls made
+// outside a unit of work are not isolated from it.
+type UnitOfWork struct {
+	db *DB
+}
+
+// NewUnitOfWork initializes and returns a new instance of UnitOfWork backed by db.
+func NewUnitOfWork(db *DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// Do calls fn with stores backed by the shared data, rolling its changes back if it fails.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, tx repository.Stores) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	u.db.txMu.Lock()
+	defer u.db.txMu.Unlock()
+
+	saved := u.db.snapshot()
+	err := fn(ctx, repository.Stores{
+		Pages:       NewPageRepository(u.db),
+		Widgets:     NewWidgetRepository(u.db),
+		Segments:    NewSegmentRepository(u.db),
+		Experiments: NewExperimentRepository(u.db),
+		Themes:      NewThemeRepository(u.db),
+		Audit:       NewAuditRepository(u.db),
+	})
+	if err != nil {
+		u.db.restore(saved)
+	}
+	return err
+}
+
+// dbState is a copy of the data held by a DB, used to roll back a failed unit of work.
+type dbState struct {
+	seq         int64
+	pages       map[uuid.UUID]pageRecord
+	widgets     map[uuid.UUID]widgetRecord
+	segments    map[uuid.UUID]segmentRecord
+	experiments map[uuid.UUID]experimentRecord
+	theme       *models.Theme
+	audit       []models.AuditEntry
+}
+
+// snapshot copies the current data.
+func (db *DB) snapshot() dbState {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	state := dbState{
+		seq:         db.seq,
+		pages:       make(map[uuid.UUID]pageRecord, len(db.pages)),
+		widgets:     make(map[uuid.UUID]widgetRecord, len(db.widgets)),
+		segments:    make(map[uuid.UUID]segmentRecord, len(db.segments)),
+		experiments: make(map[uuid.UUID]experimentRecord, len(db.experiments)),
+		theme:       db.theme,
+		audit:       db.audit[:len(db.audit):len(db.audit)],
+	}
+	for id, record := range db.pages {
+		state.pages[id] = *record
+	}
+	for id, record := range db.widgets {
+		state.widgets[id] = *record
+	}
+	for id, record := range db.segments {
+		state.segments[id] = *record
+	}
+	for id, record := range db.experiments {
+		state.experiments[id] = *record
+	}
+	return state
+}
+
+// restore replaces the current data with a snapshot.
+func (db *DB) restore(state dbState) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.seq = state.seq
+	db.pages = make(map[uuid.UUID]*pageRecord, len(state.pages))
+	for id, record := range state.pages {
+		record := record
+		db.pages[id] = &record
+	}
+	db.widgets = make(map[uuid.UUID]*widgetRecord, len(state.widgets))
+	for id, record := range state.widgets {
+		record := record
+		db.widgets[id] = &record
+	}
+	db.segments = make(map[uuid.UUID]*segmentRecord, len(state.segments))
+	for id, record := range state.segments {
+		record := record
+		db.segments[id] = &record
+	}
+	db.experiments = make(map[uuid.UUID]*experimentRecord, len(state.experiments))
+	for id, record := range state.experiments {
+		record := record
+		db.experiments[id] = &record
+	}
+	db.theme = state.theme
+	db.audit = state.audit
+}
+
+var (
+	_ repository.PageStore       = (*PageRepository)(nil)
+	_ repository.WidgetStore     = (*WidgetRepository)(nil)
+	_ repository.UnitOfWork      = (*UnitOfWork)(nil)
+	_ repository.SearchStore     = (*SearchRepository)(nil)
+	_ repository.AuditStore      = (*AuditRepository)(nil)
+	_ repository.SegmentStore    = (*SegmentRepository)(nil)
+	_ repository.ExperimentStore = (*ExperimentRepository)(nil)
+	_ repository.ThemeStore      = (*ThemeRepository)(nil)
+)
@@ -0,0 +1,25 @@
+// Package memory contains tests running the repository conformance suite against the in-memory stores.
+package memory
+
+import (
+	"testing"
+
+	"appdrop/repository/storetest"
+)
+
+// TestConformance verifies that the in-memory stores behave like every other backend.
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		db := NewDB()
+		return storetest.Backend{
+			Pages:       NewPageRepository(db),
+			Widgets:     NewWidgetRepository(db),
+			UnitOfWork:  NewUnitOfWork(db),
+			Search:      NewSearchRepository(db),
+			Audit:       NewAuditRepository(db),
+			Segments:    NewSegmentRepository(db),
+			Experiments: NewExperimentRepository(db),
+			Themes:      NewThemeRepository(db),
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+)
+
+// PageSort names a column that page listings can be ordered by. Ties are always broken by page ID.
+type PageSort string
+
+const (
+	// PageSortCreatedAt orders pages by creation time.
+	PageSortCreatedAt PageSort = "created_at"
+	// PageSortUpdatedAt orders pages by the time of their last change.
+	PageSortUpdatedAt PageSort = "updated_at"
+	// PageSortName orders pages by name.
+	PageSortName PageSort = "name"
+	// PageSortRoute orders pages by route.
+	PageSortRoute PageSort = "route"
+	// PageSortWidgetCount orders pages by the number of widgets they hold.
+	PageSortWidgetCount PageSort = "widget_count"
+)
+
+// IsValid reports whether s is one of the supported sort columns.
+func (s PageSort) IsValid() bool {
+	switch s {
+	case PageSortCreatedAt, PageSortUpdatedAt, PageSortName, PageSortRoute, PageSortWidgetCount:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidCursor is returned when a page cursor is malformed or was issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// PageQuery describes a filtered and sorted page listing. Pages are returned either from Offset,
+// or, in keyset mode, strictly after the position marked by After. Zero-valued filters are ignored.
+type PageQuery struct {
+	Sort       PageSort
+	Descending bool
+
+	IsHome       *bool
+	NamePrefix   string
+	RoutePrefix  string
+	UpdatedSince time.Time
+	WidgetType   string
+
+	Limit      int
+	Offset     int
+	After      *PageCursor
+	CountTotal bool
+}
+
+// PageList is one slice of a page listing. Total is only filled in when the query asked for it,
+// and Next is nil once there are no further pages to list.
+type PageList struct {
+	Pages []models.Page
+	Total int
+	Next  *PageCursor
+}
+
+// PageCursor marks the last page returned by a listing so that the next request can resume right
+// after it, unaffected by pages inserted or deleted in between. Only the key field matching Sort
+// is meaningful: Text for name and route, Time for the timestamps, Count for widget_count.
+type PageCursor struct {
+	Sort       PageSort
+	Descending bool
+	Text       string
+	Time       time.Time
+	Count      int
+	ID         uuid.UUID
+}
+
+// NewPageCursor returns the cursor positioned at page within a listing ordered as q requests.
+func NewPageCursor(q PageQuery, page models.Page, widgetCount int) *PageCursor {
+	cursor := &PageCursor{Sort: q.Sort, Descending: q.Descending, ID: page.ID}
+	switch q.Sort {
+	case PageSortName:
+		cursor.Text = page.Name
+	case PageSortRoute:
+		cursor.Text = page.Route
+	case PageSortUpdatedAt:
+		cursor.Time = page.UpdatedAt
+	case PageSortWidgetCount:
+		cursor.Count = widgetCount
+	default:
+		cursor.Time = page.CreatedAt
+	}
+	return cursor
+}
+
+// cursorPayload is the serialized form of a PageCursor.
+type cursorPayload struct {
+	Sort       PageSort        `json:"s"`
+	Descending bool            `json:"d,omitempty"`
+	Key        json.RawMessage `json:"k"`
+	ID         uuid.UUID       `json:"id"`
+}
+
+// Encode returns the opaque, URL-safe form of the cursor handed to clients.
+func (c PageCursor) Encode() string {
+	var key interface{}
+	switch c.Sort {
+	case PageSortName, PageSortRoute:
+		key = c.Text
+	case PageSortWidgetCount:
+		key = c.Count
+	default:
+		key = c.Time.UTC().Format(time.RFC3339Nano)
+	}
+	rawKey, _ := json.Marshal(key)
+	payload, _ := json.Marshal(cursorPayload{Sort: c.Sort, Descending: c.Descending, Key: rawKey, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodePageCursor parses a cursor produced by Encode.
+func DecodePageCursor(s string) (*PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || !payload.Sort.IsValid() || payload.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &PageCursor{Sort: payload.Sort, Descending: payload.Descending, ID: payload.ID}
+	switch payload.Sort {
+	case PageSortName, PageSortRoute:
+		err = json.Unmarshal(payload.Key, &cursor.Text)
+	case PageSortWidgetCount:
+		err = json.Unmarshal(payload.Key, &cursor.Count)
+	default:
+		var text string
+		if err = json.Unmarshal(payload.Key, &text); err == nil {
+			cursor.Time, err = time.Parse(time.RFC3339Nano, text)
+		}
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// CheckPageQuery validates a query before a store runs it, filling in the default sort order.
+func CheckPageQuery(q *PageQuery) error {
+	if q.Sort == "" {
+		q.Sort = PageSortCreatedAt
+		q.Descending = true
+	}
+	if !q.Sort.IsValid() {
+		return errors.New("unsupported page sort " + string(q.Sort))
+	}
+	if q.After != nil && (q.After.Sort != q.Sort || q.After.Descending != q.Descending) {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// EscapeLike escapes the LIKE wildcards in s, using backslash as the escape character.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
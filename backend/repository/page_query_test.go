@@ -0,0 +1,36 @@
+// Package repository contains tests for the store-independent page listing helpers.
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestPageCursorRoundTrip verifies that encoded cursors decode to the same position and that
+// tampered cursors are rejected.
+func TestPageCursorRoundTrip(t *testing.T) {
+	cursors := []PageCursor{
+		{Sort: PageSortName, Text: "Summer Sale", ID: uuid.New()},
+		{Sort: PageSortWidgetCount, Descending: true, Count: 7, ID: uuid.New()},
+		{Sort: PageSortCreatedAt, Descending: true, Time: time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC), ID: uuid.New()},
+	}
+	for _, want := range cursors {
+		got, err := DecodePageCursor(want.Encode())
+		if err != nil {
+			t.Fatalf("DecodePageCursor(%+v): %v", want, err)
+		}
+		if got.Sort != want.Sort || got.Descending != want.Descending || got.Text != want.Text ||
+			got.Count != want.Count || !got.Time.Equal(want.Time) || got.ID != want.ID {
+			t.Errorf("Expected %+v after round trip, got %+v", want, *got)
+		}
+	}
+
+	for _, bad := range []string{"", "not base64!", "e30", "eyJzIjoiYm9ndXMifQ"} {
+		if _, err := DecodePageCursor(bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", bad, err)
+		}
+	}
+}
@@ -1,48 +1,61 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"appdrop/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // PageRepository manages database operations specifically for Page entities.
 // It provides methods for CRUD operations, route validation, and complex page-widget data retrieval.
 type PageRepository struct {
-	db *sql.DB
+	db           DBTX
+	queryTimeout time.Duration
 }
 
 // NewPageRepository initializes and returns a new instance of PageRepository.
-func NewPageRepository(db *sql.DB) *PageRepository {
-	return &PageRepository{db: db}
+func NewPageRepository(db DBTX, queryTimeout time.Duration) *PageRepository {
+	return &PageRepository{db: db, queryTimeout: queryTimeout}
 }
 
 // Create persists a new Page entity in the data store.
-func (r *PageRepository) Create(page *models.Page) error {
+func (r *PageRepository) Create(ctx context.Context, page *models.Page) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO pages (name, route, is_home)
-		VALUES ($1, $2, $3)
+		INSERT INTO pages (name, route, is_home, schedule, rule, segment_id, default_locale, names)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at, updated_at
 	`
-	return r.db.QueryRow(query, page.Name, page.Route, page.IsHome).
+	err := r.db.QueryRowContext(ctx, query, page.Name, page.Route, page.IsHome, page.Schedule, page.Rule, page.SegmentID, page.DefaultLocale, page.Names).
 		Scan(&page.ID, &page.CreatedAt, &page.UpdatedAt)
+	return translateRouteConflict(err)
 }
 
 // GetByID retrieves a single Page entity by its unique identifier.
-func (r *PageRepository) GetByID(id uuid.UUID) (*models.Page, error) {
+func (r *PageRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Page, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, name, route, is_home, created_at, updated_at
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
 		FROM pages
 		WHERE id = $1
 	`
 	page := &models.Page{}
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&page.ID, &page.Name, &page.Route, &page.IsHome,
-		&page.CreatedAt, &page.UpdatedAt,
+		&page.Schedule, &page.Rule, &page.SegmentID, &page.DefaultLocale, &page.Names, &page.CreatedAt, &page.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -54,16 +67,19 @@ func (r *PageRepository) GetByID(id uuid.UUID) (*models.Page, error) {
 }
 
 // GetByRoute retrieves a Page entity by its uniquely associated route.
-func (r *PageRepository) GetByRoute(route string) (*models.Page, error) {
+func (r *PageRepository) GetByRoute(ctx context.Context, route string) (*models.Page, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, name, route, is_home, created_at, updated_at
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
 		FROM pages
 		WHERE route = $1
 	`
 	page := &models.Page{}
-	err := r.db.QueryRow(query, route).Scan(
+	err := r.db.QueryRowContext(ctx, query, route).Scan(
 		&page.ID, &page.Name, &page.Route, &page.IsHome,
-		&page.CreatedAt, &page.UpdatedAt,
+		&page.Schedule, &page.Rule, &page.SegmentID, &page.DefaultLocale, &page.Names, &page.CreatedAt, &page.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -75,17 +91,20 @@ func (r *PageRepository) GetByRoute(route string) (*models.Page, error) {
 }
 
 // GetHomePage retrieves the Page designated as the application's home page.
-func (r *PageRepository) GetHomePage() (*models.Page, error) {
+func (r *PageRepository) GetHomePage(ctx context.Context) (*models.Page, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, name, route, is_home, created_at, updated_at
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
 		FROM pages
 		WHERE is_home = TRUE
 		LIMIT 1
 	`
 	page := &models.Page{}
-	err := r.db.QueryRow(query).Scan(
+	err := r.db.QueryRowContext(ctx, query).Scan(
 		&page.ID, &page.Name, &page.Route, &page.IsHome,
-		&page.CreatedAt, &page.UpdatedAt,
+		&page.Schedule, &page.Rule, &page.SegmentID, &page.DefaultLocale, &page.Names, &page.CreatedAt, &page.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -97,21 +116,24 @@ func (r *PageRepository) GetHomePage() (*models.Page, error) {
 }
 
 // GetAll retrieves a paginated collection of Page entities.
-func (r *PageRepository) GetAll(page, perPage int) ([]models.Page, int, error) {
+func (r *PageRepository) GetAll(ctx context.Context, page, perPage int) ([]models.Page, int, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var total int
 	countQuery := `SELECT COUNT(*) FROM pages`
-	if err := r.db.QueryRow(countQuery).Scan(&total); err != nil {
+	if err := r.db.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	offset := (page - 1) * perPage
 	query := `
-		SELECT id, name, route, is_home, created_at, updated_at
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
 		FROM pages
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
 	`
-	rows, err := r.db.Query(query, perPage, offset)
+	rows, err := r.db.QueryContext(ctx, query, perPage, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -122,7 +144,7 @@ func (r *PageRepository) GetAll(page, perPage int) ([]models.Page, int, error) {
 		var p models.Page
 		if err := rows.Scan(
 			&p.ID, &p.Name, &p.Route, &p.IsHome,
-			&p.CreatedAt, &p.UpdatedAt,
+			&p.Schedule, &p.Rule, &p.SegmentID, &p.DefaultLocale, &p.Names, &p.CreatedAt, &p.UpdatedAt,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -132,8 +154,129 @@ func (r *PageRepository) GetAll(page, perPage int) ([]models.Page, int, error) {
 	return pages, total, rows.Err()
 }
 
+// List retrieves the pages matching q in the requested order, either from an offset or after a
+// keyset cursor. Widget counts are computed per page so that listings can be ordered by them.
+func (r *PageRepository) List(ctx context.Context, q PageQuery) (PageList, error) {
+	if err := CheckPageQuery(&q); err != nil {
+		return PageList{}, err
+	}
+
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var where []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.IsHome != nil {
+		where = append(where, "p.is_home = "+arg(*q.IsHome))
+	}
+	if q.NamePrefix != "" {
+		where = append(where, "lower(p.name) LIKE "+arg(strings.ToLower(EscapeLike(q.NamePrefix))+"%"))
+	}
+	if q.RoutePrefix != "" {
+		where = append(where, "p.route LIKE "+arg(EscapeLike(q.RoutePrefix)+"%"))
+	}
+	if !q.UpdatedSince.IsZero() {
+		where = append(where, "p.updated_at >= "+arg(q.UpdatedSince))
+	}
+	if q.WidgetType != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM widgets w WHERE w.page_id = p.id AND w.type = "+arg(q.WidgetType)+")")
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var list PageList
+	if q.CountTotal {
+		countQuery := `SELECT COUNT(*) FROM pages p ` + filter
+		if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&list.Total); err != nil {
+			return PageList{}, err
+		}
+	}
+
+	column := string(q.Sort)
+	direction, compare := "ASC", ">"
+	if q.Descending {
+		direction, compare = "DESC", "<"
+	}
+
+	keyset := ""
+	if c := q.After; c != nil {
+		var key interface{}
+		switch q.Sort {
+		case PageSortName, PageSortRoute:
+			key = c.Text
+		case PageSortWidgetCount:
+			key = c.Count
+		default:
+			key = c.Time
+		}
+		keyset = fmt.Sprintf("WHERE (%s, id) %s (%s, %s)", column, compare, arg(key), arg(c.ID))
+	}
+
+	page := ""
+	if q.Limit > 0 {
+		page = "LIMIT " + arg(q.Limit+1)
+	}
+	if q.Offset > 0 {
+		page += " OFFSET " + arg(q.Offset)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at, widget_count
+		FROM (
+			SELECT p.id, p.name, p.route, p.is_home, p.schedule, p.rule, p.segment_id, p.default_locale, p.names, p.created_at, p.updated_at,
+				(SELECT COUNT(*) FROM widgets w WHERE w.page_id = p.id) AS widget_count
+			FROM pages p
+			%s
+		) AS listed
+		%s
+		ORDER BY %s %s, id %s
+		%s
+	`, filter, keyset, column, direction, direction, page)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return PageList{}, err
+	}
+	defer rows.Close()
+
+	var counts []int
+	for rows.Next() {
+		var p models.Page
+		var widgetCount int
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Route, &p.IsHome,
+			&p.Schedule, &p.Rule, &p.SegmentID, &p.DefaultLocale, &p.Names, &p.CreatedAt, &p.UpdatedAt, &widgetCount,
+		); err != nil {
+			return PageList{}, err
+		}
+		list.Pages = append(list.Pages, p)
+		counts = append(counts, widgetCount)
+	}
+	if err := rows.Err(); err != nil {
+		return PageList{}, err
+	}
+
+	if q.Limit > 0 && len(list.Pages) > q.Limit {
+		list.Pages = list.Pages[:q.Limit]
+		last := len(list.Pages) - 1
+		list.Next = NewPageCursor(q, list.Pages[last], counts[last])
+	}
+	return list, nil
+}
+
 // Update modifies an existing Page entity with the provided field updates.
-func (r *PageRepository) Update(id uuid.UUID, updates map[string]interface{}) (*models.Page, error) {
+func (r *PageRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Page, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	setClauses := ""
 	args := []interface{}{}
 	argIndex := 1
@@ -148,7 +291,7 @@ func (r *PageRepository) Update(id uuid.UUID, updates map[string]interface{}) (*
 	}
 
 	if setClauses == "" {
-		return r.GetByID(id)
+		return r.GetByID(ctx, id)
 	}
 
 	args = append(args, id)
@@ -156,27 +299,30 @@ func (r *PageRepository) Update(id uuid.UUID, updates map[string]interface{}) (*
 		UPDATE pages
 		SET %s
 		WHERE id = $%d
-		RETURNING id, name, route, is_home, created_at, updated_at
+		RETURNING id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
 	`, setClauses, argIndex)
 
 	page := &models.Page{}
-	err := r.db.QueryRow(query, args...).Scan(
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
 		&page.ID, &page.Name, &page.Route, &page.IsHome,
-		&page.CreatedAt, &page.UpdatedAt,
+		&page.Schedule, &page.Rule, &page.SegmentID, &page.DefaultLocale, &page.Names, &page.CreatedAt, &page.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, translateRouteConflict(err)
 	}
 	return page, nil
 }
 
 // Delete removes a Page entity from the data store by its ID.
-func (r *PageRepository) Delete(id uuid.UUID) error {
+func (r *PageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `DELETE FROM pages WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -191,26 +337,32 @@ func (r *PageRepository) Delete(id uuid.UUID) error {
 }
 
 // UnsetHomePage clears the home page flag from any Page entity that currently has it set.
-func (r *PageRepository) UnsetHomePage() error {
+func (r *PageRepository) UnsetHomePage(ctx context.Context) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `UPDATE pages SET is_home = FALSE WHERE is_home = TRUE`
-	_, err := r.db.Exec(query)
+	_, err := r.db.ExecContext(ctx, query)
 	return err
 }
 
 // GetByIDWithWidgets retrieves a Page entity along with all its associated Widget entities.
-func (r *PageRepository) GetByIDWithWidgets(id uuid.UUID) (*models.Page, error) {
-	page, err := r.GetByID(id)
+func (r *PageRepository) GetByIDWithWidgets(ctx context.Context, id uuid.UUID) (*models.Page, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	page, err := r.GetByID(ctx, id)
 	if err != nil || page == nil {
 		return page, err
 	}
 
 	query := `
-		SELECT id, page_id, type, position, config, created_at, updated_at
+		SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
 		FROM widgets
 		WHERE page_id = $1
 		ORDER BY position ASC
 	`
-	rows, err := r.db.Query(query, id)
+	rows, err := r.db.QueryContext(ctx, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -222,7 +374,7 @@ func (r *PageRepository) GetByIDWithWidgets(id uuid.UUID) (*models.Page, error)
 		var configBytes []byte
 		if err := rows.Scan(
 			&w.ID, &w.PageID, &w.Type, &w.Position,
-			&configBytes, &w.CreatedAt, &w.UpdatedAt,
+			&configBytes, &w.Schedule, &w.Rule, &w.SegmentID, &w.Locales, &w.CreatedAt, &w.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -235,7 +387,10 @@ func (r *PageRepository) GetByIDWithWidgets(id uuid.UUID) (*models.Page, error)
 }
 
 // CheckRouteExists determines if a given route is already assigned to a Page, optionally excluding a specific ID.
-func (r *PageRepository) CheckRouteExists(route string, excludeID *uuid.UUID) (bool, error) {
+func (r *PageRepository) CheckRouteExists(ctx context.Context, route string, excludeID *uuid.UUID) (bool, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var query string
 	var args []interface{}
 
@@ -248,6 +403,25 @@ func (r *PageRepository) CheckRouteExists(route string, excludeID *uuid.UUID) (b
 	}
 
 	var exists bool
-	err := r.db.QueryRow(query, args...).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&exists)
 	return exists, err
 }
+
+// Count returns the total number of Page entities in the data store.
+func (r *PageRepository) Count(ctx context.Context) (int, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pages`).Scan(&count)
+	return count, err
+}
+
+// translateRouteConflict maps a unique violation on pages.route to ErrRouteConflict.
+func translateRouteConflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrRouteConflict
+	}
+	return err
+}
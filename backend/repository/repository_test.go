@@ -0,0 +1,60 @@
+// Package repository_test runs the repository conformance suite against PostgreSQL.
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"appdrop/database"
+	"appdrop/repository"
+	"appdrop/repository/storetest"
+
+	_ "github.com/lib/pq"
+)
+
+// testDSNEnvVar names the variable holding the connection string of a disposable test database.
+const testDSNEnvVar = "APPDROP_TEST_DATABASE_DSN"
+
+// TestPostgresConformance verifies that the PostgreSQL stores pass the shared conformance suite.
+// It is skipped unless APPDROP_TEST_DATABASE_DSN points at a database that may be truncated.
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv(testDSNEnvVar)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnvVar)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db, database.DriverPostgres, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		if _, err := db.Exec(`TRUNCATE pages, segments, audit_log, app_theme CASCADE`); err != nil {
+			t.Fatalf("Failed to reset tables: %v", err)
+		}
+		return storetest.Backend{
+			Pages:       repository.NewPageRepository(db, 5*time.Second),
+			Widgets:     repository.NewWidgetRepository(db, 5*time.Second),
+			UnitOfWork:  repository.NewUnitOfWork(db, 5*time.Second),
+			Search:      repository.NewSearchRepository(db, 5*time.Second),
+			Audit:       repository.NewAuditRepository(db, 5*time.Second),
+			Segments:    repository.NewSegmentRepository(db, 5*time.Second),
+			Experiments: repository.NewExperimentRepository(db, 5*time.Second),
+			Themes:      repository.NewThemeRepository(db, 5*time.Second),
+		}
+	})
+}
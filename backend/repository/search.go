@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"appdrop/models"
+)
+
+// SearchStore finds pages and widgets whose text matches a free-text query.
+// Hits are returned best first, and every search term has to match for a document to be a hit.
+type SearchStore interface {
+	Search(ctx context.Context, q SearchQuery) ([]models.SearchHit, error)
+}
+
+// SearchQuery describes a free-text search. Limit caps the number of hits returned.
+type SearchQuery struct {
+	Text  string
+	Limit int
+}
+
+// Rank weights shared by the backends: a page name counts more than its route or widget text,
+// matching the A and B weights given to those columns by the PostgreSQL search index.
+const (
+	nameWeight = 1.0
+	textWeight = 0.4
+)
+
+// snippetRadius is roughly how many bytes of context a snippet keeps around its first match.
+const snippetRadius = 60
+
+// WidgetSearchText joins the searchable string values of a widget config, in the order of
+// models.SearchableConfigFields, skipping fields that are missing or not strings.
+func WidgetSearchText(config json.RawMessage) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, key := range models.SearchableConfigFields {
+		if s, ok := fields[key].(string); ok && s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Highlight sentinels mark matches in snippets until they are HTML-escaped; they are private-use
+// characters, so they do not occur in content by accident.
+const (
+	markStart = "\ue000"
+	markStop  = "\ue001"
+)
+
+// wordPattern matches the runs of letters and digits that make up the words of a text.
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchOperand is a word or a quoted phrase of a query, negated by a leading "-".
+type searchOperand struct {
+	words   []string
+	negated bool
+}
+
+// TermMatcher matches, scores and highlights a query for backends without a full-text index.
+// It reads the same web search syntax as PostgreSQL's websearch_to_tsquery: terms are combined
+// with AND, "or" joins alternatives, "-" excludes a term and quotes match a phrase. Words match
+// whole and ignore case, without stemming, like the "simple" text search configuration.
+type TermMatcher struct {
+	// clauses all have to match; a clause matches when any of its operands does.
+	clauses [][]searchOperand
+	// marked holds the words that are highlighted and counted by Score.
+	marked map[string]bool
+}
+
+// NewTermMatcher parses text as a web search query.
+// It returns nil when the text holds no words.
+func NewTermMatcher(text string) *TermMatcher {
+	m := &TermMatcher{marked: make(map[string]bool)}
+	alternative := false
+	for len(text) > 0 {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text == "" {
+			break
+		}
+
+		negated := false
+		if text[0] == '-' {
+			negated = true
+			text = text[1:]
+		}
+		var raw string
+		quoted := text != "" && text[0] == '"'
+		if quoted {
+			end := strings.IndexByte(text[1:], '"')
+			if end < 0 {
+				raw, text = text[1:], ""
+			} else {
+				raw, text = text[1:end+1], text[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(text, " \t\r\n\"")
+			if end < 0 {
+				end = len(text)
+			}
+			raw, text = text[:end], text[end:]
+		}
+
+		if !quoted && !negated && strings.EqualFold(raw, "or") {
+			alternative = len(m.clauses) > 0
+			continue
+		}
+		words := searchWords(raw)
+		if len(words) == 0 {
+			continue
+		}
+
+		operand := searchOperand{words: words, negated: negated}
+		if alternative {
+			last := len(m.clauses) - 1
+			m.clauses[last] = append(m.clauses[last], operand)
+		} else {
+			m.clauses = append(m.clauses, []searchOperand{operand})
+		}
+		alternative = false
+		if !negated {
+			for _, word := range words {
+				m.marked[word] = true
+			}
+		}
+	}
+	if len(m.clauses) == 0 {
+		return nil
+	}
+	return m
+}
+
+// searchWords returns the lowercase words of text.
+func searchWords(text string) []string {
+	return wordPattern.FindAllString(strings.ToLower(text), -1)
+}
+
+// RequiredWords returns the words every match contains, so that backends can narrow their
+// candidates before calling Matches: those of clauses made of a single operand that is not negated.
+func (m *TermMatcher) RequiredWords() []string {
+	var words []string
+	for _, clause := range m.clauses {
+		if len(clause) == 1 && !clause[0].negated {
+			words = append(words, clause[0].words...)
+		}
+	}
+	return words
+}
+
+// Matches reports whether text satisfies every clause of the query.
+func (m *TermMatcher) Matches(text string) bool {
+	words := searchWords(text)
+	for _, clause := range m.clauses {
+		found := false
+		for _, operand := range clause {
+			if containsPhrase(words, operand.words) != operand.negated {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPhrase reports whether phrase occurs in words as consecutive words.
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, word := range phrase {
+			if words[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// markedSpans returns the byte ranges of the words of text that the query highlights.
+func (m *TermMatcher) markedSpans(text string) [][]int {
+	var spans [][]int
+	for _, span := range wordPattern.FindAllStringIndex(text, -1) {
+		if m.marked[strings.ToLower(text[span[0]:span[1]])] {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Score returns the number of highlighted word occurrences in text multiplied by weight.
+func (m *TermMatcher) Score(text string, weight float64) float64 {
+	return float64(len(m.markedSpans(text))) * weight
+}
+
+// Highlight returns an HTML snippet of text around its first match with every match wrapped in
+// <mark> and </mark>, or the start of text when nothing matches. The text itself is escaped.
+func (m *TermMatcher) Highlight(text string) string {
+	matches := m.markedSpans(text)
+	start, end := 0, len(text)
+	if len(matches) > 0 {
+		start = matches[0][0] - snippetRadius
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end-start > 2*snippetRadius {
+		end = start + 2*snippetRadius
+	}
+	start, end = wordBoundary(text, start, -1), wordBoundary(text, end, 1)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	pos := start
+	for _, match := range matches {
+		if match[0] < start || match[1] > end {
+			continue
+		}
+		b.WriteString(text[pos:match[0]])
+		b.WriteString(markStart)
+		b.WriteString(text[match[0]:match[1]])
+		b.WriteString(markStop)
+		pos = match[1]
+	}
+	b.WriteString(text[pos:end])
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return MarkSnippet(b.String())
+}
+
+// MarkSnippet HTML-escapes a snippet whose matches are delimited by the highlight sentinels and
+// turns the sentinels into <mark> and </mark>.
+func MarkSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	return strings.NewReplacer(markStart, "<mark>", markStop, "</mark>").Replace(snippet)
+}
+
+// wordBoundary moves i in direction dir until it sits on a space or an end of text.
+func wordBoundary(text string, i, dir int) int {
+	for i > 0 && i < len(text) && text[i] != ' ' {
+		i += dir
+	}
+	return i
+}
+
+// PageHit builds the hit for a page whose name or route matched.
+func (m *TermMatcher) PageHit(page models.Page) models.SearchHit {
+	return models.SearchHit{
+		Kind:      models.SearchKindPage,
+		PageID:    page.ID,
+		PageName:  page.Name,
+		PageRoute: page.Route,
+		Snippet:   m.Highlight(page.Name + " " + page.Route),
+		Rank:      m.Score(page.Name, nameWeight) + m.Score(page.Route, textWeight),
+	}
+}
+
+// WidgetHit builds the hit for a widget on page whose searchable text matched.
+func (m *TermMatcher) WidgetHit(page models.Page, widget models.Widget, text string) models.SearchHit {
+	id := widget.ID
+	return models.SearchHit{
+		Kind:       models.SearchKindWidget,
+		PageID:     page.ID,
+		PageName:   page.Name,
+		PageRoute:  page.Route,
+		WidgetID:   &id,
+		WidgetType: widget.Type,
+		Snippet:    m.Highlight(text),
+		Rank:       m.Score(text, textWeight),
+	}
+}
+
+// RankHits orders hits best first, breaking ties by page name and putting pages before their widgets,
+// and keeps at most limit of them when limit is positive.
+func RankHits(hits []models.SearchHit, limit int) []models.SearchHit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.PageName != b.PageName {
+			return a.PageName < b.PageName
+		}
+		return a.Kind < b.Kind
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
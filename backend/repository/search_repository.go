@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+)
+
+// SearchRepository runs PostgreSQL full-text searches over pages and widget configs.
+// It relies on the search_vector columns and GIN indexes added by migration 004.
+type SearchRepository struct {
+	db           DBTX
+	queryTimeout time.Duration
+}
+
+// NewSearchRepository initializes and returns a new instance of SearchRepository.
+func NewSearchRepository(db DBTX, queryTimeout time.Duration) *SearchRepository {
+	return &SearchRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// headlineOptions configures ts_headline to delimit matches with the highlight sentinels, which
+// MarkSnippet turns into <mark> and </mark> once the text is escaped, as with the other backends.
+const headlineOptions = `StartSel="` + markStart + `", StopSel="` + markStop + `", MaxWords=20, MinWords=5, MaxFragments=1, FragmentDelimiter=...`
+
+// Search parses the query with websearch_to_tsquery, so quoted phrases, "or" and "-term" are
+// supported, and ranks page and widget hits together with ts_rank.
+func (r *SearchRepository) Search(ctx context.Context, q SearchQuery) ([]models.SearchHit, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH q AS (SELECT websearch_to_tsquery('simple', $1) AS query)
+		SELECT kind, page_id, page_name, page_route, widget_id, widget_type, snippet, rank
+		FROM (
+			SELECT 'page' AS kind, p.id AS page_id, p.name AS page_name, p.route AS page_route,
+				NULL::uuid AS widget_id, '' AS widget_type,
+				ts_headline('simple', p.name || ' ' || p.route, q.query, $3) AS snippet,
+				ts_rank(p.search_vector, q.query) AS rank
+			FROM pages p, q
+			WHERE p.search_vector @@ q.query
+			UNION ALL
+			SELECT 'widget', p.id, p.name, p.route, w.id, w.type,
+				ts_headline('simple', widget_search_text(w.config), q.query, $3),
+				ts_rank(w.search_vector, q.query)
+			FROM widgets w
+			JOIN pages p ON p.id = w.page_id, q
+			WHERE w.search_vector @@ q.query
+		) AS hits
+		ORDER BY rank DESC, page_name, kind
+		LIMIT NULLIF($2, 0)
+	`
+	rows, err := r.db.QueryContext(ctx, query, q.Text, q.Limit, headlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []models.SearchHit
+	for rows.Next() {
+		var hit models.SearchHit
+		var widgetID uuid.NullUUID
+		if err := rows.Scan(
+			&hit.Kind, &hit.PageID, &hit.PageName, &hit.PageRoute,
+			&widgetID, &hit.WidgetType, &hit.Snippet, &hit.Rank,
+		); err != nil {
+			return nil, err
+		}
+		if widgetID.Valid {
+			hit.WidgetID = &widgetID.UUID
+		}
+		hit.Snippet = MarkSnippet(hit.Snippet)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
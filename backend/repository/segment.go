@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrSegmentInUse is returned when deleting a segment that pages or widgets still reference.
+var ErrSegmentInUse = errors.New("segment is still referenced")
+
+// SegmentStore defines the persistence operations available for audience segments and their user
+// lists. Every operation honors the cancellation and deadline of its context.
+// Lookups return a nil segment and a nil error when nothing matches. Delete returns sql.ErrNoRows
+// when the segment does not exist and ErrSegmentInUse while pages or widgets reference it; its
+// user list is deleted with it. SetUsers replaces the user list, keeping UserCount in step, and
+// returns sql.ErrNoRows for an unknown segment. Memberships reports, for each of the given segments
+// that exists, whether userID is on its list.
+type SegmentStore interface {
+	Create(ctx context.Context, segment *models.Segment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Segment, error)
+	List(ctx context.Context) ([]models.Segment, error)
+	Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Segment, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	SetUsers(ctx context.Context, id uuid.UUID, userIDs []string) error
+	Memberships(ctx context.Context, ids []uuid.UUID, userID string) ([]models.SegmentMembership, error)
+	References(ctx context.Context, id uuid.UUID) (pages, widgets int, err error)
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SegmentRepository manages database operations for audience segments. User lists live in the
+// segment_users table, keyed by segment and user ID, so that a membership check is a single
+// primary key lookup however long the list.
+type SegmentRepository struct {
+	db           DBTX
+	queryTimeout time.Duration
+}
+
+// NewSegmentRepository initializes and returns a new instance of SegmentRepository.
+func NewSegmentRepository(db DBTX, queryTimeout time.Duration) *SegmentRepository {
+	return &SegmentRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create persists a new segment without users, assigning its ID and timestamps.
+func (r *SegmentRepository) Create(ctx context.Context, segment *models.Segment) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO segments (name, rule)
+		VALUES ($1, $2)
+		RETURNING id, user_count, created_at, updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, segment.Name, segment.Rule).
+		Scan(&segment.ID, &segment.UserCount, &segment.CreatedAt, &segment.UpdatedAt)
+}
+
+// GetByID retrieves a single segment by its unique identifier.
+func (r *SegmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Segment, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, name, rule, user_count, created_at, updated_at
+		FROM segments
+		WHERE id = $1
+	`
+	segment := &models.Segment{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&segment.ID, &segment.Name, &segment.Rule, &segment.UserCount, &segment.CreatedAt, &segment.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
+
+// List retrieves every segment ordered by name.
+func (r *SegmentRepository) List(ctx context.Context) ([]models.Segment, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, name, rule, user_count, created_at, updated_at
+		FROM segments
+		ORDER BY name ASC, id ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []models.Segment
+	for rows.Next() {
+		var s models.Segment
+		if err := rows.Scan(&s.ID, &s.Name, &s.Rule, &s.UserCount, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
+
+// Update modifies an existing segment with the provided column updates ("name" and "rule").
+func (r *SegmentRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Segment, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	setClauses := ""
+	args := []interface{}{}
+	for key, value := range updates {
+		if setClauses != "" {
+			setClauses += ", "
+		}
+		args = append(args, value)
+		setClauses += fmt.Sprintf("%s = $%d", key, len(args))
+	}
+	if setClauses == "" {
+		return r.GetByID(ctx, id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf(`
+		UPDATE segments
+		SET %s
+		WHERE id = $%d
+		RETURNING id, name, rule, user_count, created_at, updated_at
+	`, setClauses, len(args))
+
+	segment := &models.Segment{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&segment.ID, &segment.Name, &segment.Rule, &segment.UserCount, &segment.CreatedAt, &segment.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
+
+// Delete removes a segment and its user list. The foreign keys from pages and widgets reject the
+// delete while the segment is referenced.
+func (r *SegmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM segments WHERE id = $1`, id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrSegmentInUse
+		}
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetUsers replaces the user list of a segment. The IDs are sent as one array parameter and
+// deduplicated by the primary key, so a large upload takes a single statement.
+func (r *SegmentRepository) SetUsers(ctx context.Context, id uuid.UUID, userIDs []string) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return InTx(ctx, r.db, func(tx DBTX) error {
+		var locked uuid.UUID
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM segments WHERE id = $1 FOR UPDATE`, id).Scan(&locked); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM segment_users WHERE segment_id = $1`, id); err != nil {
+			return err
+		}
+		query := `
+			INSERT INTO segment_users (segment_id, user_id)
+			SELECT $1, unnest($2::text[])
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, query, id, pq.Array(userIDs)); err != nil {
+			return err
+		}
+		query = `
+			UPDATE segments
+			SET user_count = (SELECT COUNT(*) FROM segment_users WHERE segment_id = $1)
+			WHERE id = $1
+		`
+		_, err := tx.ExecContext(ctx, query, id)
+		return err
+	})
+}
+
+// Memberships reports, for each of the given segments that exists, its rule, whether it has a
+// user list and whether userID is on it.
+func (r *SegmentRepository) Memberships(ctx context.Context, ids []uuid.UUID, userID string) ([]models.SegmentMembership, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT s.id, s.rule, s.user_count > 0,
+			EXISTS (SELECT 1 FROM segment_users u WHERE u.segment_id = s.id AND u.user_id = $2)
+		FROM segments s
+		WHERE s.id = ANY($1)
+	`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []models.SegmentMembership
+	for rows.Next() {
+		var m models.SegmentMembership
+		if err := rows.Scan(&m.SegmentID, &m.Rule, &m.HasUsers, &m.Listed); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+// References counts the pages and widgets that reference a segment.
+func (r *SegmentRepository) References(ctx context.Context, id uuid.UUID) (int, int, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM pages WHERE segment_id = $1),
+			(SELECT COUNT(*) FROM widgets WHERE segment_id = $1)
+	`
+	var pages, widgets int
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&pages, &widgets)
+	return pages, widgets, err
+}
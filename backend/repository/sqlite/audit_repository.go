@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"appdrop/models"
+	"appdrop/repository"
+
+	"github.com/google/uuid"
+)
+
+// AuditRepository manages SQLite operations for audit log entries.
+type AuditRepository struct {
+	db           repository.DBTX
+	queryTimeout time.Duration
+}
+
+// NewAuditRepository initializes and returns a new instance of AuditRepository.
+func NewAuditRepository(db repository.DBTX, queryTimeout time.Duration) *AuditRepository {
+	return &AuditRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Record persists a new audit entry, assigning its identifier here as SQLite has no UUID generator.
+func (r *AuditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	details := entry.Details
+	if len(details) == 0 {
+		details = json.RawMessage("{}")
+	}
+
+	query := `
+		INSERT INTO audit_log (id, action, actor, request_id, details)
+		VALUES ($1, $2, $3, $4, json($5))
+		RETURNING created_at
+	`
+	id := uuid.New()
+	if err := r.db.QueryRowContext(ctx, query, id, entry.Action, entry.Actor, entry.RequestID, string(details)).
+		Scan(&entry.CreatedAt); err != nil {
+		return err
+	}
+	entry.ID = id
+	return nil
+}
+
+// List retrieves the most recent audit entries, newest first.
+func (r *AuditRepository) List(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, action, actor, request_id, details, created_at
+		FROM audit_log
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var details string
+		if err := rows.Scan(&e.ID, &e.Action, &e.Actor, &e.RequestID, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Details = json.RawMessage(details)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
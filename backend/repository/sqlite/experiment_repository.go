@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"appdrop/models"
+	"appdrop/repository"
+
+	"github.com/google/uuid"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// experimentColumns lists the columns scanned by scanExperiment, in order.
+const experimentColumns = `id, page_id, name, status, variants, winner, started_at, stopped_at, created_at, updated_at`
+
+// ExperimentRepository manages SQLite operations for page experiments.
+type ExperimentRepository struct {
+	db           repository.DBTX
+	queryTimeout time.Duration
+}
+
+// NewExperimentRepository initializes and returns a new instance of ExperimentRepository.
+func NewExperimentRepository(db repository.DBTX, queryTimeout time.Duration) *ExperimentRepository {
+	return &ExperimentRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// scanExperiment reads one experiment row selected with experimentColumns.
+func scanExperiment(row interface{ Scan(...interface{}) error }) (*models.Experiment, error) {
+	e := &models.Experiment{}
+	err := row.Scan(
+		&e.ID, &e.PageID, &e.Name, &e.Status, &e.Variants, &e.Winner,
+		&e.StartedAt, &e.StoppedAt, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Create persists a new experiment, assigning its identifier here as SQLite has no UUID generator.
+func (r *ExperimentRepository) Create(ctx context.Context, experiment *models.Experiment) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO experiments (id, page_id, name, status, variants)
+		VALUES ($1, $2, $3, $4, json($5))
+		RETURNING created_at, updated_at
+	`
+	id := uuid.New()
+	if err := r.db.QueryRowContext(ctx, query, id, experiment.PageID, experiment.Name, experiment.Status, experiment.Variants).
+		Scan(&experiment.CreatedAt, &experiment.UpdatedAt); err != nil {
+		return err
+	}
+	experiment.ID = id
+	return nil
+}
+
+// GetByID retrieves a single experiment by its unique identifier.
+func (r *ExperimentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Experiment, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+experimentColumns+` FROM experiments WHERE id = $1`, id)
+}
+
+// Running retrieves the running experiment of a page.
+func (r *ExperimentRepository) Running(ctx context.Context, pageID uuid.UUID) (*models.Experiment, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.getOne(ctx, `SELECT `+experimentColumns+` FROM experiments WHERE page_id = $1 AND status = 'running'`, pageID)
+}
+
+// getOne runs a query returning at most one experiment, mapping no rows to a nil experiment.
+func (r *ExperimentRepository) getOne(ctx context.Context, query string, args ...interface{}) (*models.Experiment, error) {
+	experiment, err := scanExperiment(r.db.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return experiment, err
+}
+
+// ListByPage retrieves the experiments of a page, newest first.
+func (r *ExperimentRepository) ListByPage(ctx context.Context, pageID uuid.UUID) ([]models.Experiment, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE page_id = $1
+		ORDER BY created_at DESC, rowid DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []models.Experiment
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, *e)
+	}
+	return experiments, rows.Err()
+}
+
+// Update modifies an existing experiment with the provided column updates.
+func (r *ExperimentRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Experiment, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	setClauses, args, err := setClause(updates, "name", "status", "variants", "winner", "started_at", "stopped_at")
+	if err != nil {
+		return nil, err
+	}
+	if setClauses == "" {
+		return r.GetByID(ctx, id)
+	}
+
+	args = append(args, id)
+	experiment, err := r.getOne(ctx, fmt.Sprintf(`
+		UPDATE experiments
+		SET %s
+		WHERE id = $%d
+		RETURNING %s
+	`, setClauses, len(args), experimentColumns), args...)
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return nil, repository.ErrExperimentRunning
+	}
+	return experiment, err
+}
+
+// Delete removes an experiment.
+func (r *ExperimentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM experiments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
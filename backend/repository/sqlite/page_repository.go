@@ -0,0 +1,417 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"appdrop/models"
+	"appdrop/repository"
+
+	"github.com/google/uuid"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// PageRepository manages SQLite operations specifically for Page entities.
+type PageRepository struct {
+	db           repository.DBTX
+	queryTimeout time.Duration
+}
+
+// NewPageRepository initializes and returns a new instance of PageRepository.
+func NewPageRepository(db repository.DBTX, queryTimeout time.Duration) *PageRepository {
+	return &PageRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create persists a new Page entity in the data store. SQLite has no UUID generator,
+// so the identifier is assigned here rather than by a column default.
+func (r *PageRepository) Create(ctx context.Context, page *models.Page) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO pages (id, name, route, is_home, schedule, rule, segment_id, default_locale, names)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at
+	`
+	id := uuid.New()
+	err := r.db.QueryRowContext(ctx, query, id, page.Name, page.Route, page.IsHome, page.Schedule, page.Rule, page.SegmentID, page.DefaultLocale, page.Names).
+		Scan(&page.CreatedAt, &page.UpdatedAt)
+	if err != nil {
+		return translateRouteConflict(err)
+	}
+	page.ID = id
+	return nil
+}
+
+// GetByID retrieves a single Page entity by its unique identifier.
+func (r *PageRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Page, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.getOne(ctx, `
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
+		FROM pages
+		WHERE id = $1
+	`, id)
+}
+
+// GetByRoute retrieves a Page entity by its uniquely associated route.
+func (r *PageRepository) GetByRoute(ctx context.Context, route string) (*models.Page, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.getOne(ctx, `
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
+		FROM pages
+		WHERE route = $1
+	`, route)
+}
+
+// GetHomePage retrieves the Page designated as the application's home page.
+func (r *PageRepository) GetHomePage(ctx context.Context) (*models.Page, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return r.getOne(ctx, `
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
+		FROM pages
+		WHERE is_home = 1
+		ORDER BY rowid
+		LIMIT 1
+	`)
+}
+
+// getOne runs a query returning at most one page, mapping no rows to a nil page.
+func (r *PageRepository) getOne(ctx context.Context, query string, args ...interface{}) (*models.Page, error) {
+	page := &models.Page{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&page.ID, &page.Name, &page.Route, &page.IsHome,
+		&page.Schedule, &page.Rule, &page.SegmentID, &page.DefaultLocale, &page.Names, &page.CreatedAt, &page.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetAll retrieves a paginated collection of Page entities, newest first.
+// Pages created within the same millisecond are ordered by insertion.
+func (r *PageRepository) GetAll(ctx context.Context, page, perPage int) ([]models.Page, int, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pages`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * perPage
+	query := `
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
+		FROM pages
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, perPage, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var pages []models.Page
+	for rows.Next() {
+		var p models.Page
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Route, &p.IsHome,
+			&p.Schedule, &p.Rule, &p.SegmentID, &p.DefaultLocale, &p.Names, &p.CreatedAt, &p.UpdatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		pages = append(pages, p)
+	}
+
+	return pages, total, rows.Err()
+}
+
+// List retrieves the pages matching q in the requested order, either from an offset or after a
+// keyset cursor. SQLite's LIKE already ignores ASCII case, which the name prefix filter relies on.
+func (r *PageRepository) List(ctx context.Context, q repository.PageQuery) (repository.PageList, error) {
+	if err := repository.CheckPageQuery(&q); err != nil {
+		return repository.PageList{}, err
+	}
+
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var where []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.IsHome != nil {
+		where = append(where, "p.is_home = "+arg(*q.IsHome))
+	}
+	if q.NamePrefix != "" {
+		where = append(where, "p.name LIKE "+arg(repository.EscapeLike(q.NamePrefix)+"%")+` ESCAPE '\'`)
+	}
+	if q.RoutePrefix != "" {
+		where = append(where, fmt.Sprintf("substr(p.route, 1, %d) = %s", utf8.RuneCountInString(q.RoutePrefix), arg(q.RoutePrefix)))
+	}
+	if !q.UpdatedSince.IsZero() {
+		where = append(where, "p.updated_at >= "+arg(q.UpdatedSince.UTC().Format(timeFormat)))
+	}
+	if q.WidgetType != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM widgets w WHERE w.page_id = p.id AND w.type = "+arg(q.WidgetType)+")")
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var list repository.PageList
+	if q.CountTotal {
+		countQuery := `SELECT COUNT(*) FROM pages p ` + filter
+		if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&list.Total); err != nil {
+			return repository.PageList{}, err
+		}
+	}
+
+	column := string(q.Sort)
+	direction, compare := "ASC", ">"
+	if q.Descending {
+		direction, compare = "DESC", "<"
+	}
+
+	keyset := ""
+	if c := q.After; c != nil {
+		var key interface{}
+		switch q.Sort {
+		case repository.PageSortName, repository.PageSortRoute:
+			key = c.Text
+		case repository.PageSortWidgetCount:
+			key = c.Count
+		default:
+			key = c.Time.UTC().Format(timeFormat)
+		}
+		keyset = fmt.Sprintf("WHERE (%s, id) %s (%s, %s)", column, compare, arg(key), arg(c.ID))
+	}
+
+	page := ""
+	if q.Limit > 0 {
+		page = "LIMIT " + arg(q.Limit+1)
+		if q.Offset > 0 {
+			page += " OFFSET " + arg(q.Offset)
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at, widget_count
+		FROM (
+			SELECT p.id, p.name, p.route, p.is_home, p.schedule, p.rule, p.segment_id, p.default_locale, p.names, p.created_at, p.updated_at,
+				(SELECT COUNT(*) FROM widgets w WHERE w.page_id = p.id) AS widget_count
+			FROM pages p
+			%s
+		) AS listed
+		%s
+		ORDER BY %s %s, id %s
+		%s
+	`, filter, keyset, column, direction, direction, page)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return repository.PageList{}, err
+	}
+	defer rows.Close()
+
+	var counts []int
+	for rows.Next() {
+		var p models.Page
+		var widgetCount int
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Route, &p.IsHome,
+			&p.Schedule, &p.Rule, &p.SegmentID, &p.DefaultLocale, &p.Names, &p.CreatedAt, &p.UpdatedAt, &widgetCount,
+		); err != nil {
+			return repository.PageList{}, err
+		}
+		list.Pages = append(list.Pages, p)
+		counts = append(counts, widgetCount)
+	}
+	if err := rows.Err(); err != nil {
+		return repository.PageList{}, err
+	}
+
+	if q.Limit > 0 && len(list.Pages) > q.Limit {
+		list.Pages = list.Pages[:q.Limit]
+		last := len(list.Pages) - 1
+		list.Next = repository.NewPageCursor(q, list.Pages[last], counts[last])
+	}
+	return list, nil
+}
+
+// Update modifies an existing Page entity with the provided field updates.
+func (r *PageRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Page, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	setClauses, args, err := setClause(updates, "name", "route", "is_home", "schedule", "rule", "segment_id", "default_locale", "names")
+	if err != nil {
+		return nil, err
+	}
+	if setClauses == "" {
+		return r.GetByID(ctx, id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf(`
+		UPDATE pages
+		SET %s, updated_at = %s
+		WHERE id = $%d
+		RETURNING id, name, route, is_home, schedule, rule, segment_id, default_locale, names, created_at, updated_at
+	`, setClauses, nowExpr, len(args))
+
+	page, err := r.getOne(ctx, query, args...)
+	if err != nil {
+		return nil, translateRouteConflict(err)
+	}
+	return page, nil
+}
+
+// Delete removes a Page entity, and through the foreign key its widgets, from the data store.
+func (r *PageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM pages WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UnsetHomePage clears the home page flag from any Page entity that currently has it set.
+func (r *PageRepository) UnsetHomePage(ctx context.Context) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE pages SET is_home = 0 WHERE is_home = 1`)
+	return err
+}
+
+// GetByIDWithWidgets retrieves a Page entity along with all its associated Widget entities.
+func (r *PageRepository) GetByIDWithWidgets(ctx context.Context, id uuid.UUID) (*models.Page, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	page, err := r.GetByID(ctx, id)
+	if err != nil || page == nil {
+		return page, err
+	}
+
+	widgets, err := queryWidgets(ctx, r.db, `
+		SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
+		FROM widgets
+		WHERE page_id = $1
+		ORDER BY position ASC, rowid ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	page.Widgets = widgets
+	return page, nil
+}
+
+// CheckRouteExists determines if a given route is already assigned to a Page, optionally excluding a specific ID.
+func (r *PageRepository) CheckRouteExists(ctx context.Context, route string, excludeID *uuid.UUID) (bool, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT EXISTS(SELECT 1 FROM pages WHERE route = $1)`
+	args := []interface{}{route}
+	if excludeID != nil {
+		query = `SELECT EXISTS(SELECT 1 FROM pages WHERE route = $1 AND id != $2)`
+		args = append(args, *excludeID)
+	}
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&exists)
+	return exists, err
+}
+
+// Count returns the total number of Page entities in the data store.
+func (r *PageRepository) Count(ctx context.Context) (int, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pages`).Scan(&count)
+	return count, err
+}
+
+// nowExpr is the SQL expression producing the current time in the format used by the schema.
+// Updates set updated_at explicitly because RETURNING reports rows before AFTER triggers run.
+const nowExpr = `strftime('%Y-%m-%dT%H:%M:%fZ', 'now')`
+
+// timeFormat is the Go layout of the timestamps produced by nowExpr, used to bind times for comparison.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// setClause builds the SET list of an UPDATE statement from column updates, accepting only the
+// allowed columns. JSON values are bound as text and passed through json() so they are stored minified.
+func setClause(updates map[string]interface{}, allowed ...string) (string, []interface{}, error) {
+	setClauses := ""
+	var args []interface{}
+	for key, value := range updates {
+		if !contains(allowed, key) {
+			return "", nil, fmt.Errorf("unknown column %q", key)
+		}
+		if setClauses != "" {
+			setClauses += ", "
+		}
+		if raw, ok := value.(json.RawMessage); ok {
+			args = append(args, string(raw))
+			setClauses += fmt.Sprintf("%s = json($%d)", key, len(args))
+			continue
+		}
+		args = append(args, value)
+		setClauses += fmt.Sprintf("%s = $%d", key, len(args))
+	}
+	return setClauses, args, nil
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// translateRouteConflict maps a unique violation on pages.route to repository.ErrRouteConflict.
+func translateRouteConflict(err error) error {
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return repository.ErrRouteConflict
+	}
+	return err
+}
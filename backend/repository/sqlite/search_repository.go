@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"appdrop/models"
+	"appdrop/repository"
+)
+
+// SearchRepository searches pages and widget configs stored in SQLite by term matching.
+// Candidates are narrowed with LIKE, then matched, scored and highlighted like the in-memory store.
+type SearchRepository struct {
+	db           repository.DBTX
+	queryTimeout time.Duration
+}
+
+// NewSearchRepository initializes and returns a new instance of SearchRepository.
+func NewSearchRepository(db repository.DBTX, queryTimeout time.Duration) *SearchRepository {
+	return &SearchRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Search returns the pages whose name and route, and the widgets whose searchable config text,
+// match the query, which is read with the same web search syntax as on PostgreSQL.
+func (r *SearchRepository) Search(ctx context.Context, q repository.SearchQuery) ([]models.SearchHit, error) {
+	matcher := repository.NewTermMatcher(q.Text)
+	if matcher == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	// LIKE ignores ASCII case only, so words with other letters are left to the matcher.
+	pageConds, widgetConds := []string{"1 = 1"}, []string{"1 = 1"}
+	var args []interface{}
+	for _, word := range matcher.RequiredWords() {
+		if !isASCII(word) {
+			continue
+		}
+		args = append(args, "%"+repository.EscapeLike(word)+"%")
+		pageConds = append(pageConds, fmt.Sprintf(`(p.name || ' ' || p.route) LIKE $%d ESCAPE '\'`, len(args)))
+		widgetConds = append(widgetConds, fmt.Sprintf(`ws.body LIKE $%d ESCAPE '\'`, len(args)))
+	}
+
+	query := `
+		SELECT p.id, p.name, p.route, NULL, '', ''
+		FROM pages p
+		WHERE ` + strings.Join(pageConds, " AND ") + `
+		UNION ALL
+		SELECT p.id, p.name, p.route, w.id, w.type, ws.body
+		FROM widget_search ws
+		JOIN widgets w ON w.id = ws.id
+		JOIN pages p ON p.id = ws.page_id
+		WHERE ` + strings.Join(widgetConds, " AND ")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []models.SearchHit
+	for rows.Next() {
+		var page models.Page
+		var widget models.Widget
+		var widgetID *string
+		var body string
+		if err := rows.Scan(&page.ID, &page.Name, &page.Route, &widgetID, &widget.Type, &body); err != nil {
+			return nil, err
+		}
+		if widgetID == nil {
+			if matcher.Matches(page.Name + " " + page.Route) {
+				hits = append(hits, matcher.PageHit(page))
+			}
+			continue
+		}
+		if !matcher.Matches(body) {
+			continue
+		}
+		if err := widget.ID.Scan(*widgetID); err != nil {
+			return nil, err
+		}
+		hits = append(hits, matcher.WidgetHit(page, widget, body))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return repository.RankHits(hits, q.Limit), nil
+}
+
+// isASCII reports whether s holds only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+// Package sqlite contains tests running the repository conformance suite against SQLite.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"appdrop/database"
+	"appdrop/repository/storetest"
+)
+
+// TestConformance verifies that the SQLite stores behave like every other backend.
+// Each subtest runs against a freshly migrated database file.
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		db, err := sql.Open("sqlite", database.SQLiteDSN(filepath.Join(t.TempDir(), "appdrop.db")))
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := database.NewMigrator(db, database.DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if err != nil {
+			t.Fatalf("Failed to load migrations: %v", err)
+		}
+		if err := migrator.Up(context.Background()); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		return storetest.Backend{
+			Pages:       NewPageRepository(db, 5*time.Second),
+			Widgets:     NewWidgetRepository(db, 5*time.Second),
+			UnitOfWork:  NewUnitOfWork(db, 5*time.Second),
+			Search:      NewSearchRepository(db, 5*time.Second),
+			Audit:       NewAuditRepository(db, 5*time.Second),
+			Segments:    NewSegmentRepository(db, 5*time.Second),
+			Experiments: NewExperimentRepository(db, 5*time.Second),
+			Themes:      NewThemeRepository(db, 5*time.Second),
+		}
+	})
+}
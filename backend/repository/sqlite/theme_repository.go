@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"appdrop/models"
+	"appdrop/repository"
+)
+
+// ThemeRepository manages SQLite operations for the app theme, stored as a JSON document in the
+// single row of the app_theme table.
+type ThemeRepository struct {
+	db           repository.DBTX
+	queryTimeout time.Duration
+}
+
+// NewThemeRepository initializes and returns a new instance of ThemeRepository.
+func NewThemeRepository(db repository.DBTX, queryTimeout time.Duration) *ThemeRepository {
+	return &ThemeRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Get retrieves the app theme.
+func (r *ThemeRepository) Get(ctx context.Context) (*models.Theme, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var doc string
+	var updatedAt time.Time
+	err := r.db.QueryRowContext(ctx, `SELECT tokens, updated_at FROM app_theme WHERE id = 1`).Scan(&doc, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return repository.DecodeTheme([]byte(doc), updatedAt)
+}
+
+// Save creates or replaces the app theme, setting its update time.
+func (r *ThemeRepository) Save(ctx context.Context, theme *models.Theme) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	doc, err := repository.EncodeTheme(theme)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO app_theme (id, tokens)
+		VALUES (1, json($1))
+		ON CONFLICT (id) DO UPDATE SET tokens = excluded.tokens, updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+		RETURNING updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, doc).Scan(&theme.UpdatedAt)
+}
@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"appdrop/repository"
+
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// NewUnitOfWork initializes and returns a SQLite unit of work. SQLite transactions are serializable
+// by design, and connections opened with database.SQLiteDSN begin them IMMEDIATE so that writers
+// queue on the busy timeout; a transaction that still finds the database locked is retried.
+func NewUnitOfWork(db *sql.DB, queryTimeout time.Duration) *repository.SQLUnitOfWork {
+	return &repository.SQLUnitOfWork{
+		DB: db,
+		Bind: func(tx repository.DBTX) repository.Stores {
+			return repository.Stores{
+				Pages:       NewPageRepository(tx, queryTimeout),
+				Widgets:     NewWidgetRepository(tx, queryTimeout),
+				Segments:    NewSegmentRepository(tx, queryTimeout),
+				Experiments: NewExperimentRepository(tx, queryTimeout),
+				Themes:      NewThemeRepository(tx, queryTimeout),
+				Audit:       NewAuditRepository(tx, queryTimeout),
+			}
+		},
+		Retryable: isBusy,
+		Policy:    repository.DefaultRetryPolicy,
+	}
+}
+
+// isBusy reports whether err, including its extended result codes, means the database was locked.
+func isBusy(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
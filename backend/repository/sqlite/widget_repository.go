@@ -0,0 +1,392 @@
+// Package sqlite provides the SQLite implementation of the repository stores, used for zero-setup
+// local development and single-node deployments. It behaves like the PostgreSQL repositories and
+// is verified against the same conformance suite.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"appdrop/models"
+	"appdrop/repository"
+
+	"github.com/google/uuid"
+)
+
+// WidgetRepository manages SQLite operations specifically for Widget entities.
+type WidgetRepository struct {
+	db           repository.DBTX
+	queryTimeout time.Duration
+}
+
+// NewWidgetRepository initializes and returns a new instance of WidgetRepository.
+func NewWidgetRepository(db repository.DBTX, queryTimeout time.Duration) *WidgetRepository {
+	return &WidgetRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create persists a new Widget entity in the data store, storing its config as JSON text.
+// It keeps the widget's ID if it already has one.
+func (r *WidgetRepository) Create(ctx context.Context, widget *models.Widget) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	config := widget.Config
+	if config == nil {
+		config = json.RawMessage("{}")
+	}
+
+	query := `
+		INSERT INTO widgets (id, page_id, type, position, config, schedule, rule, segment_id, locales)
+		VALUES ($1, $2, $3, $4, json($5), $6, $7, $8, $9)
+		RETURNING config, created_at, updated_at
+	`
+	id := widget.ID
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	var configText string
+	err := r.db.QueryRowContext(ctx, query, id, widget.PageID, widget.Type, widget.Position, string(config), widget.Schedule, widget.Rule, widget.SegmentID, widget.Locales).
+		Scan(&configText, &widget.CreatedAt, &widget.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	widget.ID = id
+	widget.Config = json.RawMessage(configText)
+	return nil
+}
+
+// GetByID retrieves a single Widget entity by its unique identifier.
+func (r *WidgetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Widget, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	widgets, err := queryWidgets(ctx, r.db, `
+		SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
+		FROM widgets
+		WHERE id = $1
+	`, id)
+	if err != nil || len(widgets) == 0 {
+		return nil, err
+	}
+	return &widgets[0], nil
+}
+
+// GetByPageID retrieves a collection of Widget entities associated with a specific Page.
+// An optional widgetType filter can be applied to narrow the results.
+func (r *WidgetRepository) GetByPageID(ctx context.Context, pageID uuid.UUID, widgetType *string) ([]models.Widget, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if widgetType != nil && *widgetType != "" {
+		return queryWidgets(ctx, r.db, `
+			SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
+			FROM widgets
+			WHERE page_id = $1 AND type = $2
+			ORDER BY position ASC, rowid ASC
+		`, pageID, *widgetType)
+	}
+	return queryWidgets(ctx, r.db, `
+		SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
+		FROM widgets
+		WHERE page_id = $1
+		ORDER BY position ASC, rowid ASC
+	`, pageID)
+}
+
+// List retrieves widgets across pages, newest first, together with the name and route of their
+// page. Config predicates compare json_type as well as the value, so that 3 and "3" stay distinct.
+func (r *WidgetRepository) List(ctx context.Context, q repository.WidgetQuery) (repository.WidgetList, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var where []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	in := func(column string, values []interface{}) string {
+		params := make([]string, len(values))
+		for i, value := range values {
+			params[i] = arg(value)
+		}
+		return column + " IN (" + strings.Join(params, ", ") + ")"
+	}
+
+	if len(q.Types) > 0 {
+		values := make([]interface{}, len(q.Types))
+		for i, t := range q.Types {
+			values[i] = t
+		}
+		where = append(where, in("w.type", values))
+	}
+	if len(q.PageIDs) > 0 {
+		values := make([]interface{}, len(q.PageIDs))
+		for i, id := range q.PageIDs {
+			values[i] = id
+		}
+		where = append(where, in("w.page_id", values))
+	}
+	if len(q.SegmentIDs) > 0 {
+		values := make([]interface{}, len(q.SegmentIDs))
+		for i, id := range q.SegmentIDs {
+			values[i] = id
+		}
+		where = append(where, in("w.segment_id", values))
+	}
+	for _, p := range q.Config {
+		path := arg(p.JSONPath())
+		kind := "json_type(w.config, " + path + ")"
+		value := "json_extract(w.config, " + path + ")"
+		switch {
+		case p.Op == repository.ConfigOpExists:
+			where = append(where, kind+" IS NOT NULL")
+		case p.Op == repository.ConfigOpMissing:
+			where = append(where, kind+" IS NULL")
+		case p.Op.IsNumeric():
+			where = append(where, fmt.Sprintf("(%s IN ('integer', 'real') AND %s %s %s)", kind, value, p.Op, arg(p.Value)))
+		default:
+			var equals string
+			switch v := p.Value.(type) {
+			case string:
+				equals = fmt.Sprintf("%s = 'text' AND %s = %s", kind, value, arg(v))
+			case float64:
+				equals = fmt.Sprintf("%s IN ('integer', 'real') AND %s = %s", kind, value, arg(v))
+			case bool:
+				equals = fmt.Sprintf("%s = '%t'", kind, v)
+			default:
+				equals = kind + " = 'null'"
+			}
+			if p.Op == repository.ConfigOpNotEquals {
+				where = append(where, "NOT coalesce("+equals+", 0)")
+			} else {
+				where = append(where, "("+equals+")")
+			}
+		}
+	}
+	if q.After != nil {
+		where = append(where, fmt.Sprintf("(w.created_at, w.id) < (%s, %s)", arg(q.After.CreatedAt.UTC().Format(timeFormat)), arg(q.After.ID)))
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+	limit := ""
+	if q.Limit > 0 {
+		limit = "LIMIT " + arg(q.Limit+1)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT w.id, w.page_id, w.type, w.position, w.config, w.schedule, w.rule, w.segment_id, w.locales, w.created_at, w.updated_at, p.name, p.route, p.schedule, p.rule, p.segment_id
+		FROM widgets w
+		JOIN pages p ON p.id = w.page_id
+		%s
+		ORDER BY w.created_at DESC, w.id DESC
+		%s
+	`, filter, limit)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return repository.WidgetList{}, err
+	}
+	defer rows.Close()
+
+	var list repository.WidgetList
+	for rows.Next() {
+		var w models.WidgetWithPage
+		var configText string
+		if err := rows.Scan(
+			&w.ID, &w.PageID, &w.Type, &w.Position,
+			&configText, &w.Schedule, &w.Rule, &w.SegmentID, &w.Locales, &w.CreatedAt, &w.UpdatedAt, &w.PageName, &w.PageRoute, &w.PageSchedule, &w.PageRule, &w.PageSegmentID,
+		); err != nil {
+			return repository.WidgetList{}, err
+		}
+		w.Config = json.RawMessage(configText)
+		list.Widgets = append(list.Widgets, w)
+	}
+	if err := rows.Err(); err != nil {
+		return repository.WidgetList{}, err
+	}
+
+	if q.Limit > 0 && len(list.Widgets) > q.Limit {
+		list.Widgets = list.Widgets[:q.Limit]
+		last := list.Widgets[q.Limit-1]
+		list.Next = &repository.WidgetCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return list, nil
+}
+
+// GetMaxPosition determines the highest position index currently assigned to widgets on a page.
+func (r *WidgetRepository) GetMaxPosition(ctx context.Context, pageID uuid.UUID) (int, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var maxPosition int
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), 0) FROM widgets WHERE page_id = $1`, pageID).Scan(&maxPosition)
+	return maxPosition, err
+}
+
+// Update modifies an existing Widget entity with the provided field updates.
+func (r *WidgetRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Widget, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	setClauses, args, err := setClause(updates, "type", "position", "config", "schedule", "rule", "segment_id", "locales")
+	if err != nil {
+		return nil, err
+	}
+	if setClauses == "" {
+		return r.GetByID(ctx, id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf(`
+		UPDATE widgets
+		SET %s, updated_at = %s
+		WHERE id = $%d
+		RETURNING id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
+	`, setClauses, nowExpr, len(args))
+
+	widgets, err := queryWidgets(ctx, r.db, query, args...)
+	if err != nil || len(widgets) == 0 {
+		return nil, err
+	}
+	return &widgets[0], nil
+}
+
+// Delete removes a Widget entity from the data store by its ID.
+func (r *WidgetRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM widgets WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Reorder applies a new sequential order to a list of widgets within a specific page.
+func (r *WidgetRepository) Reorder(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) error {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return repository.InTx(ctx, r.db, func(tx repository.DBTX) error {
+		for i, widgetID := range widgetIDs {
+			result, err := tx.ExecContext(ctx, `UPDATE widgets SET position = $1 WHERE id = $2 AND page_id = $3`, i+1, widgetID, pageID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return fmt.Errorf("widget %s not found on page %s", widgetID, pageID)
+			}
+		}
+		return nil
+	})
+}
+
+// GetWidgetCountByPageID returns the total number of widgets associated with a specific page.
+func (r *WidgetRepository) GetWidgetCountByPageID(ctx context.Context, pageID uuid.UUID) (int, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM widgets WHERE page_id = $1`, pageID).Scan(&count)
+	return count, err
+}
+
+// CheckWidgetsBelongToPage validates that a set of widget IDs all belong to the specified page identifier.
+func (r *WidgetRepository) CheckWidgetsBelongToPage(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) (bool, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(widgetIDs) == 0 {
+		return true, nil
+	}
+
+	placeholders := make([]string, len(widgetIDs))
+	args := []interface{}{pageID}
+	for i, id := range widgetIDs {
+		args = append(args, id)
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+	}
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM widgets WHERE page_id = $1 AND id IN (%s)`, strings.Join(placeholders, ", "))
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return false, err
+	}
+	return count == len(widgetIDs), nil
+}
+
+// CountByType returns the number of widgets stored for each widget type.
+func (r *WidgetRepository) CountByType(ctx context.Context) (map[string]int, error) {
+	ctx, cancel := repository.WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT type, COUNT(*) FROM widgets GROUP BY type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var widgetType string
+		var count int
+		if err := rows.Scan(&widgetType, &count); err != nil {
+			return nil, err
+		}
+		counts[widgetType] = count
+	}
+	return counts, rows.Err()
+}
+
+// queryWidgets runs a query selecting full widget rows and scans them in order.
+func queryWidgets(ctx context.Context, db repository.DBTX, query string, args ...interface{}) ([]models.Widget, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var widgets []models.Widget
+	for rows.Next() {
+		var w models.Widget
+		var configText string
+		if err := rows.Scan(
+			&w.ID, &w.PageID, &w.Type, &w.Position,
+			&configText, &w.Schedule, &w.Rule, &w.SegmentID, &w.Locales, &w.CreatedAt, &w.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		w.Config = json.RawMessage(configText)
+		widgets = append(widgets, w)
+	}
+	return widgets, rows.Err()
+}
+
+var (
+	_ repository.PageStore       = (*PageRepository)(nil)
+	_ repository.WidgetStore     = (*WidgetRepository)(nil)
+	_ repository.SearchStore     = (*SearchRepository)(nil)
+	_ repository.AuditStore      = (*AuditRepository)(nil)
+	_ repository.SegmentStore    = (*SegmentRepository)(nil)
+	_ repository.ExperimentStore = (*ExperimentRepository)(nil)
+	_ repository.ThemeStore      = (*ThemeRepository)(nil)
+)
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrRouteConflict is returned when a page is created or updated with a route already used by another page.
+var ErrRouteConflict = errors.New("page route already exists")
+
+// PageStore defines the persistence operations available for Page entities.
+// Every operation honors the cancellation and deadline of its context.
+// Lookups return a nil page and a nil error when nothing matches, and Delete returns
+// sql.ErrNoRows when the page does not exist. Deleting a page also deletes its widgets.
+// List returns ErrInvalidCursor when q.After was issued for another sort order.
+type PageStore interface {
+	Create(ctx context.Context, page *models.Page) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Page, error)
+	GetByRoute(ctx context.Context, route string) (*models.Page, error)
+	GetHomePage(ctx context.Context) (*models.Page, error)
+	GetAll(ctx context.Context, page, perPage int) ([]models.Page, int, error)
+	List(ctx context.Context, q PageQuery) (PageList, error)
+	Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Page, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	UnsetHomePage(ctx context.Context) error
+	GetByIDWithWidgets(ctx context.Context, id uuid.UUID) (*models.Page, error)
+	CheckRouteExists(ctx context.Context, route string, excludeID *uuid.UUID) (bool, error)
+	Count(ctx context.Context) (int, error)
+}
+
+// WidgetStore defines the persistence operations available for Widget entities.
+// Every operation honors the cancellation and deadline of its context.
+// Lookups return a nil widget and a nil error when nothing matches, and Delete returns
+// sql.ErrNoRows when the widget does not exist. Widgets are listed in ascending position order.
+// Create assigns a new ID unless the widget already has one, as a promoted experiment variant does.
+type WidgetStore interface {
+	Create(ctx context.Context, widget *models.Widget) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Widget, error)
+	GetByPageID(ctx context.Context, pageID uuid.UUID, widgetType *string) ([]models.Widget, error)
+	List(ctx context.Context, q WidgetQuery) (WidgetList, error)
+	GetMaxPosition(ctx context.Context, pageID uuid.UUID) (int, error)
+	Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Widget, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Reorder(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) error
+	GetWidgetCountByPageID(ctx context.Context, pageID uuid.UUID) (int, error)
+	CheckWidgetsBelongToPage(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) (bool, error)
+	CountByType(ctx context.Context) (map[string]int, error)
+}
+
+var (
+	_ PageStore       = (*PageRepository)(nil)
+	_ WidgetStore     = (*WidgetRepository)(nil)
+	_ UnitOfWork      = (*SQLUnitOfWork)(nil)
+	_ SearchStore     = (*SearchRepository)(nil)
+	_ AuditStore      = (*AuditRepository)(nil)
+	_ SegmentStore    = (*SegmentRepository)(nil)
+	_ ExperimentStore = (*ExperimentRepository)(nil)
+	_ ThemeStore      = (*ThemeRepository)(nil)
+)
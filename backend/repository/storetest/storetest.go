@@ -0,0 +1,1258 @@
+// Package storetest provides a conformance suite that every implementation of the repository
+// stores must pass, so that all backends share the same observable behavior.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"appdrop/models"
+	"appdrop/repository"
+
+	"github.com/google/uuid"
+)
+
+// Backend is a set of stores sharing the same data, together with a unit of work and a search over that data.
+type Backend struct {
+	Pages       repository.PageStore
+	Widgets     repository.WidgetStore
+	UnitOfWork  repository.UnitOfWork
+	Search      repository.SearchStore
+	Audit       repository.AuditStore
+	Segments    repository.SegmentStore
+	Experiments repository.ExperimentStore
+	Themes      repository.ThemeStore
+}
+
+// Factory returns a fresh, empty backend for each subtest.
+type Factory func(t *testing.T) Backend
+
+// Run executes the conformance suite against the backends produced by open.
+func Run(t *testing.T, open Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore)
+	}{
+		{"PageLookups", testPageLookups},
+		{"RouteUniqueness", testRouteUniqueness},
+		{"HomePage", testHomePage},
+		{"ListNewestFirst", testListNewestFirst},
+		{"ListKeyset", testListKeyset},
+		{"ListFilters", testListFilters},
+		{"PageUpdate", testPageUpdate},
+		{"Schedules", testSchedules},
+		{"Rules", testRules},
+		{"Localization", testLocalization},
+		{"DeleteCascades", testDeleteCascades},
+		{"WidgetOrderingAndFilter", testWidgetOrderingAndFilter},
+		{"WidgetUpdate", testWidgetUpdate},
+		{"WidgetPresetID", testWidgetPresetID},
+		{"WidgetList", testWidgetList},
+		{"Reorder", testReorder},
+		{"Counts", testCounts},
+		{"CanceledContext", testCanceledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := open(t)
+			tt.fn(t, b.Pages, b.Widgets)
+		})
+	}
+
+	unitOfWorkTests := []struct {
+		name string
+		fn   func(t *testing.T, b Backend)
+	}{
+		{"UnitOfWorkCommit", testUnitOfWorkCommit},
+		{"UnitOfWorkRollback", testUnitOfWorkRollback},
+		{"Search", testSearch},
+		{"AuditLog", testAuditLog},
+		{"Segments", testSegments},
+		{"Experiments", testExperiments},
+		{"Theme", testTheme},
+	}
+	for _, tt := range unitOfWorkTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// mustCreatePage persists a page and fails the test on error.
+func mustCreatePage(t *testing.T, pages repository.PageStore, name, route string, isHome bool) *models.Page {
+	t.Helper()
+	ctx := context.Background()
+	page := &models.Page{Name: name, Route: route, IsHome: isHome}
+	if err := pages.Create(ctx, page); err != nil {
+		t.Fatalf("Create page %s: %v", route, err)
+	}
+	if page.ID == uuid.Nil || page.CreatedAt.IsZero() {
+		t.Fatalf("Expected Create to assign ID and timestamps, got %+v", page)
+	}
+	return page
+}
+
+// mustCreateWidget persists a widget and fails the test on error.
+func mustCreateWidget(t *testing.T, widgets repository.WidgetStore, pageID uuid.UUID, widgetType string, position int, config string) *models.Widget {
+	t.Helper()
+	ctx := context.Background()
+	widget := &models.Widget{PageID: pageID, Type: widgetType, Position: position}
+	if config != "" {
+		widget.Config = json.RawMessage(config)
+	}
+	if err := widgets.Create(ctx, widget); err != nil {
+		t.Fatalf("Create widget: %v", err)
+	}
+	return widget
+}
+
+// sameJSON reports whether two JSON documents are semantically equal, ignoring formatting.
+func sameJSON(t *testing.T, a, b json.RawMessage) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// widgetIDs returns the IDs of the widgets in order.
+func widgetIDs(widgets []models.Widget) []uuid.UUID {
+	ids := make([]uuid.UUID, len(widgets))
+	for i, w := range widgets {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+// testPageLookups verifies lookups by ID and route and the not-found conventions.
+func testPageLookups(t *testing.T, pages repository.PageStore, _ repository.WidgetStore) {
+	ctx := context.Background()
+	created := mustCreatePage(t, pages, "Home", "/home", false)
+
+	byID, err := pages.GetByID(ctx, created.ID)
+	if err != nil || byID == nil || byID.Route != "/home" {
+		t.Fatalf("GetByID = %+v, %v", byID, err)
+	}
+	byRoute, err := pages.GetByRoute(ctx, "/home")
+	if err != nil || byRoute == nil || byRoute.ID != created.ID {
+		t.Fatalf("GetByRoute = %+v, %v", byRoute, err)
+	}
+
+	missing, err := pages.GetByID(ctx, uuid.New())
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil, nil for unknown ID, got %+v, %v", missing, err)
+	}
+	missing, err = pages.GetByRoute(ctx, "/nowhere")
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil, nil for unknown route, got %+v, %v", missing, err)
+	}
+	if err := pages.Delete(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting unknown page, got %v", err)
+	}
+}
+
+// testRouteUniqueness verifies that a route can belong to only one page.
+func testRouteUniqueness(t *testing.T, pages repository.PageStore, _ repository.WidgetStore) {
+	ctx := context.Background()
+	first := mustCreatePage(t, pages, "Sale", "/sale", false)
+	second := mustCreatePage(t, pages, "Other", "/other", false)
+
+	if err := pages.Create(ctx, &models.Page{Name: "Dup", Route: "/sale"}); !errors.Is(err, repository.ErrRouteConflict) {
+		t.Errorf("Expected ErrRouteConflict creating duplicate route, got %v", err)
+	}
+	if _, err := pages.Update(ctx, second.ID, map[string]interface{}{"route": "/sale"}); !errors.Is(err, repository.ErrRouteConflict) {
+		t.Errorf("Expected ErrRouteConflict updating to duplicate route, got %v", err)
+	}
+
+	exists, err := pages.CheckRouteExists(ctx, "/sale", nil)
+	if err != nil || !exists {
+		t.Errorf("Expected /sale to exist, got %v, %v", exists, err)
+	}
+	exists, err = pages.CheckRouteExists(ctx, "/sale", &first.ID)
+	if err != nil || exists {
+		t.Errorf("Expected /sale to be free when excluding its own page, got %v, %v", exists, err)
+	}
+}
+
+// testHomePage verifies that the home page can be moved from one page to another.
+func testHomePage(t *testing.T, pages repository.PageStore, _ repository.WidgetStore) {
+	ctx := context.Background()
+	home, err := pages.GetHomePage(ctx)
+	if err != nil || home != nil {
+		t.Fatalf("Expected no home page initially, got %+v, %v", home, err)
+	}
+
+	old := mustCreatePage(t, pages, "Old Home", "/old", true)
+	if err := pages.UnsetHomePage(ctx); err != nil {
+		t.Fatalf("UnsetHomePage: %v", err)
+	}
+	current := mustCreatePage(t, pages, "New Home", "/new", true)
+
+	home, err = pages.GetHomePage(ctx)
+	if err != nil || home == nil || home.ID != current.ID {
+		t.Fatalf("Expected new home page, got %+v, %v", home, err)
+	}
+	previous, _ := pages.GetByID(ctx, old.ID)
+	if previous.IsHome {
+		t.Error("Expected UnsetHomePage to clear the previous home page")
+	}
+}
+
+// testListNewestFirst verifies that pages are listed newest first with offset pagination.
+func testListNewestFirst(t *testing.T, pages repository.PageStore, _ repository.WidgetStore) {
+	ctx := context.Background()
+	var routes []string
+	for _, route := range []string{"/a", "/b", "/c"} {
+		mustCreatePage(t, pages, route, route, false)
+		routes = append([]string{route}, routes...)
+	}
+
+	firstPage, total, err := pages.GetAll(ctx, 1, 2)
+	if err != nil || total != 3 || len(firstPage) != 2 {
+		t.Fatalf("GetAll(1, 2) = %d pages, total %d, %v", len(firstPage), total, err)
+	}
+	secondPage, _, err := pages.GetAll(ctx, 2, 2)
+	if err != nil || len(secondPage) != 1 {
+		t.Fatalf("GetAll(2, 2) = %d pages, %v", len(secondPage), err)
+	}
+
+	got := []string{firstPage[0].Route, firstPage[1].Route, secondPage[0].Route}
+	if !reflect.DeepEqual(got, routes) {
+		t.Errorf("Expected newest first %v, got %v", routes, got)
+	}
+
+	empty, total, err := pages.GetAll(ctx, 5, 2)
+	if err != nil || len(empty) != 0 || total != 3 {
+		t.Errorf("Expected an empty page past the end, got %d pages, total %d, %v", len(empty), total, err)
+	}
+}
+
+// testListKeyset verifies that walking a listing by cursor visits every page once, in order.
+func testListKeyset(t *testing.T, pages repository.PageStore, _ repository.WidgetStore) {
+	ctx := context.Background()
+	names := []string{"delta", "alpha", "echo", "charlie", "bravo"}
+	for _, name := range names {
+		mustCreatePage(t, pages, name, "/"+name, false)
+	}
+
+	for _, descending := range []bool{false, true} {
+		q := repository.PageQuery{Sort: repository.PageSortName, Descending: descending, Limit: 2}
+		var got []string
+		for i := 0; ; i++ {
+			list, err := pages.List(ctx, q)
+			if err != nil {
+				t.Fatalf("List(descending=%v) step %d: %v", descending, i, err)
+			}
+			for _, p := range list.Pages {
+				got = append(got, p.Name)
+			}
+			if list.Next == nil {
+				break
+			}
+			if i > len(names) {
+				t.Fatalf("List(descending=%v) did not terminate, got %v", descending, got)
+			}
+			if q.After, err = repository.DecodePageCursor(list.Next.Encode()); err != nil {
+				t.Fatalf("DecodePageCursor: %v", err)
+			}
+		}
+
+		want := []string{"alpha", "bravo", "charlie", "delta", "echo"}
+		if descending {
+			want = []string{"echo", "delta", "charlie", "bravo", "alpha"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected cursor walk (descending=%v) %v, got %v", descending, want, got)
+		}
+	}
+
+	list, err := pages.List(ctx, repository.PageQuery{Sort: repository.PageSortRoute, Limit: 1, Offset: 1, CountTotal: true})
+	if err != nil || list.Total != len(names) || len(list.Pages) != 1 || list.Pages[0].Route != "/bravo" {
+		t.Errorf("Expected offset listing to return /bravo of %d, got %+v, %v", len(names), list, err)
+	}
+
+	mismatched := &repository.PageCursor{Sort: repository.PageSortName, ID: uuid.New()}
+	if _, err := pages.List(ctx, repository.PageQuery{Sort: repository.PageSortRoute, After: mismatched}); !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a cursor of another sort, got %v", err)
+	}
+}
+
+// testListFilters verifies the listing filters and ordering by widget count.
+func testListFilters(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	home := mustCreatePage(t, pages, "Home", "/home", true)
+	sale := mustCreatePage(t, pages, "Summer Sale", "/promo/summer", false)
+	promo := mustCreatePage(t, pages, "Winter Sale", "/promo/winter", false)
+	mustCreatePage(t, pages, "About 100%", "/about_us", false)
+
+	mustCreateWidget(t, widgets, sale.ID, "banner", 1, `{}`)
+	mustCreateWidget(t, widgets, sale.ID, "text", 2, `{}`)
+	mustCreateWidget(t, widgets, home.ID, "text", 1, `{}`)
+
+	routes := func(q repository.PageQuery) []string {
+		t.Helper()
+		q.Sort = repository.PageSortRoute
+		list, err := pages.List(ctx, q)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", q, err)
+		}
+		got := []string{}
+		for _, p := range list.Pages {
+			got = append(got, p.Route)
+		}
+		return got
+	}
+
+	isHome := true
+	cases := []struct {
+		name string
+		q    repository.PageQuery
+		want []string
+	}{
+		{"is_home", repository.PageQuery{IsHome: &isHome}, []string{"/home"}},
+		{"name prefix ignores case", repository.PageQuery{NamePrefix: "summer"}, []string{"/promo/summer"}},
+		{"name prefix escapes wildcards", repository.PageQuery{NamePrefix: "About 100%"}, []string{"/about_us"}},
+		{"route prefix", repository.PageQuery{RoutePrefix: "/promo/"}, []string{"/promo/summer", "/promo/winter"}},
+		{"route prefix escapes wildcards", repository.PageQuery{RoutePrefix: "/about_"}, []string{"/about_us"}},
+		{"route prefix matches case", repository.PageQuery{RoutePrefix: "/PROMO"}, []string{}},
+		{"widget type", repository.PageQuery{WidgetType: "text"}, []string{"/home", "/promo/summer"}},
+		{"updated since", repository.PageQuery{UpdatedSince: time.Now().Add(time.Hour)}, []string{}},
+		{"combined", repository.PageQuery{RoutePrefix: "/promo", WidgetType: "banner"}, []string{"/promo/summer"}},
+	}
+	for _, tc := range cases {
+		if got := routes(tc.q); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	list, err := pages.List(ctx, repository.PageQuery{Sort: repository.PageSortWidgetCount, Descending: true, Limit: 2, RoutePrefix: "/promo", CountTotal: true})
+	if err != nil || list.Total != 2 || len(list.Pages) != 2 || list.Pages[0].ID != sale.ID || list.Pages[1].ID != promo.ID || list.Next != nil {
+		t.Errorf("Expected /promo pages by widget count descending with no next cursor, got %+v, %v", list, err)
+	}
+}
+
+// testPageUpdate verifies partial page updates by column name.
+func testPageUpdate(t *testing.T, pages repository.PageStore, _ repository.WidgetStore) {
+	ctx := context.Background()
+	page := mustCreatePage(t, pages, "Before", "/before", false)
+
+	updated, err := pages.Update(ctx, page.ID, map[string]interface{}{"name": "After", "is_home": true})
+	if err != nil || updated == nil {
+		t.Fatalf("Update: %+v, %v", updated, err)
+	}
+	if updated.Name != "After" || !updated.IsHome || updated.Route != "/before" {
+		t.Errorf("Unexpected updated page %+v", updated)
+	}
+	if updated.UpdatedAt.Before(page.UpdatedAt) {
+		t.Error("Expected updated_at not to move backwards")
+	}
+
+	missing, err := pages.Update(ctx, uuid.New(), map[string]interface{}{"name": "x"})
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil, nil updating unknown page, got %+v, %v", missing, err)
+	}
+}
+
+// testSchedules verifies that page and widget schedules round-trip through every read path,
+// keeping their timezone and weekly windows, and that updating a schedule to nil removes it.
+func testSchedules(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	var schedule models.Schedule
+	raw := `{"timezone":"America/New_York","visible_from":"2026-11-27T00:00","weekly":[{"days":["fri","sat"],"start":"18:00","end":"02:00"}]}`
+	if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+		t.Fatalf("Unmarshal schedule: %v", err)
+	}
+	saturdayNight := time.Date(2026, 11, 29, 5, 30, 0, 0, time.UTC) // 00:30 on Sunday in New York
+
+	page := &models.Page{Name: "Sale", Route: "/sale", Schedule: &schedule}
+	if err := pages.Create(ctx, page); err != nil {
+		t.Fatalf("Create page: %v", err)
+	}
+	widget := &models.Widget{PageID: page.ID, Type: "banner", Position: 1, Config: json.RawMessage(`{}`), Schedule: &schedule}
+	if err := widgets.Create(ctx, widget); err != nil {
+		t.Fatalf("Create widget: %v", err)
+	}
+	mustCreateWidget(t, widgets, page.ID, "text", 2, "")
+
+	check := func(what string, got *models.Schedule) {
+		t.Helper()
+		if got == nil || got.Timezone != "America/New_York" || got.VisibleFrom == nil || !got.VisibleFrom.Equal(*schedule.VisibleFrom) || len(got.Weekly) != 1 {
+			t.Errorf("Expected %s to keep its schedule, got %+v", what, got)
+			return
+		}
+		if !got.LiveAt(saturdayNight) || got.LiveAt(saturdayNight.Add(2*time.Hour)) {
+			t.Errorf("Expected the %s schedule to evaluate like the original", what)
+		}
+	}
+
+	stored, err := pages.GetByIDWithWidgets(ctx, page.ID)
+	if err != nil || stored == nil || len(stored.Widgets) != 2 {
+		t.Fatalf("GetByIDWithWidgets: %+v, %v", stored, err)
+	}
+	check("page", stored.Schedule)
+	check("widget on page", stored.Widgets[0].Schedule)
+	if stored.Widgets[1].Schedule != nil {
+		t.Errorf("Expected an unscheduled widget to have no schedule, got %+v", stored.Widgets[1].Schedule)
+	}
+
+	byPage, err := widgets.GetByPageID(ctx, page.ID, nil)
+	if err != nil || len(byPage) != 2 {
+		t.Fatalf("GetByPageID: %+v, %v", byPage, err)
+	}
+	check("widget by page", byPage[0].Schedule)
+
+	list, err := pages.List(ctx, repository.PageQuery{Limit: 10})
+	if err != nil || len(list.Pages) != 1 {
+		t.Fatalf("List: %+v, %v", list, err)
+	}
+	check("listed page", list.Pages[0].Schedule)
+
+	updated, err := widgets.Update(ctx, widget.ID, map[string]interface{}{"schedule": (*models.Schedule)(nil)})
+	if err != nil || updated == nil || updated.Schedule != nil {
+		t.Errorf("Expected the widget schedule to be removed, got %+v, %v", updated, err)
+	}
+	cleared, err := pages.Update(ctx, page.ID, map[string]interface{}{"schedule": (*models.Schedule)(nil)})
+	if err != nil || cleared == nil || cleared.Schedule != nil {
+		t.Errorf("Expected the page schedule to be removed, got %+v, %v", cleared, err)
+	}
+}
+
+// testLocalization verifies that default locales, localized page names and localized widget
+// configs round-trip through every read path, and that updating them to empty maps removes them.
+func testLocalization(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	page := &models.Page{Name: "Sale", Route: "/sale", DefaultLocale: "en", Names: models.PageNames{"es": "Oferta", "ar": "تخفيضات"}}
+	if err := pages.Create(ctx, page); err != nil {
+		t.Fatalf("Create page: %v", err)
+	}
+	widget := &models.Widget{
+		PageID: page.ID, Type: "banner", Position: 1, Config: json.RawMessage(`{"title":"Sale"}`),
+		Locales: models.WidgetLocales{"es": json.RawMessage(`{"title":"Oferta"}`), "es-MX": json.RawMessage(`{"style":{"color":"green"}}`)},
+	}
+	if err := widgets.Create(ctx, widget); err != nil {
+		t.Fatalf("Create widget: %v", err)
+	}
+	mustCreateWidget(t, widgets, page.ID, "text", 2, "")
+
+	checkPage := func(what string, got models.Page) {
+		t.Helper()
+		if got.DefaultLocale != "en" || len(got.Names) != 2 || got.Names["es"] != "Oferta" || got.Names["ar"] != "تخفيضات" {
+			t.Errorf("Expected the %s to keep its locales, got %q %v", what, got.DefaultLocale, got.Names)
+		}
+	}
+	checkWidget := func(what string, got models.Widget) {
+		t.Helper()
+		if len(got.Locales) != 2 {
+			t.Errorf("Expected the %s to keep its localized configs, got %v", what, got.Locales)
+			return
+		}
+		sameJSON(t, got.Locales["es"], json.RawMessage(`{"title":"Oferta"}`))
+		sameJSON(t, got.Locales["es-MX"], json.RawMessage(`{"style":{"color":"green"}}`))
+	}
+
+	stored, err := pages.GetByIDWithWidgets(ctx, page.ID)
+	if err != nil || stored == nil || len(stored.Widgets) != 2 {
+		t.Fatalf("GetByIDWithWidgets: %+v, %v", stored, err)
+	}
+	checkPage("page", *stored)
+	checkWidget("widget on page", stored.Widgets[0])
+	if stored.Widgets[1].Locales != nil {
+		t.Errorf("Expected an unlocalized widget to have no localized configs, got %v", stored.Widgets[1].Locales)
+	}
+
+	list, err := pages.List(ctx, repository.PageQuery{Limit: 10})
+	if err != nil || len(list.Pages) != 1 {
+		t.Fatalf("List: %+v, %v", list, err)
+	}
+	checkPage("listed page", list.Pages[0])
+
+	byID, err := widgets.GetByID(ctx, widget.ID)
+	if err != nil || byID == nil {
+		t.Fatalf("GetByID: %+v, %v", byID, err)
+	}
+	checkWidget("widget", *byID)
+
+	listed, err := widgets.List(ctx, repository.WidgetQuery{Types: []string{"banner"}, Limit: 10})
+	if err != nil || len(listed.Widgets) != 1 {
+		t.Fatalf("List widgets: %+v, %v", listed, err)
+	}
+	checkWidget("listed widget", listed.Widgets[0].Widget)
+
+	updated, err := pages.Update(ctx, page.ID, map[string]interface{}{"default_locale": "es", "names": models.PageNames{}})
+	if err != nil || updated == nil || updated.DefaultLocale != "es" || updated.Names != nil {
+		t.Errorf("Expected the page names to be removed, got %+v, %v", updated, err)
+	}
+	cleared, err := widgets.Update(ctx, widget.ID, map[string]interface{}{"locales": models.WidgetLocales{}})
+	if err != nil || cleared == nil || cleared.Locales != nil {
+		t.Errorf("Expected the localized configs to be removed, got %+v, %v", cleared, err)
+	}
+}
+
+// testRules verifies that page and widget audience rules round-trip through every read path,
+// including the page rule joined into cross-page widget listings, and can be cleared.
+func testRules(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	pageRule := `platform == "ios"`
+	widgetRule := `app_version >= "3.2" && country in ["US","CA"]`
+
+	page := &models.Page{Name: "Launch", Route: "/launch", Rule: pageRule}
+	if err := pages.Create(ctx, page); err != nil {
+		t.Fatalf("Create page: %v", err)
+	}
+	widget := &models.Widget{PageID: page.ID, Type: "banner", Position: 1, Config: json.RawMessage(`{}`), Rule: widgetRule}
+	if err := widgets.Create(ctx, widget); err != nil {
+		t.Fatalf("Create widget: %v", err)
+	}
+
+	stored, err := pages.GetByIDWithWidgets(ctx, page.ID)
+	if err != nil || stored == nil || len(stored.Widgets) != 1 {
+		t.Fatalf("GetByIDWithWidgets: %+v, %v", stored, err)
+	}
+	if stored.Rule != pageRule || stored.Widgets[0].Rule != widgetRule {
+		t.Errorf("Expected the rules to round-trip, got %q and %q", stored.Rule, stored.Widgets[0].Rule)
+	}
+
+	list, err := widgets.List(ctx, repository.WidgetQuery{Limit: 10})
+	if err != nil || len(list.Widgets) != 1 {
+		t.Fatalf("List: %+v, %v", list, err)
+	}
+	if list.Widgets[0].Rule != widgetRule || list.Widgets[0].PageRule != pageRule {
+		t.Errorf("Expected the listed widget to carry both rules, got %q and %q", list.Widgets[0].Rule, list.Widgets[0].PageRule)
+	}
+
+	updated, err := widgets.Update(ctx, widget.ID, map[string]interface{}{"rule": ""})
+	if err != nil || updated == nil || updated.Rule != "" {
+		t.Errorf("Expected the widget rule to be removed, got %+v, %v", updated, err)
+	}
+	cleared, err := pages.Update(ctx, page.ID, map[string]interface{}{"rule": "new_user"})
+	if err != nil || cleared == nil || cleared.Rule != "new_user" {
+		t.Errorf("Expected the page rule to be replaced, got %+v, %v", cleared, err)
+	}
+}
+
+// testDeleteCascades verifies that deleting a page deletes its widgets and nothing else.
+func testDeleteCascades(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	page := mustCreatePage(t, pages, "Doomed", "/doomed", false)
+	keep := mustCreatePage(t, pages, "Kept", "/kept", false)
+	doomed := mustCreateWidget(t, widgets, page.ID, "text", 1, `{"content":"bye"}`)
+	kept := mustCreateWidget(t, widgets, keep.ID, "text", 1, "")
+
+	if err := pages.Delete(ctx, page.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if w, err := widgets.GetByID(ctx, doomed.ID); err != nil || w != nil {
+		t.Errorf("Expected widget to be deleted with its page, got %+v, %v", w, err)
+	}
+	if w, err := widgets.GetByID(ctx, kept.ID); err != nil || w == nil {
+		t.Errorf("Expected widget on another page to survive, got %+v, %v", w, err)
+	}
+	if err := widgets.Delete(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting unknown widget, got %v", err)
+	}
+}
+
+// testWidgetOrderingAndFilter verifies position ordering, type filtering and config round-tripping.
+func testWidgetOrderingAndFilter(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	page := mustCreatePage(t, pages, "Home", "/home", false)
+	third := mustCreateWidget(t, widgets, page.ID, "text", 3, "")
+	first := mustCreateWidget(t, widgets, page.ID, "banner", 1, `{"title": "Hi"}`)
+	second := mustCreateWidget(t, widgets, page.ID, "text", 2, "")
+
+	all, err := widgets.GetByPageID(ctx, page.ID, nil)
+	if err != nil {
+		t.Fatalf("GetByPageID: %v", err)
+	}
+	if want := []uuid.UUID{first.ID, second.ID, third.ID}; !reflect.DeepEqual(widgetIDs(all), want) {
+		t.Errorf("Expected widgets in position order")
+	}
+	if !sameJSON(t, all[0].Config, json.RawMessage(`{"title":"Hi"}`)) || !sameJSON(t, all[1].Config, json.RawMessage(`{}`)) {
+		t.Errorf("Unexpected configs %s and %s", all[0].Config, all[1].Config)
+	}
+
+	textType := "text"
+	texts, err := widgets.GetByPageID(ctx, page.ID, &textType)
+	if err != nil || !reflect.DeepEqual(widgetIDs(texts), []uuid.UUID{second.ID, third.ID}) {
+		t.Errorf("Expected text widgets only, got %d, %v", len(texts), err)
+	}
+
+	withWidgets, err := pages.GetByIDWithWidgets(ctx, page.ID)
+	if err != nil || withWidgets == nil || len(withWidgets.Widgets) != 3 {
+		t.Fatalf("GetByIDWithWidgets = %+v, %v", withWidgets, err)
+	}
+
+	maxPosition, err := widgets.GetMaxPosition(ctx, page.ID)
+	if err != nil || maxPosition != 3 {
+		t.Errorf("Expected max position 3, got %d, %v", maxPosition, err)
+	}
+	maxPosition, err = widgets.GetMaxPosition(ctx, uuid.New())
+	if err != nil || maxPosition != 0 {
+		t.Errorf("Expected max position 0 for an empty page, got %d, %v", maxPosition, err)
+	}
+}
+
+// testWidgetPresetID verifies that Create keeps an ID the widget already has.
+func testWidgetPresetID(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	page := mustCreatePage(t, pages, "Home", "/home", false)
+	id := uuid.New()
+	widget := &models.Widget{ID: id, PageID: page.ID, Type: "text", Position: 1}
+	if err := widgets.Create(ctx, widget); err != nil || widget.ID != id {
+		t.Fatalf("Expected Create to keep ID %s, got %s, %v", id, widget.ID, err)
+	}
+	got, err := widgets.GetByID(ctx, id)
+	if err != nil || got == nil || got.PageID != page.ID {
+		t.Errorf("Expected the widget to be stored under its ID, got %+v, %v", got, err)
+	}
+	if err := widgets.Create(ctx, &models.Widget{ID: id, PageID: page.ID, Type: "text", Position: 2}); err == nil {
+		t.Error("Expected Create to fail for an ID that is already taken")
+	}
+}
+
+// testWidgetUpdate verifies partial widget updates by column name.
+func testWidgetUpdate(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	page := mustCreatePage(t, pages, "Home", "/home", false)
+	widget := mustCreateWidget(t, widgets, page.ID, "text", 1, `{"content":"a"}`)
+
+	updated, err := widgets.Update(ctx, widget.ID, map[string]interface{}{
+		"type":     "banner",
+		"position": 7,
+		"config":   json.RawMessage(`{"title":"b"}`),
+	})
+	if err != nil || updated == nil {
+		t.Fatalf("Update: %+v, %v", updated, err)
+	}
+	if updated.Type != "banner" || updated.Position != 7 || !sameJSON(t, updated.Config, json.RawMessage(`{"title":"b"}`)) {
+		t.Errorf("Unexpected updated widget %+v", updated)
+	}
+
+	missing, err := widgets.Update(ctx, uuid.New(), map[string]interface{}{"position": 1})
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil, nil updating unknown widget, got %+v, %v", missing, err)
+	}
+}
+
+// testWidgetList verifies cross-page widget listings with type, page and config filters and cursor pagination.
+func testWidgetList(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	a := mustCreatePage(t, pages, "A", "/a", false)
+	b := mustCreatePage(t, pages, "B", "/b", false)
+	banner := mustCreateWidget(t, widgets, a.ID, "banner", 1, `{"link":"/sale","columns":3,"meta":{"tag":"x"}}`)
+	text := mustCreateWidget(t, widgets, a.ID, "text", 2, `{"content":"hi","columns":"3"}`)
+	grid := mustCreateWidget(t, widgets, b.ID, "product_grid", 1, `{"columns":4}`)
+	spacer := mustCreateWidget(t, widgets, b.ID, "spacer", 2, `{}`)
+
+	predicates := func(filters ...string) []repository.ConfigPredicate {
+		t.Helper()
+		var out []repository.ConfigPredicate
+		for _, filter := range filters {
+			p, err := repository.ParseConfigPredicate(filter)
+			if err != nil {
+				t.Fatalf("ParseConfigPredicate(%q): %v", filter, err)
+			}
+			out = append(out, p)
+		}
+		return out
+	}
+
+	cases := []struct {
+		name string
+		q    repository.WidgetQuery
+		want []uuid.UUID
+	}{
+		{"types", repository.WidgetQuery{Types: []string{"banner", "product_grid"}}, []uuid.UUID{banner.ID, grid.ID}},
+		{"page ids", repository.WidgetQuery{PageIDs: []uuid.UUID{b.ID}}, []uuid.UUID{grid.ID, spacer.ID}},
+		{"string equals", repository.WidgetQuery{Config: predicates("link=/sale")}, []uuid.UUID{banner.ID}},
+		{"number equals", repository.WidgetQuery{Config: predicates("columns=3")}, []uuid.UUID{banner.ID}},
+		{"quoted string equals", repository.WidgetQuery{Config: predicates(`columns="3"`)}, []uuid.UUID{text.ID}},
+		{"not equals", repository.WidgetQuery{Config: predicates("columns!=3")}, []uuid.UUID{text.ID, grid.ID, spacer.ID}},
+		{"numeric range skips strings", repository.WidgetQuery{Config: predicates("columns>=3")}, []uuid.UUID{banner.ID, grid.ID}},
+		{"nested exists", repository.WidgetQuery{Config: predicates("meta.tag")}, []uuid.UUID{banner.ID}},
+		{"missing", repository.WidgetQuery{Config: predicates("!link")}, []uuid.UUID{text.ID, grid.ID, spacer.ID}},
+		{"combined", repository.WidgetQuery{Types: []string{"banner", "product_grid"}, Config: predicates("columns>3")}, []uuid.UUID{grid.ID}},
+	}
+	for _, tc := range cases {
+		list, err := widgets.List(ctx, tc.q)
+		if err != nil {
+			t.Fatalf("%s: List: %v", tc.name, err)
+		}
+		got := make(map[uuid.UUID]bool)
+		for _, w := range list.Widgets {
+			got[w.ID] = true
+		}
+		want := make(map[uuid.UUID]bool)
+		for _, id := range tc.want {
+			want[id] = true
+		}
+		if !reflect.DeepEqual(got, want) || list.Next != nil {
+			t.Errorf("%s: expected %v, got %+v", tc.name, tc.want, list)
+		}
+	}
+
+	all, err := widgets.List(ctx, repository.WidgetQuery{})
+	if err != nil || len(all.Widgets) != 4 {
+		t.Fatalf("Expected all 4 widgets, got %+v, %v", all, err)
+	}
+	for _, w := range all.Widgets {
+		if (w.PageID == a.ID && w.PageName != "A") || (w.PageID == b.ID && w.PageRoute != "/b") {
+			t.Errorf("Expected widget %s to carry its page name and route, got %q %q", w.ID, w.PageName, w.PageRoute)
+		}
+	}
+
+	q := repository.WidgetQuery{Limit: 3}
+	var walked []uuid.UUID
+	for i := 0; i < 4; i++ {
+		list, err := widgets.List(ctx, q)
+		if err != nil {
+			t.Fatalf("List step %d: %v", i, err)
+		}
+		for _, w := range list.Widgets {
+			walked = append(walked, w.ID)
+		}
+		if list.Next == nil {
+			break
+		}
+		if q.After, err = repository.DecodeWidgetCursor(list.Next.Encode()); err != nil {
+			t.Fatalf("DecodeWidgetCursor: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(walked, widgetIDs(withoutPages(all.Widgets))) {
+		t.Errorf("Expected the cursor walk to match the full listing %v, got %v", widgetIDs(withoutPages(all.Widgets)), walked)
+	}
+}
+
+// withoutPages strips the page details from a cross-page widget listing.
+func withoutPages(listed []models.WidgetWithPage) []models.Widget {
+	widgets := make([]models.Widget, len(listed))
+	for i, w := range listed {
+		widgets[i] = w.Widget
+	}
+	return widgets
+}
+
+// testReorder verifies that reordering is all-or-nothing and limited to the page's own widgets.
+func testReorder(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	page := mustCreatePage(t, pages, "Home", "/home", false)
+	other := mustCreatePage(t, pages, "Other", "/other", false)
+	a := mustCreateWidget(t, widgets, page.ID, "text", 1, "")
+	b := mustCreateWidget(t, widgets, page.ID, "text", 2, "")
+	foreign := mustCreateWidget(t, widgets, other.ID, "text", 1, "")
+
+	if err := widgets.Reorder(ctx, page.ID, []uuid.UUID{b.ID, a.ID}); err != nil {
+		t.Fatalf("Reorder: %v", err)
+	}
+	ordered, _ := widgets.GetByPageID(ctx, page.ID, nil)
+	if !reflect.DeepEqual(widgetIDs(ordered), []uuid.UUID{b.ID, a.ID}) || ordered[0].Position != 1 {
+		t.Errorf("Expected b then a after reorder")
+	}
+
+	if err := widgets.Reorder(ctx, page.ID, []uuid.UUID{a.ID, foreign.ID}); err == nil {
+		t.Error("Expected reorder with a widget from another page to fail")
+	}
+	ordered, _ = widgets.GetByPageID(ctx, page.ID, nil)
+	if !reflect.DeepEqual(widgetIDs(ordered), []uuid.UUID{b.ID, a.ID}) {
+		t.Error("Expected a failed reorder to leave positions unchanged")
+	}
+
+	belong, err := widgets.CheckWidgetsBelongToPage(ctx, page.ID, []uuid.UUID{a.ID, b.ID})
+	if err != nil || !belong {
+		t.Errorf("Expected widgets to belong to page, got %v, %v", belong, err)
+	}
+	belong, err = widgets.CheckWidgetsBelongToPage(ctx, page.ID, []uuid.UUID{a.ID, foreign.ID})
+	if err != nil || belong {
+		t.Errorf("Expected foreign widget to be rejected, got %v, %v", belong, err)
+	}
+}
+
+// testCounts verifies the page and widget counters used by metrics and reordering.
+func testCounts(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	ctx := context.Background()
+	page := mustCreatePage(t, pages, "Home", "/home", false)
+	mustCreatePage(t, pages, "Other", "/other", false)
+	mustCreateWidget(t, widgets, page.ID, "text", 1, "")
+	mustCreateWidget(t, widgets, page.ID, "text", 2, "")
+	mustCreateWidget(t, widgets, page.ID, "spacer", 3, "")
+
+	if n, err := pages.Count(ctx); err != nil || n != 2 {
+		t.Errorf("Expected 2 pages, got %d, %v", n, err)
+	}
+	if n, err := widgets.GetWidgetCountByPageID(ctx, page.ID); err != nil || n != 3 {
+		t.Errorf("Expected 3 widgets on page, got %d, %v", n, err)
+	}
+	counts, err := widgets.CountByType(ctx)
+	if err != nil || !reflect.DeepEqual(counts, map[string]int{"text": 2, "spacer": 1}) {
+		t.Errorf("Unexpected counts by type %v, %v", counts, err)
+	}
+}
+
+// testCanceledContext verifies that operations fail with the context's error once it is canceled
+// and leave the stored data untouched.
+func testCanceledContext(t *testing.T, pages repository.PageStore, widgets repository.WidgetStore) {
+	page := mustCreatePage(t, pages, "Home", "/home", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := pages.GetByID(ctx, page.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetByID, got %v", err)
+	}
+	if err := pages.Create(ctx, &models.Page{Name: "Late", Route: "/late"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Create, got %v", err)
+	}
+	if _, err := widgets.GetByPageID(ctx, page.ID, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetByPageID, got %v", err)
+	}
+	if n, err := pages.Count(context.Background()); err != nil || n != 1 {
+		t.Errorf("Expected the canceled Create to store nothing, got %d pages (%v)", n, err)
+	}
+}
+
+// testUnitOfWorkCommit verifies that changes made through a unit of work, including a nested
+// multi-statement reorder, are visible through the plain stores once it commits.
+func testUnitOfWorkCommit(t *testing.T, b Backend) {
+	ctx := context.Background()
+	var page *models.Page
+	err := b.UnitOfWork.Do(ctx, func(ctx context.Context, tx repository.Stores) error {
+		page = &models.Page{Name: "Home", Route: "/home", IsHome: true}
+		if err := tx.Pages.Create(ctx, page); err != nil {
+			return err
+		}
+		first := &models.Widget{PageID: page.ID, Type: "text", Position: 1}
+		second := &models.Widget{PageID: page.ID, Type: "image", Position: 2}
+		if err := tx.Widgets.Create(ctx, first); err != nil {
+			return err
+		}
+		if err := tx.Widgets.Create(ctx, second); err != nil {
+			return err
+		}
+		return tx.Widgets.Reorder(ctx, page.ID, []uuid.UUID{second.ID, first.ID})
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	stored, err := b.Pages.GetByIDWithWidgets(ctx, page.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("Expected committed page, got %v (%v)", stored, err)
+	}
+	if len(stored.Widgets) != 2 || stored.Widgets[0].Type != "image" {
+		t.Errorf("Expected committed widgets in reordered order, got %+v", stored.Widgets)
+	}
+}
+
+// testUnitOfWorkRollback verifies that a failing unit of work returns its error and leaves no trace.
+func testUnitOfWorkRollback(t *testing.T, b Backend) {
+	ctx := context.Background()
+	existing := mustCreatePage(t, b.Pages, "Home", "/home", true)
+	errAbort := errors.New("abort")
+
+	err := b.UnitOfWork.Do(ctx, func(ctx context.Context, tx repository.Stores) error {
+		if err := tx.Pages.UnsetHomePage(ctx); err != nil {
+			return err
+		}
+		if err := tx.Pages.Create(ctx, &models.Page{Name: "Sale", Route: "/sale", IsHome: true}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the unit of work to return its error, got %v", err)
+	}
+
+	if page, err := b.Pages.GetByRoute(ctx, "/sale"); err != nil || page != nil {
+		t.Errorf("Expected rolled back page to be absent, got %v (%v)", page, err)
+	}
+	if home, err := b.Pages.GetHomePage(ctx); err != nil || home == nil || home.ID != existing.ID {
+		t.Errorf("Expected the original home page to survive the rollback, got %v (%v)", home, err)
+	}
+}
+
+// testSearch verifies that search finds pages by name and route and widgets by their text fields,
+// naming the matched widget and its page, that every backend reads web search syntax (whole words,
+// phrases, "or" and "-term") alike, and that snippets are HTML-escaped around their highlights.
+func testSearch(t *testing.T, b Backend) {
+	ctx := context.Background()
+	sale := mustCreatePage(t, b.Pages, "Black Friday", "/black-friday", false)
+	home := mustCreatePage(t, b.Pages, "Home", "/home", true)
+	banner := mustCreateWidget(t, b.Widgets, sale.ID, "banner", 1, `{"title":"Friday deals","image_url":"https://cdn.example.com/doorbuster.png"}`)
+	mustCreateWidget(t, b.Widgets, home.ID, "text", 1, `{"content":"Welcome to the store"}`)
+
+	hits, err := b.Search.Search(ctx, repository.SearchQuery{Text: "friday", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("Expected a page hit and a widget hit for friday, got %+v", hits)
+	}
+	if hits[0].Kind != models.SearchKindPage || hits[0].PageID != sale.ID {
+		t.Errorf("Expected the page named Black Friday to rank first, got %+v", hits[0])
+	}
+	widgetHit := hits[1]
+	if widgetHit.Kind != models.SearchKindWidget || widgetHit.WidgetID == nil || *widgetHit.WidgetID != banner.ID ||
+		widgetHit.WidgetType != "banner" || widgetHit.PageID != sale.ID || widgetHit.PageRoute != "/black-friday" {
+		t.Errorf("Expected a hit on the banner of Black Friday, got %+v", widgetHit)
+	}
+	for _, hit := range hits {
+		if !strings.Contains(strings.ToLower(hit.Snippet), "<mark>friday</mark>") || hit.Rank <= 0 {
+			t.Errorf("Expected a ranked, highlighted snippet, got %+v", hit)
+		}
+	}
+
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"welcome store", 1},
+		{"welcome friday", 0},
+		{"doorbuster", 0},
+		{"HOME", 1},
+		{"fri", 0},
+		{`"black friday"`, 1},
+		{`"friday deals"`, 1},
+		{`"deals friday"`, 0},
+		{"welcome or deals", 2},
+		{"friday -deals", 1},
+		{"-friday", 2},
+		{`friday -"black friday"`, 1},
+	}
+	for _, tc := range cases {
+		hits, err := b.Search.Search(ctx, repository.SearchQuery{Text: tc.text, Limit: 10})
+		if err != nil || len(hits) != tc.want {
+			t.Errorf("Search(%q) = %+v, %v; expected %d hits", tc.text, hits, err, tc.want)
+		}
+	}
+
+	limited, err := b.Search.Search(ctx, repository.SearchQuery{Text: "friday", Limit: 1})
+	if err != nil || len(limited) != 1 {
+		t.Errorf("Expected Limit to cap the hits at 1, got %+v, %v", limited, err)
+	}
+	mustCreateWidget(t, b.Widgets, home.ID, "text", 2, `{"caption":"Cats & dogs > birds"}`)
+	escaped, err := b.Search.Search(ctx, repository.SearchQuery{Text: "dogs", Limit: 10})
+	if err != nil || len(escaped) != 1 {
+		t.Fatalf("Expected a hit for dogs, got %+v, %v", escaped, err)
+	}
+	if snippet := escaped[0].Snippet; !strings.Contains(snippet, "&amp; <mark>dogs</mark> &gt;") {
+		t.Errorf("Expected an HTML-escaped snippet, got %q", snippet)
+	}
+}
+
+// testAuditLog verifies that audit entries are listed newest first with their details, and that an
+// entry recorded inside a unit of work that fails is rolled back with it.
+func testAuditLog(t *testing.T, b Backend) {
+	ctx := context.Background()
+	first := &models.AuditEntry{Action: "pages.first", Actor: "key:a", RequestID: "req-1", Details: json.RawMessage(`{"count":1}`)}
+	if err := b.Audit.Record(ctx, first); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if first.ID == uuid.Nil || first.CreatedAt.IsZero() {
+		t.Errorf("Expected Record to assign an ID and creation time, got %+v", first)
+	}
+
+	err := b.UnitOfWork.Do(ctx, func(ctx context.Context, tx repository.Stores) error {
+		return tx.Audit.Record(ctx, &models.AuditEntry{Action: "pages.second", Actor: "key:b"})
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	err = b.UnitOfWork.Do(ctx, func(ctx context.Context, tx repository.Stores) error {
+		if err := tx.Audit.Record(ctx, &models.AuditEntry{Action: "pages.aborted", Actor: "key:c"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the unit of work to return its error, got %v", err)
+	}
+
+	entries, err := b.Audit.List(ctx, 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != "pages.second" || entries[1].Action != "pages.first" {
+		t.Fatalf("Expected the two committed entries newest first, got %+v", entries)
+	}
+	got := entries[1]
+	if got.ID != first.ID || got.Actor != "key:a" || got.RequestID != "req-1" {
+		t.Errorf("Expected the first entry to round-trip, got %+v", got)
+	}
+	var details map[string]int
+	if err := json.Unmarshal(got.Details, &details); err != nil || details["count"] != 1 {
+		t.Errorf("Expected details to round-trip, got %s (%v)", got.Details, err)
+	}
+	if string(entries[0].Details) == "" {
+		t.Error("Expected missing details to be stored as an empty object")
+	}
+
+	if limited, err := b.Audit.List(ctx, 1); err != nil || len(limited) != 1 || limited[0].Action != "pages.second" {
+		t.Errorf("Expected the limit to keep the newest entry, got %+v (%v)", limited, err)
+	}
+}
+
+// testSegments verifies segment CRUD, user list replacement and membership lookups, the segment
+// references of pages and widgets, the widget listing filter on them, and that a referenced segment
+// cannot be deleted.
+func testSegments(t *testing.T, b Backend) {
+	ctx := context.Background()
+	vip := &models.Segment{Name: "VIP", Rule: `country == "US"`}
+	if err := b.Segments.Create(ctx, vip); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	beta := &models.Segment{Name: "Beta"}
+	if err := b.Segments.Create(ctx, beta); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if vip.ID == uuid.Nil || vip.CreatedAt.IsZero() || vip.UserCount != 0 {
+		t.Errorf("Expected Create to assign an ID and timestamps, got %+v", vip)
+	}
+
+	if err := b.Segments.SetUsers(ctx, beta.ID, []string{"u1", "u2", "u2", "u3"}); err != nil {
+		t.Fatalf("SetUsers: %v", err)
+	}
+	if err := b.Segments.SetUsers(ctx, beta.ID, []string{"u2", "u4"}); err != nil {
+		t.Fatalf("SetUsers: %v", err)
+	}
+	if err := b.Segments.SetUsers(ctx, uuid.New(), []string{"u1"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows setting the users of an unknown segment, got %v", err)
+	}
+	if got, err := b.Segments.GetByID(ctx, beta.ID); err != nil || got == nil || got.UserCount != 2 {
+		t.Errorf("Expected the replaced list to hold 2 users, got %+v, %v", got, err)
+	}
+
+	memberships, err := b.Segments.Memberships(ctx, []uuid.UUID{vip.ID, beta.ID, uuid.New()}, "u4")
+	if err != nil || len(memberships) != 2 {
+		t.Fatalf("Memberships: %+v, %v", memberships, err)
+	}
+	for _, m := range memberships {
+		switch m.SegmentID {
+		case vip.ID:
+			if m.Rule != vip.Rule || m.HasUsers || m.Listed {
+				t.Errorf("Expected the rule-only segment without a list, got %+v", m)
+			}
+		case beta.ID:
+			if !m.HasUsers || !m.Listed {
+				t.Errorf("Expected u4 to be listed in the beta segment, got %+v", m)
+			}
+		default:
+			t.Errorf("Unexpected membership %+v", m)
+		}
+	}
+	if m, err := b.Segments.Memberships(ctx, []uuid.UUID{beta.ID}, "u1"); err != nil || len(m) != 1 || m[0].Listed {
+		t.Errorf("Expected u1 to be dropped from the replaced list, got %+v, %v", m, err)
+	}
+
+	renamed, err := b.Segments.Update(ctx, vip.ID, map[string]interface{}{"name": "Big spenders"})
+	if err != nil || renamed == nil || renamed.Name != "Big spenders" || renamed.Rule != vip.Rule {
+		t.Errorf("Expected the segment to be renamed, got %+v, %v", renamed, err)
+	}
+	list, err := b.Segments.List(ctx)
+	if err != nil || len(list) != 2 || list[0].ID != beta.ID {
+		t.Errorf("Expected segments ordered by name, got %+v, %v", list, err)
+	}
+
+	page := &models.Page{Name: "Members", Route: "/members", SegmentID: &vip.ID}
+	if err := b.Pages.Create(ctx, page); err != nil {
+		t.Fatalf("Create page: %v", err)
+	}
+	widget := &models.Widget{PageID: page.ID, Type: "banner", Position: 1, Config: json.RawMessage(`{}`), SegmentID: &beta.ID}
+	if err := b.Widgets.Create(ctx, widget); err != nil {
+		t.Fatalf("Create widget: %v", err)
+	}
+	mustCreateWidget(t, b.Widgets, page.ID, "text", 2, "")
+
+	stored, err := b.Pages.GetByIDWithWidgets(ctx, page.ID)
+	if err != nil || stored == nil || stored.SegmentID == nil || *stored.SegmentID != vip.ID {
+		t.Fatalf("Expected the page to reference its segment, got %+v, %v", stored, err)
+	}
+	if len(stored.Widgets) != 2 || stored.Widgets[0].SegmentID == nil || *stored.Widgets[0].SegmentID != beta.ID || stored.Widgets[1].SegmentID != nil {
+		t.Errorf("Expected only the banner to reference a segment, got %+v", stored.Widgets)
+	}
+
+	referencing, err := b.Widgets.List(ctx, repository.WidgetQuery{SegmentIDs: []uuid.UUID{beta.ID}})
+	if err != nil || len(referencing.Widgets) != 1 || referencing.Widgets[0].ID != widget.ID {
+		t.Fatalf("Expected the listing to keep the widget referencing the segment, got %+v, %v", referencing, err)
+	}
+	if got := referencing.Widgets[0].PageSegmentID; got == nil || *got != vip.ID {
+		t.Errorf("Expected the listed widget to carry its page's segment, got %v", got)
+	}
+
+	if pages, widgets, err := b.Segments.References(ctx, vip.ID); err != nil || pages != 1 || widgets != 0 {
+		t.Errorf("Expected one page referencing the VIP segment, got %d pages, %d widgets, %v", pages, widgets, err)
+	}
+	if err := b.Segments.Delete(ctx, beta.ID); !errors.Is(err, repository.ErrSegmentInUse) {
+		t.Errorf("Expected ErrSegmentInUse deleting a referenced segment, got %v", err)
+	}
+
+	if _, err := b.Widgets.Update(ctx, widget.ID, map[string]interface{}{"segment_id": (*uuid.UUID)(nil)}); err != nil {
+		t.Fatalf("Update widget: %v", err)
+	}
+	if err := b.Segments.Delete(ctx, beta.ID); err != nil {
+		t.Errorf("Expected the unreferenced segment to be deleted, got %v", err)
+	}
+	if err := b.Segments.Delete(ctx, beta.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting it again, got %v", err)
+	}
+	if m, err := b.Segments.Memberships(ctx, []uuid.UUID{beta.ID}, "u4"); err != nil || len(m) != 0 {
+		t.Errorf("Expected no membership for a deleted segment, got %+v, %v", m, err)
+	}
+}
+
+// testExperiments verifies experiment CRUD with variants stored and read back intact, the single
+// running experiment per page, and that deleting a page deletes its experiments.
+func testExperiments(t *testing.T, b Backend) {
+	ctx := context.Background()
+	page := mustCreatePage(t, b.Pages, "Home", "/home", false)
+	grid := mustCreateWidget(t, b.Widgets, page.ID, "product_grid", 1, `{"columns":2}`)
+
+	variants := models.Variants{
+		{Key: "two", Weight: 50},
+		{Key: "three", Weight: 50, Overrides: map[uuid.UUID]json.RawMessage{grid.ID: json.RawMessage(`{"columns":3}`)}},
+	}
+	first := &models.Experiment{PageID: page.ID, Name: "Grid columns", Status: models.ExperimentDraft, Variants: variants}
+	if err := b.Experiments.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if first.ID == uuid.Nil || first.CreatedAt.IsZero() {
+		t.Errorf("Expected Create to assign an ID and timestamps, got %+v", first)
+	}
+	second := &models.Experiment{PageID: page.ID, Name: "Banner copy", Status: models.ExperimentDraft, Variants: models.Variants{
+		{Key: "control", Weight: 1},
+		{Key: "bold", Weight: 1, Widgets: []models.VariantWidget{{ID: uuid.New(), Type: "text", Config: json.RawMessage(`{"text":"Sale"}`)}}},
+	}}
+	if err := b.Experiments.Create(ctx, second); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := b.Experiments.GetByID(ctx, first.ID)
+	if err != nil || got == nil || got.Status != models.ExperimentDraft || len(got.Variants) != 2 {
+		t.Fatalf("GetByID: %+v, %v", got, err)
+	}
+	if patch := got.Variants[1].Overrides[grid.ID]; !sameJSON(t, patch, json.RawMessage(`{"columns":3}`)) {
+		t.Errorf("Expected the override to round-trip, got %s", patch)
+	}
+	if missing, err := b.Experiments.GetByID(ctx, uuid.New()); err != nil || missing != nil {
+		t.Errorf("Expected nil for an unknown experiment, got %+v, %v", missing, err)
+	}
+
+	list, err := b.Experiments.ListByPage(ctx, page.ID)
+	if err != nil || len(list) != 2 || list[0].ID != second.ID {
+		t.Errorf("Expected experiments newest first, got %+v, %v", list, err)
+	}
+
+	if running, err := b.Experiments.Running(ctx, page.ID); err != nil || running != nil {
+		t.Errorf("Expected no running experiment yet, got %+v, %v", running, err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	started, err := b.Experiments.Update(ctx, first.ID, map[string]interface{}{"status": models.ExperimentRunning, "started_at": &now})
+	if err != nil || started == nil || started.Status != models.ExperimentRunning || started.StartedAt == nil || !started.StartedAt.Equal(now) {
+		t.Fatalf("Expected the experiment to start, got %+v, %v", started, err)
+	}
+	if running, err := b.Experiments.Running(ctx, page.ID); err != nil || running == nil || running.ID != first.ID {
+		t.Errorf("Expected the started experiment to be running, got %+v, %v", running, err)
+	}
+	if _, err := b.Experiments.Update(ctx, second.ID, map[string]interface{}{"status": models.ExperimentRunning}); !errors.Is(err, repository.ErrExperimentRunning) {
+		t.Errorf("Expected ErrExperimentRunning starting a second experiment, got %v", err)
+	}
+
+	updated, err := b.Experiments.Update(ctx, first.ID, map[string]interface{}{
+		"status": models.ExperimentPromoted, "winner": "three", "stopped_at": &now, "variants": variants[1:],
+	})
+	if err != nil || updated == nil || updated.Winner != "three" || updated.StoppedAt == nil || len(updated.Variants) != 1 {
+		t.Errorf("Expected the experiment to be promoted, got %+v, %v", updated, err)
+	}
+	if missing, err := b.Experiments.Update(ctx, uuid.New(), map[string]interface{}{"name": "x"}); err != nil || missing != nil {
+		t.Errorf("Expected nil updating an unknown experiment, got %+v, %v", missing, err)
+	}
+
+	if err := b.Experiments.Delete(ctx, second.ID); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := b.Experiments.Delete(ctx, second.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting it again, got %v", err)
+	}
+	if err := b.Pages.Delete(ctx, page.ID); err != nil {
+		t.Fatalf("Delete page: %v", err)
+	}
+	if got, err := b.Experiments.GetByID(ctx, first.ID); err != nil || got != nil {
+		t.Errorf("Expected the page's experiments to be deleted with it, got %+v, %v", got, err)
+	}
+}
+
+// testTheme verifies that the theme is missing until saved, round-trips as a whole, is replaced
+// by later saves, and is left unchanged by a failing unit of work.
+func testTheme(t *testing.T, b Backend) {
+	ctx := context.Background()
+	if got, err := b.Themes.Get(ctx); err != nil || got != nil {
+		t.Fatalf("Expected no theme before the first save, got %+v, %v", got, err)
+	}
+
+	theme := &models.Theme{
+		Colors:     map[string]string{"primary": "#0055ff", "background": "#ffffff"},
+		DarkColors: map[string]string{"background": "#000000"},
+		Typography: map[string]models.TextStyle{"title": {FontFamily: "Inter", FontSize: 24, FontWeight: 700, LineHeight: 1.2}},
+		Radii:      map[string]float64{"sm": 4},
+		Spacing:    map[string]float64{"md": 16},
+		Contrast:   []models.ContrastPair{{Text: "primary", Background: "background", MinRatio: 3}},
+	}
+	if err := b.Themes.Save(ctx, theme); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if theme.UpdatedAt.IsZero() {
+		t.Error("Expected Save to set the update time")
+	}
+	got, err := b.Themes.Get(ctx)
+	if err != nil || got == nil {
+		t.Fatalf("Get: %+v, %v", got, err)
+	}
+	if !reflect.DeepEqual(got.Colors, theme.Colors) || !reflect.DeepEqual(got.DarkColors, theme.DarkColors) ||
+		!reflect.DeepEqual(got.Typography, theme.Typography) || !reflect.DeepEqual(got.Radii, theme.Radii) ||
+		!reflect.DeepEqual(got.Spacing, theme.Spacing) || !reflect.DeepEqual(got.Contrast, theme.Contrast) {
+		t.Errorf("Expected the theme to round-trip, got %+v", got)
+	}
+	if !got.UpdatedAt.Equal(theme.UpdatedAt) {
+		t.Errorf("Expected update time %v, got %v", theme.UpdatedAt, got.UpdatedAt)
+	}
+
+	errAbort := errors.New("abort")
+	err = b.UnitOfWork.Do(ctx, func(ctx context.Context, tx repository.Stores) error {
+		if err := tx.Themes.Save(ctx, &models.Theme{Colors: map[string]string{"primary": "#ff0000"}}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the unit of work to return its error, got %v", err)
+	}
+	if got, err := b.Themes.Get(ctx); err != nil || got == nil || got.Colors["primary"] != "#0055ff" {
+		t.Errorf("Expected the rolled back save to leave the theme unchanged, got %+v, %v", got, err)
+	}
+
+	replacement := &models.Theme{Colors: map[string]string{"accent": "#00ff00"}}
+	err = b.UnitOfWork.Do(ctx, func(ctx context.Context, tx repository.Stores) error {
+		return tx.Themes.Save(ctx, replacement)
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	got, err = b.Themes.Get(ctx)
+	if err != nil || got == nil || len(got.Colors) != 1 || got.Colors["accent"] != "#00ff00" || got.DarkColors != nil || len(got.Typography) != 0 || len(got.Contrast) != 0 {
+		t.Errorf("Expected the theme to be replaced as a whole, got %+v, %v", got, err)
+	}
+	if got != nil && got.UpdatedAt.Before(theme.UpdatedAt) {
+		t.Errorf("Expected the update time to move forward, got %v after %v", got.UpdatedAt, theme.UpdatedAt)
+	}
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"appdrop/models"
+)
+
+// ThemeStore defines the persistence operations available for the app theme, of which there is
+// one. Every operation honors the cancellation and deadline of its context.
+// Get returns a nil theme and a nil error until a theme is first saved. Save replaces the theme as
+// a whole and sets its UpdatedAt.
+type ThemeStore interface {
+	Get(ctx context.Context) (*models.Theme, error)
+	Save(ctx context.Context, theme *models.Theme) error
+}
+
+// EncodeTheme returns the JSON document a theme is stored as, which leaves out its update time
+// kept in a column of its own.
+func EncodeTheme(theme *models.Theme) (string, error) {
+	doc, err := json.Marshal(models.ThemeRequest{
+		Colors:     theme.Colors,
+		DarkColors: theme.DarkColors,
+		Typography: theme.Typography,
+		Radii:      theme.Radii,
+		Spacing:    theme.Spacing,
+		Contrast:   theme.Contrast,
+	})
+	return string(doc), err
+}
+
+// DecodeTheme returns the theme stored as doc and last updated at updatedAt.
+func DecodeTheme(doc []byte, updatedAt time.Time) (*models.Theme, error) {
+	var req models.ThemeRequest
+	if err := json.Unmarshal(doc, &req); err != nil {
+		return nil, err
+	}
+	theme := req.Theme()
+	theme.UpdatedAt = updatedAt
+	return theme, nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"appdrop/models"
+)
+
+// ThemeRepository manages database operations for the app theme, stored as a JSONB document in
+// the single row of the app_theme table.
+type ThemeRepository struct {
+	db           DBTX
+	queryTimeout time.Duration
+}
+
+// NewThemeRepository initializes and returns a new instance of ThemeRepository.
+func NewThemeRepository(db DBTX, queryTimeout time.Duration) *ThemeRepository {
+	return &ThemeRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Get retrieves the app theme.
+func (r *ThemeRepository) Get(ctx context.Context) (*models.Theme, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var doc []byte
+	var updatedAt time.Time
+	err := r.db.QueryRowContext(ctx, `SELECT tokens, updated_at FROM app_theme WHERE id = 1`).Scan(&doc, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DecodeTheme(doc, updatedAt)
+}
+
+// Save creates or replaces the app theme, setting its update time.
+func (r *ThemeRepository) Save(ctx context.Context, theme *models.Theme) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	doc, err := EncodeTheme(theme)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO app_theme (id, tokens)
+		VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET tokens = EXCLUDED.tokens, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, doc).Scan(&theme.UpdatedAt)
+}
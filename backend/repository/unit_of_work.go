@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBTX is the subset of *sql.DB and *sql.Tx used by the SQL repositories, so the same
+// repository code runs either directly on the pool or inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Stores bundles the page, widget, segment, experiment, theme and audit stores taking part in one unit of work.
+type Stores struct {
+	Pages       PageStore
+	Widgets     WidgetStore
+	Segments    SegmentStore
+	Experiments ExperimentStore
+	Themes      ThemeStore
+	Audit       AuditStore
+}
+
+// UnitOfWork runs multi-step operations atomically across the page, widget, segment, experiment,
+// theme and audit stores.
+type UnitOfWork interface {
+	// Do calls fn with stores bound to a new transaction, committing it when fn returns nil and
+	// rolling it back otherwise. fn runs again when the transaction hits a transient conflict such
+	// as a serialization failure or deadlock, so it must not have side effects outside the stores.
+	Do(ctx context.Context, fn func(ctx context.Context, tx Stores) error) error
+}
+
+// RetryPolicy bounds how often and how quickly a unit of work is retried after a transient conflict.
+// The delay before each retry doubles from InitialBackoff up to MaxBackoff, with random jitter.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy makes up to four attempts, waiting between 10ms and 200ms before each retry.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     200 * time.Millisecond,
+}
+
+// Run calls attempt until it succeeds or fails with an error that retryable rejects. It gives up
+// and returns the last error once MaxAttempts is reached, or the context error if ctx ends while waiting.
+func (p RetryPolicy) Run(ctx context.Context, retryable func(error) bool, attempt func() error) error {
+	backoff := p.InitialBackoff
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// SQLUnitOfWork implements UnitOfWork with database/sql transactions. Bind builds the stores for a
+// transaction, and Retryable decides which failures of the whole transaction are worth retrying.
+type SQLUnitOfWork struct {
+	DB        *sql.DB
+	TxOptions *sql.TxOptions
+	Bind      func(tx DBTX) Stores
+	Retryable func(err error) bool
+	Policy    RetryPolicy
+}
+
+// NewUnitOfWork initializes and returns a PostgreSQL unit of work. Transactions run at the
+// serializable isolation level, and serialization failures and deadlocks are retried.
+func NewUnitOfWork(db *sql.DB, queryTimeout time.Duration) *SQLUnitOfWork {
+	return &SQLUnitOfWork{
+		DB:        db,
+		TxOptions: &sql.TxOptions{Isolation: sql.LevelSerializable},
+		Bind: func(tx DBTX) Stores {
+			return Stores{
+				Pages:       NewPageRepository(tx, queryTimeout),
+				Widgets:     NewWidgetRepository(tx, queryTimeout),
+				Segments:    NewSegmentRepository(tx, queryTimeout),
+				Experiments: NewExperimentRepository(tx, queryTimeout),
+				Themes:      NewThemeRepository(tx, queryTimeout),
+				Audit:       NewAuditRepository(tx, queryTimeout),
+			}
+		},
+		Retryable: isTransientPostgresError,
+		Policy:    DefaultRetryPolicy,
+	}
+}
+
+// Do runs fn in a transaction, retrying the whole transaction on transient conflicts.
+func (u *SQLUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, tx Stores) error) error {
+	return u.Policy.Run(ctx, u.Retryable, func() error {
+		tx, err := u.DB.BeginTx(ctx, u.TxOptions)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(ctx, u.Bind(tx)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// InTx runs fn in a new transaction on db, or directly on db when it already is a transaction,
+// so that a multi-statement repository method stays atomic on its own and inside a unit of work.
+func InTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	pool, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isTransientPostgresError reports whether err is a serialization failure or a detected deadlock,
+// both of which PostgreSQL resolves by aborting one transaction that can safely be run again.
+func isTransientPostgresError(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
@@ -0,0 +1,57 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"appdrop/repository"
+)
+
+// TestRetryPolicy verifies that only retryable errors are retried, at most MaxAttempts times,
+// and that waiting between attempts stops when the context ends.
+func TestRetryPolicy(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+	policy := repository.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	calls := 0
+	err := policy.Run(context.Background(), retryable, func() error {
+		calls++
+		if calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third attempt, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = policy.Run(context.Background(), retryable, func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || calls != 3 {
+		t.Errorf("Expected the transient error after 3 calls, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = policy.Run(context.Background(), retryable, func() error {
+		calls++
+		return errFatal
+	})
+	if !errors.Is(err, errFatal) || calls != 1 {
+		t.Errorf("Expected the fatal error after 1 call, got %v after %d calls", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := repository.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	err = slow.Run(ctx, retryable, func() error { return errTransient })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled while backing off, got %v", err)
+	}
+}
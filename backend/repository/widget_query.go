@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"appdrop/models"
+
+	"github.com/google/uuid"
+)
+
+// ConfigOp is a comparison applied to a value inside a widget config.
+type ConfigOp string
+
+const (
+	// ConfigOpEquals matches configs holding exactly the value, compared by JSON type.
+	ConfigOpEquals ConfigOp = "="
+	// ConfigOpNotEquals matches configs not holding the value, including those without the key.
+	ConfigOpNotEquals ConfigOp = "!="
+	// ConfigOpGreater matches configs holding a number greater than the value.
+	ConfigOpGreater ConfigOp = ">"
+	// ConfigOpGreaterOrEqual matches configs holding a number greater than or equal to the value.
+	ConfigOpGreaterOrEqual ConfigOp = ">="
+	// ConfigOpLess matches configs holding a number less than the value.
+	ConfigOpLess ConfigOp = "<"
+	// ConfigOpLessOrEqual matches configs holding a number less than or equal to the value.
+	ConfigOpLessOrEqual ConfigOp = "<="
+	// ConfigOpExists matches configs holding the key, whatever its value.
+	ConfigOpExists ConfigOp = "exists"
+	// ConfigOpMissing matches configs not holding the key.
+	ConfigOpMissing ConfigOp = "missing"
+)
+
+// IsNumeric reports whether op orders numbers rather than testing equality or presence.
+func (op ConfigOp) IsNumeric() bool {
+	switch op {
+	case ConfigOpGreater, ConfigOpGreaterOrEqual, ConfigOpLess, ConfigOpLessOrEqual:
+		return true
+	}
+	return false
+}
+
+// ConfigPredicate tests the value found at Path inside a widget config. Value is a string,
+// float64, bool or nil for equality tests, a float64 for numeric comparisons, and unused otherwise.
+type ConfigPredicate struct {
+	Path  []string
+	Op    ConfigOp
+	Value interface{}
+}
+
+// configKey restricts the segments of a config path, so that paths can be embedded in JSON path
+// expressions without escaping.
+var configKey = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// configOps lists the operators recognized by ParseConfigPredicate, longest first.
+var configOps = []ConfigOp{ConfigOpNotEquals, ConfigOpGreaterOrEqual, ConfigOpLessOrEqual, ConfigOpEquals, ConfigOpGreater, ConfigOpLess}
+
+// ParseConfigPredicate parses a filter such as "link=/sale", "columns>=3", "link" (the key exists)
+// or "!link" (the key is missing). Nested keys are separated by dots. Equality values are read as
+// JSON numbers, booleans, null or quoted strings when they parse as such, and as plain strings otherwise.
+func ParseConfigPredicate(filter string) (ConfigPredicate, error) {
+	var p ConfigPredicate
+	path, rest := filter, ""
+	at := -1
+	for _, op := range configOps {
+		if i := strings.Index(filter, string(op)); i >= 0 && (at < 0 || i < at) {
+			at, p.Op = i, op
+		}
+	}
+	if at >= 0 {
+		path, rest = filter[:at], filter[at+len(p.Op):]
+	} else if strings.HasPrefix(filter, "!") {
+		path, p.Op = filter[1:], ConfigOpMissing
+	} else {
+		p.Op = ConfigOpExists
+	}
+
+	p.Path = strings.Split(path, ".")
+	for _, key := range p.Path {
+		if !configKey.MatchString(key) {
+			return p, fmt.Errorf("invalid config key %q: keys are 1-64 letters, digits, '_' or '-'", key)
+		}
+	}
+
+	switch {
+	case p.Op.IsNumeric():
+		var n float64
+		if err := json.Unmarshal([]byte(rest), &n); err != nil {
+			return p, fmt.Errorf("%s needs a number, got %q", p.Op, rest)
+		}
+		p.Value = n
+	case p.Op == ConfigOpEquals || p.Op == ConfigOpNotEquals:
+		var v interface{}
+		if err := json.Unmarshal([]byte(rest), &v); err == nil {
+			if _, composite := v.([]interface{}); !composite {
+				if _, composite = v.(map[string]interface{}); !composite {
+					p.Value = v
+					return p, nil
+				}
+			}
+		}
+		p.Value = rest
+	}
+	return p, nil
+}
+
+// JSONPath returns the path as a SQLite or PostgreSQL JSON path expression, such as $."a"."b".
+func (p ConfigPredicate) JSONPath() string {
+	return `$."` + strings.Join(p.Path, `"."`) + `"`
+}
+
+// ConfigContainment returns the JSON document that a config has to contain for an equality
+// predicate to hold, such as {"a":{"b":3}} for a.b=3.
+func (p ConfigPredicate) ConfigContainment() string {
+	var doc interface{} = p.Value
+	for i := len(p.Path) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{p.Path[i]: doc}
+	}
+	raw, _ := json.Marshal(doc)
+	return string(raw)
+}
+
+// Lookup returns the value at the predicate's path in a decoded config, and whether it exists.
+func (p ConfigPredicate) Lookup(config map[string]interface{}) (interface{}, bool) {
+	var value interface{} = config
+	for _, key := range p.Path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Match reports whether a decoded config satisfies the predicate.
+func (p ConfigPredicate) Match(config map[string]interface{}) bool {
+	value, ok := p.Lookup(config)
+	switch p.Op {
+	case ConfigOpExists:
+		return ok
+	case ConfigOpMissing:
+		return !ok
+	case ConfigOpEquals:
+		return ok && value == p.Value
+	case ConfigOpNotEquals:
+		return !ok || value != p.Value
+	}
+
+	n, isNumber := value.(float64)
+	want := p.Value.(float64)
+	if !ok || !isNumber {
+		return false
+	}
+	switch p.Op {
+	case ConfigOpGreater:
+		return n > want
+	case ConfigOpGreaterOrEqual:
+		return n >= want
+	case ConfigOpLess:
+		return n < want
+	default:
+		return n <= want
+	}
+}
+
+// WidgetQuery describes a listing of widgets across pages, newest first. Types, PageIDs and
+// SegmentIDs match any of their entries, and every config predicate has to hold. Widgets are returned after the
+// position marked by After, when set.
+type WidgetQuery struct {
+	Types      []string
+	PageIDs    []uuid.UUID
+	SegmentIDs []uuid.UUID
+	Config     []ConfigPredicate
+	Limit      int
+	After      *WidgetCursor
+}
+
+// WidgetList is one slice of a widget listing. Next is nil once there are no further widgets to list.
+type WidgetList struct {
+	Widgets []models.WidgetWithPage
+	Next    *WidgetCursor
+}
+
+// WidgetCursor marks the last widget returned by a listing by its creation time and ID.
+type WidgetCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// Before reports whether a widget created at createdAt with the given ID comes after the cursor
+// in newest-first order.
+func (c WidgetCursor) Before(createdAt time.Time, id uuid.UUID) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.Before(c.CreatedAt)
+	}
+	return strings.Compare(id.String(), c.ID.String()) < 0
+}
+
+// Encode returns the opaque, URL-safe form of the cursor handed to clients.
+func (c WidgetCursor) Encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeWidgetCursor parses a cursor produced by Encode.
+func DecodeWidgetCursor(s string) (*WidgetCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor WidgetCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,38 @@
+// Package repository contains tests for parsing widget config filters.
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseConfigPredicate verifies operator detection, value typing and key validation.
+func TestParseConfigPredicate(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   ConfigPredicate
+	}{
+		{"link=/sale", ConfigPredicate{Path: []string{"link"}, Op: ConfigOpEquals, Value: "/sale"}},
+		{"link=/a=b", ConfigPredicate{Path: []string{"link"}, Op: ConfigOpEquals, Value: "/a=b"}},
+		{"columns>=3", ConfigPredicate{Path: []string{"columns"}, Op: ConfigOpGreaterOrEqual, Value: 3.0}},
+		{"columns<2.5", ConfigPredicate{Path: []string{"columns"}, Op: ConfigOpLess, Value: 2.5}},
+		{`code!="3"`, ConfigPredicate{Path: []string{"code"}, Op: ConfigOpNotEquals, Value: "3"}},
+		{"visible=true", ConfigPredicate{Path: []string{"visible"}, Op: ConfigOpEquals, Value: true}},
+		{"style.color=null", ConfigPredicate{Path: []string{"style", "color"}, Op: ConfigOpEquals, Value: nil}},
+		{"items=[1]", ConfigPredicate{Path: []string{"items"}, Op: ConfigOpEquals, Value: "[1]"}},
+		{"meta.tag", ConfigPredicate{Path: []string{"meta", "tag"}, Op: ConfigOpExists}},
+		{"!link", ConfigPredicate{Path: []string{"link"}, Op: ConfigOpMissing}},
+	}
+	for _, tt := range tests {
+		got, err := ParseConfigPredicate(tt.filter)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseConfigPredicate(%q) = %+v, %v; expected %+v", tt.filter, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"columns>=three", "a..b", `li"nk=x`, "=x", "!link=x"} {
+		if _, err := ParseConfigPredicate(bad); err == nil {
+			t.Errorf("Expected an error parsing %q", bad)
+		}
+	}
+}
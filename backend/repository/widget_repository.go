@@ -3,54 +3,75 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"appdrop/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // WidgetRepository manages database operations specifically for Widget entities.
 // It provides methods for CRUD operations, reordering, and validation.
 type WidgetRepository struct {
-	db *sql.DB
+	db           DBTX
+	queryTimeout time.Duration
 }
 
 // NewWidgetRepository initializes and returns a new instance of WidgetRepository.
-func NewWidgetRepository(db *sql.DB) *WidgetRepository {
-	return &WidgetRepository{db: db}
+func NewWidgetRepository(db DBTX, queryTimeout time.Duration) *WidgetRepository {
+	return &WidgetRepository{db: db, queryTimeout: queryTimeout}
 }
 
-// Create persists a new Widget entity in the data store.
-func (r *WidgetRepository) Create(widget *models.Widget) error {
+// Create persists a new Widget entity in the data store, keeping its ID if it already has one.
+func (r *WidgetRepository) Create(ctx context.Context, widget *models.Widget) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	config := widget.Config
 	if config == nil {
 		config = json.RawMessage("{}")
 	}
 
 	query := `
-		INSERT INTO widgets (page_id, type, position, config)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at
+		INSERT INTO widgets (id, page_id, type, position, config, schedule, rule, segment_id, locales)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at
 	`
-	return r.db.QueryRow(query, widget.PageID, widget.Type, widget.Position, config).
-		Scan(&widget.ID, &widget.CreatedAt, &widget.UpdatedAt)
+	id := widget.ID
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	err := r.db.QueryRowContext(ctx, query, id, widget.PageID, widget.Type, widget.Position, config, widget.Schedule, widget.Rule, widget.SegmentID, widget.Locales).
+		Scan(&widget.CreatedAt, &widget.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	widget.ID = id
+	return nil
 }
 
 // GetByID retrieves a single Widget entity by its unique identifier.
-func (r *WidgetRepository) GetByID(id uuid.UUID) (*models.Widget, error) {
+func (r *WidgetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Widget, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, page_id, type, position, config, created_at, updated_at
+		SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
 		FROM widgets
 		WHERE id = $1
 	`
 	widget := &models.Widget{}
 	var configBytes []byte
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&widget.ID, &widget.PageID, &widget.Type, &widget.Position,
-		&configBytes, &widget.CreatedAt, &widget.UpdatedAt,
+		&configBytes, &widget.Schedule, &widget.Rule, &widget.SegmentID, &widget.Locales, &widget.CreatedAt, &widget.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -64,13 +85,16 @@ func (r *WidgetRepository) GetByID(id uuid.UUID) (*models.Widget, error) {
 
 // GetByPageID retrieves a collection of Widget entities associated with a specific Page.
 // An optional widgetType filter can be applied to narrow the results.
-func (r *WidgetRepository) GetByPageID(pageID uuid.UUID, widgetType *string) ([]models.Widget, error) {
+func (r *WidgetRepository) GetByPageID(ctx context.Context, pageID uuid.UUID, widgetType *string) ([]models.Widget, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var query string
 	var args []interface{}
 
 	if widgetType != nil && *widgetType != "" {
 		query = `
-			SELECT id, page_id, type, position, config, created_at, updated_at
+			SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
 			FROM widgets
 			WHERE page_id = $1 AND type = $2
 			ORDER BY position ASC
@@ -78,7 +102,7 @@ func (r *WidgetRepository) GetByPageID(pageID uuid.UUID, widgetType *string) ([]
 		args = []interface{}{pageID, *widgetType}
 	} else {
 		query = `
-			SELECT id, page_id, type, position, config, created_at, updated_at
+			SELECT id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
 			FROM widgets
 			WHERE page_id = $1
 			ORDER BY position ASC
@@ -86,7 +110,7 @@ func (r *WidgetRepository) GetByPageID(pageID uuid.UUID, widgetType *string) ([]
 		args = []interface{}{pageID}
 	}
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -98,7 +122,7 @@ func (r *WidgetRepository) GetByPageID(pageID uuid.UUID, widgetType *string) ([]
 		var configBytes []byte
 		if err := rows.Scan(
 			&w.ID, &w.PageID, &w.Type, &w.Position,
-			&configBytes, &w.CreatedAt, &w.UpdatedAt,
+			&configBytes, &w.Schedule, &w.Rule, &w.SegmentID, &w.Locales, &w.CreatedAt, &w.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -109,16 +133,114 @@ func (r *WidgetRepository) GetByPageID(pageID uuid.UUID, widgetType *string) ([]
 	return widgets, rows.Err()
 }
 
+// List retrieves widgets across pages, newest first, together with the name and route of their
+// page. Equality, existence and numeric config predicates are expressed as JSONB containment and
+// strict JSON path tests, which the GIN index on widgets.config added by migration 005 serves.
+// A strict path test on a missing key yields NULL rather than false, hence the coalesce.
+func (r *WidgetRepository) List(ctx context.Context, q WidgetQuery) (WidgetList, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var where []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(q.Types) > 0 {
+		where = append(where, "w.type = ANY("+arg(pq.Array(q.Types))+")")
+	}
+	if len(q.PageIDs) > 0 {
+		where = append(where, "w.page_id = ANY("+arg(pq.Array(q.PageIDs))+")")
+	}
+	if len(q.SegmentIDs) > 0 {
+		where = append(where, "w.segment_id = ANY("+arg(pq.Array(q.SegmentIDs))+")")
+	}
+	for _, p := range q.Config {
+		switch {
+		case p.Op == ConfigOpEquals:
+			where = append(where, "w.config @> "+arg(p.ConfigContainment())+"::jsonb")
+		case p.Op == ConfigOpNotEquals:
+			where = append(where, "NOT (w.config @> "+arg(p.ConfigContainment())+"::jsonb)")
+		case p.Op == ConfigOpExists:
+			where = append(where, "w.config @? "+arg("strict "+p.JSONPath())+"::jsonpath")
+		case p.Op == ConfigOpMissing:
+			where = append(where, "NOT coalesce(w.config @? "+arg("strict "+p.JSONPath())+"::jsonpath, FALSE)")
+		case p.Op.IsNumeric():
+			op := string(p.Op)
+			path := fmt.Sprintf("strict %s ? (@ %s %s)", p.JSONPath(), op, strconv.FormatFloat(p.Value.(float64), 'f', -1, 64))
+			where = append(where, "w.config @? "+arg(path)+"::jsonpath")
+		}
+	}
+	if q.After != nil {
+		where = append(where, fmt.Sprintf("(w.created_at, w.id) < (%s, %s)", arg(q.After.CreatedAt), arg(q.After.ID)))
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+	limit := ""
+	if q.Limit > 0 {
+		limit = "LIMIT " + arg(q.Limit+1)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT w.id, w.page_id, w.type, w.position, w.config, w.schedule, w.rule, w.segment_id, w.locales, w.created_at, w.updated_at, p.name, p.route, p.schedule, p.rule, p.segment_id
+		FROM widgets w
+		JOIN pages p ON p.id = w.page_id
+		%s
+		ORDER BY w.created_at DESC, w.id DESC
+		%s
+	`, filter, limit)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return WidgetList{}, err
+	}
+	defer rows.Close()
+
+	var list WidgetList
+	for rows.Next() {
+		var w models.WidgetWithPage
+		var configBytes []byte
+		if err := rows.Scan(
+			&w.ID, &w.PageID, &w.Type, &w.Position,
+			&configBytes, &w.Schedule, &w.Rule, &w.SegmentID, &w.Locales, &w.CreatedAt, &w.UpdatedAt, &w.PageName, &w.PageRoute, &w.PageSchedule, &w.PageRule, &w.PageSegmentID,
+		); err != nil {
+			return WidgetList{}, err
+		}
+		w.Config = json.RawMessage(configBytes)
+		list.Widgets = append(list.Widgets, w)
+	}
+	if err := rows.Err(); err != nil {
+		return WidgetList{}, err
+	}
+
+	if q.Limit > 0 && len(list.Widgets) > q.Limit {
+		list.Widgets = list.Widgets[:q.Limit]
+		last := list.Widgets[q.Limit-1]
+		list.Next = &WidgetCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return list, nil
+}
+
 // GetMaxPosition determines the highest position index currently assigned to widgets on a page.
-func (r *WidgetRepository) GetMaxPosition(pageID uuid.UUID) (int, error) {
+func (r *WidgetRepository) GetMaxPosition(ctx context.Context, pageID uuid.UUID) (int, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `SELECT COALESCE(MAX(position), 0) FROM widgets WHERE page_id = $1`
 	var maxPosition int
-	err := r.db.QueryRow(query, pageID).Scan(&maxPosition)
+	err := r.db.QueryRowContext(ctx, query, pageID).Scan(&maxPosition)
 	return maxPosition, err
 }
 
 // Update modifies an existing Widget entity with the provided field updates.
-func (r *WidgetRepository) Update(id uuid.UUID, updates map[string]interface{}) (*models.Widget, error) {
+func (r *WidgetRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*models.Widget, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	setClauses := ""
 	args := []interface{}{}
 	argIndex := 1
@@ -133,7 +255,7 @@ func (r *WidgetRepository) Update(id uuid.UUID, updates map[string]interface{})
 	}
 
 	if setClauses == "" {
-		return r.GetByID(id)
+		return r.GetByID(ctx, id)
 	}
 
 	args = append(args, id)
@@ -141,14 +263,14 @@ func (r *WidgetRepository) Update(id uuid.UUID, updates map[string]interface{})
 		UPDATE widgets
 		SET %s
 		WHERE id = $%d
-		RETURNING id, page_id, type, position, config, created_at, updated_at
+		RETURNING id, page_id, type, position, config, schedule, rule, segment_id, locales, created_at, updated_at
 	`, setClauses, argIndex)
 
 	widget := &models.Widget{}
 	var configBytes []byte
-	err := r.db.QueryRow(query, args...).Scan(
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
 		&widget.ID, &widget.PageID, &widget.Type, &widget.Position,
-		&configBytes, &widget.CreatedAt, &widget.UpdatedAt,
+		&configBytes, &widget.Schedule, &widget.Rule, &widget.SegmentID, &widget.Locales, &widget.CreatedAt, &widget.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -161,9 +283,12 @@ func (r *WidgetRepository) Update(id uuid.UUID, updates map[string]interface{})
 }
 
 // Delete removes a Widget entity from the data store by its ID.
-func (r *WidgetRepository) Delete(id uuid.UUID) error {
+func (r *WidgetRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `DELETE FROM widgets WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -178,41 +303,45 @@ func (r *WidgetRepository) Delete(id uuid.UUID) error {
 }
 
 // Reorder applies a new sequential order to a list of widgets within a specific page.
-func (r *WidgetRepository) Reorder(pageID uuid.UUID, widgetIDs []uuid.UUID) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+func (r *WidgetRepository) Reorder(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) error {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
 
-	for i, widgetID := range widgetIDs {
-		query := `UPDATE widgets SET position = $1 WHERE id = $2 AND page_id = $3`
-		result, err := tx.Exec(query, i+1, widgetID, pageID)
-		if err != nil {
-			return err
-		}
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			return err
-		}
-		if rowsAffected == 0 {
-			return fmt.Errorf("widget %s not found on page %s", widgetID, pageID)
+	return InTx(ctx, r.db, func(tx DBTX) error {
+		for i, widgetID := range widgetIDs {
+			query := `UPDATE widgets SET position = $1 WHERE id = $2 AND page_id = $3`
+			result, err := tx.ExecContext(ctx, query, i+1, widgetID, pageID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return fmt.Errorf("widget %s not found on page %s", widgetID, pageID)
+			}
 		}
-	}
-
-	return tx.Commit()
+		return nil
+	})
 }
 
 // GetWidgetCountByPageID returns the total number of widgets associated with a specific page.
-func (r *WidgetRepository) GetWidgetCountByPageID(pageID uuid.UUID) (int, error) {
+func (r *WidgetRepository) GetWidgetCountByPageID(ctx context.Context, pageID uuid.UUID) (int, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `SELECT COUNT(*) FROM widgets WHERE page_id = $1`
 	var count int
-	err := r.db.QueryRow(query, pageID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, pageID).Scan(&count)
 	return count, err
 }
 
 // CheckWidgetsBelongToPage validates that a set of widget IDs all belong to the specified page identifier.
-func (r *WidgetRepository) CheckWidgetsBelongToPage(pageID uuid.UUID, widgetIDs []uuid.UUID) (bool, error) {
+func (r *WidgetRepository) CheckWidgetsBelongToPage(ctx context.Context, pageID uuid.UUID, widgetIDs []uuid.UUID) (bool, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	if len(widgetIDs) == 0 {
 		return true, nil
 	}
@@ -223,10 +352,33 @@ func (r *WidgetRepository) CheckWidgetsBelongToPage(pageID uuid.UUID, widgetIDs
 	`
 
 	var count int
-	err := r.db.QueryRow(query, pageID, widgetIDs).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, pageID, pq.Array(widgetIDs)).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 
 	return count == len(widgetIDs), nil
 }
+
+// CountByType returns the number of widgets stored for each widget type.
+func (r *WidgetRepository) CountByType(ctx context.Context) (map[string]int, error) {
+	ctx, cancel := WithQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT type, COUNT(*) FROM widgets GROUP BY type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var widgetType string
+		var count int
+		if err := rows.Scan(&widgetType, &count); err != nil {
+			return nil, err
+		}
+		counts[widgetType] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,21 @@
+// Package requestid carries the per-request correlation identifier through request contexts.
+package requestid
+
+import "context"
+
+// Header is the HTTP header used to receive and echo the request identifier.
+const Header = "X-Request-ID"
+
+// contextKey is an unexported type preventing collisions with context keys from other packages.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries the given request identifier.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext retrieves the request identifier stored in ctx, or an empty string if none is present.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,151 @@
+// Package response renders error payloads in the format negotiated with the client.
+// It supports both the legacy {"error": {...}} envelope and RFC 9457 problem details.
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"appdrop/models"
+	"appdrop/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// jsonFieldName reports the JSON name of a struct field so validation errors reference request keys.
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// WantsProblem reports whether the client prefers RFC 9457 problem details over the legacy error envelope.
+func WantsProblem(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, models.ProblemContentType) == models.ProblemContentType
+}
+
+// Error writes the error response using the representation negotiated through the Accept header.
+// The request identifier, when present, is attached so clients can quote it in support requests.
+func Error(c *gin.Context, status int, resp models.ErrorResponse) {
+	resp.Error.RequestID = requestid.FromContext(c.Request.Context())
+	if WantsProblem(c) {
+		c.Render(status, problemRender{problem: resp.Problem(status, c.Request.URL.Path)})
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// Abort writes the error response like Error and prevents any pending handlers from running.
+func Abort(c *gin.Context, status int, resp models.ErrorResponse) {
+	c.Abort()
+	Error(c, status, resp)
+}
+
+// FromBindingError translates a request binding failure into a validation ErrorResponse with field-level details.
+func FromBindingError(err error) models.ErrorResponse {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var fieldErr *models.FieldError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fieldErrs := make([]models.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fieldErrs = append(fieldErrs, models.FieldError{
+				Field:   fieldPath(fe),
+				Code:    fe.Tag(),
+				Message: validationMessage(fe),
+			})
+		}
+		return models.NewValidationError("Request body failed validation").WithFieldErrors(fieldErrs...)
+	case errors.As(err, &fieldErr):
+		return models.NewFieldValidationError(fieldErr.Field, fieldErr.Code, fieldErr.Message)
+	case errors.As(err, &typeErr):
+		return models.NewFieldValidationError(typeErr.Field, "type",
+			fmt.Sprintf("must be of type %s", jsonTypeName(typeErr.Type)))
+	case errors.As(err, &syntaxErr):
+		return models.NewValidationError(fmt.Sprintf("Request body is not valid JSON (offset %d)", syntaxErr.Offset))
+	case errors.Is(err, io.EOF):
+		return models.NewValidationError("Request body is required")
+	default:
+		return models.NewValidationError("Invalid request body: " + err.Error())
+	}
+}
+
+// fieldPath strips the root struct name from a validator namespace, yielding a JSON path such as "widget_ids".
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
+
+// validationMessage produces a human-readable explanation for a failed validation tag.
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
+
+// jsonTypeName maps a Go type onto the JSON type a client would recognise.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// problemRender writes a problem details document with the application/problem+json content type.
+type problemRender struct {
+	problem models.ProblemDetails
+}
+
+// Render serializes the problem document to the response writer.
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.problem)
+}
+
+// WriteContentType sets the problem details media type on the response.
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", models.ProblemContentType)
+}
@@ -0,0 +1,87 @@
+// Package response contains unit tests for verifying error negotiation and binding error translation.
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"appdrop/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindingTarget mirrors the shape of a typical request model for exercising binding translation.
+type bindingTarget struct {
+	Name     string `json:"name" binding:"required"`
+	Position int    `json:"position"`
+}
+
+// bindAndRespond builds a router that binds the request body and reports failures through Error.
+func bindAndRespond(body, accept string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/pages", func(c *gin.Context) {
+		var req bindingTarget
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Error(c, http.StatusBadRequest, FromBindingError(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/pages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestErrorLegacyEnvelope verifies that clients without a problem details preference keep the legacy shape,
+// now enriched with field-level errors.
+func TestErrorLegacyEnvelope(t *testing.T) {
+	w := bindAndRespond(`{}`, "application/json")
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected application/json content type, got %q", ct)
+	}
+
+	var body models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.Error.Code != models.ErrorCodeValidation {
+		t.Errorf("Expected code %s, got %s", models.ErrorCodeValidation, body.Error.Code)
+	}
+	if len(body.Error.Errors) != 1 || body.Error.Errors[0].Field != "name" || body.Error.Errors[0].Code != "required" {
+		t.Errorf("Expected a single required error on name, got %+v", body.Error.Errors)
+	}
+}
+
+// TestErrorProblemDetails verifies that clients accepting application/problem+json receive an RFC 9457 document.
+func TestErrorProblemDetails(t *testing.T) {
+	w := bindAndRespond(`{"name": "Home", "position": "first"}`, models.ProblemContentType)
+
+	if ct := w.Header().Get("Content-Type"); ct != models.ProblemContentType {
+		t.Errorf("Expected %s content type, got %q", models.ProblemContentType, ct)
+	}
+
+	var problem models.ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if problem.Status != http.StatusBadRequest || problem.Instance != "/pages" {
+		t.Errorf("Unexpected status or instance: %+v", problem)
+	}
+	if problem.Type != "/problems/validation-error" {
+		t.Errorf("Expected validation problem type, got %q", problem.Type)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "position" || problem.Errors[0].Code != "type" {
+		t.Errorf("Expected a single type error on position, got %+v", problem.Errors)
+	}
+}
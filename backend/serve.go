@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"appdrop/config"
+	"appdrop/database"
+	"appdrop/handlers"
+	"appdrop/metrics"
+	"appdrop/middleware"
+	"appdrop/repository"
+	"appdrop/repository/sqlite"
+	"appdrop/server"
+	"appdrop/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve sets up the application infrastructure, configures the routing engine,
+// and runs the HTTP server until a termination signal is received.
+func serve(cfg *config.Config, logger *slog.Logger) error {
+	if err := connectDatabase(cfg); err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	if cfg.Database.AutoMigrate {
+		migrator, err := database.NewMigrator(db, database.Driver(), logger)
+		if err != nil {
+			return err
+		}
+		if err := migrator.Up(context.Background()); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+	stores, uow := newStores(db, database.Driver(), cfg.Database.QueryTimeout)
+	searchRepo := newSearchStore(db, database.Driver(), cfg.Database.QueryTimeout)
+
+	registry := metrics.NewRegistry()
+	registry.Register(metrics.DBStatsCollector(db))
+	registry.Register(metrics.ContentCollector(stores.Pages, stores.Widgets))
+
+	quotas := middleware.NewQuotaTracker(cfg.RateLimit.DailyQuota)
+	deliveryLimiter := middleware.NewRateLimiter("delivery", middleware.RateLimitConfig{
+		Rate:  cfg.RateLimit.Delivery.RPS,
+		Burst: cfg.RateLimit.Delivery.Burst,
+	}, quotas)
+	managementLimiter := middleware.NewRateLimiter("management", middleware.RateLimitConfig{
+		Rate:  cfg.RateLimit.Management.RPS,
+		Burst: cfg.RateLimit.Management.Burst,
+	}, quotas)
+
+	deliveryCORS := middleware.NewCORSPolicy(corsConfig(cfg.CORS.Delivery))
+	managementCORS := middleware.NewCORSPolicy(corsConfig(cfg.CORS.Management))
+
+	pagination := handlers.Pagination{
+		DefaultPerPage: cfg.Pagination.DefaultPerPage,
+		MaxPerPage:     cfg.Pagination.MaxPerPage,
+	}
+	pageHandler := handlers.NewPageHandler(stores.Pages, stores.Widgets, stores.Segments, stores.Experiments, stores.Themes, uow, pagination)
+	widgetHandler := handlers.NewWidgetHandler(stores.Widgets, stores.Pages, stores.Segments, stores.Experiments, stores.Themes, uow, pagination)
+	segmentHandler := handlers.NewSegmentHandler(stores.Segments, stores.Widgets, uow, pagination)
+	experimentHandler := handlers.NewExperimentHandler(stores.Experiments, stores.Pages, uow)
+	translationHandler := handlers.NewTranslationHandler(stores.Pages, stores.Widgets, uow)
+	themeHandler := handlers.NewThemeHandler(stores.Themes, uow)
+	searchHandler := handlers.NewSearchHandler(searchRepo, pagination)
+	adminHandler := handlers.NewAdminHandler(quotas, stores.Audit)
+	healthHandler := handlers.NewHealthHandler(db, cfg.Server.HealthTimeout)
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("failed to set trusted proxies: %w", err)
+	}
+
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Identify(cfg.RateLimit.APIKeys))
+	router.Use(middleware.Logger(logger))
+	router.Use(registry.Middleware())
+	router.Use(middleware.Recovery())
+	router.Use(middleware.Deadline(cfg.Server.RequestTimeout))
+	corsRoutes := middleware.NewCORSRoutes()
+	router.Use(middleware.CORSPreflight(corsRoutes))
+	router.NoRoute(middleware.NotFound())
+
+	health := corsRoutes.Group(router, "/", deliveryCORS)
+	{
+		health.GET("/livez", healthHandler.Livez)
+		health.GET("/readyz", healthHandler.Readyz)
+		health.GET("/health", healthHandler.Readyz)
+	}
+
+	router.GET("/metrics", middleware.AdminAuth(cfg.Admin.Token), registry.Handler())
+
+	delivery := corsRoutes.Group(router, "/", deliveryCORS, deliveryLimiter.Middleware())
+	{
+		delivery.GET("/pages", pageHandler.ListPages)
+		delivery.GET("/pages/:id", pageHandler.GetPage)
+		delivery.GET("/pages/:id/widgets", widgetHandler.GetWidgets)
+		delivery.GET("/widgets", widgetHandler.ListWidgets)
+		delivery.GET("/theme", themeHandler.GetTheme)
+	}
+
+	management := corsRoutes.Group(router, "/", managementCORS, managementLimiter.Middleware())
+	{
+		management.POST("/pages", pageHandler.CreatePage)
+		management.PUT("/pages/:id", pageHandler.UpdatePage)
+		management.DELETE("/pages/:id", pageHandler.DeletePage)
+		management.GET("/pages/:id/preview", pageHandler.PreviewPage)
+
+		management.POST("/pages/:id/widgets", widgetHandler.CreateWidget)
+		management.POST("/pages/:id/widgets/reorder", widgetHandler.ReorderWidgets)
+		management.PUT("/widgets/:id", widgetHandler.UpdateWidget)
+		management.DELETE("/widgets/:id", widgetHandler.DeleteWidget)
+		management.POST("/widgets/replace", widgetHandler.ReplaceInConfigs)
+		management.GET("/search", searchHandler.Search)
+
+		management.GET("/segments", segmentHandler.ListSegments)
+		management.POST("/segments", segmentHandler.CreateSegment)
+		management.GET("/segments/:id", segmentHandler.GetSegment)
+		management.PUT("/segments/:id", segmentHandler.UpdateSegment)
+		management.DELETE("/segments/:id", segmentHandler.DeleteSegment)
+		management.PUT("/segments/:id/users", segmentHandler.SetSegmentUsers)
+		management.POST("/segments/:id/test", segmentHandler.TestSegment)
+		management.GET("/segments/:id/widgets", segmentHandler.ListSegmentWidgets)
+
+		management.GET("/pages/:id/experiments", experimentHandler.ListExperiments)
+		management.POST("/pages/:id/experiments", experimentHandler.CreateExperiment)
+		management.GET("/experiments/:id", experimentHandler.GetExperiment)
+		management.PUT("/experiments/:id", experimentHandler.UpdateExperiment)
+		management.DELETE("/experiments/:id", experimentHandler.DeleteExperiment)
+		management.POST("/experiments/:id/start", experimentHandler.StartExperiment)
+		management.POST("/experiments/:id/stop", experimentHandler.StopExperiment)
+		management.POST("/experiments/:id/promote", experimentHandler.PromoteExperiment)
+
+		management.GET("/translations/export", translationHandler.ExportTranslations)
+		management.POST("/translations/import", translationHandler.ImportTranslations)
+
+		management.PUT("/theme", themeHandler.UpdateTheme)
+	}
+
+	manage := corsRoutes.Group(router, "/manage", managementCORS, managementLimiter.Middleware(), handlers.Unfiltered())
+	{
+		manage.GET("/pages", pageHandler.ListPages)
+		manage.GET("/pages/:id", pageHandler.GetPage)
+		manage.GET("/pages/:id/widgets", widgetHandler.GetWidgets)
+		manage.GET("/widgets", widgetHandler.ListWidgets)
+	}
+
+	admin := router.Group("/admin", middleware.AdminAuth(cfg.Admin.Token))
+	{
+		admin.GET("/quotas", adminHandler.ListQuotas)
+		admin.GET("/quotas/:key", adminHandler.GetQuota)
+		admin.GET("/audit", adminHandler.ListAudit)
+	}
+
+	srv := server.New(server.Config{
+		Addr:              ":" + cfg.Server.Port,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ShutdownTimeout:   cfg.Server.ShutdownTimeout,
+	}, router, logger)
+	srv.OnShutdown(func(context.Context) error {
+		return database.Close()
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("Mini App Config API running", "port", cfg.Server.Port, "version", version.Version, "commit", version.Commit)
+
+	return srv.Run(ctx)
+}
+
+// corsConfig converts a configured CORS policy into its middleware representation.
+func corsConfig(policy config.CORSPolicy) middleware.CORSConfig {
+	return middleware.CORSConfig{
+		AllowedOrigins:   policy.AllowedOrigins,
+		AllowedMethods:   policy.AllowedMethods,
+		AllowedHeaders:   policy.AllowedHeaders,
+		ExposedHeaders:   policy.ExposedHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
+	}
+}
+
+// connectDatabase opens the database connection pool described by the configuration.
+func connectDatabase(cfg *config.Config) error {
+	if err := database.Connect(database.Config{
+		Driver:          cfg.Database.Driver,
+		Path:            cfg.Database.Path,
+		Host:            cfg.Database.Host,
+		Port:            cfg.Database.Port,
+		User:            cfg.Database.User,
+		Password:        cfg.Database.Password,
+		DBName:          cfg.Database.Name,
+		SSLMode:         cfg.Database.SSLMode,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+	}); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return nil
+}
+
+// newStores returns the page, widget, segment, experiment, theme and audit stores, and the unit of work spanning them,
+// implemented for the given database driver.
+func newStores(db *sql.DB, driver string, queryTimeout time.Duration) (repository.Stores, repository.UnitOfWork) {
+	if driver == database.DriverSQLite {
+		return repository.Stores{
+			Pages:       sqlite.NewPageRepository(db, queryTimeout),
+			Widgets:     sqlite.NewWidgetRepository(db, queryTimeout),
+			Segments:    sqlite.NewSegmentRepository(db, queryTimeout),
+			Experiments: sqlite.NewExperimentRepository(db, queryTimeout),
+			Themes:      sqlite.NewThemeRepository(db, queryTimeout),
+			Audit:       sqlite.NewAuditRepository(db, queryTimeout),
+		}, sqlite.NewUnitOfWork(db, queryTimeout)
+	}
+	return repository.Stores{
+		Pages:       repository.NewPageRepository(db, queryTimeout),
+		Widgets:     repository.NewWidgetRepository(db, queryTimeout),
+		Segments:    repository.NewSegmentRepository(db, queryTimeout),
+		Experiments: repository.NewExperimentRepository(db, queryTimeout),
+		Themes:      repository.NewThemeRepository(db, queryTimeout),
+		Audit:       repository.NewAuditRepository(db, queryTimeout),
+	}, repository.NewUnitOfWork(db, queryTimeout)
+}
+
+// newSearchStore returns the search store implemented for the given database driver.
+func newSearchStore(db *sql.DB, driver string, queryTimeout time.Duration) repository.SearchStore {
+	if driver == database.DriverSQLite {
+		return sqlite.NewSearchRepository(db, queryTimeout)
+	}
+	return repository.NewSearchRepository(db, queryTimeout)
+}
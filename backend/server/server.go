@@ -0,0 +1,111 @@
+// Package server runs the HTTP server with hardened timeouts and coordinates graceful shutdown.
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Config defines the listener address, connection timeouts and shutdown deadline of the HTTP server.
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	ShutdownTimeout   time.Duration
+}
+
+// Server wraps an http.Server and the hooks that must run once it stops accepting requests.
+type Server struct {
+	httpServer      *http.Server
+	shutdownTimeout time.Duration
+	hooks           []func(context.Context) error
+	logger          *slog.Logger
+}
+
+// New initializes a Server serving the handler with the timeouts described by the configuration.
+func New(config Config, handler http.Handler, logger *slog.Logger) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              config.Addr,
+			Handler:           handler,
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+			MaxHeaderBytes:    config.MaxHeaderBytes,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		},
+		shutdownTimeout: config.ShutdownTimeout,
+		logger:          logger,
+	}
+}
+
+// OnShutdown registers a hook that runs after in-flight requests have drained, such as stopping
+// background workers or closing the database. Hooks run in registration order.
+func (s *Server) OnShutdown(hook func(context.Context) error) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// Run listens on the configured address and serves until ctx is cancelled, then shuts down gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on the listener until ctx is cancelled or the server fails.
+// On cancellation it stops accepting new connections, waits for in-flight requests to finish within
+// the shutdown timeout, and then runs the registered shutdown hooks under the same deadline.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.httpServer.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		return errors.Join(err, s.runHooks(context.Background()))
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("Shutting down server", "timeout", s.shutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
+		_ = s.httpServer.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	errs = append(errs, s.runHooks(shutdownCtx))
+
+	s.logger.Info("Server stopped")
+	return errors.Join(errs...)
+}
+
+// runHooks executes every shutdown hook, continuing past failures so each resource gets released.
+func (s *Server) runHooks(ctx context.Context) error {
+	var errs []error
+	for _, hook := range s.hooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+// Package server contains tests for verifying graceful shutdown behavior.
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestServeDrainsInFlightRequests verifies that a request already being handled when shutdown begins
+// still completes successfully, and that shutdown hooks run only after it has finished.
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handlerDone, hookRan time.Time
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		handlerDone = time.Now()
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "reordered")
+	})
+
+	srv := New(Config{ShutdownTimeout: 5 * time.Second}, handler, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	srv.OnShutdown(func(context.Context) error {
+		hookRan = time.Now()
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveDone := make(chan error, 1)
+	go func() { serveDone <- srv.Serve(ctx, ln) }()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/pages/1/widgets/reorder")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-serveDone:
+		t.Fatalf("Serve returned before the in-flight request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	res := <-responses
+	if res.err != nil {
+		t.Fatalf("In-flight request failed: %v", res.err)
+	}
+	if res.status != http.StatusOK || res.body != "reordered" {
+		t.Errorf("Expected 200 reordered, got %d %q", res.status, res.body)
+	}
+
+	if err := <-serveDone; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+	if hookRan.IsZero() || hookRan.Before(handlerDone) {
+		t.Error("Expected shutdown hook to run after the in-flight request completed")
+	}
+}
@@ -0,0 +1,115 @@
+package targeting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind classifies the tokens of a rule expression.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+)
+
+// token is one lexical element of a rule, with its byte offset in the source.
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+// operators lists the operator spellings, two-character ones first so that they win over prefixes.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!"}
+
+// lex splits a rule into tokens, ending with a tokenEOF.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokenLBracket, "[", i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokenRBracket, "]", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, errorAt(i, "unterminated string")
+			}
+			value, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, errorAt(i, "invalid string %s", src[i:end+1])
+			}
+			tokens = append(tokens, token{tokenString, value, i})
+			i = end + 1
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(src) && (src[end] == '_' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{tokenIdent, src[i:end], i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorAt(i, "unexpected character %q", c)
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(src)}), nil
+}
+
+// Error reports an invalid rule, pointing at the byte offset where the problem was found.
+type Error struct {
+	Offset  int
+	Message string
+}
+
+// Error returns the message prefixed with the offset at which the problem was found.
+func (e *Error) Error() string {
+	return fmt.Sprintf("at offset %d: %s", e.Offset, e.Message)
+}
+
+// errorAt initializes and returns an Error at the given offset.
+func errorAt(offset int, format string, args ...interface{}) *Error {
+	return &Error{Offset: offset, Message: fmt.Sprintf(format, args...)}
+}
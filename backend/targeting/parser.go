@@ -0,0 +1,189 @@
+package targeting
+
+// parser is a recursive descent parser over the tokens of a rule. It type-checks comparisons as it
+// builds them, so that a compiled rule never fails at evaluation time.
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | primary
+//	primary    = "(" or ")" | "true" | "false" | attribute [ comparison ]
+//	comparison = op literal | "in" "[" literal { "," literal } "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// describe names a token for error messages.
+func describe(tok token) string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of rule"
+	case tokenString:
+		return "string"
+	}
+	return "\"" + tok.text + "\""
+}
+
+// parseOr parses a disjunction.
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOp && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses a conjunction.
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOp && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+// parseUnary parses a negation or a primary expression.
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); tok.kind == tokenOp && tok.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a parenthesized expression, a literal or a test on an attribute.
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, errorAt(closing.offset, "expected \")\", found %s", describe(closing))
+		}
+		return inner, nil
+	case tok.kind == tokenIdent && (tok.text == "true" || tok.text == "false"):
+		return constNode{tok.text == "true"}, nil
+	case tok.kind == tokenIdent:
+		return p.parseTest(tok)
+	}
+	return nil, errorAt(tok.offset, "expected an attribute, \"(\" or \"!\", found %s", describe(tok))
+}
+
+// parseTest parses what follows an attribute name and type-checks it against the attribute's type.
+func (p *parser) parseTest(attr token) (node, error) {
+	typ, ok := Attributes[attr.text]
+	if !ok {
+		return nil, errorAt(attr.offset, "unknown attribute %q", attr.text)
+	}
+
+	op := p.peek()
+	isComparison := op.kind == tokenOp && op.text != "&&" && op.text != "||" && op.text != "!"
+	isIn := op.kind == tokenIdent && op.text == "in"
+	if !isComparison && !isIn {
+		if typ != TypeBool {
+			return nil, errorAt(attr.offset, "%s is a %s and needs a comparison", attr.text, typ)
+		}
+		return boolNode{attr: attr.text, value: true}, nil
+	}
+	p.next()
+
+	if isIn {
+		if typ != TypeString {
+			return nil, errorAt(op.offset, "in needs a string attribute, %s is a %s", attr.text, typ)
+		}
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return stringNode{attr: attr.text, values: values}, nil
+	}
+
+	value := p.next()
+	switch typ {
+	case TypeBool:
+		if op.text != "==" && op.text != "!=" {
+			return nil, errorAt(op.offset, "%s is a bool and only supports == and !=", attr.text)
+		}
+		if value.kind != tokenIdent || (value.text != "true" && value.text != "false") {
+			return nil, errorAt(value.offset, "%s is a bool and compares with true or false, found %s", attr.text, describe(value))
+		}
+		return boolNode{attr: attr.text, value: (value.text == "true") == (op.text == "==")}, nil
+	case TypeString:
+		if op.text != "==" && op.text != "!=" {
+			return nil, errorAt(op.offset, "%s is a string and only supports ==, != and in", attr.text)
+		}
+		if value.kind != tokenString {
+			return nil, errorAt(value.offset, "%s compares with a quoted string, found %s", attr.text, describe(value))
+		}
+		return stringNode{attr: attr.text, values: []string{value.text}, negated: op.text == "!="}, nil
+	default:
+		if value.kind != tokenString {
+			return nil, errorAt(value.offset, "%s compares with a quoted version such as \"3.2\", found %s", attr.text, describe(value))
+		}
+		version, ok := parseVersion(value.text)
+		if !ok {
+			return nil, errorAt(value.offset, "invalid version %q", value.text)
+		}
+		return versionNode{attr: attr.text, op: op.text, version: version}, nil
+	}
+}
+
+// parseList parses a bracketed, non-empty list of strings.
+func (p *parser) parseList() ([]string, error) {
+	if open := p.next(); open.kind != tokenLBracket {
+		return nil, errorAt(open.offset, "expected \"[\", found %s", describe(open))
+	}
+	var values []string
+	for {
+		value := p.next()
+		if value.kind != tokenString {
+			return nil, errorAt(value.offset, "expected a quoted string, found %s", describe(value))
+		}
+		values = append(values, value.text)
+
+		sep := p.next()
+		if sep.kind == tokenRBracket {
+			return values, nil
+		}
+		if sep.kind != tokenComma {
+			return nil, errorAt(sep.offset, "expected \",\" or \"]\", found %s", describe(sep))
+		}
+	}
+}
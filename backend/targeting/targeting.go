@@ -0,0 +1,254 @@
+// Package targeting parses, type-checks and evaluates the audience rules attached to pages and
+// widgets, such as platform == "ios" && app_version >= "3.2" && country in ["US","CA"].
+package targeting
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Type is the type of a client attribute.
+type Type string
+
+const (
+	// TypeString attributes compare case-insensitively with ==, != and in.
+	TypeString Type = "string"
+	// TypeVersion attributes hold dotted versions such as "3.2.1", compared numerically with any
+	// comparison operator.
+	TypeVersion Type = "version"
+	// TypeBool attributes are tested on their own, negated with !, or compared with true and false.
+	TypeBool Type = "bool"
+)
+
+// Attributes lists the client attributes that rules may refer to, with their types.
+var Attributes = map[string]Type{
+	"platform":    TypeString,
+	"app_version": TypeVersion,
+	"os_version":  TypeVersion,
+	"country":     TypeString,
+	"language":    TypeString,
+	"new_user":    TypeBool,
+	"user_id":     TypeString,
+	"theme":       TypeString,
+	"form_factor": TypeString,
+}
+
+// MaxRuleLength caps the length of a rule's source.
+const MaxRuleLength = 1000
+
+// Context holds the attributes of the client a rule is evaluated for, keyed by attribute name.
+// Attributes the client did not send are absent, and every comparison on them is false.
+type Context map[string]string
+
+// Rule is a compiled audience rule.
+type Rule struct {
+	source string
+	root   node
+}
+
+// cache keeps compiled rules by source, since the same stored rules are evaluated on every request.
+// It stops growing at maxCached rules.
+var (
+	cache     sync.Map
+	cached    atomic.Int64
+	maxCached int64 = 4096
+)
+
+// Compile parses and type-checks a rule. Failures are returned as *Error.
+func Compile(source string) (*Rule, error) {
+	if hit, ok := cache.Load(source); ok {
+		return hit.(*Rule), nil
+	}
+	if len(source) > MaxRuleLength {
+		return nil, errorAt(MaxRuleLength, "rules are limited to %d characters", MaxRuleLength)
+	}
+
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorAt(tok.offset, "unexpected %q", tok.text)
+	}
+
+	rule := &Rule{source: source, root: root}
+	if cached.Load() < maxCached {
+		if _, loaded := cache.LoadOrStore(source, rule); !loaded {
+			cached.Add(1)
+		}
+	}
+	return rule, nil
+}
+
+// Matches reports whether the client described by ctx is in the rule's audience.
+func (r *Rule) Matches(ctx Context) bool {
+	return r.root.eval(ctx)
+}
+
+// String returns the source of the rule.
+func (r *Rule) String() string {
+	return r.source
+}
+
+// Matches reports whether the client described by ctx is in the audience of source. An empty rule
+// matches every client; a rule that does not compile matches none.
+func Matches(source string, ctx Context) bool {
+	if strings.TrimSpace(source) == "" {
+		return true
+	}
+	rule, err := Compile(source)
+	if err != nil {
+		return false
+	}
+	return rule.Matches(ctx)
+}
+
+// node is one operation of a compiled rule.
+type node interface {
+	eval(ctx Context) bool
+}
+
+// andNode holds when both sides hold.
+type andNode struct{ left, right node }
+
+// eval evaluates both sides, short-circuiting on false.
+func (n andNode) eval(ctx Context) bool { return n.left.eval(ctx) && n.right.eval(ctx) }
+
+// orNode holds when either side holds.
+type orNode struct{ left, right node }
+
+// eval evaluates both sides, short-circuiting on true.
+func (n orNode) eval(ctx Context) bool { return n.left.eval(ctx) || n.right.eval(ctx) }
+
+// notNode negates its operand.
+type notNode struct{ operand node }
+
+// eval negates the operand.
+func (n notNode) eval(ctx Context) bool { return !n.operand.eval(ctx) }
+
+// constNode is a literal true or false.
+type constNode struct{ value bool }
+
+// eval returns the literal.
+func (n constNode) eval(Context) bool { return n.value }
+
+// boolNode compares a bool attribute with a value.
+type boolNode struct {
+	attr  string
+	value bool
+}
+
+// eval reads the attribute as a bool, failing when it is absent or not a bool.
+func (n boolNode) eval(ctx Context) bool {
+	raw, ok := ctx[n.attr]
+	if !ok {
+		return false
+	}
+	value, err := strconv.ParseBool(raw)
+	return err == nil && value == n.value
+}
+
+// stringNode compares a string attribute with one or more values, ignoring case. Negated
+// comparisons (!=) are false as well when the attribute is absent.
+type stringNode struct {
+	attr    string
+	values  []string
+	negated bool
+}
+
+// eval compares the attribute with each value, failing when it is absent.
+func (n stringNode) eval(ctx Context) bool {
+	raw, ok := ctx[n.attr]
+	if !ok {
+		return false
+	}
+	for _, value := range n.values {
+		if strings.EqualFold(raw, value) {
+			return !n.negated
+		}
+	}
+	return n.negated
+}
+
+// versionNode compares a version attribute with a version.
+type versionNode struct {
+	attr    string
+	op      string
+	version []int
+}
+
+// eval compares the attribute as a version, failing when it is absent or not a version.
+func (n versionNode) eval(ctx Context) bool {
+	raw, ok := ctx[n.attr]
+	if !ok {
+		return false
+	}
+	version, ok := parseVersion(raw)
+	if !ok {
+		return false
+	}
+	cmp := compareVersions(version, n.version)
+	switch n.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// parseVersion reads a dotted version such as "3.2" or "v3.2.1". A pre-release or build suffix
+// after "-" or "+" is ignored.
+func parseVersion(s string) ([]int, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return nil, false
+	}
+	parts := strings.Split(s, ".")
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+// compareVersions orders two versions component by component, treating missing components as 0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+// Package targeting contains tests for parsing, type-checking and evaluating audience rules.
+package targeting
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestMatches verifies rule evaluation against client contexts, including absent attributes.
+func TestMatches(t *testing.T) {
+	ios := Context{"platform": "iOS", "app_version": "3.10.0", "country": "ca", "new_user": "true"}
+	android := Context{"platform": "android", "app_version": "3.1", "country": "US", "new_user": "false"}
+
+	tests := []struct {
+		rule string
+		ctx  Context
+		want bool
+	}{
+		{`platform == "ios" && app_version >= "3.2" && country in ["US","CA"]`, ios, true},
+		{`platform == "ios" && app_version >= "3.2" && country in ["US","CA"]`, android, false},
+		{`app_version < "3.2"`, android, true},
+		{`app_version == "3.10"`, ios, true},
+		{`platform != "ios"`, android, true},
+		{`platform != "ios"`, Context{}, false},
+		{`!(platform == "ios")`, Context{}, true},
+		{`new_user`, ios, true},
+		{`!new_user`, android, true},
+		{`new_user == false`, android, true},
+		{`new_user != true`, ios, false},
+		{`platform == "web" || country in ["US"]`, android, true},
+		{`language == "fr"`, ios, false},
+		{`app_version > "1"`, Context{"app_version": "beta"}, false},
+		{`true && !false`, Context{}, true},
+		{"", Context{}, true},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.rule, tt.ctx); got != tt.want {
+			t.Errorf("Matches(%q, %v) = %v, want %v", tt.rule, tt.ctx, got, tt.want)
+		}
+	}
+}
+
+// TestCompileErrors verifies that syntax and type errors are reported with their offset.
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		rule   string
+		offset int
+	}{
+		{`plaform == "ios"`, 0},
+		{`platform >= "ios"`, 9},
+		{`platform == ios`, 12},
+		{`app_version >= "three"`, 15},
+		{`app_version in ["3.2"]`, 12},
+		{`new_user == "yes"`, 12},
+		{`country`, 0},
+		{`country in "US"`, 11},
+		{`country in ["US" "CA"]`, 17},
+		{`(platform == "ios"`, 18},
+		{`platform == "ios" country == "US"`, 18},
+		{`platform == "ios`, 12},
+		{`platform = "ios"`, 9},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.rule)
+		var ruleErr *Error
+		if !errors.As(err, &ruleErr) {
+			t.Errorf("Compile(%q): expected an *Error, got %v", tt.rule, err)
+			continue
+		}
+		if ruleErr.Offset != tt.offset {
+			t.Errorf("Compile(%q): expected offset %d, got %d (%v)", tt.rule, tt.offset, ruleErr.Offset, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Package version exposes build metadata injected at link time.
+//
+// Release builds set these values with linker flags, for example:
+//
+//	go build -ldflags "-X appdrop/version.Version=1.2.0 -X appdrop/version.Commit=$(git rev-parse --short HEAD) -X appdrop/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+var (
+	// Version is the semantic version of the build.
+	Version = "dev"
+	// Commit is the source control revision the binary was built from.
+	Commit = "unknown"
+	// BuildTime is the UTC timestamp at which the binary was built.
+	BuildTime = "unknown"
+)
+
+// Info describes the running build for health and diagnostic responses.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+// Get returns the build metadata of the running binary.
+func Get() Info {
+	return Info{Version: Version, Commit: Commit, BuildTime: BuildTime}
+}
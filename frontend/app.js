@@ -324,7 +324,7 @@ async function checkApiStatus() {
  */
 async function loadDashboardData() {
     try {
-        const data = await apiCall(`/pages?page=1&per_page=100`);
+        const data = await apiCall(`/manage/pages?page=1&per_page=100&all=true`);
         state.pages = data.pages || [];
 
         elements.totalPages.textContent = data.total || 0;
@@ -335,7 +335,7 @@ async function loadDashboardData() {
 
         let widgetCount = 0;
         for (const page of state.pages) {
-            const pageData = await apiCall(`/pages/${page.id}`);
+            const pageData = await apiCall(`/manage/pages/${page.id}?all=true`);
             widgetCount += (pageData.widgets || []).length;
         }
         elements.totalWidgets.textContent = widgetCount;
@@ -390,7 +390,7 @@ function renderRecentPages(pages) {
 async function loadPages() {
     try {
         const filter = elements.pageFilter.value;
-        const data = await apiCall(`/pages?page=${state.currentPage}&per_page=${state.perPage}`);
+        const data = await apiCall(`/manage/pages?page=${state.currentPage}&per_page=${state.perPage}&all=true`);
 
         let pages = data.pages || [];
 
@@ -550,7 +550,7 @@ function goToPage(page) {
  */
 async function loadPageSelector() {
     try {
-        const data = await apiCall('/pages?page=1&per_page=100');
+        const data = await apiCall('/manage/pages?page=1&per_page=100&all=true');
         const pages = data.pages || [];
 
         elements.pageSelector.innerHTML = '<option value="">Select a Page</option>' +
@@ -572,9 +572,9 @@ async function loadPageSelector() {
  */
 async function loadWidgets(pageId, typeFilter = '') {
     try {
-        let endpoint = `/pages/${pageId}/widgets`;
+        let endpoint = `/manage/pages/${pageId}/widgets?all=true`;
         if (typeFilter) {
-            endpoint += `?type=${typeFilter}`;
+            endpoint += `&type=${typeFilter}`;
         }
 
         const data = await apiCall(endpoint);
@@ -891,7 +891,7 @@ function closePageModal() {
  */
 async function editPage(pageId) {
     try {
-        const page = await apiCall(`/pages/${pageId}`);
+        const page = await apiCall(`/manage/pages/${pageId}?all=true`);
         openPageModal(page);
     } catch (error) {
         console.error('Failed to load page:', error);